@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+
+	"github.com/KompiTech/fabric-cc-core/v2/pkg/schemacompat"
+)
+
+func main() {
+	oldRegistryDir := flag.String("oldRegistryDir", "", "directory containing currently deployed registry definitions")
+	registryDir := flag.String("registryDir", "", "directory containing new registry definitions for cc-core based chaincode")
+
+	flag.Parse()
+
+	if *oldRegistryDir == "" {
+		log.Fatal("oldRegistryDir is mandatory argument")
+	}
+
+	if *registryDir == "" {
+		log.Fatal("registryDir is mandatory argument")
+	}
+
+	reports, err := schemacompat.AnalyzeDirs(*oldRegistryDir, *registryDir)
+	if err != nil {
+		log.Fatal(err.Error())
+	}
+
+	output := map[string]interface{}{}
+	isIncompatible := false
+
+	for name, report := range reports {
+		output[name] = report.Rmap().Mapa
+
+		if !report.IsBackward() {
+			log.Printf("%s has changes, that are not backward compatible", name)
+			isIncompatible = true
+		}
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+
+	if err := encoder.Encode(output); err != nil {
+		log.Fatal(err.Error())
+	}
+
+	if isIncompatible {
+		os.Exit(1)
+	}
+}
@@ -74,6 +74,17 @@ func assetGet(r *http.Request, urlPart string) ([]string, error) {
 	return ret, nil
 }
 
+func assetReferrers(r *http.Request, urlPart string) ([]string, error) {
+	elems := strings.Split(urlPart, "/")
+	if len(elems) != 3 {
+		return nil, fmt.Errorf("invalid request")
+	}
+	assetName := elems[1]
+	uuid := elems[2]
+
+	return []string{"assetReferrers", assetName, uuid, r.Form.Get("bookmark")}, nil
+}
+
 func assetUpdate(r *http.Request, urlPart string) ([]string, error) {
 	uuid := ""
 	assetName := ""
@@ -88,7 +99,7 @@ func assetUpdate(r *http.Request, urlPart string) ([]string, error) {
 		return nil, err
 	}
 
-	ret := []string{"assetUpdate", assetName, uuid, string(bodyBytes)}
+	ret := []string{"assetUpdate", assetName, uuid, string(bodyBytes), ifMatchRevision(r)}
 
 	if _, pForceExists := r.Form["force"]; pForceExists {
 		ret[0] = ret[0] + "Direct"
@@ -122,7 +133,66 @@ func assetMigrate(r *http.Request, urlPart string) ([]string, error) {
 		}
 		version = parsed
 	}
-	return []string{"assetMigrate", assetName, uuid, string(bodyBytes), fmt.Sprintf("%d", version)}, nil
+	return []string{"assetMigrate", assetName, uuid, string(bodyBytes), fmt.Sprintf("%d", version), ifMatchRevision(r)}, nil
+}
+
+func assetMigrateAll(r *http.Request, urlPart string) ([]string, error) {
+	elems := strings.Split(urlPart, "/")
+	if len(elems) != 2 {
+		return nil, fmt.Errorf("invalid request")
+	}
+	assetName := elems[1]
+	version := "-1"
+	if pVersion, pVersionExists := r.Form["version"]; pVersionExists {
+		if _, err := strconv.Atoi(pVersion[0]); err != nil {
+			return nil, err
+		}
+		version = pVersion[0]
+	}
+	ret := []string{"assetMigrateAll", assetName, version, r.Form.Get("bookmark")}
+	if pLimit, pLimitExists := r.Form["limit"]; pLimitExists {
+		if _, err := strconv.Atoi(pLimit[0]); err != nil {
+			return nil, err
+		}
+		ret = append(ret, pLimit[0])
+	}
+	return ret, nil
+}
+
+func assetDelete(r *http.Request, urlPart string) ([]string, error) {
+	elems := strings.Split(urlPart, "/")
+	if len(elems) != 2 {
+		return nil, fmt.Errorf("invalid request")
+	}
+	assetName := elems[0]
+	uuid := elems[1]
+
+	ret := []string{"assetDelete", assetName, uuid, ifMatchRevision(r)}
+
+	if _, pForceExists := r.Form["force"]; pForceExists {
+		ret[0] = ret[0] + "Direct"
+	}
+
+	return ret, nil
+}
+
+func assetRestore(r *http.Request, urlPart string) ([]string, error) {
+	elems := strings.Split(urlPart, "/")
+	if len(elems) != 3 {
+		return nil, fmt.Errorf("invalid request")
+	}
+	assetName := elems[1]
+	uuid := elems[2]
+
+	return []string{"assetRestore", assetName, uuid}, nil
+}
+
+func assetBatch(r *http.Request) ([]string, error) {
+	bodyBytes, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	return []string{"assetBatch", string(bodyBytes)}, nil
 }
 
 func assetQuery(r *http.Request, urlPart string) ([]string, error) {
@@ -164,9 +234,18 @@ func AssetHandler(w http.ResponseWriter, r *http.Request) {
 	urlPart := r.URL.Path[len("/api/v1/assets/"):] //get URL path without /asset/ -> name of asset being used
 	switch method := r.Method; method {
 	case "POST":
-		//POST /assets/<name> or /assets/<name>/<uuid> or /asset/migrate/<name>/<uuid>
+		//POST /assets/<name> or /assets/<name>/<uuid> or /asset/migrate/<name>/<uuid> or /assets/migrate/<name> or /assets/batch or /assets/restore/<name>/<uuid>
 		elems := strings.Split(urlPart, "/")
-		if elems[0] != "migrate" {
+		if elems[0] == "batch" && len(elems) == 1 {
+			args, err = assetBatch(r)
+			invoke = true
+		} else if elems[0] == "migrate" && len(elems) == 2 {
+			args, err = assetMigrateAll(r, urlPart)
+			invoke = true
+		} else if elems[0] == "restore" {
+			args, err = assetRestore(r, urlPart)
+			invoke = true
+		} else if elems[0] != "migrate" {
 			args, err = assetCreate(r, urlPart)
 			invoke = true
 		} else {
@@ -174,13 +253,21 @@ func AssetHandler(w http.ResponseWriter, r *http.Request) {
 			invoke = true
 		}
 	case "GET":
-		//GET /assets/<name>/<uuid>
-		args, err = assetGet(r, urlPart)
+		//GET /assets/<name>/<uuid> or /assets/referrers/<name>/<uuid>
+		if strings.HasPrefix(urlPart, "referrers/") {
+			args, err = assetReferrers(r, urlPart)
+		} else {
+			args, err = assetGet(r, urlPart)
+		}
 		invoke = false
 	case "PATCH":
 		//PATCH /assets/<name>/<uuid>
 		args, err = assetUpdate(r, urlPart)
 		invoke = true
+	case "DELETE":
+		//DELETE /assets/<name>/<uuid>
+		args, err = assetDelete(r, urlPart)
+		invoke = true
 	case "OPTIONS":
 		//OPTIONS /assets/<name>/<uuid> or /asset/<name>
 		elems := strings.Split(urlPart, "/")
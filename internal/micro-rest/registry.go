@@ -40,7 +40,11 @@ func registryUpsert(r *http.Request, urlPart string) ([]string, error) {
 		return nil, fmt.Errorf("invalid request")
 	}
 	assetName := elems[0]
-	return []string{"registryUpsert", assetName, string(bodyBytes)}, nil
+	ret := []string{"registryUpsert", assetName, string(bodyBytes)}
+	if _, pForceExists := r.Form["force"]; pForceExists {
+		ret = append(ret, "true")
+	}
+	return ret, nil
 }
 
 func registryList(r *http.Request, urlPart string) ([]string, error) {
@@ -70,7 +74,7 @@ func RegistryHandler(w http.ResponseWriter, r *http.Request) {
 		}
 		invoke = false
 	case "POST":
-		//POST /registry/<name>
+		//POST /registry/<name> or /registry/<name>?force
 		args, err = registryUpsert(r, urlPart)
 		invoke = true
 	}
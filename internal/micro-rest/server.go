@@ -8,6 +8,7 @@ import (
 	"os"
 	"os/exec"
 	"path"
+	"strconv"
 	"strings"
 
 	"github.com/KompiTech/rmap"
@@ -104,6 +105,71 @@ func callBackend(args []string, invoke bool) (string, int) {
 	return out, cmd.ProcessState.ExitCode()
 }
 
+// ifMatchRevision returns asset revision from If-Match header or empty string, if header was not sent or matches any revision
+func ifMatchRevision(r *http.Request) string {
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "*" {
+		return ""
+	}
+
+	return strings.Trim(strings.TrimPrefix(ifMatch, "W/"), `"`)
+}
+
+// setETag sets ETag header with revision of asset returned by backend, if there is any
+func setETag(w http.ResponseWriter, beOutput string) {
+	output, err := rmap.NewFromString(beOutput)
+	if err != nil {
+		return
+	}
+
+	result, err := output.GetRmap("result")
+	if err != nil {
+		return
+	}
+
+	revision, err := result.GetInt("xxx_revision")
+	if err != nil {
+		return
+	}
+
+	w.Header().Set("ETag", fmt.Sprintf(`"%d"`, revision))
+}
+
+// errorStatusCode returns HTTP status code from error message in format message|||NNN, or 500 if there is none
+func errorStatusCode(beOutput string) int {
+	idx := strings.LastIndex(beOutput, "|||")
+	if idx == -1 || len(beOutput) < idx+6 {
+		return 500
+	}
+
+	code, err := strconv.Atoi(beOutput[idx+3 : idx+6])
+	if err != nil {
+		return 500
+	}
+
+	return code
+}
+
+// jsonError returns JSON error document from peer output, if chaincode does not use legacy error format
+func jsonError(beOutput string) (rmap.Rmap, bool) {
+	start := strings.Index(beOutput, "{")
+	end := strings.LastIndex(beOutput, "}") + 1
+	if start == -1 || end <= start {
+		return rmap.Rmap{}, false
+	}
+
+	jsonErr, err := rmap.NewFromString(strings.Replace(beOutput[start:end], `\"`, `"`, -1))
+	if err != nil {
+		return rmap.Rmap{}, false
+	}
+
+	if _, err := jsonErr.GetInt("status"); err != nil {
+		return rmap.Rmap{}, false
+	}
+
+	return jsonErr, true
+}
+
 func jsonizeErrorString(msg string) string {
 	return rmap.NewFromMap(map[string]interface{}{"error": msg}).String()
 }
@@ -152,11 +218,18 @@ func handleBackend(args []string, invoke bool, r *http.Request, w http.ResponseW
 
 	beOutput, exitCode := callBackend(args, invoke)
 	if exitCode == 0 {
+		setETag(w, beOutput)
 		w.WriteHeader(200)
 	} else {
-		w.WriteHeader(500)
-		// wrap error into JSON
-		beOutput = jsonizeErrorString(beOutput)
+		if jsonErr, isJSON := jsonError(beOutput); isJSON {
+			// chaincode returned JSON error, forward it
+			w.WriteHeader(jsonErr.MustGetInt("status"))
+			beOutput = jsonErr.String()
+		} else {
+			w.WriteHeader(errorStatusCode(beOutput))
+			// wrap error into JSON
+			beOutput = jsonizeErrorString(beOutput)
+		}
 	}
 	log.Print(beOutput)
 
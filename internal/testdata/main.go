@@ -3,6 +3,8 @@ package testdata
 import (
 	mockblogicfail2 "github.com/KompiTech/fabric-cc-core/v2/internal/testdata/mock_blogic/mockblogicfail"
 	mockdataafterresolve2 "github.com/KompiTech/fabric-cc-core/v2/internal/testdata/mock_blogic/mockdataafterresolve"
+	mockevent2 "github.com/KompiTech/fabric-cc-core/v2/internal/testdata/mock_blogic/mockevent"
+	mockmigration2 "github.com/KompiTech/fabric-cc-core/v2/internal/testdata/mock_blogic/mockmigration"
 	mockpaginate2 "github.com/KompiTech/fabric-cc-core/v2/internal/testdata/mock_blogic/mockpaginate"
 	mockrequest2 "github.com/KompiTech/fabric-cc-core/v2/internal/testdata/mock_blogic/mockrequest"
 	mocktimelog2 "github.com/KompiTech/fabric-cc-core/v2/internal/testdata/mock_blogic/mocktimelog"
@@ -119,6 +121,35 @@ func getBusinessLogicPolicy() BusinessExecutor {
 		},
 	})
 
+	bexec.SetPolicy(FuncKey{Name: "mockevent", Version: 1}, map[Stage][]BusinessPolicyMember{
+		AfterCreate: {
+			mockevent2.EmitCreated,
+			mockevent2.EmitText,
+		},
+		BeforeDelete: {
+			mockevent2.EmitText,
+			mockblogicfail2.Fail,
+		},
+	})
+
+	bexec.SetPolicy(FuncKey{Name: "mocksoftdelete", Version: 1}, map[Stage][]BusinessPolicyMember{
+		AfterRestore: {
+			mockevent2.EmitText,
+		},
+	})
+
+	bexec.SetPolicy(FuncKey{Name: "mockincident", Version: 3}, map[Stage][]BusinessPolicyMember{
+		AfterMigrate: {
+			mockmigration2.EmitMigrated,
+		},
+	})
+
+	bexec.SetPolicy(FuncKey{Name: "mockincident", Version: 4}, map[Stage][]BusinessPolicyMember{
+		AfterMigrate: {
+			mockblogicfail2.Fail,
+		},
+	})
+
 	return *bexec
 }
 
@@ -148,6 +179,13 @@ func getFunctionLogicPolicy() FunctionExecutor {
 	return *fexec
 }
 
+func getMigrations() map[string]MigrationFunc {
+	return map[string]MigrationFunc{
+		"mockincident_uppercase_priority": mockmigration2.UppercasePriority,
+		"mockincident_break_identity":     mockmigration2.BreakIdentity,
+	}
+}
+
 func getRecursiveResolveWhitelist() rmap.Rmap {
 	rrw, _ := rmap.NewFromSlice([]interface{}{"mocklevel1.level2", "mocklevel2.level3"})
 	return rrw
@@ -167,6 +205,16 @@ func getResolveFieldsBlacklist() rmap.Rmap {
 	})
 }
 
+func getEventWhitelist() rmap.Rmap {
+	ew, _ := rmap.NewFromSlice([]interface{}{"mockstate", "mockpd"})
+	return ew
+}
+
+func getEventDiffWhitelist() rmap.Rmap {
+	edw, _ := rmap.NewFromSlice([]interface{}{"mockstate", "mockpd"})
+	return edw
+}
+
 func GetConfiguration() Configuration {
 	return Configuration{
 		BusinessExecutor:              getBusinessLogicPolicy(),
@@ -174,6 +222,9 @@ func GetConfiguration() Configuration {
 		RecursiveResolveWhitelist:     getRecursiveResolveWhitelist(),
 		ResolveBlacklist:              getResolveBlacklist(),
 		ResolveFieldsBlacklist:        getResolveFieldsBlacklist(),
+		EventWhitelist:                getEventWhitelist(),
+		EventDiffWhitelist:            getEventDiffWhitelist(),
 		SchemaDefinitionCompatibility: "definitions",
+		Migrations:                    getMigrations(),
 	}
 }
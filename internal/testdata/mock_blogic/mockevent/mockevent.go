@@ -0,0 +1,33 @@
+package mockevent
+
+import (
+	"github.com/KompiTech/fabric-cc-core/v2/pkg/engine"
+	"github.com/KompiTech/fabric-cc-core/v2/pkg/konst"
+	"github.com/KompiTech/rmap"
+)
+
+// EmitCreated emits event with ID of created mockevent
+var EmitCreated = func(ctx engine.ContextInterface, prePatch *rmap.Rmap, postPatch rmap.Rmap) (rmap.Rmap, error) {
+	payload := rmap.NewFromMap(map[string]interface{}{
+		konst.AssetIdKey: postPatch.Mapa[konst.AssetIdKey],
+	})
+
+	if err := ctx.EmitEvent("mockevent_created", payload); err != nil {
+		return rmap.Rmap{}, err
+	}
+
+	return postPatch, nil
+}
+
+// EmitText emits event with text of created mockevent
+var EmitText = func(ctx engine.ContextInterface, prePatch *rmap.Rmap, postPatch rmap.Rmap) (rmap.Rmap, error) {
+	payload := rmap.NewFromMap(map[string]interface{}{
+		"text": postPatch.Mapa["text"],
+	})
+
+	if err := ctx.EmitEvent("mockevent_text", payload); err != nil {
+		return rmap.Rmap{}, err
+	}
+
+	return postPatch, nil
+}
@@ -0,0 +1,41 @@
+package mockmigration
+
+import (
+	"strings"
+
+	"github.com/KompiTech/fabric-cc-core/v2/pkg/engine"
+	"github.com/KompiTech/fabric-cc-core/v2/pkg/konst"
+	"github.com/KompiTech/rmap"
+)
+
+// UppercasePriority is migration function, that converts priority of mockincident to upper case
+var UppercasePriority = func(ctx engine.ContextInterface, asset rmap.Rmap) (rmap.Rmap, error) {
+	priority, err := asset.GetString("priority")
+	if err != nil {
+		return rmap.Rmap{}, err
+	}
+
+	asset.Mapa["priority"] = strings.ToUpper(priority)
+
+	return asset, nil
+}
+
+// BreakIdentity is migration function, that attempts to change UUID of asset
+var BreakIdentity = func(ctx engine.ContextInterface, asset rmap.Rmap) (rmap.Rmap, error) {
+	asset.Mapa[konst.AssetIdKey] = "iWillBreakIt"
+	return asset, nil
+}
+
+// EmitMigrated emits event with original and target version of migrated asset
+var EmitMigrated = func(ctx engine.ContextInterface, prePatch *rmap.Rmap, postPatch rmap.Rmap) (rmap.Rmap, error) {
+	payload := rmap.NewFromMap(map[string]interface{}{
+		"from": prePatch.Mapa[konst.AssetVersionKey],
+		"to":   postPatch.Mapa[konst.AssetVersionKey],
+	})
+
+	if err := ctx.EmitEvent("mockincident_migrated", payload); err != nil {
+		return rmap.Rmap{}, err
+	}
+
+	return postPatch, nil
+}
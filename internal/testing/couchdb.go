@@ -8,6 +8,7 @@ import (
 	"log"
 	"math/rand"
 	"net/http"
+	"net/url"
 	"path/filepath"
 	"strings"
 	"sync"
@@ -377,7 +378,8 @@ func randStringBytes(n int) string {
 }
 
 func (cdb *CouchDBMock) getPathString(dbName, key string) string {
-	return "/" + dbName + "/" + key
+	// key can contain slash (reference index keys), it must be escaped to be used as document ID
+	return "/" + dbName + "/" + url.PathEscape(strings.Replace(key, "\x00", "", -1))
 }
 
 func (cdb *CouchDBMock) get(dbName, key string) []byte {
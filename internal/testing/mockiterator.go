@@ -39,3 +39,23 @@ func (mt *mockIterator) HasNext() bool {
 	}
 	return true
 }
+
+//mock for range query result with pagination
+type mockKVIterator struct {
+	Results    []*queryresult.KV //array of results
+	currentPos int               //current position in iterator
+}
+
+func (mt *mockKVIterator) Next() (*queryresult.KV, error) {
+	elem := mt.Results[mt.currentPos]
+	mt.currentPos++
+	return elem, nil
+}
+
+func (mt *mockKVIterator) Close() error {
+	return nil
+}
+
+func (mt *mockKVIterator) HasNext() bool {
+	return mt.currentPos < len(mt.Results)
+}
@@ -459,9 +459,40 @@ func (stub *MockStub) GetStateByRangeWithPagination(startKey, endKey string, pag
 }
 
 // GetStateByPartialCompositeKeyWithPagination ...
+// bookmark is the first key of next page, it is empty on the last page
 func (stub *MockStub) GetStateByPartialCompositeKeyWithPagination(objectType string, keys []string,
 	pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *peer.QueryResponseMetadata, error) {
-	return nil, nil, nil
+	partialCompositeKey, err := stub.CreateCompositeKey(objectType, keys)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	stub.isRO = true
+
+	startKey := partialCompositeKey
+	if bookmark != "" {
+		startKey = bookmark
+	}
+
+	iterator := NewMockStateRangeQueryIterator(stub, startKey, partialCompositeKey+string(utf8.MaxRune))
+	results := []*queryresult.KV{}
+	nextBookmark := ""
+
+	for iterator.HasNext() {
+		kv, err := iterator.Next()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if int32(len(results)) == pageSize {
+			nextBookmark = kv.Key
+			break
+		}
+
+		results = append(results, kv)
+	}
+
+	return &mockKVIterator{Results: results}, &peer.QueryResponseMetadata{FetchedRecordsCount: int32(len(results)), Bookmark: nextBookmark}, nil
 }
 
 // GetQueryResultWithPagination ...
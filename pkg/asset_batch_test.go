@@ -0,0 +1,120 @@
+package cc_core
+
+import (
+	"fmt"
+
+	"github.com/KompiTech/fabric-cc-core/v2/pkg/konst"
+	. "github.com/KompiTech/fabric-cc-core/v2/pkg/testing"
+	"github.com/KompiTech/rmap"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("assetBatch method tests", func() {
+	var tctx *TestContext
+
+	BeforeEach(func() {
+		tctx = getDefaultTextContext()
+		tctx.InitOk(tctx.GetInit("../internal/testdata/assets", "").Bytes())
+		tctx.RegisterAllActors()
+	})
+
+	It("Should create incident with timelog and link them using placeholders", func() {
+		output := tctx.JSONNoResult("assetBatch", `[
+			{"op": "create", "name": "mockincident", "data": {"description": "batch incident"}},
+			{"op": "create", "name": "mocktimelog", "data": {"incident": "${0}"}},
+			{"op": "update", "name": "mockincident", "id": "${0}", "patch": {"timelogs": ["${1}"]}}
+		]`)
+
+		results := output[konst.OutputResultKey].([]interface{})
+		Expect(results).To(HaveLen(3))
+
+		incidentID := results[0].(map[string]interface{})[konst.AssetIdKey].(string)
+		timelogID := results[1].(map[string]interface{})[konst.AssetIdKey].(string)
+		Expect(incidentID).NotTo(Equal(timelogID))
+		Expect(results[1]).To(HaveKeyWithValue("incident", incidentID))
+		Expect(results[2]).To(HaveKeyWithValue("timelogs", []interface{}{timelogID}))
+
+		incident := tctx.Rmap("assetGet", "mockincident", incidentID, false, "")
+		Expect(incident.Mapa).To(HaveKeyWithValue("description", "batch incident"))
+		Expect(incident.Mapa).To(HaveKeyWithValue("timelogs", []interface{}{timelogID}))
+
+		timelog := tctx.Rmap("assetGet", "mocktimelog", timelogID, false, "")
+		Expect(timelog.Mapa).To(HaveKeyWithValue("incident", incidentID))
+	})
+
+	It("Should generate distinct ID for every create in batch", func() {
+		output := tctx.JSONNoResult("assetBatch", `[
+			{"op": "create", "name": "mockincident", "data": {"description": "first"}},
+			{"op": "create", "name": "mockincident", "data": {"description": "second"}},
+			{"op": "create", "name": "mocktimelog", "data": {"incident": "${0}"}},
+			{"op": "create", "name": "mockincident", "data": {"description": "third"}}
+		]`)
+
+		ids := map[string]bool{}
+		for _, result := range output[konst.OutputResultKey].([]interface{}) {
+			ids[result.(map[string]interface{})[konst.AssetIdKey].(string)] = true
+		}
+		Expect(ids).To(HaveLen(4))
+	})
+
+	It("Should migrate and delete assets", func() {
+		commentID := MustGetID(tctx.Rmap("assetCreate", "mockcomment", `{"text":"hello"}`, -1, ""))
+		incidentID := MustGetID(tctx.Rmap("assetCreate", "mockincident", `{"description":"hello"}`, 1, ""))
+
+		regItemV2 := rmap.MustNewFromYAMLFile("../internal/testdata/assets/mockincident.yaml")
+		regItemV2.MustSetJPtr("/schema/properties/closed", map[string]interface{}{"type": "boolean"})
+		tctx.Ok("registryUpsert", "mockincident", regItemV2.Bytes())
+
+		output := tctx.JSONNoResult("assetBatch", fmt.Sprintf(`[
+			{"op": "migrate", "name": "mockincident", "id": "%s", "version": 2, "patch": {"description": "migrated"}},
+			{"op": "delete", "name": "mockcomment", "id": "%s"}
+		]`, incidentID, commentID))
+
+		results := output[konst.OutputResultKey].([]interface{})
+		Expect(results).To(HaveLen(2))
+		Expect(results[0]).To(HaveKeyWithValue(konst.AssetVersionKey, float64(2)))
+		Expect(results[1]).To(BeTrue())
+
+		incident := tctx.Rmap("assetGet", "mockincident", incidentID, false, "")
+		Expect(incident.Mapa).To(HaveKeyWithValue("description", "migrated"))
+		tctx.Error("not found", "assetGet", "mockcomment", commentID, false, "")
+	})
+
+	It("Should update and migrate assets using JSON patch", func() {
+		firstID := MustGetID(tctx.Rmap("assetCreate", "mockincident", `{"description":"first"}`, 1, ""))
+		secondID := MustGetID(tctx.Rmap("assetCreate", "mockincident", `{"description":"second"}`, 1, ""))
+
+		regItemV2 := rmap.MustNewFromYAMLFile("../internal/testdata/assets/mockincident.yaml")
+		regItemV2.MustSetJPtr("/schema/properties/closed", map[string]interface{}{"type": "boolean"})
+		tctx.Ok("registryUpsert", "mockincident", regItemV2.Bytes())
+
+		tctx.Ok("assetBatch", fmt.Sprintf(`[
+			{"op": "update", "name": "mockincident", "id": "%s", "patch": [{"op": "test", "path": "/description", "value": "first"}, {"op": "replace", "path": "/description", "value": "patched"}]},
+			{"op": "migrate", "name": "mockincident", "id": "%s", "version": 2, "patch": [{"op": "add", "path": "/closed", "value": true}]}
+		]`, firstID, secondID))
+
+		Expect(tctx.JSON("assetGet", "mockincident", firstID, false, "")).To(HaveKeyWithValue("description", "patched"))
+		second := tctx.JSON("assetGet", "mockincident", secondID, false, "")
+		Expect(second).To(HaveKeyWithValue("closed", true))
+		Expect(second).To(HaveKeyWithValue(konst.AssetVersionKey, float64(2)))
+
+		tctx.Error("operation has invalid key: patch, JSON object or array expected", "assetBatch", fmt.Sprintf(`[{"op": "update", "name": "mockincident", "id": "%s", "patch": "text"}]`, firstID))
+	})
+
+	It("Should fail whole batch when any operation fails", func() {
+		tctx.Error("operation 1 failed: assetUpdateBackend() failed", "assetBatch", `[
+			{"op": "create", "name": "mockincident", "data": {"description": "batch incident"}},
+			{"op": "update", "name": "mockincident", "id": "${0}", "patch": {"assigned_to": "nonexistent"}},
+			{"op": "create", "name": "mockcomment", "data": {"text": "never created"}}
+		]`)
+	})
+
+	It("Should return error on invalid operations", func() {
+		tctx.Error("operations must be JSON array", "assetBatch", `{"op": "create"}`)
+		tctx.Error("operations are empty", "assetBatch", `[]`)
+		tctx.Error("unknown operation: upsert", "assetBatch", `[{"op": "upsert", "name": "mockincident", "id": "abc"}]`)
+		tctx.Error("operation: update requires key: id", "assetBatch", `[{"op": "update", "name": "mockincident", "patch": {}}]`)
+		tctx.Error("placeholder: ${0} refers to operation that was not executed yet", "assetBatch", `[{"op": "create", "name": "mocktimelog", "data": {"incident": "${0}"}}]`)
+	})
+})
@@ -290,6 +290,8 @@ var _ = Describe("asset* method family tests", func() {
 			init := rmap.NewEmpty()
 			init.MustSetJPtr("/registries", rmap.NewEmpty())
 			init.MustSetJPtr("/registries/mockincident", regItemV2)
+			// removed description is breaking change of schema
+			init.Mapa["force"] = true
 			tctx.InitOk(init.Bytes())
 		})
 
@@ -353,6 +355,16 @@ var _ = Describe("asset* method family tests", func() {
 			Expect(migratedV1.Mapa).To(Not(HaveKey("short_description")))
 			Expect(migratedV1.Mapa).To(HaveKeyWithValue("description", migrateV2V1.Mapa["description"]))
 		})
+
+		It("Should allow to migrate using JSON patch", func() {
+			reqV1 := rmap.NewFromMap(map[string]interface{}{"description": "foobar"})
+			v1ID := MustGetID(tctx.Rmap("assetCreate", "mockincident", reqV1.Bytes(), 1, ""))
+
+			migratedV2 := tctx.Rmap("assetMigrate", "mockincident", v1ID, `[{"op":"move","from":"/description","path":"/short_description"}]`, 2)
+			Expect(MustGetVersion(migratedV2)).To(Equal(2))
+			Expect(migratedV2.Mapa).To(Not(HaveKey("description")))
+			Expect(migratedV2.Mapa).To(HaveKeyWithValue("short_description", "foobar"))
+		})
 	})
 
 	Describe("Call to CC method assetQuery", func() {
@@ -564,6 +576,106 @@ var _ = Describe("asset* method family tests", func() {
 			updated := tctx.Rmap("assetUpdate", name, MustGetID(incident), req.Bytes())
 			Expect(updated.Mapa).To(HaveKeyWithValue(key, value))
 		})
+
+		It("Should increment revision once per transaction", func() {
+			Expect(incident.Mapa).To(HaveKeyWithValue(konst.AssetRevisionKey, float64(1)))
+
+			updated := tctx.Rmap("assetUpdate", name, MustGetID(incident), `{"description":"first"}`)
+			Expect(updated.Mapa).To(HaveKeyWithValue(konst.AssetRevisionKey, float64(2)))
+
+			results := tctx.JSONNoResult("assetBatch", fmt.Sprintf(`[
+				{"op": "update", "name": "%s", "id": "%s", "patch": {"description": "second"}},
+				{"op": "update", "name": "%s", "id": "%s", "patch": {"description": "third"}}
+			]`, name, MustGetID(incident), name, MustGetID(incident)))[konst.OutputResultKey].([]interface{})
+			Expect(results[1]).To(HaveKeyWithValue(konst.AssetRevisionKey, float64(3)))
+		})
+
+		It("Should update only when expected revision matches", func() {
+			id := MustGetID(incident)
+
+			Expect(tctx.ErrorJSON("asset revision mismatch, expected: 2, actual: 1", "assetUpdate", name, id, `{"description":"changed"}`, 2).Mapa).To(HaveKeyWithValue(konst.ErrorStatusKey, float64(409)))
+
+			updated := tctx.Rmap("assetUpdate", name, id, `{"description":"changed"}`, 1)
+			Expect(updated.Mapa).To(HaveKeyWithValue(konst.AssetRevisionKey, float64(2)))
+
+			// second client still holds revision 1
+			Expect(tctx.ErrorJSON("asset revision mismatch, expected: 1, actual: 2", "assetUpdate", name, id, `{"description":"overwritten"}`, 1).Mapa).To(HaveKeyWithValue(konst.ErrorStatusKey, float64(409)))
+			tctx.Error("invalid revision: abc", "assetUpdate", name, id, `{"description":"overwritten"}`, "abc")
+		})
+
+		It("Should parse revision strictly", func() {
+			id := MustGetID(incident)
+
+			for _, revision := range []string{`"1"`, "-1", "+1", "1.0", "1e0", "[1]"} {
+				tctx.Error("must be non-negative integer", "assetUpdate", name, id, `{"description":"changed"}`, revision)
+			}
+
+			// JSON object in place of revision is tracing info only when it is the last argument
+			tctx.Error("must be non-negative integer", "assetUpdate", name, id, `{"description":"changed"}`, `{"trace":false}`, `{}`)
+			tctx.Error("operation has invalid key: revision", "assetBatch", fmt.Sprintf(`[{"op": "update", "name": "%s", "id": "%s", "patch": {"description": "changed"}, "revision": 1.5}]`, name, id))
+
+			updated := tctx.Rmap("assetUpdate", name, id, `{"description":"changed"}`, `{"trace":false}`)
+			Expect(updated.Mapa).To(HaveKeyWithValue(konst.AssetRevisionKey, float64(2)))
+		})
+
+		It("Should compare missing revision of asset stored before revisions as revision 0", func() {
+			id := MustGetID(incident)
+
+			removeStoredRevision(tctx, id)
+
+			Expect(tctx.ErrorJSON("asset revision mismatch, expected: 1, actual: 0", "assetUpdate", name, id, `{"description":"changed"}`, 1).Mapa).To(HaveKeyWithValue(konst.ErrorStatusKey, float64(409)))
+
+			updated := tctx.Rmap("assetUpdate", name, id, `{"description":"changed"}`, 0)
+			Expect(updated.Mapa).To(HaveKeyWithValue(konst.AssetRevisionKey, float64(1)))
+
+			Expect(tctx.ErrorJSON("asset revision mismatch, expected: 0, actual: 1", "assetUpdate", name, id, `{"description":"again"}`, 0).Mapa).To(HaveKeyWithValue(konst.ErrorStatusKey, float64(409)))
+		})
+
+		It("Should update asset stored before revisions without expected revision", func() {
+			id := MustGetID(incident)
+
+			removeStoredRevision(tctx, id)
+
+			updated := tctx.Rmap("assetUpdate", name, id, `{"description":"changed"}`)
+			Expect(updated.Mapa).To(HaveKeyWithValue(konst.AssetRevisionKey, float64(1)))
+		})
+
+		It("Should update using JSON patch", func() {
+			id := MustGetID(incident)
+			user1 := MustGetID(tctx.Rmap("assetCreate", "mockuser", `{"name":"John","surname":"Doe"}`, -1, ""))
+			user2 := MustGetID(tctx.Rmap("assetCreate", "mockuser", `{"name":"Jane","surname":"Doe"}`, -1, ""))
+
+			tctx.Ok("assetUpdate", name, id, fmt.Sprintf(`{"additional_assignees":["%s"]}`, user1))
+
+			// append to array
+			updated := tctx.Rmap("assetUpdate", name, id, fmt.Sprintf(`[{"op":"add","path":"/additional_assignees/-","value":"%s"}]`, user2))
+			Expect(updated.Mapa).To(HaveKeyWithValue("additional_assignees", []interface{}{user1, user2}))
+
+			// remove single array element with test-and-set
+			updated = tctx.Rmap("assetUpdate", name, id, fmt.Sprintf(`[
+				{"op":"test","path":"/additional_assignees/0","value":"%s"},
+				{"op":"remove","path":"/additional_assignees/0"},
+				{"op":"replace","path":"/description","value":"patched"}
+			]`, user1))
+			Expect(updated.Mapa).To(HaveKeyWithValue("additional_assignees", []interface{}{user2}))
+			Expect(updated.Mapa).To(HaveKeyWithValue("description", "patched"))
+		})
+
+		It("Should return error if JSON patch is invalid or cannot be applied", func() {
+			id := MustGetID(incident)
+
+			Expect(tctx.ErrorJSON("JSON patch test operation failed: testing value /description failed: test failed", "assetUpdate", name, id, `[{"op":"test","path":"/description","value":"other"}]`).Mapa).To(HaveKeyWithValue(konst.ErrorStatusKey, float64(409)))
+			tctx.Error("unable to apply JSON patch", "assetUpdate", name, id, `[{"op":"remove","path":"/nonexistent"}]`)
+			tctx.Error("invalid JSON patch", "assetUpdate", name, id, `[1, 2]`)
+			Expect(tctx.ErrorJSON("JSON patch sets null value at: /description, null can only be used to remove key", "assetUpdate", name, id, `[{"op":"replace","path":"/description","value":null}]`).Mapa).To(HaveKeyWithValue(konst.ErrorStatusKey, float64(422)))
+			tctx.Error("JSON patch sets null value at: /details/note", "assetUpdate", name, id, `[{"op":"add","path":"/details","value":{"note":null}}]`)
+			tctx.Error("patch contains service key(s)", "assetUpdate", name, id, `[{"op":"replace","path":"/xxx_version","value":5}]`)
+			tctx.Error("patch contains service key(s)", "assetUpdate", name, id, `[{"op":"remove","path":"/xxx_revision"}]`)
+		})
+
+		It("Should return error if attempting to set revision", func() {
+			tctx.Error("patch contains service key(s)", "assetUpdate", name, MustGetID(incident), rmap.NewFromMap(map[string]interface{}{konst.AssetRevisionKey: 10}).Bytes())
+		})
 	})
 
 	Describe("Call to CC method assetUpdateDirect", func() {
@@ -622,6 +734,11 @@ var _ = Describe("asset* method family tests", func() {
 			tctx.Ok("assetDelete", name, MustGetID(incident))
 			tctx.Error("state entry not found: MOCKINCIDENT"+MustGetID(incident), "assetGet", name, MustGetID(incident), false, "")
 		})
+
+		It("Should delete asset only when expected revision matches", func() {
+			Expect(tctx.ErrorJSON("asset revision mismatch, expected: 5, actual: 1", "assetDelete", name, MustGetID(incident), 5).Mapa).To(HaveKeyWithValue(konst.ErrorStatusKey, float64(409)))
+			tctx.Ok("assetDelete", name, MustGetID(incident), 1)
+		})
 	})
 
 	Describe("Call to CC method assetDeleteDirect", func() {
@@ -0,0 +1,279 @@
+package cc_core
+
+import (
+	"time"
+
+	"github.com/KompiTech/fabric-cc-core/v2/internal/testdata"
+	"github.com/KompiTech/fabric-cc-core/v2/pkg/engine"
+	"github.com/KompiTech/fabric-cc-core/v2/pkg/konst"
+	. "github.com/KompiTech/fabric-cc-core/v2/pkg/testing"
+	"github.com/KompiTech/rmap"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("audit trail", func() {
+	var tctx *TestContext
+	var since string
+
+	// audit returns audit records matching filter, records of init and actors registration are skipped unless filter sets from
+	audit := func(filter map[string]interface{}) []interface{} {
+		if _, exists := filter[konst.AuditFromKey]; !exists {
+			filter[konst.AuditFromKey] = since
+		}
+		return tctx.JSONNoResult("auditQuery", string(rmap.NewFromMap(filter).Bytes()))["result"].([]interface{})
+	}
+
+	BeforeEach(func() {
+		config := testdata.GetConfiguration()
+		config.CurrentIDFunc = engine.CertSHA512IDFunc
+		config.AuditWhitelist, _ = rmap.NewFromSlice([]interface{}{"mockincident", "mockpd"})
+
+		tctx = NewTestContext("mock", config, nil, nil)
+		// init and actors registration are recorded an hour ago, specs see only their own records
+		tctx.SetTime(time.Now().Add(-time.Hour))
+		tctx.InitOk(tctx.GetInit("../internal/testdata/assets", "").Bytes())
+		tctx.RegisterAllActors()
+		tctx.SetTime(time.Now())
+		tctx.ResetTime()
+		since = time.Now().Add(-time.Minute).UTC().Format(time.RFC3339)
+	})
+
+	It("Should record who changed which fields of audited asset", func() {
+		incidentUUID := MustGetID(tctx.Rmap("assetCreate", "mockincident", `{"description": "created"}`, -1, ""))
+		tctx.Ok("assetUpdate", "mockincident", incidentUUID, `{"description": "updated"}`)
+
+		records := audit(map[string]interface{}{konst.AssetIdKey: incidentUUID})
+		Expect(records).To(HaveLen(2))
+
+		created := records[0].(map[string]interface{})
+		Expect(created).To(HaveKeyWithValue(konst.AssetFingerprintKey, tctx.GetActorFingerprint("superUser")))
+		Expect(created).To(HaveKeyWithValue(konst.AuditFunctionKey, "assetCreate"))
+		Expect(created).To(HaveKeyWithValue(konst.AuditDirectKey, false))
+		Expect(created).To(HaveKey(konst.AuditTxIdKey))
+		Expect(created).To(HaveKey(konst.AuditTimestampKey))
+		Expect(created[konst.AuditChangesKey]).To(ConsistOf(map[string]interface{}{
+			konst.AssetDocTypeKey:   "MOCKINCIDENT",
+			konst.AssetIdKey:        incidentUUID,
+			konst.AuditOperationKey: konst.EventCreateOperation,
+			konst.AuditPointersKey:  []interface{}{"/description", "/docType", "/uuid", "/xxx_version"},
+		}))
+
+		updated := records[1].(map[string]interface{})
+		Expect(updated).To(HaveKeyWithValue(konst.AuditFunctionKey, "assetUpdate"))
+		Expect(updated[konst.AuditChangesKey]).To(ConsistOf(map[string]interface{}{
+			konst.AssetDocTypeKey:   "MOCKINCIDENT",
+			konst.AssetIdKey:        incidentUUID,
+			konst.AuditOperationKey: konst.EventUpdateOperation,
+			konst.AuditPointersKey:  []interface{}{"/description"},
+		}))
+	})
+
+	It("Should record direct calls and deletes", func() {
+		incidentUUID := MustGetID(tctx.Rmap("assetCreateDirect", "mockincident", `{"description": "created"}`, -1, ""))
+		tctx.Ok("assetDeleteDirect", "mockincident", incidentUUID)
+
+		records := audit(map[string]interface{}{konst.AssetIdKey: incidentUUID})
+		Expect(records).To(HaveLen(2))
+		Expect(records[0]).To(HaveKeyWithValue(konst.AuditDirectKey, true))
+
+		deleted := records[1].(map[string]interface{})
+		Expect(deleted).To(HaveKeyWithValue(konst.AuditFunctionKey, "assetDeleteDirect"))
+		Expect(deleted).To(HaveKeyWithValue(konst.AuditDirectKey, true))
+		Expect(deleted[konst.AuditChangesKey]).To(ConsistOf(map[string]interface{}{
+			konst.AssetDocTypeKey:   "MOCKINCIDENT",
+			konst.AssetIdKey:        incidentUUID,
+			konst.AuditOperationKey: konst.EventDeleteOperation,
+		}))
+	})
+
+	It("Should record requester, approvers and function of approved proposal", func() {
+		incidentUUID := MustGetID(tctx.Rmap("assetCreate", "mockincident", `{"description": "created"}`, -1, ""))
+
+		policy := rmap.NewFromMap(map[string]interface{}{
+			"value": map[string]interface{}{"assetDelete/mockincident": map[string]interface{}{"quorum": 1}},
+		})
+		tctx.Ok("singletonUpsert", konst.ApprovalPolicySingletonName, policy.Bytes())
+
+		approver := rmap.NewFromMap(map[string]interface{}{
+			"name": "Approver",
+			"grants": []map[string]interface{}{{
+				"object": "/proposal/*",
+				"action": "approve",
+			}},
+		})
+		approverUUID := MustGetID(tctx.Rmap("assetCreate", "role", approver.Bytes(), -1, ""))
+		tctx.Ok("assetUpdate", "identity", tctx.GetActorFingerprint("ordinaryUser"), rmap.NewFromMap(map[string]interface{}{"roles": []string{approverUUID}}).Bytes())
+
+		input := rmap.NewFromMap(map[string]interface{}{"function": "assetDelete", "args": []string{"mockincident", incidentUUID}})
+		proposalUUID := MustGetID(tctx.Rmap("proposalCreate", input.Bytes()))
+
+		tctx.SetActor("ordinaryUser")
+		tctx.Ok("proposalApprove", proposalUUID)
+
+		tctx.SetActor("superUser")
+		records := audit(map[string]interface{}{konst.AssetIdKey: incidentUUID})
+		Expect(records).To(HaveLen(2))
+
+		deleted := records[1].(map[string]interface{})
+		Expect(deleted).To(HaveKeyWithValue(konst.AssetFingerprintKey, tctx.GetActorFingerprint("superUser")))
+		Expect(deleted).To(HaveKeyWithValue(konst.AuditFunctionKey, "assetDelete"))
+		Expect(deleted).To(HaveKeyWithValue(konst.AuditDirectKey, false))
+		Expect(deleted).To(HaveKeyWithValue(konst.AuditProposalKey, map[string]interface{}{
+			konst.AssetIdKey:         proposalUUID,
+			konst.AuditApprovedByKey: []interface{}{tctx.GetActorFingerprint("ordinaryUser")},
+		}))
+
+		// approver finds the change too
+		Expect(audit(map[string]interface{}{konst.AssetFingerprintKey: tctx.GetActorFingerprint("ordinaryUser"), konst.AssetIdKey: incidentUUID})).To(HaveLen(1))
+	})
+
+	It("Should always record changes of internal assets, registry items and singletons", func() {
+		roleUUID := MustGetID(tctx.Rmap("assetCreate", "role", rmap.NewFromMap(map[string]interface{}{"name": "Audited", "grants": []interface{}{}}).Bytes(), -1, ""))
+		tctx.TravelInTime(1)
+		tctx.Ok("assetUpdateDirect", "role", roleUUID, `{"name": "Changed"}`)
+
+		records := audit(map[string]interface{}{konst.AssetIdKey: roleUUID})
+		Expect(records).To(HaveLen(2))
+		Expect(records[0]).To(HaveKeyWithValue(konst.AuditDirectKey, false))
+		Expect(records[1]).To(HaveKeyWithValue(konst.AuditFunctionKey, "assetUpdateDirect"))
+		Expect(records[1]).To(HaveKeyWithValue(konst.AuditDirectKey, true))
+		Expect(records[1].(map[string]interface{})[konst.AuditChangesKey]).To(ConsistOf(map[string]interface{}{
+			konst.AssetDocTypeKey:   "ROLE",
+			konst.AssetIdKey:        roleUUID,
+			konst.AuditOperationKey: konst.EventUpdateOperation,
+			konst.AuditPointersKey:  []interface{}{"/name"},
+		}))
+
+		tctx.TravelInTime(1)
+		tctx.Ok("singletonUpsert", "audited", `{"value": {}}`)
+		tctx.TravelInTime(1)
+		tctx.Ok("registryUpsert", "mockaudited", rmap.MustNewFromYAMLFile("../internal/testdata/assets/mockstate.yaml").Bytes())
+
+		records = audit(map[string]interface{}{})
+		Expect(records).To(HaveLen(4))
+		Expect(records[2].(map[string]interface{})[konst.AuditChangesKey]).To(ConsistOf(map[string]interface{}{
+			konst.AuditSingletonKey: "audited",
+			konst.AuditVersionKey:   float64(1),
+			konst.AuditOperationKey: konst.EventCreateOperation,
+		}))
+		Expect(records[3].(map[string]interface{})[konst.AuditChangesKey]).To(ConsistOf(map[string]interface{}{
+			konst.AuditRegistryKey:  "mockaudited",
+			konst.AuditVersionKey:   float64(1),
+			konst.AuditOperationKey: konst.EventCreateOperation,
+		}))
+	})
+
+	It("Should not record fields of private data", func() {
+		pdUUID := MustGetID(tctx.Rmap("assetCreate", "mockpd", `{}`, -1, ""))
+
+		records := audit(map[string]interface{}{konst.AssetDocTypeKey: "mockpd"})
+		Expect(records).To(HaveLen(1))
+		Expect(records[0].(map[string]interface{})[konst.AuditChangesKey]).To(ConsistOf(map[string]interface{}{
+			konst.AssetDocTypeKey:   "MOCKPD",
+			konst.AssetIdKey:        pdUUID,
+			konst.AuditOperationKey: konst.EventCreateOperation,
+		}))
+	})
+
+	It("Should record only successful changes of audited assets", func() {
+		tctx.Ok("assetCreate", "mockstate", rmap.NewEmpty().Bytes(), -1, "")
+		incidentUUID := MustGetID(tctx.Rmap("assetCreate", "mockincident", `{"description": "created"}`, -1, ""))
+
+		// audit trail does not emit changes of assets without events enabled
+		Expect(tctx.GetLastAssetChanges()).To(BeEmpty())
+
+		tctx.Error("asset.ValidateSchema() failed", "assetUpdate", "mockincident", incidentUUID, `{"description": 1}`)
+
+		records := audit(map[string]interface{}{})
+		Expect(records).To(HaveLen(1))
+		Expect(records[0]).To(HaveKeyWithValue(konst.AuditChangesKey, ConsistOf(HaveKeyWithValue(konst.AssetIdKey, incidentUUID))))
+	})
+
+	It("Should filter records by identity, asset, field and time range", func() {
+		firstUUID := MustGetID(tctx.Rmap("assetCreate", "mockincident", `{"description": "first"}`, -1, ""))
+		first := audit(map[string]interface{}{})[0].(map[string]interface{})
+		firstTime, err := time.Parse(time.RFC3339, first[konst.AuditTimestampKey].(string))
+		Expect(err).To(BeNil())
+
+		tctx.TravelInTime(3600)
+		secondUUID := MustGetID(tctx.Rmap("assetCreate", "mockincident", `{"description": "second"}`, -1, ""))
+		tctx.Ok("assetUpdate", "mockincident", firstUUID, `{"assigned_to": null}`)
+
+		Expect(audit(map[string]interface{}{})).To(HaveLen(3))
+		Expect(audit(map[string]interface{}{konst.AssetFingerprintKey: tctx.GetActorFingerprint("superUser")})).To(HaveLen(3))
+		Expect(audit(map[string]interface{}{konst.AssetFingerprintKey: tctx.GetActorFingerprint("ordinaryUser")})).To(BeEmpty())
+		Expect(audit(map[string]interface{}{konst.AssetDocTypeKey: "mockincident", konst.AssetIdKey: secondUUID})).To(HaveLen(1))
+		Expect(audit(map[string]interface{}{konst.AssetDocTypeKey: "mockstate"})).To(BeEmpty())
+		Expect(audit(map[string]interface{}{konst.AssetIdKey: firstUUID, konst.AuditPointerKey: "/description"})).To(HaveLen(1))
+
+		later := firstTime.Add(30 * time.Minute).Format(time.RFC3339)
+		Expect(audit(map[string]interface{}{konst.AuditFromKey: later})).To(HaveLen(2))
+		Expect(audit(map[string]interface{}{konst.AuditToKey: later})).To(ConsistOf(HaveKeyWithValue(konst.AuditTxIdKey, first[konst.AuditTxIdKey])))
+	})
+
+	It("Should return records in pages", func() {
+		for i := 0; i < 3; i++ {
+			tctx.TravelInTime(60)
+			tctx.Ok("assetCreate", "mockincident", `{"description": "paged"}`, -1, "")
+		}
+
+		first := tctx.JSONNoResult("auditQuery", rmap.NewFromMap(map[string]interface{}{"from": since, "limit": 2}).Bytes())
+		Expect(first["result"]).To(HaveLen(2))
+		Expect(first["bookmark"]).NotTo(BeEmpty())
+
+		second := tctx.JSONNoResult("auditQuery", rmap.NewFromMap(map[string]interface{}{"from": since, "limit": 2, "bookmark": first["bookmark"]}).Bytes())
+		Expect(second["result"]).To(HaveLen(1))
+		Expect(second["bookmark"]).To(BeEmpty())
+		Expect(append(first["result"].([]interface{}), second["result"].([]interface{})...)).To(Equal(audit(map[string]interface{}{})))
+
+		// bookmark is valid only in time range of filter
+		lastTime, err := time.Parse(time.RFC3339, second["result"].([]interface{})[0].(map[string]interface{})[konst.AuditTimestampKey].(string))
+		Expect(err).To(BeNil())
+		later := lastTime.Add(time.Hour).Format(time.RFC3339)
+		tctx.Error("input key: bookmark is not from time range of filter", "auditQuery", rmap.NewFromMap(map[string]interface{}{"from": later, "bookmark": first["bookmark"]}).Bytes())
+	})
+
+	It("Should end page after limit of records is read, even when fewer of them match", func() {
+		ids := []string{}
+		for i := 0; i < 3; i++ {
+			tctx.TravelInTime(60)
+			ids = append(ids, MustGetID(tctx.Rmap("assetCreate", "mockincident", `{"description": "paged"}`, -1, "")))
+		}
+
+		filter := map[string]interface{}{"from": since, "limit": 2, konst.AssetIdKey: ids[2]}
+		first := tctx.JSONNoResult("auditQuery", rmap.NewFromMap(filter).Bytes())
+		Expect(first["result"]).To(BeEmpty())
+		Expect(first["bookmark"]).NotTo(BeEmpty())
+
+		filter["bookmark"] = first["bookmark"]
+		second := tctx.JSONNoResult("auditQuery", rmap.NewFromMap(filter).Bytes())
+		Expect(second["result"]).To(ConsistOf(HaveKeyWithValue(konst.AuditChangesKey, ContainElement(HaveKeyWithValue(konst.AssetIdKey, ids[2])))))
+		Expect(second["bookmark"]).To(BeEmpty())
+	})
+
+	It("Should refuse invalid filter", func() {
+		tctx.Error("input has unknown key: name", "auditQuery", `{"name": "mockincident"}`)
+		tctx.Error("input key: from must be RFC 3339 timestamp", "auditQuery", `{"from": "yesterday"}`)
+		tctx.Error("input key: limit must be positive integer", "auditQuery", `{"limit": 0}`)
+	})
+
+	It("Should require read grant on audit trail", func() {
+		tctx.SetActor("ordinaryUser")
+		tctx.Error("permission denied", "auditQuery", "")
+
+		tctx.SetActor("superUser")
+		auditor := rmap.NewFromMap(map[string]interface{}{
+			"name": "Auditor",
+			"grants": []map[string]interface{}{{
+				"object": "/" + konst.AuditCasbinObject,
+				"action": konst.ReadAction,
+			}},
+		})
+		auditorUUID := MustGetID(tctx.Rmap("assetCreate", "role", auditor.Bytes(), -1, ""))
+		tctx.Ok("assetUpdate", "identity", tctx.GetActorFingerprint("ordinaryUser"), rmap.NewFromMap(map[string]interface{}{"roles": []string{auditorUUID}}).Bytes())
+
+		tctx.SetActor("ordinaryUser")
+		tctx.Ok("auditQuery", "")
+	})
+})
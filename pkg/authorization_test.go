@@ -0,0 +1,136 @@
+package cc_core
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/KompiTech/fabric-cc-core/v2/pkg/kompiguard"
+	"github.com/KompiTech/fabric-cc-core/v2/pkg/konst"
+	. "github.com/KompiTech/fabric-cc-core/v2/pkg/testing"
+	"github.com/KompiTech/rmap"
+	. "github.com/onsi/gomega"
+)
+
+// number of assets read by one iteration of benchmarks
+const benchmarkAssetCount = 100
+
+// BenchmarkAssetQuery measures query of all pages of 100 assets by ordinary user
+// every asset is checked by business logic AfterQuery, so this measures access checks of query
+func BenchmarkAssetQuery(b *testing.B) {
+	InitializeCouchDBContainer()
+	RegisterTestingT(b)
+
+	tctx := getDefaultTextContext()
+	tctx.InitOk(tctx.GetInit("../internal/testdata/assets", "").Bytes())
+	tctx.RegisterAllActors()
+
+	role := rmap.NewFromMap(map[string]interface{}{
+		"name": "Incident reader",
+		"grants": []map[string]interface{}{{
+			"object": "/mockincident/*",
+			"action": "read",
+		}},
+	})
+	roleUUID := MustGetID(tctx.Rmap("assetCreate", "role", role.Bytes(), -1, ""))
+	tctx.Ok("assetUpdate", "identity", tctx.GetActorFingerprint("ordinaryUser"), rmap.NewFromMap(map[string]interface{}{"roles": []string{roleUUID}}).Bytes())
+
+	for i := 0; i < benchmarkAssetCount; i++ {
+		tctx.Ok("assetCreate", "mockincident", rmap.NewFromMap(map[string]interface{}{"description": "mockIncident"}).Bytes(), -1, "")
+	}
+
+	tctx.SetActor("ordinaryUser")
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		count := 0
+		bookmark := ""
+
+		for {
+			response := tctx.RmapNoResult("assetQuery", "mockincident", rmap.NewFromMap(map[string]interface{}{"bookmark": bookmark}), false)
+			result := response.MustGetIterable(konst.OutputResultKey)
+			if len(result) == 0 {
+				break
+			}
+
+			for _, assetI := range result {
+				Expect(assetI).NotTo(HaveKey("error"))
+			}
+
+			count += len(result)
+			bookmark = response.MustGetString(konst.OutputBookmarkKey)
+		}
+
+		Expect(count).To(Equal(benchmarkAssetCount))
+	}
+}
+
+// BenchmarkEnforceAssets compares enforcer built for every checked asset with enforcer built once and reused for all 100 assets
+func BenchmarkEnforceAssets(b *testing.B) {
+	identity := rmap.NewFromMap(map[string]interface{}{
+		konst.AssetDocTypeKey:     konst.IdentityAssetName,
+		konst.AssetFingerprintKey: "benchmark",
+		konst.IsEnabledKey:        true,
+		konst.RolesKey: []interface{}{map[string]interface{}{
+			konst.AssetDocTypeKey: konst.RoleAssetName,
+			konst.AssetIdKey:      "reader",
+			konst.GrantsKey: []interface{}{map[string]interface{}{
+				konst.ObjectKey: "/mockincident/*",
+				konst.ActionKey: konst.ReadAction,
+			}},
+		}},
+	})
+
+	assets := make([]rmap.Rmap, 0, benchmarkAssetCount)
+	for i := 0; i < benchmarkAssetCount; i++ {
+		assets = append(assets, rmap.NewFromMap(map[string]interface{}{
+			konst.AssetDocTypeKey: "mockincident",
+			konst.AssetIdKey:      strconv.Itoa(i),
+			konst.OverridesKey: []interface{}{map[string]interface{}{
+				konst.SubjectKey: "benchmark",
+				konst.ActionKey:  konst.UpdateAction,
+				konst.EffectKey:  konst.AllowEffect,
+			}},
+		}))
+	}
+
+	b.Run("per check", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, asset := range assets {
+				kmpg, err := kompiguard.New()
+				if err != nil {
+					b.Fatal(err)
+				}
+
+				granted, _, err := kmpg.EnforceAsset(asset, identity, konst.ReadAction)
+				if err != nil || !granted {
+					b.Fatalf("asset not granted: %v", err)
+				}
+			}
+		}
+	})
+
+	b.Run("per transaction", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			kmpg, err := kompiguard.New()
+			if err != nil {
+				b.Fatal(err)
+			}
+
+			if err := kmpg.LoadRoles(identity); err != nil {
+				b.Fatal(err)
+			}
+
+			for _, asset := range assets {
+				object, err := konst.AssetGetCasbinObject(asset)
+				if err != nil {
+					b.Fatal(err)
+				}
+
+				granted, _, err := kmpg.EnforceCustom(object, "benchmark", konst.ReadAction, &asset)
+				if err != nil || !granted {
+					b.Fatalf("asset not granted: %v", err)
+				}
+			}
+		}
+	})
+}
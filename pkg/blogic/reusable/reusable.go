@@ -2,30 +2,24 @@ package reusable
 
 import (
 	"github.com/KompiTech/fabric-cc-core/v2/pkg/engine"
-	"github.com/KompiTech/fabric-cc-core/v2/pkg/kompiguard"
 	"github.com/KompiTech/fabric-cc-core/v2/pkg/konst"
 	"github.com/KompiTech/rmap"
 	"github.com/pkg/errors"
 )
 
 func authorize(ctx engine.ContextInterface, asset rmap.Rmap, action string) (rmap.Rmap, error) {
-	thisIdentity, err := ctx.Get("registry").(*engine.Registry).GetThisIdentityResolved()
+	auth, err := ctx.GetAuthorization()
 	if err != nil {
-		return rmap.Rmap{}, errors.Wrap(err, "reg.GetThisIdentityResolved() failed")
+		return rmap.Rmap{}, errors.Wrap(err, "ctx.GetAuthorization() failed")
 	}
 
-	kmpg, err := kompiguard.New()
+	granted, reason, err := auth.EnforceAsset(asset, action)
 	if err != nil {
-		return rmap.Rmap{}, errors.Wrap(err, "kompiguard.New() failed")
-	}
-
-	granted, reason, err := kmpg.EnforceAsset(asset, thisIdentity, action)
-	if err != nil {
-		return rmap.Rmap{}, errors.Wrap(err, "kompiguard.New().EnforceAsset() failed")
+		return rmap.Rmap{}, errors.Wrap(err, "auth.EnforceAsset() failed")
 	}
 
 	if !granted {
-		return rmap.Rmap{}, engine.ErrorForbidden(reason)
+		return rmap.Rmap{}, engine.ErrorAssetPermissionDenied(reason, asset, action)
 	}
 
 	return asset, nil
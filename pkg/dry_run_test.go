@@ -0,0 +1,182 @@
+package cc_core
+
+import (
+	"fmt"
+
+	"github.com/KompiTech/fabric-cc-core/v2/pkg/konst"
+	. "github.com/KompiTech/fabric-cc-core/v2/pkg/testing"
+	"github.com/KompiTech/rmap"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("dry run tests", func() {
+	var tctx *TestContext
+	var dryRun []byte
+
+	BeforeEach(func() {
+		tctx = getDefaultTextContext()
+		tctx.InitOk(tctx.GetInit("../internal/testdata/assets", "").Bytes())
+		tctx.RegisterAllActors()
+
+		// version 2 of mockstate has some property to update
+		regItemV2 := rmap.MustNewFromYAMLFile("../internal/testdata/assets/mockstate.yaml")
+		regItemV2.MustSetJPtr("/schema/properties", map[string]interface{}{
+			"text": map[string]interface{}{"type": "string"},
+		})
+		tctx.Ok("registryUpsert", "mockstate", regItemV2.Bytes())
+
+		dryRun = rmap.NewFromMap(map[string]interface{}{konst.TracingDryRunKey: true}).Bytes()
+	})
+
+	It("Should return intended writes, changes and event of create without writing them", func() {
+		output := tctx.RmapNoResult("assetCreate", "mockstate", `{"text":"created"}`, -1, "", dryRun)
+		result := output.MustGetRmap(konst.OutputResultKey)
+		id := MustGetID(result)
+		Expect(result.Mapa).To(HaveKeyWithValue("text", "created"))
+
+		writes, err := output.GetJPtrIterable("/" + konst.OutputDryRunKey + "/" + konst.DryRunWritesKey)
+		Expect(err).To(BeNil())
+		Expect(writes).To(ContainElement(map[string]interface{}{
+			konst.DryRunOperationKey:  konst.DryRunPutOperation,
+			konst.DryRunKeyKey:        "MOCKSTATE",
+			konst.DryRunAttributesKey: []interface{}{id},
+		}))
+
+		changes, err := output.GetJPtrIterable("/" + konst.OutputDryRunKey + "/" + konst.DryRunChangesKey)
+		Expect(err).To(BeNil())
+		Expect(changes).To(ConsistOf(And(
+			HaveKeyWithValue(konst.AssetDocTypeKey, "MOCKSTATE"),
+			HaveKeyWithValue(konst.AssetIdKey, id),
+			HaveKeyWithValue(konst.DryRunOperationKey, konst.EventCreateOperation),
+			HaveKeyWithValue(konst.DryRunValueKey, HaveKeyWithValue("text", "created")),
+		)))
+
+		event, err := output.GetJPtrRmap("/" + konst.OutputDryRunKey + "/" + konst.DryRunEventKey)
+		Expect(err).To(BeNil())
+		Expect(event.Mapa).To(HaveKeyWithValue(konst.EventNameKey, konst.EventEnvelopeName))
+		Expect(event.Mapa).To(HaveKeyWithValue(konst.EventPayloadKey, HaveKey(konst.EventChangesKey)))
+
+		Expect(tctx.GetCC().ChaincodeEvent).To(BeNil())
+		tctx.Error("state entry not found: MOCKSTATE"+id, "assetGet", "mockstate", id, false, "")
+	})
+
+	It("Should not return payload of intended private data write", func() {
+		output := tctx.RmapNoResult("assetCreate", "mockpd", `{}`, -1, "", dryRun)
+		id := MustGetID(output.MustGetRmap(konst.OutputResultKey))
+
+		writes, err := output.GetJPtrIterable("/" + konst.OutputDryRunKey + "/" + konst.DryRunWritesKey)
+		Expect(err).To(BeNil())
+		Expect(writes).To(ContainElement(And(
+			HaveKeyWithValue(konst.DryRunOperationKey, konst.DryRunPutOperation),
+			HaveKeyWithValue(konst.DryRunKeyKey, "MOCKPD"),
+			HaveKeyWithValue(konst.DryRunAttributesKey, []interface{}{id}),
+			HaveKey(konst.DryRunCollectionKey),
+		)))
+
+		for _, write := range writes {
+			Expect(write).To(Not(HaveKey(konst.DryRunValueKey)))
+		}
+
+		changes, err := output.GetJPtrIterable("/" + konst.OutputDryRunKey + "/" + konst.DryRunChangesKey)
+		Expect(err).To(BeNil())
+		Expect(changes).To(BeEmpty())
+	})
+
+	It("Should mask restricted fields in intended changes", func() {
+		regItemV3 := rmap.MustNewFromYAMLFile("../internal/testdata/assets/mockstate.yaml")
+		regItemV3.MustSetJPtr("/schema/properties", map[string]interface{}{
+			"text":   map[string]interface{}{"type": "string"},
+			"salary": map[string]interface{}{"type": "integer"},
+		})
+		regItemV3.Mapa[konst.RegistryItemRestrictedFieldsKey] = []interface{}{"/salary"}
+		tctx.Ok("registryUpsert", "mockstate", regItemV3.Bytes())
+
+		role := rmap.NewFromMap(map[string]interface{}{
+			"name": "State writer",
+			"grants": []map[string]interface{}{{
+				"object": "/mockstate/*",
+				"action": "create",
+			}, {
+				"object": "/mockstate/*",
+				"action": "read",
+			}, {
+				"object": "/mockstate/*#/salary",
+				"action": "update",
+			}},
+		})
+		roleID := MustGetID(tctx.Rmap("assetCreate", "role", role.Bytes(), -1, ""))
+		tctx.Ok("assetUpdate", "identity", tctx.GetActorFingerprint("ordinaryUser"), rmap.NewFromMap(map[string]interface{}{"roles": []string{roleID}}).Bytes())
+
+		tctx.SetActor("ordinaryUser")
+		output := tctx.RmapNoResult("assetCreate", "mockstate", `{"text":"created","salary":1000}`, -1, "", dryRun)
+
+		changes, err := output.GetJPtrIterable("/" + konst.OutputDryRunKey + "/" + konst.DryRunChangesKey)
+		Expect(err).To(BeNil())
+		Expect(changes).To(ConsistOf(HaveKeyWithValue(konst.DryRunValueKey, And(
+			HaveKeyWithValue("text", "created"),
+			Not(HaveKey("salary")),
+		))))
+	})
+
+	It("Should return changes of referencing assets done by delete policy", func() {
+		incidentV2 := rmap.MustNewFromYAMLFile("../internal/testdata/assets/mockincident.yaml")
+		incidentV2.Mapa["on_delete"] = map[string]interface{}{"/timelogs": "set-null"}
+		tctx.Ok("registryUpsert", "mockincident", incidentV2.Bytes())
+
+		incidentID := MustGetID(tctx.Rmap("assetCreate", "mockincident", `{"description":"set-null"}`, -1, ""))
+		timelogID := MustGetID(tctx.Rmap("assetCreate", "mocktimelog", fmt.Sprintf(`{"incident":"%s"}`, incidentID), -1, ""))
+		tctx.Ok("assetUpdate", "mockincident", incidentID, fmt.Sprintf(`{"timelogs":["%s"]}`, timelogID))
+
+		output := tctx.RmapNoResult("assetDelete", "mocktimelog", timelogID, "", dryRun)
+		changes, err := output.GetJPtrIterable("/" + konst.OutputDryRunKey + "/" + konst.DryRunChangesKey)
+		Expect(err).To(BeNil())
+		Expect(changes).To(ConsistOf(
+			And(
+				HaveKeyWithValue(konst.AssetIdKey, incidentID),
+				HaveKeyWithValue(konst.DryRunOperationKey, konst.EventUpdateOperation),
+				HaveKeyWithValue(konst.DryRunValueKey, HaveKeyWithValue("timelogs", BeEmpty())),
+			),
+			And(
+				HaveKeyWithValue(konst.AssetIdKey, timelogID),
+				HaveKeyWithValue(konst.DryRunOperationKey, konst.EventDeleteOperation),
+				Not(HaveKey(konst.DryRunValueKey)),
+			),
+		))
+
+		Expect(tctx.JSON("assetGet", "mockincident", incidentID, false, "")).To(HaveKeyWithValue("timelogs", []interface{}{timelogID}))
+	})
+
+	It("Should return intended delete without deleting", func() {
+		id := MustGetID(tctx.Rmap("assetCreate", "mockstate", `{"text":"created"}`, -1, ""))
+
+		output := tctx.RmapNoResult("assetDelete", "mockstate", id, "", dryRun)
+		writes, err := output.GetJPtrIterable("/" + konst.OutputDryRunKey + "/" + konst.DryRunWritesKey)
+		Expect(err).To(BeNil())
+		Expect(writes).To(ContainElement(map[string]interface{}{
+			konst.DryRunOperationKey:  konst.DryRunDeleteOperation,
+			konst.DryRunKeyKey:        "MOCKSTATE",
+			konst.DryRunAttributesKey: []interface{}{id},
+		}))
+
+		Expect(tctx.JSON("assetGet", "mockstate", id, false, "")).To(HaveKeyWithValue("text", "created"))
+	})
+
+	It("Should not change anything when dry run is combined with tracing", func() {
+		id := MustGetID(tctx.Rmap("assetCreate", "mockstate", `{"text":"created"}`, -1, ""))
+
+		tracing := rmap.NewFromMap(map[string]interface{}{
+			konst.TracingDryRunKey: true,
+			konst.TracingTraceKey:  true,
+			"request":              "dry-run-request",
+		})
+
+		output := tctx.RmapNoResult("assetUpdate", "mockstate", id, `{"text":"updated"}`, "", tracing.Bytes())
+		Expect(output.Mapa).To(HaveKeyWithValue(konst.OutputResultKey, HaveKeyWithValue("text", "updated")))
+		Expect(output.Mapa).To(HaveKey(konst.OutputDryRunKey))
+		Expect(tctx.JSON("assetGet", "mockstate", id, false, "")).To(HaveKeyWithValue("text", "created"))
+
+		// errors are returned as usual, with tracing info
+		tctx.Error("dry-run-request", "assetUpdate", "mockstate", id, `{"text":1}`, "", tracing.Bytes())
+	})
+})
@@ -0,0 +1,193 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/KompiTech/fabric-cc-core/v2/pkg/kompiguard"
+	. "github.com/KompiTech/fabric-cc-core/v2/pkg/konst"
+	"github.com/KompiTech/rmap"
+	"github.com/hyperledger/fabric-chaincode-go/pkg/attrmgr"
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+	"github.com/pkg/errors"
+)
+
+// NewKompiGuard returns new kompiguard with casbin model of chaincode and with attributes of certificate of current identity available to conditions of grants
+// and with time of transaction used to check validity of grants and role assignments
+// holders of superuser roles from configuration are granted every action, their use is audited when AuditSuperuser is set
+// roles still must be loaded by LoadRoles or by Enforce* method
+func NewKompiGuard(ctx ContextInterface) (kompiguard.KompiGuard, error) {
+	modelText, err := getCasbinModel(ctx)
+	if err != nil {
+		return kompiguard.KompiGuard{}, errors.Wrap(err, "getCasbinModel() failed")
+	}
+
+	kmpg, err := kompiguard.NewWithModel(modelText)
+	if err != nil {
+		return kompiguard.KompiGuard{}, errors.Wrap(err, "kompiguard.NewWithModel() failed")
+	}
+
+	attrs, err := getCertAttributes(ctx)
+	if err != nil {
+		return kompiguard.KompiGuard{}, errors.Wrap(err, "getCertAttributes() failed")
+	}
+
+	now, err := ctx.Time()
+	if err != nil {
+		return kompiguard.KompiGuard{}, errors.Wrap(err, "ctx.Time() failed")
+	}
+
+	kmpg.SetCertAttributes(attrs)
+	kmpg.SetTime(now)
+	kmpg.SetSuperuserRoles(getSuperuserRoles(ctx))
+	if ctx.GetConfiguration().AuditSuperuser {
+		kmpg.SetSuperuserAudit(makeSuperuserAudit(ctx))
+	}
+	return kmpg, nil
+}
+
+// getCertAttributes returns attributes of certificate of current identity
+// these are Fabric CA attributes together with MSP ID and organization name of certificate
+func getCertAttributes(ctx ContextInterface) (map[string]string, error) {
+	cert, err := cid.GetX509Certificate(ctx.Stub())
+	if err != nil {
+		return nil, errors.Wrap(err, "cid.GetX509Certificate() failed")
+	}
+
+	caAttrs, err := attrmgr.New().GetAttributesFromCert(cert)
+	if err != nil {
+		return nil, errors.Wrap(err, "attrmgr.GetAttributesFromCert() failed")
+	}
+
+	attrs := map[string]string{}
+	for key, value := range caAttrs.Attrs {
+		attrs[key] = value
+	}
+
+	mspID, err := cid.GetMSPID(ctx.Stub())
+	if err != nil {
+		return nil, errors.Wrap(err, "cid.GetMSPID() failed")
+	}
+	attrs[CertMSPIDAttribute] = mspID
+
+	// certificates with unexpected issuer do not have organization name
+	if orgName, err := GetMyOrgName(ctx); err == nil {
+		attrs[CertOrgNameAttribute] = orgName
+	}
+
+	return attrs, nil
+}
+
+// validateGrantConditions checks conditions of all grants in role, so malformed expressions are rejected when role is stored
+func validateGrantConditions(role rmap.Rmap) error {
+	if !role.Exists(GrantsKey) {
+		return nil
+	}
+
+	grants, err := role.GetIterable(GrantsKey)
+	if err != nil {
+		return errors.Wrap(err, "role.GetIterable() failed")
+	}
+
+	fields := []rmap.Rmap{}
+	for index, grantI := range grants {
+		grant, err := rmap.NewFromInterface(grantI)
+		if err != nil {
+			return errors.Wrap(err, "rmap.NewFromInterface() failed")
+		}
+
+		if !grant.Exists(ConditionKey) {
+			continue
+		}
+
+		condition, err := grant.GetString(ConditionKey)
+		if err != nil {
+			return errors.Wrap(err, "grant.GetString() failed")
+		}
+
+		if err := kompiguard.ValidateCondition(condition); err != nil {
+			fields = append(fields, rmap.NewFromMap(map[string]interface{}{
+				ValidationPointerKey: fmt.Sprintf("%s/%d/%s", GrantsJPtr, index, ConditionKey),
+				ValidationKeywordKey: ConditionKey,
+				ValidationMessageKey: err.Error(),
+			}))
+		}
+	}
+
+	if len(fields) > 0 {
+		return ErrorValidationFields("invalid condition of grant", fields)
+	}
+
+	return nil
+}
+
+// validateRoleParents rejects role, that would inherit from itself through its parent roles
+// parents that do not exist are skipped, they are reported by validation of references
+func (r *Registry) validateRoleParents(role rmap.Rmap) error {
+	roleID, err := AssetGetID(role)
+	if err != nil {
+		return errors.Wrap(err, "AssetGetID(role) failed")
+	}
+
+	cycle, err := r.findRoleCycle(role, []string{roleID})
+	if err != nil {
+		return errors.Wrap(err, "r.findRoleCycle() failed")
+	}
+
+	if cycle != nil {
+		return ErrorValidationFields("invalid parents of role", []rmap.Rmap{rmap.NewFromMap(map[string]interface{}{
+			ValidationPointerKey: "/" + ParentsKey,
+			ValidationKeywordKey: ParentsKey,
+			ValidationMessageKey: fmt.Sprintf("cycle in role parents: %s", strings.Join(cycle, " -> ")),
+		})})
+	}
+
+	return nil
+}
+
+// findRoleCycle walks parents of role depth-first and returns path of role UUIDs ending with role already in path, or nil if there is no cycle
+func (r *Registry) findRoleCycle(role rmap.Rmap, path []string) ([]string, error) {
+	if !role.Exists(ParentsKey) {
+		return nil, nil
+	}
+
+	parents, err := role.GetIterable(ParentsKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "role.GetIterable() failed")
+	}
+
+	for _, parentI := range parents {
+		parentID, ok := parentI.(string)
+		if !ok {
+			return nil, fmt.Errorf("parent role is not string: %v", parentI)
+		}
+
+		parentPath := append(append([]string{}, path...), parentID)
+
+		for _, id := range path {
+			if id == parentID {
+				return parentPath, nil
+			}
+		}
+
+		parent, err := r.GetAsset(RoleAssetName, parentID, false, false)
+		if err != nil {
+			return nil, errors.Wrap(err, "r.GetAsset() failed")
+		}
+
+		if parent.IsEmpty() {
+			continue
+		}
+
+		cycle, err := r.findRoleCycle(parent, parentPath)
+		if err != nil {
+			return nil, err
+		}
+
+		if cycle != nil {
+			return cycle, nil
+		}
+	}
+
+	return nil, nil
+}
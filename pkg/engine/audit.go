@@ -0,0 +1,241 @@
+package engine
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	. "github.com/KompiTech/fabric-cc-core/v2/pkg/konst"
+	"github.com/KompiTech/rmap"
+	"github.com/pkg/errors"
+)
+
+// auditKeyTimeFormat is RFC 3339 with fixed number of fractional digits, so it can be sorted as string
+const auditKeyTimeFormat = "2006-01-02T15:04:05.000000000Z07:00"
+
+// getAuditKey returns key of audit record: XXXAUDIT:<timestamp>:<txid>
+// it is simple key, not composite one, so records can be read by range of timestamps
+// key with empty txID sorts before all records with the same timestamp
+func getAuditKey(timestamp time.Time, txID string) string {
+	return AuditRecordPrefix + ":" + timestamp.UTC().Format(auditKeyTimeFormat) + ":" + txID
+}
+
+// isAuditEnabled returns true, if asset name is configured to record its changes in audit trail
+// internal assets controlling access are always audited
+func isAuditEnabled(ctx ContextInterface, name string) bool {
+	name = strings.ToLower(name)
+
+	switch name {
+	case IdentityAssetName, RoleAssetName, GroupAssetName, ProposalAssetName:
+		return true
+	}
+
+	return ctx.GetConfiguration().AuditWhitelist.Exists(name)
+}
+
+// markDirect records, that backend skipped business logic in current TX, audit record of TX is marked as direct
+func markDirect(ctx ContextInterface) {
+	ctx.Set(DirectKey, true)
+}
+
+// makeConfigAuditChange returns item of audit record for new version of registry item or singleton, key is AuditRegistryKey or AuditSingletonKey
+func makeConfigAuditChange(key, name string, version int, isCreate bool) rmap.Rmap {
+	operation := EventUpdateOperation
+	if isCreate {
+		operation = EventCreateOperation
+	}
+
+	return rmap.NewFromMap(map[string]interface{}{
+		key:               strings.ToLower(name),
+		AuditVersionKey:   version,
+		AuditOperationKey: operation,
+	})
+}
+
+// escapeJPtrToken escapes key of JSON object to be used as JSON pointer reference token (RFC 6901)
+func escapeJPtrToken(token string) string {
+	return strings.Replace(strings.Replace(token, "~", "~0", -1), JPtrSeparator, "~1", -1)
+}
+
+// getChangedPointers appends JSON pointers of values, that differ between pre and post, to pointers
+// objects are compared key by key, any other values (including arrays) are compared as a whole
+func getChangedPointers(pointer string, pre, post interface{}, pointers *[]string) {
+	preMap, preIsMap := pre.(map[string]interface{})
+	postMap, postIsMap := post.(map[string]interface{})
+
+	if !preIsMap || !postIsMap {
+		if !reflect.DeepEqual(pre, post) {
+			*pointers = append(*pointers, pointer)
+		}
+		return
+	}
+
+	keys := make([]string, 0, len(preMap)+len(postMap))
+	for key := range preMap {
+		keys = append(keys, key)
+	}
+	for key := range postMap {
+		if _, exists := preMap[key]; !exists {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if pointer == "" && key == AssetRevisionKey {
+			// revision is changed by every TX, it does not say anything about the change
+			continue
+		}
+
+		getChangedPointers(pointer+JPtrSeparator+escapeJPtrToken(key), preMap[key], postMap[key], pointers)
+	}
+}
+
+// makeAuditChange converts recorded change to item of audit record
+func makeAuditChange(change assetChange) (rmap.Rmap, error) {
+	asset := change.asset()
+
+	docType, err := AssetGetDocType(asset)
+	if err != nil {
+		return rmap.Rmap{}, errors.Wrap(err, "konst.AssetGetDocType() failed")
+	}
+
+	id, err := AssetGetID(asset)
+	if err != nil {
+		return rmap.Rmap{}, errors.Wrap(err, "konst.AssetGetID() failed")
+	}
+
+	item := rmap.NewFromMap(map[string]interface{}{
+		AssetDocTypeKey:   strings.ToUpper(docType),
+		AssetIdKey:        id,
+		AuditOperationKey: change.operation,
+	})
+
+	// changed fields of private data are not recorded, audit trail is stored in public state
+	if change.destination == StateDestinationValue && change.operation != EventDeleteOperation {
+		pointers := []string{}
+		getChangedPointers("", change.pre.Mapa, change.post.Mapa, &pointers)
+		item.Mapa[AuditPointersKey] = pointers
+	}
+
+	return item, nil
+}
+
+// addProposalAudit sets requester and proposed function to audit record of TX, that executed approved proposal
+// proposal with its approvers is added to record
+func addProposalAudit(record, proposal rmap.Rmap) error {
+	id, err := AssetGetID(proposal)
+	if err != nil {
+		return errors.Wrap(err, "konst.AssetGetID() failed")
+	}
+
+	requestedBy, err := proposal.GetString(ProposalRequestedByKey)
+	if err != nil {
+		return errors.Wrap(err, "proposal.GetString() failed")
+	}
+
+	function, err := proposal.GetString(ProposalFunctionKey)
+	if err != nil {
+		return errors.Wrap(err, "proposal.GetString() failed")
+	}
+
+	approvals, err := proposal.GetIterable(ProposalApprovalsKey)
+	if err != nil {
+		return errors.Wrap(err, "proposal.GetIterable() failed")
+	}
+
+	approvedBy := make([]interface{}, 0, len(approvals))
+	for _, approvalI := range approvals {
+		approval, err := rmap.NewFromInterface(approvalI)
+		if err != nil {
+			return errors.Wrap(err, "rmap.NewFromInterface() failed")
+		}
+
+		approvedBy = append(approvedBy, approval.Mapa[AssetFingerprintKey])
+	}
+
+	record.Mapa[AssetFingerprintKey] = requestedBy
+	record.Mapa[AuditFunctionKey] = function
+	record.Mapa[AuditProposalKey] = map[string]interface{}{
+		AssetIdKey:         id,
+		AuditApprovedByKey: approvedBy,
+	}
+
+	return nil
+}
+
+// flushAudit stores audit record with all changes of audited assets done in TX
+// it is called when TX ends successfully, so failed TX are not recorded, record is never overwritten
+func flushAudit(ctx ContextInterface) error {
+	reg := ctx.GetRegistry()
+
+	changes := []interface{}{}
+	for _, key := range reg.changeKeys {
+		change := *reg.changes[key]
+
+		docType, err := AssetGetDocType(change.asset())
+		if err != nil {
+			return errors.Wrap(err, "konst.AssetGetDocType() failed")
+		}
+
+		if !isAuditEnabled(ctx, docType) {
+			continue
+		}
+
+		item, err := makeAuditChange(change)
+		if err != nil {
+			return errors.Wrap(err, "makeAuditChange() failed")
+		}
+
+		changes = append(changes, item.Mapa)
+	}
+
+	// registry items and singletons are always audited
+	for _, change := range reg.configChanges {
+		changes = append(changes, change.Mapa)
+	}
+
+	if len(changes) == 0 {
+		return nil
+	}
+
+	myFP, err := GetMyFingerprint(ctx)
+	if err != nil {
+		return errors.Wrap(err, "GetMyFingerprint() failed")
+	}
+
+	now, err := ctx.Time()
+	if err != nil {
+		return errors.Wrap(err, "ctx.Time() failed")
+	}
+
+	timestamp := now.UTC().Format(time.RFC3339)
+	funcName, _ := ctx.Stub().GetFunctionAndParameters()
+	txID := ctx.Stub().GetTxID()
+
+	record := rmap.NewFromMap(map[string]interface{}{
+		AssetFingerprintKey: myFP,
+		AuditFunctionKey:    funcName,
+		AuditTimestampKey:   timestamp,
+		AuditTxIdKey:        txID,
+		AuditChangesKey:     changes,
+	})
+
+	if proposal, isExecuted := ctx.Get(ExecutedProposalKey).(rmap.Rmap); isExecuted {
+		// changes were done by proposed function with identity of requester, approval only triggered it
+		if err := addProposalAudit(record, proposal); err != nil {
+			return errors.Wrap(err, "addProposalAudit() failed")
+		}
+	}
+
+	isDirect, _ := ctx.Get(DirectKey).(bool)
+	record.Mapa[AuditDirectKey] = isDirect
+
+	// timestamp with fixed width is part of key, so records are listed in chronological order
+	if err := putRmapToState(ctx, getAuditKey(now, txID), true, record); err != nil {
+		return errors.Wrap(err, "putRmapToState() failed")
+	}
+
+	return nil
+}
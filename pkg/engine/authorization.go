@@ -0,0 +1,82 @@
+package engine
+
+import (
+	"github.com/KompiTech/fabric-cc-core/v2/pkg/kompiguard"
+	. "github.com/KompiTech/fabric-cc-core/v2/pkg/konst"
+	"github.com/KompiTech/rmap"
+	"github.com/pkg/errors"
+)
+
+// Authorization is authorization context of current identity for one transaction
+// resolved identity is built once and reused by all access checks in transaction, checks are decided by Authorizer from configuration
+type Authorization struct {
+	ctx        ContextInterface
+	authorizer Authorizer
+	identity   rmap.Rmap
+	subject    string
+}
+
+// newAuthorization resolves current identity
+func newAuthorization(ctx ContextInterface) (*Authorization, error) {
+	thisIdentity, err := ctx.GetRegistry().GetThisIdentityResolved()
+	if err != nil {
+		return nil, errors.Wrap(err, "reg.GetThisIdentityResolved() failed")
+	}
+
+	subject, err := AssetGetID(thisIdentity)
+	if err != nil {
+		return nil, errors.Wrap(err, "konst.AssetGetID(thisIdentity) failed")
+	}
+
+	return &Authorization{
+		ctx:        ctx,
+		authorizer: getAuthorizer(ctx),
+		identity:   thisIdentity,
+		subject:    subject,
+	}, nil
+}
+
+// invalidateAuthorization drops authorization context of transaction, so it is built again on next access check
+// it must be called, when identity, role or group is changed, because any of them can change policy of current identity
+func invalidateAuthorization(ctx ContextInterface, docType string) {
+	switch docType {
+	case IdentityAssetName, RoleAssetName, GroupAssetName:
+		ctx.Set(AuthorizationKey, nil)
+		ctx.Set(KompiGuardsKey, nil)
+	}
+}
+
+// Identity returns resolved identity of current user, it must not be modified
+func (a *Authorization) Identity() rmap.Rmap {
+	return a.identity
+}
+
+// Subject returns fingerprint of current identity used as subject in policy
+func (a *Authorization) Subject() string {
+	return a.subject
+}
+
+// EnforceCustom checks if action is allowed on object, that is not related to any asset
+func (a *Authorization) EnforceCustom(object, action string) (bool, string, error) {
+	return a.authorizer.EnforceCustom(a.ctx, a.identity, object, action, nil)
+}
+
+// EnforceAsset checks if action is allowed on asset, overrides of asset are evaluated too
+func (a *Authorization) EnforceAsset(asset rmap.Rmap, action string) (bool, string, error) {
+	return a.authorizer.EnforceAsset(a.ctx, a.identity, asset, action)
+}
+
+// EnforceField checks if action is allowed on field on JSON pointer in asset, overrides of asset are evaluated too
+func (a *Authorization) EnforceField(asset rmap.Rmap, pointer, action string) (bool, string, error) {
+	object, err := AssetGetCasbinObject(asset)
+	if err != nil {
+		return false, "", errors.Wrap(err, "AssetGetCasbinObject(asset) failed")
+	}
+
+	return a.authorizer.EnforceCustom(a.ctx, a.identity, kompiguard.FieldObject(object, pointer), action, &asset)
+}
+
+// FilterAssets replaces assets, that cannot be read, by censored object with ID and reason
+func (a *Authorization) FilterAssets(assets []rmap.Rmap, action string) ([]rmap.Rmap, error) {
+	return a.authorizer.FilterAssets(a.ctx, a.identity, assets, action)
+}
@@ -0,0 +1,179 @@
+package engine
+
+import (
+	"fmt"
+
+	"github.com/KompiTech/fabric-cc-core/v2/pkg/kompiguard"
+	. "github.com/KompiTech/fabric-cc-core/v2/pkg/konst"
+	"github.com/KompiTech/rmap"
+	"github.com/pkg/errors"
+)
+
+// Authorizer is backend deciding, if identity is granted action on asset, on its field or on custom object
+// it is set in Configuration, KompiGuardAuthorizer is used when it is nil
+// identity passed to all methods is resolved by Registry.GetThisIdentityResolved or Registry.GetIdentityResolved
+type Authorizer interface {
+	// EnforceAsset checks if action is allowed on asset for identity
+	// returns (granted, reason, error), reason contains which permission is required, when action is not granted
+	EnforceAsset(ctx ContextInterface, identity, asset rmap.Rmap, action string) (bool, string, error)
+
+	// EnforceCustom checks if action is allowed on object for identity
+	// asset is optional, it is asset that object belongs to, for example for object of restricted field
+	EnforceCustom(ctx ContextInterface, identity rmap.Rmap, object, action string, asset *rmap.Rmap) (bool, string, error)
+
+	// FilterAssets keeps order of assets and replaces assets, that identity is not granted action on, by their ID and reason in FilteredKey
+	FilterAssets(ctx ContextInterface, identity rmap.Rmap, assets []rmap.Rmap, action string) ([]rmap.Rmap, error)
+
+	// ExplainAccess returns explanation, why action on object was granted or denied to identity, it is output of explainAccess function
+	ExplainAccess(ctx ContextInterface, identity rmap.Rmap, object, action string, asset *rmap.Rmap) (rmap.Rmap, error)
+}
+
+// getAuthorizer returns Authorizer from configuration or default KompiGuardAuthorizer
+func getAuthorizer(ctx ContextInterface) Authorizer {
+	if authorizer := ctx.GetConfiguration().Authorizer; authorizer != nil {
+		return authorizer
+	}
+
+	return KompiGuardAuthorizer{}
+}
+
+// KompiGuardAuthorizer is default Authorizer using kompiguard with roles, grants and overrides
+// casbin model is loaded from casbin_model singleton, if it exists, otherwise konst.CasbinModel is used
+// kompiguard with loaded roles is built once per identity and transaction and reused for all checks of that identity
+type KompiGuardAuthorizer struct{}
+
+// guard returns kompiguard with loaded roles of identity and subject of identity
+func (KompiGuardAuthorizer) guard(ctx ContextInterface, identity rmap.Rmap) (kompiguard.KompiGuard, string, error) {
+	subject, err := AssetGetID(identity)
+	if err != nil {
+		return kompiguard.KompiGuard{}, "", errors.Wrap(err, "konst.AssetGetID(identity) failed")
+	}
+
+	guards, _ := ctx.Get(KompiGuardsKey).(map[string]kompiguard.KompiGuard)
+	if kmpg, exists := guards[subject]; exists {
+		return kmpg, subject, nil
+	}
+
+	kmpg, err := newIdentityKompiGuard(ctx, subject)
+	if err != nil {
+		return kompiguard.KompiGuard{}, "", errors.Wrap(err, "newIdentityKompiGuard() failed")
+	}
+
+	if err := kmpg.LoadRoles(identity); err != nil {
+		return kompiguard.KompiGuard{}, "", errors.Wrap(err, "kmpg.LoadRoles() failed")
+	}
+
+	if guards == nil {
+		guards = map[string]kompiguard.KompiGuard{}
+		ctx.Set(KompiGuardsKey, guards)
+	}
+	guards[subject] = kmpg
+
+	return kmpg, subject, nil
+}
+
+func (a KompiGuardAuthorizer) EnforceAsset(ctx ContextInterface, identity, asset rmap.Rmap, action string) (bool, string, error) {
+	object, err := AssetGetCasbinObject(asset)
+	if err != nil {
+		return false, "", errors.Wrap(err, "AssetGetCasbinObject(asset) failed")
+	}
+
+	return a.EnforceCustom(ctx, identity, object, action, &asset)
+}
+
+func (a KompiGuardAuthorizer) EnforceCustom(ctx ContextInterface, identity rmap.Rmap, object, action string, asset *rmap.Rmap) (bool, string, error) {
+	kmpg, subject, err := a.guard(ctx, identity)
+	if err != nil {
+		return false, "", errors.Wrap(err, "a.guard() failed")
+	}
+
+	return kmpg.EnforceCustom(object, subject, action, asset)
+}
+
+func (a KompiGuardAuthorizer) FilterAssets(ctx ContextInterface, identity rmap.Rmap, assets []rmap.Rmap, action string) ([]rmap.Rmap, error) {
+	kmpg, subject, err := a.guard(ctx, identity)
+	if err != nil {
+		return nil, errors.Wrap(err, "a.guard() failed")
+	}
+
+	return kmpg.FilterAssetsOfSubject(assets, subject, action)
+}
+
+func (KompiGuardAuthorizer) ExplainAccess(ctx ContextInterface, identity rmap.Rmap, object, action string, asset *rmap.Rmap) (rmap.Rmap, error) {
+	subject, err := AssetGetID(identity)
+	if err != nil {
+		return rmap.Rmap{}, errors.Wrap(err, "konst.AssetGetID(identity) failed")
+	}
+
+	// explanation loads overrides without removing them, so cached kompiguard is not used
+	kmpg, err := newIdentityKompiGuard(ctx, subject)
+	if err != nil {
+		return rmap.Rmap{}, errors.Wrap(err, "newIdentityKompiGuard() failed")
+	}
+
+	return kmpg.Explain(identity, object, action, asset)
+}
+
+// newIdentityKompiGuard returns kompiguard with casbin model of chaincode and time of transaction
+// attributes of certificate are known only for current identity, so they are set only when subject is fingerprint of current identity
+// superuser shortcut is audited only for current identity, because only current identity performs actions in TX
+func newIdentityKompiGuard(ctx ContextInterface, subject string) (kompiguard.KompiGuard, error) {
+	myFP, err := GetMyFingerprint(ctx)
+	if err != nil {
+		return kompiguard.KompiGuard{}, errors.Wrap(err, "GetMyFingerprint() failed")
+	}
+
+	if subject == myFP {
+		return NewKompiGuard(ctx)
+	}
+
+	modelText, err := getCasbinModel(ctx)
+	if err != nil {
+		return kompiguard.KompiGuard{}, errors.Wrap(err, "getCasbinModel() failed")
+	}
+
+	kmpg, err := kompiguard.NewWithModel(modelText)
+	if err != nil {
+		return kompiguard.KompiGuard{}, errors.Wrap(err, "kompiguard.NewWithModel() failed")
+	}
+
+	now, err := ctx.Time()
+	if err != nil {
+		return kompiguard.KompiGuard{}, errors.Wrap(err, "ctx.Time() failed")
+	}
+
+	kmpg.SetTime(now)
+	kmpg.SetSuperuserRoles(getSuperuserRoles(ctx))
+	return kmpg, nil
+}
+
+// getCasbinModel returns text of casbin model from casbin_model singleton or default konst.CasbinModel, when singleton does not exist
+func getCasbinModel(ctx ContextInterface) (string, error) {
+	reg := ctx.GetRegistry()
+
+	exists, err := reg.ExistsSingleton(CasbinModelSingletonName, -1)
+	if err != nil {
+		return "", errors.Wrap(err, "reg.ExistsSingleton() failed")
+	}
+
+	if !exists {
+		return CasbinModel, nil
+	}
+
+	singleton, _, err := reg.GetSingleton(CasbinModelSingletonName, -1)
+	if err != nil {
+		return "", errors.Wrap(err, "reg.GetSingleton() failed")
+	}
+
+	return getSingletonCasbinModel(singleton)
+}
+
+// getSingletonCasbinModel returns text of casbin model from value of casbin_model singleton
+func getSingletonCasbinModel(singleton rmap.Rmap) (string, error) {
+	modelText, err := singleton.GetJPtrString("/" + SingletonValueKey + "/" + CasbinModelSingletonKey)
+	if err != nil {
+		return "", fmt.Errorf("singleton: %s must contain casbin model as string in key: %s", CasbinModelSingletonName, CasbinModelSingletonKey)
+	}
+
+	return modelText, nil
+}
@@ -1,6 +1,7 @@
 package engine
 
 import (
+	stderrors "errors"
 	"strings"
 
 	. "github.com/KompiTech/fabric-cc-core/v2/pkg/konst"
@@ -110,6 +111,12 @@ func (be BusinessExecutor) executePolicy(ctx ContextInterface, policy []Business
 	for index, fnc := range policy {
 		tmpNext, err := fnc(ctx, assetPrePatch, next)
 		if err != nil {
+			var httpErr HTTPErr
+			if !stderrors.As(err, &httpErr) {
+				// error without HTTP status code is considered failure of business logic itself
+				return rmap.Rmap{}, ErrorBlogicFailed(errors.Wrapf(err, "func index: %d returned error", index))
+			}
+
 			return rmap.Rmap{}, errors.Wrapf(err, "func index: %d returned error", index)
 		}
 		next = tmpNext
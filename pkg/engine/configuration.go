@@ -8,15 +8,27 @@ import (
 
 type IDFunc func(cert *x509.Certificate) (string, error)
 
+// MigrationFunc migrates asset instance to version of registry item that references it by name in its migration definition
+// asset already has xxx_version set to the new version, returned asset is validated against schema of that version
+type MigrationFunc func(ctx ContextInterface, asset rmap.Rmap) (rmap.Rmap, error)
+
 // Configuration is used to manage configuration of this particular dynamic chaincode (previously called Engine)
 type Configuration struct {
-	BusinessExecutor          BusinessExecutor // Abstraction for executing business logic
-	FunctionExecutor          FunctionExecutor // Abstraction for executing generic functionality
-	RecursiveResolveWhitelist rmap.Rmap        // Rmap of asset names that have recursive resolve enabled
-	ResolveBlacklist          rmap.Rmap        // Rmap of asset names that are forbidden from being resolved
-	ResolveFieldsBlacklist    rmap.Rmap        // Rmap of asset name -> list of fields to not resolve
-	CurrentIDFunc             IDFunc           // Function to get identity fingerprint
-	PreviousIDFunc            *IDFunc          // Previous function to get identity fingerprint when migration is desired
+	BusinessExecutor          BusinessExecutor         // Abstraction for executing business logic
+	FunctionExecutor          FunctionExecutor         // Abstraction for executing generic functionality
+	RecursiveResolveWhitelist rmap.Rmap                // Rmap of asset names that have recursive resolve enabled
+	ResolveBlacklist          rmap.Rmap                // Rmap of asset names that are forbidden from being resolved
+	ResolveFieldsBlacklist    rmap.Rmap                // Rmap of asset name -> list of fields to not resolve
+	CurrentIDFunc             IDFunc                   // Function to get identity fingerprint
+	PreviousIDFunc            *IDFunc                  // Previous function to get identity fingerprint when migration is desired
+	EventWhitelist            rmap.Rmap                // Rmap of asset names that emit chaincode event when created, updated, deleted or migrated
+	EventDiffWhitelist        rmap.Rmap                // Rmap of asset names that include JSON merge-diff in chaincode event (state destination only)
+	AuditWhitelist            rmap.Rmap                // Rmap of asset names, whose changes are recorded in append-only audit trail
+	Migrations                map[string]MigrationFunc // Named migration functions usable in migration definition of registry items
+	Authorizer                Authorizer               // Backend deciding access of identities, KompiGuardAuthorizer is used when nil
+	SuperuserRoles            []string                 // UUIDs of roles, whose holders are granted every action by default Authorizer, konst.SuperuserRoleUUID is used when nil
+	DisableSuperuser          bool                     // Disables superuser shortcut, superuser role created by bootstrap gets grants to manage chaincode instead
+	AuditSuperuser            bool                     // Stores audit record and emits event, when action is granted to current identity only by its superuser role (break-glass)
 
 	// SchemaDefinitionCompatibility is legacy setting, to allow the chaincode to work with older JSONSchemas (draft-07 and older) that are using reusable definitions.
 	// Previously, any location for the definitions can be used, but JSONSchema newer than draft-07 allows only "$defs" key to be used.
@@ -26,4 +38,9 @@ type Configuration struct {
 	// If SchemaDefinitionCompatibility is empty string, then no replacements are done.
 	// This is the default setting, as the issue only occurs with legacy schemas.
 	SchemaDefinitionCompatibility string
+
+	// LegacyErrorFormat is compatibility setting for clients, that parse errors in legacy format "message|||NNN", where NNN is HTTP status code.
+	// If it is false, chaincode errors are returned as JSON document with stable error code, HTTP status code, message and details.
+	// This is the default setting.
+	LegacyErrorFormat bool
 }
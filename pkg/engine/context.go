@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/KompiTech/fabric-cc-core/v2/pkg/konst"
+	"github.com/KompiTech/rmap"
 	"github.com/hyperledger/fabric-chaincode-go/shim"
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 )
@@ -29,6 +30,22 @@ func (ctx *Context) GetRegistry() *Registry {
 	return ctx.Get(konst.RegistryKey).(*Registry)
 }
 
+// GetAuthorization returns authorization context of current identity
+// it is built on first access check in transaction and reused until identity, role or group is changed
+func (ctx *Context) GetAuthorization() (*Authorization, error) {
+	if auth, ok := ctx.Get(konst.AuthorizationKey).(*Authorization); ok {
+		return auth, nil
+	}
+
+	auth, err := newAuthorization(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx.Set(konst.AuthorizationKey, auth)
+	return auth, nil
+}
+
 func (ctx *Context) Get(key string) interface{} {
 	return ctx.store[key]
 }
@@ -41,6 +58,22 @@ func (ctx *Context) Set(key string, value interface{}) {
 	ctx.store[key] = value
 }
 
+// EmitEvent queues custom event. Fabric keeps only last event set in TX, so all queued events are emitted in one envelope when TX ends
+func (ctx *Context) EmitEvent(name string, payload rmap.Rmap) error {
+	if name == "" {
+		return errors.New("event name cannot be empty string")
+	}
+
+	events, _ := ctx.Get(konst.EventsKey).([]rmap.Rmap)
+	events = append(events, rmap.NewFromMap(map[string]interface{}{
+		konst.EventNameKey:    name,
+		konst.EventPayloadKey: payload.Copy().Mapa,
+	}))
+	ctx.Set(konst.EventsKey, events)
+
+	return nil
+}
+
 func (ctx *Context) Stub() shim.ChaincodeStubInterface {
 	return ctx.GetStub()
 }
@@ -58,20 +91,31 @@ func (ctx *Context) Time() (time.Time, error) {
 // this is related code not existent in current impl anymore
 // https://github.com/KompiTech/fabric-cc-core/v2/src/blob/6ba28e33e1f7ec6d81f561a97c6a788f25971259/engine/router.go#L50
 func (ctx *Context) getArgNames() []string {
+	method, _ := ctx.Stub().GetFunctionAndParameters()
+	return getFunctionArgNames(method)
+}
+
+// getFunctionArgNames returns names of positional arguments of chaincode function, nil is returned for unknown function
+func getFunctionArgNames(method string) []string {
 	argInfo := map[string][]string{
 		"init":                 {"input"},
+		"assetBatch":           {"operations"},
 		"assetCreate":          {"name", "data", "version", "id"},
 		"assetCreateDirect":    {"name", "data", "version", "id"},
-		"assetDelete":          {"name", "id"},
-		"assetDeleteDirect":    {"name", "id"},
+		"assetDelete":          {"name", "id", "revision"},
+		"assetDeleteDirect":    {"name", "id", "revision"},
 		"assetGet":             {"name", "id", "resolve", "data"},
 		"assetGetDirect":       {"name", "id", "resolve"},
 		"assetHistory":         {"name", "id"},
-		"assetMigrate":         {"name", "id", "patch", "version"},
-		"assetUpdate":          {"name", "id", "patch"},
-		"assetUpdateDirect":    {"name", "id", "patch"},
+		"assetMigrate":         {"name", "id", "patch", "version", "revision"},
+		"assetMigrateAll":      {"name", "version", "bookmark", "limit"},
+		"assetReferrers":       {"name", "id", "bookmark"},
+		"assetRestore":         {"name", "id"},
+		"assetUpdate":          {"name", "id", "patch", "revision"},
+		"assetUpdateDirect":    {"name", "id", "patch", "revision"},
 		"assetQuery":           {"name", "query", "resolve"},
 		"assetQueryDirect":     {"name", "query", "resolve"},
+		"auditQuery":           {"input"},
 		"changelogGet":         {"number"},
 		"changelogList":        {},
 		"functionInvoke":       {"name", "input"},
@@ -83,8 +127,11 @@ func (ctx *Context) getArgNames() []string {
 		"identityQuery":        {"query", "resolve"},
 		"identityCreateDirect": {"data", "id"},
 		"identityUpdateDirect": {"id", "patch"},
+		"proposalCreate":       {"input"},
+		"proposalApprove":      {"id"},
+		"proposalReject":       {"id", "input"},
 		"registryGet":          {"name", "version"},
-		"registryUpsert":       {"name", "data"},
+		"registryUpsert":       {"name", "data", "force"},
 		"registryList":         {},
 		"roleGet":              {"id", "data"},
 		"roleCreate":           {"data", "id"},
@@ -94,9 +141,8 @@ func (ctx *Context) getArgNames() []string {
 		"singletonUpsert":      {"name", "data"},
 		"singletonList":        {},
 	}
-	method, _ := ctx.Stub().GetFunctionAndParameters()
 	namedArgs := argInfo[method]
-	if namedArgs == nil {
+	if namedArgs == nil && method != "" {
 		// older cc-core used always first lowercase letter in method name
 		lowerMethod := strings.ToLower(string(method[0])) + method[1:]
 		namedArgs = argInfo[lowerMethod]
@@ -180,6 +226,10 @@ func (ctx *Context) mapArgs() map[string]interface{} {
 	outMap := make(map[string]interface{}, len(argNames))
 
 	for argIdx, argName := range ctx.getArgNames() {
+		if len(args) <= argIdx+1 {
+			// optional trailing params were not sent
+			break
+		}
 		outMap[argName] = args[argIdx+1] // first arg is always function name
 	}
 
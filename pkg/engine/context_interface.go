@@ -3,6 +3,7 @@ package engine
 import (
 	"time"
 
+	"github.com/KompiTech/rmap"
 	"github.com/hyperledger/fabric-chaincode-go/shim"
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 )
@@ -14,9 +15,11 @@ type ContextInterface interface {
 	SetConfFunc(func() Configuration)
 	GetConfiguration() Configuration
 	GetRegistry() *Registry
+	GetAuthorization() (*Authorization, error)
 	Get(key string) interface{}
 	Set(key string, value interface{})
 	Stub() shim.ChaincodeStubInterface
+	EmitEvent(name string, payload rmap.Rmap) error
 	Time() (time.Time, error)
 	Params() map[string]interface{}
 	Param(name string) (interface{}, error)
@@ -0,0 +1,186 @@
+package engine
+
+import (
+	"encoding/json"
+	"strings"
+
+	. "github.com/KompiTech/fabric-cc-core/v2/pkg/konst"
+	"github.com/KompiTech/rmap"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/pkg/errors"
+)
+
+// dryRunStub wraps stub of TX and records all writes and event instead of performing them
+// only keys and operations of writes are recorded, written values are returned from changes of assets, see makeDryRunChanges
+// reads are passed to wrapped stub, so they do not see writes done in the same TX, the same as in Fabric
+type dryRunStub struct {
+	shim.ChaincodeStubInterface
+	writes []interface{}
+	event  rmap.Rmap
+}
+
+func newDryRunStub(stub shim.ChaincodeStubInterface) *dryRunStub {
+	return &dryRunStub{
+		ChaincodeStubInterface: stub,
+		writes:                 []interface{}{},
+	}
+}
+
+func (s *dryRunStub) PutState(key string, value []byte) error {
+	s.record(DryRunPutOperation, "", key)
+	return nil
+}
+
+func (s *dryRunStub) DelState(key string) error {
+	s.record(DryRunDeleteOperation, "", key)
+	return nil
+}
+
+func (s *dryRunStub) PutPrivateData(collection string, key string, value []byte) error {
+	s.record(DryRunPutOperation, collection, key)
+	return nil
+}
+
+func (s *dryRunStub) DelPrivateData(collection string, key string) error {
+	s.record(DryRunDeleteOperation, collection, key)
+	return nil
+}
+
+func (s *dryRunStub) SetStateValidationParameter(key string, ep []byte) error {
+	return nil
+}
+
+func (s *dryRunStub) SetPrivateDataValidationParameter(collection, key string, ep []byte) error {
+	return nil
+}
+
+func (s *dryRunStub) SetEvent(name string, payload []byte) error {
+	s.event = rmap.NewFromMap(map[string]interface{}{
+		EventNameKey:    name,
+		EventPayloadKey: decodeDryRunValue(payload),
+	})
+	return nil
+}
+
+// record appends one intended write, composite keys are split to object type and attributes to be readable
+func (s *dryRunStub) record(operation, collection, key string) {
+	write := map[string]interface{}{
+		DryRunOperationKey: operation,
+		DryRunKeyKey:       key,
+	}
+
+	if collection != "" {
+		write[DryRunCollectionKey] = collection
+	}
+
+	if len(key) > 0 && key[0] == 0 {
+		if objectType, attributes, err := s.SplitCompositeKey(key); err == nil {
+			attributesI := make([]interface{}, 0, len(attributes))
+			for _, attribute := range attributes {
+				attributesI = append(attributesI, attribute)
+			}
+
+			write[DryRunKeyKey] = objectType
+			write[DryRunAttributesKey] = attributesI
+		}
+	}
+
+	s.writes = append(s.writes, write)
+}
+
+// output returns all recorded writes and event
+func (s *dryRunStub) output() rmap.Rmap {
+	output := rmap.NewFromMap(map[string]interface{}{
+		DryRunWritesKey: s.writes,
+	})
+
+	if s.event.Mapa != nil {
+		output.Mapa[DryRunEventKey] = s.event.Mapa
+	}
+
+	return output
+}
+
+// decodeDryRunValue returns value as decoded JSON, if possible, or as a string otherwise
+func decodeDryRunValue(value []byte) interface{} {
+	var decoded interface{}
+	if err := json.Unmarshal(value, &decoded); err != nil {
+		return string(value)
+	}
+
+	return decoded
+}
+
+// isDryRun returns true, if TX is dry run, all changes of assets are then recorded to be returned
+func isDryRun(ctx ContextInterface) bool {
+	_, isDryRun := ctx.GetStub().(*dryRunStub)
+	return isDryRun
+}
+
+// makeDryRunChanges returns changes of assets done by TX with asset value after TX, in order of first modification
+// restricted fields, that this identity cannot read, are masked the same as when asset is read
+// changes of private data are left out, value of deleted asset is not returned
+func makeDryRunChanges(ctx ContextInterface) ([]interface{}, error) {
+	reg := ctx.GetRegistry()
+
+	changes := make([]interface{}, 0, len(reg.changeKeys))
+	for _, key := range reg.changeKeys {
+		change := *reg.changes[key]
+		if change.destination != StateDestinationValue {
+			continue
+		}
+
+		asset := change.asset()
+
+		docType, err := AssetGetDocType(asset)
+		if err != nil {
+			return nil, errors.Wrap(err, "konst.AssetGetDocType() failed")
+		}
+
+		id, err := AssetGetID(asset)
+		if err != nil {
+			return nil, errors.Wrap(err, "konst.AssetGetID() failed")
+		}
+
+		item := map[string]interface{}{
+			AssetDocTypeKey:    strings.ToUpper(docType),
+			AssetIdKey:         id,
+			DryRunOperationKey: change.operation,
+		}
+
+		if !change.post.IsEmpty() {
+			value, err := reg.maskRestrictedFields(change.post)
+			if err != nil {
+				return nil, errors.Wrap(err, "reg.maskRestrictedFields() failed")
+			}
+
+			item[DryRunValueKey] = value.Mapa
+		}
+
+		changes = append(changes, item)
+	}
+
+	return changes, nil
+}
+
+// makeDryRunResponse appends recorded writes, changes of assets and event to response of TX
+// if response is not JSON object, it is wrapped in result key
+func makeDryRunResponse(ctx ContextInterface, ret string, stub *dryRunStub) (string, error) {
+	output, err := rmap.NewFromString(ret)
+	if err != nil {
+		output = rmap.NewFromMap(map[string]interface{}{
+			OutputResultKey: ret,
+		})
+	}
+
+	changes, err := makeDryRunChanges(ctx)
+	if err != nil {
+		return "", errors.Wrap(err, "makeDryRunChanges() failed")
+	}
+
+	dryRun := stub.output()
+	dryRun.Mapa[DryRunChangesKey] = changes
+	output.Mapa[OutputDryRunKey] = dryRun.Mapa
+
+	return output.String(), nil
+}
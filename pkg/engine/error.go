@@ -1,21 +1,52 @@
 package engine
 
 import (
+	"bytes"
+	"encoding/json"
+	stderrors "errors"
 	"fmt"
 	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	. "github.com/KompiTech/fabric-cc-core/v2/pkg/konst"
+	"github.com/KompiTech/rmap"
+	"github.com/pkg/errors"
+)
+
+// ErrorCode is stable machine-readable code of error, clients should use it instead of matching error messages
+type ErrorCode string
+
+const (
+	ErrorCodeBadRequest          ErrorCode = "BAD_REQUEST"          // request is malformed
+	ErrorCodeSchemaValidation    ErrorCode = "SCHEMA_VALIDATION"    // asset is not valid against JSONSchema
+	ErrorCodeRefNotFound         ErrorCode = "REF_NOT_FOUND"        // asset references asset, that does not exist
+	ErrorCodeForbidden           ErrorCode = "FORBIDDEN"            // identity does not have required grant
+	ErrorCodeNotFound            ErrorCode = "NOT_FOUND"            // requested entity does not exist
+	ErrorCodeConflict            ErrorCode = "CONFLICT"             // request conflicts with current state
+	ErrorCodeUnprocessableEntity ErrorCode = "UNPROCESSABLE_ENTITY" // request is well-formed, but it cannot be processed
+	ErrorCodeBlogicFailed        ErrorCode = "BLOGIC_FAILED"        // business logic function returned error
+	ErrorCodeInternal            ErrorCode = "INTERNAL"             // any other error
 )
 
-// HTTPErr is an error with custom HTTP status code
+// legacyStatusRegexp matches HTTP status suffix of legacy error format
+var legacyStatusRegexp = regexp.MustCompile(`\|\|\|\d{3}`)
+
+// HTTPErr is an error with custom HTTP status code, error code and details
 type HTTPErr struct {
-	code int
-	err  error
+	code      int
+	err       error
+	errorCode ErrorCode
+	details   map[string]interface{}
 }
 
-// Error creates new initialized HttpError
+// Error creates new initialized HttpError, error code is inferred from HTTP status code
 func Error(code int, err error) HTTPErr {
 	return HTTPErr{
-		code: code,
-		err:  err,
+		code:      code,
+		err:       err,
+		errorCode: statusErrorCode(code),
 	}
 }
 
@@ -44,7 +75,163 @@ func ErrorUnprocessableEntity(message string) HTTPErr {
 	return Error(http.StatusUnprocessableEntity, fmt.Errorf(message))
 }
 
+// ErrorPermissionDenied returns new HttpError with HTTP status code 403 and grant required for action on object in details
+func ErrorPermissionDenied(message, object, action string) HTTPErr {
+	return ErrorForbidden(message).WithDetail(ErrorGrantKey, map[string]interface{}{
+		ErrorGrantObjectKey: object,
+		ErrorGrantActionKey: strings.ToLower(action),
+	})
+}
+
+// ErrorAssetPermissionDenied returns new HttpError with HTTP status code 403 and grant required for action on asset in details
+func ErrorAssetPermissionDenied(message string, asset rmap.Rmap, action string) HTTPErr {
+	// object is only informative, it was already inferred successfully by enforcer
+	object, _ := AssetGetCasbinObject(asset)
+	return ErrorPermissionDenied(message, object, action)
+}
+
+// ErrorSchemaValidation returns new HttpError with HTTP status code 422 for asset not valid against its schema
+func ErrorSchemaValidation(message string) HTTPErr {
+	return ErrorUnprocessableEntity(message).WithCode(ErrorCodeSchemaValidation)
+}
+
+// ErrorRefNotFound returns new HttpError with HTTP status code 422 and JSON pointers of references to non-existent assets in details
+func ErrorRefNotFound(message string, pointers []string) HTTPErr {
+	pointersI := make([]interface{}, 0, len(pointers))
+	for _, pointer := range pointers {
+		pointersI = append(pointersI, pointer)
+	}
+
+	return ErrorUnprocessableEntity(message).WithCode(ErrorCodeRefNotFound).WithDetail(ErrorPointersKey, pointersI)
+}
+
+// ErrorValidationFields returns new HttpError with HTTP status code 422 and all JSONSchema validation errors in details
+func ErrorValidationFields(message string, fields []rmap.Rmap) HTTPErr {
+	fieldsI := make([]interface{}, 0, len(fields))
+	messages := make([]string, 0, len(fields))
+
+	for _, field := range fields {
+		fieldsI = append(fieldsI, field.Mapa)
+		messages = append(messages, fmt.Sprintf("%s: %s", field.Mapa[ValidationPointerKey], field.Mapa[ValidationMessageKey]))
+	}
+
+	return ErrorSchemaValidation(fmt.Sprintf("%s: %s", message, strings.Join(messages, ", "))).WithDetail(ErrorFieldsKey, fieldsI)
+}
+
+// ErrorBlogicFailed returns new HttpError with HTTP status code 500 wrapping error returned by business logic function
+func ErrorBlogicFailed(err error) HTTPErr {
+	return Error(http.StatusInternalServerError, err).WithCode(ErrorCodeBlogicFailed)
+}
+
+// WithCode returns copy of error with different error code
+func (e HTTPErr) WithCode(errorCode ErrorCode) HTTPErr {
+	e.errorCode = errorCode
+	return e
+}
+
+// WithDetail returns copy of error with detail value set under key
+func (e HTTPErr) WithDetail(key string, value interface{}) HTTPErr {
+	details := make(map[string]interface{}, len(e.details)+1)
+	for k, v := range e.details {
+		details[k] = v
+	}
+	details[key] = value
+
+	e.details = details
+	return e
+}
+
+// Status returns HTTP status code of error
+func (e HTTPErr) Status() int {
+	return e.code
+}
+
+// Code returns error code
+func (e HTTPErr) Code() ErrorCode {
+	return e.errorCode
+}
+
+// Message returns error message without HTTP status code
+func (e HTTPErr) Message() string {
+	return legacyStatusRegexp.ReplaceAllString(e.err.Error(), "")
+}
+
+// Details returns details of error, it can be nil
+func (e HTTPErr) Details() map[string]interface{} {
+	return e.details
+}
+
 // Error implements error interface
 func (e HTTPErr) Error() string {
 	return fmt.Sprintf("%s|||%d", e.err.Error(), e.code)
 }
+
+// statusErrorCode returns default error code for HTTP status code
+func statusErrorCode(code int) ErrorCode {
+	switch code {
+	case http.StatusBadRequest:
+		return ErrorCodeBadRequest
+	case http.StatusForbidden:
+		return ErrorCodeForbidden
+	case http.StatusNotFound:
+		return ErrorCodeNotFound
+	case http.StatusConflict:
+		return ErrorCodeConflict
+	case http.StatusUnprocessableEntity:
+		return ErrorCodeUnprocessableEntity
+	default:
+		return ErrorCodeInternal
+	}
+}
+
+// asHTTPErr finds HTTPErr in chain of wrapped errors
+// if there is none, because error was converted to string somewhere, HTTP status code is parsed from legacy format
+func asHTTPErr(err error) HTTPErr {
+	var httpErr HTTPErr
+	if stderrors.As(err, &httpErr) {
+		return httpErr
+	}
+
+	msg := err.Error()
+	code := http.StatusInternalServerError
+
+	if loc := legacyStatusRegexp.FindAllStringIndex(msg, -1); len(loc) > 0 {
+		last := loc[len(loc)-1]
+		code, _ = strconv.Atoi(msg[last[0]+3 : last[1]])
+	}
+
+	return Error(code, errors.Cause(err))
+}
+
+// makeErrorJSON returns JSON document describing error
+// tracingInfo is added to document, if it is not nil
+func makeErrorJSON(err error, tracingInfo *rmap.Rmap) string {
+	httpErr := asHTTPErr(err)
+
+	output := map[string]interface{}{
+		ErrorCodeKey:    httpErr.Code(),
+		ErrorStatusKey:  httpErr.Status(),
+		ErrorMessageKey: httpErr.Message(),
+		ErrorChainKey:   legacyStatusRegexp.ReplaceAllString(err.Error(), ""),
+	}
+
+	if httpErr.Details() != nil {
+		output[ErrorDetailsKey] = httpErr.Details()
+	}
+
+	if tracingInfo != nil {
+		output[ErrorTracingKey] = tracingInfo.Mapa
+	}
+
+	// HTML characters are not escaped to keep messages readable
+	buf := &bytes.Buffer{}
+	encoder := json.NewEncoder(buf)
+	encoder.SetEscapeHTML(false)
+
+	if err := encoder.Encode(output); err != nil {
+		// cannot happen, output contains only JSON compatible values
+		return err.Error()
+	}
+
+	return strings.TrimSuffix(buf.String(), "\n")
+}
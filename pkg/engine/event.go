@@ -0,0 +1,175 @@
+package engine
+
+import (
+	"strings"
+
+	. "github.com/KompiTech/fabric-cc-core/v2/pkg/konst"
+	"github.com/KompiTech/rmap"
+	"github.com/pkg/errors"
+)
+
+// assetChange is single modification of asset instance done in this TX
+// multiple modifications of the same instance are merged into one assetChange
+type assetChange struct {
+	operation   string
+	destination string
+	pre         rmap.Rmap // asset value before this TX, empty when asset is created
+	post        rmap.Rmap // asset value after this TX, empty when asset is deleted
+}
+
+// isEventEnabled returns true, if asset name is configured to emit chaincode events
+func isEventEnabled(ctx ContextInterface, name string) bool {
+	return ctx.GetConfiguration().EventWhitelist.Exists(strings.ToLower(name))
+}
+
+// isChangeRecorded returns true, if modifications of asset name are needed for chaincode event, audit trail or dry run output
+func isChangeRecorded(ctx ContextInterface, name string) bool {
+	return isEventEnabled(ctx, name) || isAuditEnabled(ctx, name) || isDryRun(ctx)
+}
+
+// recordChange remembers modification of asset instance stored under key, so it can be emitted in chaincode event when TX ends
+// pre is asset value before modification and it is used only when key was not modified previously in this TX
+func (r *Registry) recordChange(key, operation, destination string, pre, post rmap.Rmap) {
+	existing, exists := r.changes[key]
+	if !exists {
+		r.changes[key] = &assetChange{
+			operation:   operation,
+			destination: destination,
+			pre:         pre,
+			post:        post,
+		}
+		r.changeKeys = append(r.changeKeys, key)
+		return
+	}
+
+	switch {
+	case existing.operation == EventCreateOperation && operation == EventDeleteOperation:
+		// asset was created and deleted in the same TX, there is nothing to report
+		delete(r.changes, key)
+		for i, changeKey := range r.changeKeys {
+			if changeKey == key {
+				r.changeKeys = append(r.changeKeys[:i], r.changeKeys[i+1:]...)
+				break
+			}
+		}
+		return
+	case existing.operation == EventCreateOperation:
+		// any further modification of newly created asset is still a create
+	case existing.operation == EventMigrateOperation && operation == EventUpdateOperation:
+		// update after migration does not hide the migration
+	default:
+		existing.operation = operation
+	}
+
+	existing.post = post
+}
+
+// asset returns the last known value of changed asset
+func (c assetChange) asset() rmap.Rmap {
+	if c.operation == EventDeleteOperation {
+		return c.pre
+	}
+	return c.post
+}
+
+// makeChangeEvent converts recorded change to event item
+func makeChangeEvent(ctx ContextInterface, change assetChange) (rmap.Rmap, error) {
+	asset := change.asset()
+
+	docType, err := AssetGetDocType(asset)
+	if err != nil {
+		return rmap.Rmap{}, errors.Wrap(err, "konst.AssetGetDocType() failed")
+	}
+
+	id, err := AssetGetID(asset)
+	if err != nil {
+		return rmap.Rmap{}, errors.Wrap(err, "konst.AssetGetID() failed")
+	}
+
+	version, err := AssetGetVersion(asset)
+	if err != nil {
+		return rmap.Rmap{}, errors.Wrap(err, "konst.AssetGetVersion() failed")
+	}
+
+	event := rmap.NewFromMap(map[string]interface{}{
+		AssetDocTypeKey:   strings.ToUpper(docType),
+		AssetIdKey:        id,
+		EventVersionKey:   version,
+		EventOperationKey: change.operation,
+		EventTxIdKey:      ctx.Stub().GetTxID(),
+	})
+
+	withDiff := ctx.GetConfiguration().EventDiffWhitelist.Exists(strings.ToLower(docType))
+
+	// diff is never included for private data, it would leak the data to everyone listening for events
+	if withDiff && change.destination == StateDestinationValue && change.operation != EventDeleteOperation {
+		pre := change.pre
+		if pre.Mapa == nil {
+			pre = rmap.NewEmpty()
+		}
+
+		diff, err := pre.CreateMergePatch(change.post)
+		if err != nil {
+			return rmap.Rmap{}, errors.Wrap(err, "pre.CreateMergePatch() failed")
+		}
+
+		diffRm, err := rmap.NewFromBytes(diff)
+		if err != nil {
+			return rmap.Rmap{}, errors.Wrap(err, "rmap.NewFromBytes() failed")
+		}
+
+		event.Mapa[EventDiffKey] = diffRm.Mapa
+	}
+
+	return event, nil
+}
+
+// flushEvents sets one chaincode event with all asset changes recorded in this TX and all custom events queued by ctx.EmitEvent()
+// Fabric allows only one event per TX, so everything is aggregated into one envelope
+func flushEvents(ctx ContextInterface) error {
+	reg := ctx.GetRegistry()
+	queued, _ := ctx.Get(EventsKey).([]rmap.Rmap)
+
+	changes := make([]interface{}, 0, len(reg.changeKeys))
+	for _, key := range reg.changeKeys {
+		change := *reg.changes[key]
+
+		docType, err := AssetGetDocType(change.asset())
+		if err != nil {
+			return errors.Wrap(err, "konst.AssetGetDocType() failed")
+		}
+
+		// changes of assets recorded only for audit trail are not emitted
+		if !isEventEnabled(ctx, docType) {
+			continue
+		}
+
+		event, err := makeChangeEvent(ctx, change)
+		if err != nil {
+			return errors.Wrap(err, "makeChangeEvent() failed")
+		}
+
+		changes = append(changes, event.Mapa)
+	}
+
+	if len(changes) == 0 && len(queued) == 0 {
+		return nil
+	}
+
+	events := make([]interface{}, 0, len(queued))
+	for _, event := range queued {
+		events = append(events, event.Mapa)
+	}
+
+	payload := rmap.NewFromMap(map[string]interface{}{
+		EventTxIdKey:    ctx.Stub().GetTxID(),
+		EventChangesKey: changes,
+		EventEventsKey:  events,
+	})
+
+	if err := ctx.Stub().SetEvent(EventEnvelopeName, payload.Bytes()); err != nil {
+		return errors.Wrap(err, "ctx.Stub().SetEvent() failed")
+	}
+
+	return nil
+}
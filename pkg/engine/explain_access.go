@@ -0,0 +1,124 @@
+package engine
+
+import (
+	"fmt"
+
+	"github.com/KompiTech/fabric-cc-core/v2/pkg/kompiguard"
+	"github.com/KompiTech/fabric-cc-core/v2/pkg/konst"
+	"github.com/KompiTech/rmap"
+	"github.com/pkg/errors"
+)
+
+// explainAccessFunc is implementation of built-in explainAccess function to explain, why action was granted or denied to identity
+// input contains action and either object or asset name with id, identity is optional and defaults to current identity
+// when asset is specified, its overrides are evaluated too and pointer can be used to explain access to its restricted field
+// current identity must be able to read explained asset or object, otherwise its grants and overrides would be disclosed
+/*
+{
+	"identity": "<fingerprint>",
+	"object": "/incident/*",
+	"asset": "incident",
+	"id": "<uuid>",
+	"pointer": "/salary",
+	"action": "read"
+}
+*/
+// output:
+/*
+{
+	"subject": "<fingerprint>",
+	"object": "/incident/<uuid>",
+	"action": "read",
+	"identity_enabled": true,
+	"superuser": false,
+	"roles": [{"role": "<uuid>", "name": "Incident reader", "valid": true}],
+	"grants": [{"role": "<uuid>", "object": "/incident/*", "action": "read", "effect": "allow", "valid": true, "condition_met": true, "applies": true}],
+	"overrides": [{"subject": "<fingerprint>", "object": "/incident/<uuid>", "action": "read", "effect": "deny", "applies": true}],
+	"policy": {"subject": "<fingerprint>", "object": "/incident/<uuid>", "action": "read", "effect": "deny"},
+	"decision": "deny",
+	"reason": "denied by policy of subject: <fingerprint>, obj: /incident/<uuid> with effect: deny"
+}
+*/
+var explainAccessFunc = func(ctx ContextInterface, input rmap.Rmap, output rmap.Rmap) (rmap.Rmap, error) {
+	null := rmap.Rmap{}
+	reg := ctx.Get("registry").(*Registry)
+
+	action, err := input.GetString(konst.ActionKey)
+	if err != nil {
+		return null, ErrorBadRequest("'action' key is missing")
+	}
+
+	thisIdentity, err := reg.GetThisIdentityResolved()
+	if err != nil {
+		return null, errors.Wrap(err, "reg.GetThisIdentityResolved() failed")
+	}
+
+	thisFP, err := konst.AssetGetID(thisIdentity)
+	if err != nil {
+		return null, errors.Wrap(err, "konst.AssetGetID(thisIdentity) failed")
+	}
+
+	identity := thisIdentity
+	if input.Exists("identity") {
+		identityFP, err := input.GetString("identity")
+		if err != nil {
+			return null, ErrorBadRequest("'identity' key must be string")
+		}
+
+		if identityFP != thisFP {
+			identity, err = reg.GetIdentityResolved(identityFP)
+			if err != nil {
+				return null, ErrorBadRequest(fmt.Sprintf("cannot get identity for fingerprint: %s", identityFP))
+			}
+		}
+	}
+
+	var object string
+	var asset *rmap.Rmap
+
+	if input.Exists("asset") {
+		name, err := input.GetString("asset")
+		if err != nil {
+			return null, ErrorBadRequest("'asset' key must be string")
+		}
+
+		id, err := input.GetString("id")
+		if err != nil {
+			return null, ErrorBadRequest("'id' key is missing")
+		}
+
+		assetInst, err := reg.GetAsset(name, id, false, true)
+		if err != nil {
+			return null, errors.Wrap(err, "reg.GetAsset() failed")
+		}
+		asset = &assetInst
+
+		if err := enforceAssetAccess(reg, assetInst, konst.ReadAction); err != nil {
+			return null, err
+		}
+
+		object, err = konst.AssetGetCasbinObject(assetInst)
+		if err != nil {
+			return null, errors.Wrap(err, "konst.AssetGetCasbinObject() failed")
+		}
+
+		if input.Exists(konst.PointerKey) {
+			pointer, err := input.GetString(konst.PointerKey)
+			if err != nil {
+				return null, ErrorBadRequest("'pointer' key must be string")
+			}
+			object = kompiguard.FieldObject(object, pointer)
+		}
+	} else {
+		object, err = input.GetString(konst.ObjectKey)
+		if err != nil {
+			return null, ErrorBadRequest("'object' or 'asset' key is missing")
+		}
+
+		if err := enforceCustomAccess(reg, object, konst.ReadAction); err != nil {
+			return null, err
+		}
+	}
+
+	return getAuthorizer(ctx).ExplainAccess(ctx, identity, object, action, asset)
+}
@@ -0,0 +1,156 @@
+package engine
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/KompiTech/fabric-cc-core/v2/pkg/kompiguard"
+	. "github.com/KompiTech/fabric-cc-core/v2/pkg/konst"
+	. "github.com/KompiTech/rmap"
+	"github.com/pkg/errors"
+)
+
+// getRestrictedFields returns JSON pointers of restricted fields of asset from registry item of its version
+// builtin assets and assets filtered out by kompiguard do not have any restricted fields
+func (r *Registry) getRestrictedFields(asset Rmap) ([]string, error) {
+	if !asset.Exists(AssetDocTypeKey) || !asset.Exists(AssetVersionKey) {
+		return nil, nil
+	}
+
+	name, err := AssetGetDocType(asset)
+	if err != nil {
+		return nil, errors.Wrap(err, "konst.AssetGetDocType() failed")
+	}
+
+	if name == IdentityAssetName || name == RoleAssetName || name == GroupAssetName || name == ProposalAssetName {
+		return nil, nil
+	}
+
+	version, err := AssetGetVersion(asset)
+	if err != nil {
+		return nil, errors.Wrap(err, "konst.AssetGetVersion() failed")
+	}
+
+	item, _, err := r.GetItem(name, version)
+	if err != nil {
+		return nil, errors.Wrap(err, "r.GetItem() failed")
+	}
+
+	if !item.Exists(RegistryItemRestrictedFieldsKey) {
+		return nil, nil
+	}
+
+	fieldsI, err := item.GetIterable(RegistryItemRestrictedFieldsKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "item.GetIterable() failed")
+	}
+
+	fields := make([]string, 0, len(fieldsI))
+	for _, fieldI := range fieldsI {
+		field, ok := fieldI.(string)
+		if !ok {
+			return nil, fmt.Errorf("restricted field is not string: %v", fieldI)
+		}
+		fields = append(fields, field)
+	}
+
+	return fields, nil
+}
+
+// maskRestrictedFields returns copy of asset without restricted fields, that this identity is not granted to read
+// asset is returned unchanged, if it does not have any restricted fields
+func (r *Registry) maskRestrictedFields(asset Rmap) (Rmap, error) {
+	fields, err := r.getRestrictedFields(asset)
+	if err != nil {
+		return Rmap{}, errors.Wrap(err, "r.getRestrictedFields() failed")
+	}
+
+	if len(fields) == 0 {
+		return asset, nil
+	}
+
+	auth, err := r.ctx.GetAuthorization()
+	if err != nil {
+		return Rmap{}, errors.Wrap(err, "r.ctx.GetAuthorization() failed")
+	}
+
+	masked := asset.Copy()
+	for _, field := range fields {
+		exists, err := masked.ExistsJPtr(field)
+		if err != nil || !exists {
+			continue
+		}
+
+		granted, _, err := auth.EnforceField(asset, field, ReadAction)
+		if err != nil {
+			return Rmap{}, errors.Wrap(err, "auth.EnforceField() failed")
+		}
+
+		if !granted {
+			if err := masked.DeleteJPtr(field); err != nil {
+				return Rmap{}, errors.Wrap(err, "masked.DeleteJPtr() failed")
+			}
+		}
+	}
+
+	return masked, nil
+}
+
+// enforceRestrictedFields checks, that this identity is granted action on every restricted field, that differs between assetPre and assetPost
+// assetPre is nil when asset is created, overrides are then taken from assetPost
+func (r *Registry) enforceRestrictedFields(assetPre *Rmap, assetPost Rmap, action string) error {
+	fields, err := r.getRestrictedFields(assetPost)
+	if err != nil {
+		return errors.Wrap(err, "r.getRestrictedFields() failed")
+	}
+
+	if len(fields) == 0 {
+		return nil
+	}
+
+	// overrides of stored asset are used, so update cannot grant itself access to fields
+	overridesAsset := assetPost
+	if assetPre != nil {
+		overridesAsset = *assetPre
+	}
+
+	var auth *Authorization
+
+	for _, field := range fields {
+		postValue, postErr := assetPost.GetJPtr(field)
+
+		if assetPre != nil {
+			preValue, preErr := assetPre.GetJPtr(field)
+			if (preErr == nil) == (postErr == nil) && reflect.DeepEqual(preValue, postValue) {
+				// field was not changed
+				continue
+			}
+		} else if postErr != nil {
+			// field is not set by create
+			continue
+		}
+
+		if auth == nil {
+			auth, err = r.ctx.GetAuthorization()
+			if err != nil {
+				return errors.Wrap(err, "r.ctx.GetAuthorization() failed")
+			}
+		}
+
+		granted, reason, err := auth.EnforceField(overridesAsset, field, action)
+		if err != nil {
+			return errors.Wrap(err, "auth.EnforceField() failed")
+		}
+
+		if !granted {
+			object, err := AssetGetCasbinObject(overridesAsset)
+			if err != nil {
+				return errors.Wrap(err, "konst.AssetGetCasbinObject() failed")
+			}
+
+			return ErrorPermissionDenied(reason, kompiguard.FieldObject(object, field), action)
+		}
+	}
+
+	return nil
+}
@@ -24,8 +24,11 @@ func NewFunctionExecutor() *FunctionExecutor {
 		policy: FunctionPolicy{
 			konst.MyAccessFuncName:         []FunctionPolicyMember{myAccessFunc},       // add myAccess built-in function
 			konst.UserAccessFuncName:       []FunctionPolicyMember{identityAccessFunc}, // add userAccess built-in function
+			konst.ExplainAccessFuncName:    []FunctionPolicyMember{explainAccessFunc},  // add explainAccess built-in function
 			konst.UpsertRegistriesFuncName: []FunctionPolicyMember{upsertRegistriesFunc},
 			konst.UpsertSingletonsFuncName: []FunctionPolicyMember{upsertSingletonsFunc},
+			konst.MigrateAllFuncName:       []FunctionPolicyMember{migrateAllFunc},      // add migrateAll built-in function
+			konst.IndexReferencesFuncName:  []FunctionPolicyMember{indexReferencesFunc}, // add indexReferences built-in function
 		},
 	}
 }
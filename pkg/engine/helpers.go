@@ -3,12 +3,14 @@ package engine
 import (
 	"fmt"
 	"math/rand"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
-	"github.com/KompiTech/fabric-cc-core/v2/pkg/kompiguard"
 	. "github.com/KompiTech/fabric-cc-core/v2/pkg/konst"
 	"github.com/KompiTech/rmap"
+	jsonpatch "github.com/evanphx/json-patch"
 	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
 	"github.com/pkg/errors"
 )
@@ -94,64 +96,200 @@ func putRmapToState(ctx ContextInterface, key string, isCreate bool, rm rmap.Rma
 	return ctx.Stub().PutState(key, rm.Bytes())
 }
 
-// enforceCustomAccess loads identity for this and checks, if identity can do action on object
+// isInitFunction returns true, when chaincode is being initialized
+func isInitFunction(ctx ContextInterface) bool {
+	function, _ := ctx.GetStub().GetFunctionAndParameters()
+	return strings.EqualFold(function, "init")
+}
+
+// enforceCustomAccess checks, if identity for this can do action on object
 // this is used for methods where there is no related asset for inferring object
 func enforceCustomAccess(reg *Registry, object, action string) error {
-	thisIdentity, err := reg.GetThisIdentityResolved()
+	auth, err := reg.ctx.GetAuthorization()
 	if err != nil {
-		return errors.Wrap(err, "reg.GetThisIdentityResolved() failed")
+		return errors.Wrap(err, "reg.ctx.GetAuthorization() failed")
 	}
 
-	subject, err := AssetGetID(thisIdentity)
+	granted, reason, err := auth.EnforceCustom(object, action)
 	if err != nil {
-		return errors.Wrap(err, "konst.AssetGetID(thisIdentity) failed")
+		return errors.Wrap(err, "auth.EnforceCustom() failed")
 	}
 
-	kmpg, err := kompiguard.New()
-	if err != nil {
-		return errors.Wrap(err, "kompiguard.New() failed")
+	if !granted {
+		return ErrorPermissionDenied(reason, object, action)
 	}
 
-	if err := kmpg.LoadRoles(thisIdentity); err != nil {
-		return errors.Wrap(err, "kmpg.LoadRoles() failed")
+	return nil
+}
+
+// enforceAssetAccess enforces standard action for some asset for identity for this
+func enforceAssetAccess(reg *Registry, asset rmap.Rmap, action string) error {
+	auth, err := reg.ctx.GetAuthorization()
+	if err != nil {
+		return errors.Wrap(err, "reg.ctx.GetAuthorization() failed")
 	}
 
-	granted, reason, err := kmpg.EnforceCustom(object, subject, action, nil)
+	granted, reason, err := auth.EnforceAsset(asset, action)
 	if err != nil {
-		return errors.Wrap(err, "kompiguard.New().EnforceCustom() failed")
+		return errors.Wrap(err, "auth.EnforceAsset() failed")
 	}
 
 	if !granted {
-		return ErrorForbidden(reason)
+		return ErrorAssetPermissionDenied(reason, asset, action)
 	}
 
 	return nil
 }
 
-// enforceAssetAccess loads identity for this and enforces standard action for some asset
-func enforceAssetAccess(reg *Registry, asset rmap.Rmap, action string) error {
-	thisIdentity, err := reg.GetThisIdentityResolved()
-	if err != nil {
-		return errors.Wrap(err, "reg.GetThisIdentityResolved() failed")
+// anyRevision is expected revision, when client does not require any particular revision of asset
+const anyRevision = -1
+
+// isLastArg returns true, if param name is the last argument sent by client
+func isLastArg(ctx ContextInterface, name string) bool {
+	method, _ := ctx.Stub().GetFunctionAndParameters()
+	args := ctx.Stub().GetArgs()
+
+	for argIdx, argName := range getFunctionArgNames(method) {
+		if argName == name {
+			return argIdx+2 == len(args) // first arg is always function name
+		}
+	}
+
+	return false
+}
+
+// getExpectedRevision returns asset revision that client expects for optimistic concurrency control
+// revision param is optional, anyRevision is returned when it is missing or empty
+// tracing info is always the last argument, so JSON object sent as the last argument in place of revision is not revision
+func getExpectedRevision(ctx ContextInterface) (int, error) {
+	revisionB, err := ctx.ParamBytes(RevisionParam)
+	if err != nil || len(revisionB) == 0 {
+		return anyRevision, nil
 	}
 
-	kmpg, err := kompiguard.New()
+	if isLastArg(ctx, RevisionParam) {
+		if _, err := rmap.NewFromBytes(revisionB); err == nil {
+			return anyRevision, nil
+		}
+	}
+
+	// only decimal digits are accepted, sign, quotes or any other JSON value are rejected
+	revision, err := strconv.ParseUint(string(revisionB), 10, 31)
 	if err != nil {
-		return errors.Wrap(err, "kompiguard.New() failed")
+		return anyRevision, ErrorBadRequest(fmt.Sprintf("invalid revision: %s, must be non-negative integer", revisionB))
 	}
 
-	granted, reason, err := kmpg.EnforceAsset(asset, thisIdentity, action)
+	return int(revision), nil
+}
+
+// checkRevision returns ErrorConflict, if asset was modified since client read it
+// asset stored before revisions were introduced and not modified since then has revision 0
+func checkRevision(asset rmap.Rmap, expected int) error {
+	if expected == anyRevision {
+		return nil
+	}
+
+	actual, err := AssetGetRevision(asset)
 	if err != nil {
-		return errors.Wrap(err, "kompiguard.New().EnforceAsset() failed")
+		return errors.Wrap(err, "konst.AssetGetRevision() failed")
 	}
 
-	if !granted {
-		return ErrorForbidden(reason)
+	if actual != expected {
+		return ErrorConflict(fmt.Sprintf("asset revision mismatch, expected: %d, actual: %d", expected, actual))
 	}
 
 	return nil
 }
 
+// isJSONPatch returns true, if patch is JSON patch (RFC 6902) - JSON array of operations
+// anything else is considered to be JSON merge patch (RFC 7386)
+func isJSONPatch(patchBytes string) bool {
+	return strings.HasPrefix(strings.TrimSpace(patchBytes), "[")
+}
+
+// makeMergePatch returns patch sent by client normalized to JSON merge patch, which is relative to asset
+// JSON merge patch is returned as is, JSON patch is applied to asset and the difference is returned as JSON merge patch
+func makeMergePatch(asset rmap.Rmap, patchBytes string) (rmap.Rmap, error) {
+	if !isJSONPatch(patchBytes) {
+		patch, err := rmap.NewFromString(patchBytes)
+		if err != nil {
+			return rmap.Rmap{}, errors.Wrap(err, "rmap.NewFromBytes() failed")
+		}
+
+		return patch, nil
+	}
+
+	operations, err := jsonpatch.DecodePatch([]byte(patchBytes))
+	if err != nil {
+		return rmap.Rmap{}, ErrorBadRequest(fmt.Sprintf("invalid JSON patch: %s", err))
+	}
+
+	assetBytes := asset.Bytes()
+
+	patched, err := operations.Apply(assetBytes)
+	if err != nil {
+		if errors.Cause(err) == jsonpatch.ErrTestFailed {
+			return rmap.Rmap{}, ErrorConflict(fmt.Sprintf("JSON patch test operation failed: %s", err))
+		}
+		return rmap.Rmap{}, ErrorUnprocessableEntity(fmt.Sprintf("unable to apply JSON patch: %s", err))
+	}
+
+	mergePatch, err := jsonpatch.CreateMergePatch(assetBytes, patched)
+	if err != nil {
+		return rmap.Rmap{}, errors.Wrap(err, "jsonpatch.CreateMergePatch() failed")
+	}
+
+	mergePatchR, err := rmap.NewFromBytes(mergePatch)
+	if err != nil {
+		return rmap.Rmap{}, errors.Wrap(err, "rmap.NewFromBytes() failed")
+	}
+
+	patchedR, err := rmap.NewFromBytes(patched)
+	if err != nil {
+		return rmap.Rmap{}, errors.Wrap(err, "rmap.NewFromBytes() failed")
+	}
+
+	// null in JSON merge patch removes key, so value set to null cannot be expressed by it
+	if pointer, isFound := findSetNull("", mergePatchR.Mapa, patchedR.Mapa); isFound {
+		return rmap.Rmap{}, ErrorUnprocessableEntity(fmt.Sprintf("JSON patch sets null value at: %s, null can only be used to remove key", pointer))
+	}
+
+	return mergePatchR, nil
+}
+
+// findSetNull returns JSON pointer of the first null in JSON merge patch, that sets key of patched document to null instead of removing it
+// keys are visited in sorted order, so the same pointer is returned on every peer
+func findSetNull(pointer string, mergePatch, patched map[string]interface{}) (string, bool) {
+	keys := make([]string, 0, len(mergePatch))
+	for key := range mergePatch {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		patchedValue, exists := patched[key]
+		if !exists {
+			// key was removed
+			continue
+		}
+
+		keyPointer := pointer + JPtrSeparator + escapeJPtrToken(key)
+
+		switch value := mergePatch[key].(type) {
+		case nil:
+			return keyPointer, true
+		case map[string]interface{}:
+			if patchedMap, isMap := patchedValue.(map[string]interface{}); isMap {
+				if found, isFound := findSetNull(keyPointer, value, patchedMap); isFound {
+					return found, true
+				}
+			}
+		}
+	}
+
+	return "", false
+}
+
 func GetMyFingerprint(ctx ContextInterface) (string, error) {
 	myCert, err := cid.GetX509Certificate(ctx.Stub())
 	if err != nil {
@@ -194,6 +332,18 @@ func MakeUUID(now time.Time) (string, error) {
 	return strings.ToLower(fmt.Sprintf("%X-%X-%X-%X-%X", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])), nil
 }
 
+// generateUUID returns new UUID for asset created in current TX
+// all UUIDs of TX come from one generator shared in context, so multiple creates in the same TX never get the same UUID
+func generateUUID(ctx ContextInterface) (string, error) {
+	generate, exists := ctx.Get(UUIDGeneratorKey).(func(ctx ContextInterface) (string, error))
+	if !exists {
+		generate = GetUUIDGeneratingFunc()
+		ctx.Set(UUIDGeneratorKey, generate)
+	}
+
+	return generate(ctx)
+}
+
 // GetUUIDGeneratingFunc returns func that returns unique UUID and returned func can be called repeatedly in one transaction
 func GetUUIDGeneratingFunc() (f func(ctx ContextInterface) (string, error)) {
 	var i int
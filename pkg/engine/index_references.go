@@ -0,0 +1,172 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	. "github.com/KompiTech/fabric-cc-core/v2/pkg/konst"
+	"github.com/KompiTech/rmap"
+	"github.com/pkg/errors"
+)
+
+// indexReferencesBookmarkSeparator separates asset name and UUID of last indexed instance in bookmark of indexReferences
+const indexReferencesBookmarkSeparator = ":"
+
+// indexReferencesFunc is implementation of built-in indexReferences function, that backfills reverse reference index of chaincode upgraded from version without it
+// asset types stored in state are processed in order of name and their instances (including soft deleted) in order of UUID, up to limit instances per call
+// when all instances are indexed, index is marked as complete and delete policies are applied from then on
+/*
+{
+	"bookmark": "<name>:<uuid>",
+	"limit": 10
+}
+*/
+// output:
+/*
+{
+	"result": [{"docType": "INCIDENT", "uuid": "<uuid>"}],
+	"bookmark": "<name>:<uuid>"
+}
+*/
+var indexReferencesFunc = func(ctx ContextInterface, input rmap.Rmap, output rmap.Rmap) (rmap.Rmap, error) {
+	null := rmap.Rmap{}
+	reg := ctx.GetRegistry()
+
+	bookmark := ""
+	if input.Exists(BookmarkParam) {
+		var err error
+		bookmark, err = input.GetString(BookmarkParam)
+		if err != nil {
+			return null, ErrorBadRequest(err.Error())
+		}
+	}
+
+	limit := PageSize
+	if input.Exists(LimitParam) {
+		var err error
+		limit, err = input.GetInt(LimitParam)
+		if err != nil {
+			return null, ErrorBadRequest(err.Error())
+		}
+	}
+
+	if limit <= 0 {
+		return null, ErrorBadRequest(fmt.Sprintf("invalid limit: %d, must be greater than 0", limit))
+	}
+
+	startName, startID := "", ""
+	if bookmark != "" {
+		parts := strings.SplitN(bookmark, indexReferencesBookmarkSeparator, 2)
+		if len(parts) != 2 {
+			return null, ErrorBadRequest(fmt.Sprintf("invalid bookmark: %s", bookmark))
+		}
+		startName, startID = parts[0], parts[1]
+	}
+
+	names, err := getStateAssetNames(reg)
+	if err != nil {
+		return null, errors.Wrap(err, "getStateAssetNames() failed")
+	}
+
+	indexed := make([]interface{}, 0, limit)
+
+	for _, name := range names {
+		if name < startName {
+			continue
+		}
+
+		afterID := ""
+		if name == startName {
+			afterID = startID
+		}
+
+		// one more instance is fetched to know if there are more of them
+		ids, err := queryIndexedAssetIDs(reg, name, afterID, limit-len(indexed)+1)
+		if err != nil {
+			return null, errors.Wrap(err, "queryIndexedAssetIDs() failed")
+		}
+
+		for _, id := range ids {
+			if len(indexed) == limit {
+				// page is full, next call continues after last indexed instance
+				last := indexed[len(indexed)-1].(map[string]interface{})
+				return rmap.NewFromMap(map[string]interface{}{
+					OutputResultKey:   indexed,
+					OutputBookmarkKey: strings.ToLower(last[AssetDocTypeKey].(string)) + indexReferencesBookmarkSeparator + last[AssetIdKey].(string),
+				}), nil
+			}
+
+			isStored, err := reg.indexStoredReferences(name, id)
+			if err != nil {
+				return null, errors.Wrap(err, "reg.indexStoredReferences() failed")
+			}
+
+			if !isStored {
+				continue
+			}
+
+			indexed = append(indexed, map[string]interface{}{
+				AssetDocTypeKey: strings.ToUpper(name),
+				AssetIdKey:      id,
+			})
+		}
+	}
+
+	if err := markReferenceIndexComplete(ctx); err != nil {
+		return null, errors.Wrap(err, "markReferenceIndexComplete() failed")
+	}
+
+	return rmap.NewFromMap(map[string]interface{}{
+		OutputResultKey:   indexed,
+		OutputBookmarkKey: "",
+	}), nil
+}
+
+// getStateAssetNames returns sorted names of asset types, whose latest version is stored in state
+// references of private data assets are not indexed
+func getStateAssetNames(reg *Registry) ([]string, error) {
+	items, err := reg.ListItems()
+	if err != nil {
+		return nil, errors.Wrap(err, "reg.ListItems() failed")
+	}
+	sort.Strings(items)
+
+	names := make([]string, 0, len(items))
+	for _, name := range items {
+		item, _, err := reg.GetItem(name, -1)
+		if err != nil {
+			return nil, errors.Wrap(err, "reg.GetItem() failed")
+		}
+
+		destination, err := item.GetString(RegistryItemDestinationKey)
+		if err != nil {
+			return nil, errors.Wrap(err, "item.GetString() failed")
+		}
+
+		if destination == StateDestinationValue {
+			names = append(names, name)
+		}
+	}
+
+	return names, nil
+}
+
+// queryIndexedAssetIDs returns UUIDs of up to max instances of asset name (including soft deleted) with UUID greater than afterID in order of UUID
+func queryIndexedAssetIDs(reg *Registry, name, afterID string, max int) ([]string, error) {
+	selector := map[string]interface{}{}
+	if afterID != "" {
+		selector[AssetIdKey] = map[string]interface{}{"$gt": afterID}
+	}
+
+	// sort requires CouchDB index on docType and uuid, it is generated by metainfgen as default index uuid
+	query := rmap.NewFromMap(map[string]interface{}{
+		QuerySelectorKey:       selector,
+		QueryFieldsKey:         []interface{}{AssetIdKey},
+		QuerySortKey:           []interface{}{map[string]interface{}{AssetDocTypeKey: "asc"}, map[string]interface{}{AssetIdKey: "asc"}},
+		QueryLimitKey:          max,
+		QueryIncludeDeletedKey: true,
+	})
+
+	return queryAssetIDs(reg, name, query, max)
+}
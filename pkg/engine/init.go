@@ -9,20 +9,6 @@ import (
 	"github.com/pkg/errors"
 )
 
-// getSUQuery returns "const map" of query to select SUs
-func getSUQuery() map[string]interface{} {
-	return map[string]interface{}{
-		QuerySelectorKey: map[string]interface{}{
-			AssetDocTypeKey: IdentityAssetKeyPrefix,
-			"roles": map[string]interface{}{
-				"$elemMatch": map[string]interface{}{
-					"$eq": SuperuserRoleUUID,
-				},
-			},
-		},
-	}
-}
-
 // initChaincode is the standard initialization method for chaincode
 // it handles:
 // setting of initManager variable if no initManagers are present
@@ -67,6 +53,10 @@ func initChaincode(ctx ContextInterface) error {
 		return errors.Wrap(err, "bootstrapSuperUser() failed")
 	}
 
+	if err := initReferenceIndex(ctx); err != nil {
+		return errors.Wrap(err, "initReferenceIndex() failed")
+	}
+
 	if err := upsertRegistries(ctx, input); err != nil {
 		return errors.Wrap(err, "upsertRegistries() failed")
 	}
@@ -82,12 +72,20 @@ func initChaincode(ctx ContextInterface) error {
 func bootstrapSuperUser(ctx ContextInterface, input rmap.Rmap) error {
 	reg := ctx.Get(RegistryKey).(*Registry)
 
-	superUsers, _, err := reg.QueryAssets(IdentityAssetName, rmap.NewFromMap(getSUQuery()), "", false, false, PageSize)
+	now, err := ctx.Time()
 	if err != nil {
-		return errors.Wrap(err, "reg.QueryAssets() failed")
+		return errors.Wrap(err, "ctx.Time() failed")
 	}
 
-	if len(superUsers) == 0 {
+	// role created by bootstrap counts too, it manages chaincode when it is not superuser role
+	managerRoles := getRoleSet(append(getSuperuserRoles(ctx), SuperuserRoleUUID))
+
+	hasManager, err := reg.hasRoleHolder(managerRoles, now)
+	if err != nil {
+		return errors.Wrap(err, "reg.hasRoleHolder() failed")
+	}
+
+	if !hasManager {
 		// if no superUsers are present, first init_manager must be bootstrapped, param is mandatory
 		if !input.Exists(InitSuperuserKey) {
 			return errors.New(InitSuperuserKey + " is mandatory when no superusers are present")
@@ -127,6 +125,14 @@ func upsertRegistries(ctx ContextInterface, input rmap.Rmap) error {
 			return errors.Wrap(err, "input.GetRmap() failed")
 		}
 
+		force := false
+		if input.Exists(InitForceKey) {
+			force, err = input.GetBool(InitForceKey)
+			if err != nil {
+				return errors.Wrap(err, "input.GetBool() failed")
+			}
+		}
+
 		buis := make([]bulkItem, 0, len(registries.Mapa))
 
 		// iteration through keys must be deterministic on all peers -> sort keys before iterating
@@ -147,6 +153,7 @@ func upsertRegistries(ctx ContextInterface, input rmap.Rmap) error {
 			buis = append(buis, bulkItem{
 				Name:  assetName,
 				Value: regItem,
+				Force: force,
 			})
 		}
 
@@ -3,9 +3,7 @@ package engine
 import (
 	"fmt"
 	"strings"
-	"time"
 
-	"github.com/KompiTech/fabric-cc-core/v2/pkg/kompiguard"
 	. "github.com/KompiTech/fabric-cc-core/v2/pkg/konst"
 	"github.com/KompiTech/rmap"
 	"github.com/pkg/errors"
@@ -26,32 +24,27 @@ func assetGetBackend(ctx ContextInterface, name, id string, resolve bool, data s
 
 	if isDirect {
 		// when isDirect, explicit permission is required
-		thisIdentity, err := ctx.GetRegistry().GetThisIdentityResolved()
+		auth, err := ctx.GetAuthorization()
 		if err != nil {
-			return "", errors.Wrap(err, "reg.GetThisIdentityResolved() failed")
+			return "", errors.Wrap(err, "ctx.GetAuthorization() failed")
 		}
 
-		kmpg, err := kompiguard.New()
+		granted, reason, err := auth.EnforceAsset(asset, ReadDirectAction)
 		if err != nil {
-			return "", errors.Wrap(err, "kompiguard.New() failed")
-		}
-
-		granted, reason, err := kmpg.EnforceAsset(asset, thisIdentity, ReadDirectAction)
-		if err != nil {
-			return "", errors.Wrap(err, "kmpg.EnforceCustom()")
+			return "", errors.Wrap(err, "auth.EnforceAsset()")
 		}
 
 		if !granted {
-			return "", ErrorForbidden(reason)
+			return "", ErrorAssetPermissionDenied(reason, asset, ReadDirectAction)
 		}
 	}
 
 	// built-in assets need to be protected in non-direct mode
-	if (docType == IdentityAssetName || docType == RoleAssetName) && !isDirect {
+	if (docType == IdentityAssetName || docType == RoleAssetName || docType == GroupAssetName || docType == ProposalAssetName) && !isDirect {
 		// get identity of this user
-		thisIdentity, err := ctx.GetRegistry().GetThisIdentityResolved()
+		thisIdentityID, err := GetMyFingerprint(ctx)
 		if err != nil {
-			return "", errors.Wrap(err, "reg.GetThisIdentityResolved() failed")
+			return "", errors.Wrap(err, "GetMyFingerprint() failed")
 		}
 
 		assetID, err := AssetGetID(asset)
@@ -59,26 +52,21 @@ func assetGetBackend(ctx ContextInterface, name, id string, resolve bool, data s
 			return "", errors.Wrap(err, "AssetGetID(asset) failed")
 		}
 
-		thisIdentityID, err := AssetGetID(thisIdentity)
-		if err != nil {
-			return "", errors.Wrap(err, "AssetGetID(thisIdentity) failed")
-		}
-
 		if !(docType == IdentityAssetName && assetID == thisIdentityID) {
 			// when client reads anything else than his own identity, standard access control is used
-			kmpg, err := kompiguard.New()
+			auth, err := ctx.GetAuthorization()
 			if err != nil {
-				return "", errors.Wrap(err, "kompiguard.New() failed")
+				return "", errors.Wrap(err, "ctx.GetAuthorization() failed")
 			}
 
-			granted, reason, err := kmpg.EnforceAsset(asset, thisIdentity, ReadAction)
+			granted, reason, err := auth.EnforceAsset(asset, ReadAction)
 			if err != nil {
 				return "", err
 			}
 
 			if !granted {
 				// no, return permission denied message
-				return "", ErrorForbidden(reason)
+				return "", ErrorAssetPermissionDenied(reason, asset, ReadAction)
 			}
 		}
 	}
@@ -102,14 +90,17 @@ func assetGetBackend(ctx ContextInterface, name, id string, resolve bool, data s
 		}
 	}
 
+	asset, err = ctx.GetRegistry().maskRestrictedFields(asset)
+	if err != nil {
+		return "", errors.Wrap(err, "reg.maskRestrictedFields() failed")
+	}
+
 	return string(asset.WrappedResultBytes()), nil
 }
 
-func assetUpdateBackend(ctx ContextInterface, name, id string, patchBytes string, isDirect bool) (string, error) {
-	// load json patch
-	patch, err := rmap.NewFromString(patchBytes)
-	if err != nil {
-		return "", errors.Wrap(err, "rmap.NewFromBytes() failed")
+func assetUpdateBackend(ctx ContextInterface, name, id string, patchBytes string, revision int, isDirect bool) (string, error) {
+	if err := checkProposalWrite(name); err != nil {
+		return "", err
 	}
 
 	// get asset that client wants to update
@@ -118,6 +109,16 @@ func assetUpdateBackend(ctx ContextInterface, name, id string, patchBytes string
 		return "", errors.Wrap(err, "reg.GetAsset() failed")
 	}
 
+	if err := checkRevision(assetPre, revision); err != nil {
+		return "", err
+	}
+
+	// load patch, JSON patch is converted to JSON merge patch
+	patch, err := makeMergePatch(assetPre, patchBytes)
+	if err != nil {
+		return "", errors.Wrap(err, "makeMergePatch() failed")
+	}
+
 	docType, err := AssetGetDocType(assetPre)
 	if err != nil {
 		return "", errors.Wrap(err, "assetPre.GetDocType() failed")
@@ -126,8 +127,8 @@ func assetUpdateBackend(ctx ContextInterface, name, id string, patchBytes string
 	var requiredAction string
 
 	if !isDirect {
-		if docType == IdentityAssetName {
-			// when updating identity in non-direct mode, require update action
+		if docType == IdentityAssetName || docType == GroupAssetName {
+			// when updating identity or group in non-direct mode, require update action
 			requiredAction = UpdateAction
 		} else if docType == RoleAssetName {
 			var isSystemRole bool
@@ -162,6 +163,10 @@ func assetUpdateBackend(ctx ContextInterface, name, id string, patchBytes string
 		}
 	}
 
+	if err := checkApproval(ctx, newApprovalOperation(ApprovalAssetUpdate, docType, assetPre, patch)); err != nil {
+		return "", err
+	}
+
 	if patch.IsEmpty() {
 		return "", ErrorBadRequest("patch is empty")
 	}
@@ -191,6 +196,11 @@ func assetUpdateBackend(ctx ContextInterface, name, id string, patchBytes string
 		return "", errors.Wrap(err, "assetPre.ApplyMergePatch() failed")
 	}
 
+	// restricted fields changed by patch require field grant, changes done by blogic are not checked
+	if err := ctx.GetRegistry().enforceRestrictedFields(&assetPre, assetPost, UpdateAction); err != nil {
+		return "", err
+	}
+
 	if !isDirect {
 		// execute BeforeUpdate blogic if not running in direct mode
 		assetPost, err = ctx.GetConfiguration().BusinessExecutor.Execute(ctx, BeforeUpdate, &assetPre, assetPost)
@@ -201,17 +211,20 @@ func assetUpdateBackend(ctx ContextInterface, name, id string, patchBytes string
 
 	if docType == IdentityAssetName {
 		// execute extra validations for identity in direct and not direct mode
-		// this protects system from last superuser taking his permission away
-		thisIdentity, err := ctx.GetRegistry().GetThisIdentityResolved()
+		thisIdentity, err := ctx.GetRegistry().GetThisIdentity()
 		if err != nil {
-			return "", errors.Wrap(err, "reg.GetThisIdentityResolved() failed")
+			return "", errors.Wrap(err, "reg.GetThisIdentity() failed")
 		}
 
-		if err := identityExtraValidate(ctx.GetRegistry(), assetPre, assetPost, thisIdentity); err != nil {
+		if err := identityExtraValidate(assetPost, thisIdentity); err != nil {
 			return "", errors.Wrap(err, "identityExtraValidate() failed")
 		}
 	}
 
+	if isDirect {
+		markDirect(ctx)
+	}
+
 	// persist changes to modified asset
 	if err := ctx.GetRegistry().putAsset(assetPost, false, isDirect); err != nil {
 		return "", errors.Wrap(err, "reg.PutAsset() failed")
@@ -225,6 +238,11 @@ func assetUpdateBackend(ctx ContextInterface, name, id string, patchBytes string
 		}
 	}
 
+	assetPost, err = ctx.GetRegistry().maskRestrictedFields(assetPost)
+	if err != nil {
+		return "", errors.Wrap(err, "reg.maskRestrictedFields() failed")
+	}
+
 	return string(assetPost.WrappedResultBytes()), nil
 }
 
@@ -244,7 +262,12 @@ func assetUpdateFrontend(ctx ContextInterface) (string, error) {
 		return "", err
 	}
 
-	return assetUpdateBackend(ctx, name, id, patch, false)
+	revision, err := getExpectedRevision(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return assetUpdateBackend(ctx, name, id, patch, revision, false)
 }
 
 func assetUpdateDirectFrontend(ctx ContextInterface) (string, error) {
@@ -263,7 +286,12 @@ func assetUpdateDirectFrontend(ctx ContextInterface) (string, error) {
 		return "", err
 	}
 
-	return assetUpdateBackend(ctx, name, id, patch, true)
+	revision, err := getExpectedRevision(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return assetUpdateBackend(ctx, name, id, patch, revision, true)
 }
 
 func assetGetFrontend(ctx ContextInterface) (string, error) {
@@ -309,11 +337,15 @@ func assetGetDirectFrontend(ctx ContextInterface) (string, error) {
 	return assetGetBackend(ctx, name, id, resolve, rmap.NewEmpty().String(), true)
 }
 
-func assetCreateBackend(ctx ContextInterface, now time.Time, name string, data string, version int, id string, isDirect bool) (string, error) {
+func assetCreateBackend(ctx ContextInterface, name string, data string, version int, id string, isDirect bool) (string, error) {
 	name = strings.ToLower(name)
 	var err error
 	var patch rmap.Rmap
 
+	if err := checkProposalWrite(name); err != nil {
+		return "", err
+	}
+
 	if len(data) == 0 {
 		patch = rmap.NewEmpty()
 	} else {
@@ -380,9 +412,9 @@ func assetCreateBackend(ctx ContextInterface, now time.Time, name string, data s
 			// client did not sent id in data
 			if id == "" {
 				// client wants to autogenerate id
-				id, err = MakeUUID(now)
+				id, err = generateUUID(ctx)
 				if err != nil {
-					return "", errors.Wrap(err, "engine.MakeUUID() failed")
+					return "", errors.Wrap(err, "generateUUID() failed")
 				}
 			}
 		}
@@ -392,6 +424,10 @@ func assetCreateBackend(ctx ContextInterface, now time.Time, name string, data s
 		return "", ErrorBadRequest("patch contains service key(s)")
 	}
 
+	if err := checkApproval(ctx, newApprovalOperation(ApprovalAssetCreate, name, patch)); err != nil {
+		return "", err
+	}
+
 	// create asset with only service keys
 	newAsset, err := ctx.GetRegistry().MakeAsset(name, id, version)
 	if err != nil {
@@ -401,8 +437,8 @@ func assetCreateBackend(ctx ContextInterface, now time.Time, name string, data s
 	var requiredAction string
 
 	if !isDirect {
-		if name == IdentityAssetName {
-			// when create role or identity in non-direct mode, require create action
+		if name == IdentityAssetName || name == GroupAssetName {
+			// when create role, identity or group in non-direct mode, require create action
 			requiredAction = CreateAction
 		} else if name == RoleAssetName {
 			var isSystem bool
@@ -454,6 +490,11 @@ func assetCreateBackend(ctx ContextInterface, now time.Time, name string, data s
 		return "", errors.Wrap(err, "newAsset.ApplyMergePatch() failed")
 	}
 
+	// restricted fields set by patch require field grant, fields set by blogic are not checked
+	if err := ctx.GetRegistry().enforceRestrictedFields(nil, newAsset, UpdateAction); err != nil {
+		return "", err
+	}
+
 	if !isDirect {
 		newAsset, err = ctx.GetConfiguration().BusinessExecutor.Execute(ctx, BeforeCreate, nil, newAsset)
 		if err != nil {
@@ -461,6 +502,10 @@ func assetCreateBackend(ctx ContextInterface, now time.Time, name string, data s
 		}
 	}
 
+	if isDirect {
+		markDirect(ctx)
+	}
+
 	if err := ctx.Get("registry").(*Registry).putAsset(newAsset, true, isDirect); err != nil {
 		return "", errors.Wrap(err, "reg.PutAsset() failed")
 	}
@@ -472,15 +517,15 @@ func assetCreateBackend(ctx ContextInterface, now time.Time, name string, data s
 		}
 	}
 
+	newAsset, err = ctx.GetRegistry().maskRestrictedFields(newAsset)
+	if err != nil {
+		return "", errors.Wrap(err, "reg.maskRestrictedFields() failed")
+	}
+
 	return string(newAsset.WrappedResultBytes()), nil
 }
 
 func assetCreateDirectFrontend(ctx ContextInterface) (string, error) {
-	now, err := ctx.Time()
-	if err != nil {
-		return "", errors.Wrap(err, "ctx.Time() failed")
-	}
-
 	name, err := ctx.ParamString(NameParam)
 	if err != nil {
 		return "", err
@@ -501,15 +546,10 @@ func assetCreateDirectFrontend(ctx ContextInterface) (string, error) {
 		return "", err
 	}
 
-	return assetCreateBackend(ctx, now, name, data, version, id, true)
+	return assetCreateBackend(ctx, name, data, version, id, true)
 }
 
 func assetCreateFrontend(ctx ContextInterface) (string, error) {
-	now, err := ctx.Time()
-	if err != nil {
-		return "", errors.Wrap(err, "ctx.Time() failed")
-	}
-
 	name, err := ctx.ParamString(NameParam)
 	if err != nil {
 		return "", err
@@ -530,7 +570,7 @@ func assetCreateFrontend(ctx ContextInterface) (string, error) {
 		return "", err
 	}
 
-	return assetCreateBackend(ctx, now, name, data, version, id, false)
+	return assetCreateBackend(ctx, name, data, version, id, false)
 }
 
 func assetQueryFrontend(ctx ContextInterface) (string, error) {
@@ -587,32 +627,18 @@ func assetQueryBackend(ctx ContextInterface, name string, queryBytes string, res
 
 	if isDirect {
 		// when isDirect, explicit permission is required
-		thisIdentity, err := ctx.GetRegistry().GetThisIdentityResolved()
-		if err != nil {
-			return "", errors.Wrap(err, "reg.GetThisIdentityResolved() failed")
-		}
-
-		kmpg, err := kompiguard.New()
-		if err != nil {
-			return "", errors.Wrap(err, "kompiguard.New() failed")
-		}
-
-		myFP, err := AssetGetID(thisIdentity)
+		auth, err := ctx.GetAuthorization()
 		if err != nil {
-			return "", errors.Wrap(err, "AssetGetID(thisIdentity) failed")
+			return "", errors.Wrap(err, "ctx.GetAuthorization() failed")
 		}
 
-		if err := kmpg.LoadRoles(thisIdentity); err != nil {
-			return "", errors.Wrap(err, "kmpg.LoadRoles()")
-		}
-
-		granted, reason, err := kmpg.EnforceCustom("/"+docType, myFP, "query_direct", nil)
+		granted, reason, err := auth.EnforceCustom("/"+docType, "query_direct")
 		if err != nil {
-			return "", errors.Wrap(err, "kmpg.EnforceCustom()")
+			return "", errors.Wrap(err, "auth.EnforceCustom()")
 		}
 
 		if !granted {
-			return "", ErrorForbidden(reason)
+			return "", ErrorPermissionDenied(reason, "/"+docType, "query_direct")
 		}
 	}
 
@@ -642,20 +668,15 @@ func assetQueryBackend(ctx ContextInterface, name string, queryBytes string, res
 		return "", errors.Wrap(err, "reg.QueryAssets() failed")
 	}
 
-	if (docType == IdentityAssetName || docType == RoleAssetName) && !isDirect {
-		thisIdentity, err := ctx.GetRegistry().GetThisIdentityResolved()
+	if (docType == IdentityAssetName || docType == RoleAssetName || docType == GroupAssetName || docType == ProposalAssetName) && !isDirect {
+		auth, err := ctx.GetAuthorization()
 		if err != nil {
-			return "", errors.Wrap(err, "reg.GetThisIdentityResolved() failed")
+			return "", errors.Wrap(err, "ctx.GetAuthorization() failed")
 		}
 
-		kmpg, err := kompiguard.New()
+		assets, err = auth.FilterAssets(assets, "read")
 		if err != nil {
-			return "", errors.Wrap(err, "kompiguard.New() failed")
-		}
-
-		assets, err = kmpg.FilterAssets(assets, thisIdentity, "read")
-		if err != nil {
-			return "", errors.Wrap(err, "kompiguard.New().FilterAssets() failed")
+			return "", errors.Wrap(err, "auth.FilterAssets() failed")
 		}
 	}
 
@@ -671,6 +692,11 @@ func assetQueryBackend(ctx ContextInterface, name string, queryBytes string, res
 			asset = assetTmp
 		}
 
+		asset, err = ctx.GetRegistry().maskRestrictedFields(asset)
+		if err != nil {
+			return "", errors.Wrap(err, "reg.maskRestrictedFields() failed")
+		}
+
 		outputSlice = append(outputSlice, asset.Mapa)
 	}
 
@@ -703,6 +729,19 @@ func assetMigrateFrontend(ctx ContextInterface) (string, error) {
 		return "", err
 	}
 
+	revision, err := getExpectedRevision(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return assetMigrateBackend(ctx, name, id, patchB, version, revision)
+}
+
+func assetMigrateBackend(ctx ContextInterface, name, id string, patchB string, version int, revision int) (string, error) {
+	if err := checkProposalWrite(name); err != nil {
+		return "", err
+	}
+
 	reg := ctx.Get(RegistryKey).(*Registry)
 
 	asset, err := reg.GetAsset(name, id, false, true)
@@ -710,7 +749,15 @@ func assetMigrateFrontend(ctx ContextInterface) (string, error) {
 		return "", errors.Wrap(err, "reg.GetAsset() failed")
 	}
 
-	if err := enforceAssetAccess(reg, asset, "migrate"); err != nil {
+	if err := checkRevision(asset, revision); err != nil {
+		return "", err
+	}
+
+	if err := enforceAssetAccess(reg, asset, MigrateAction); err != nil {
+		return "", err
+	}
+
+	if err := checkApproval(ctx, newApprovalOperation(ApprovalAssetMigrate, name, asset)); err != nil {
 		return "", err
 	}
 
@@ -728,25 +775,54 @@ func assetMigrateFrontend(ctx ContextInterface) (string, error) {
 		return "", ErrorBadRequest("unable to migrate to the same version of asset")
 	}
 
-	asset.Mapa[AssetVersionKey] = targetVersion
+	assetPre := asset.Copy()
 
-	patch, err := rmap.NewFromString(patchB)
+	// apply migration definitions of all versions between this and target version
+	asset, err = migrateAsset(ctx, asset, targetVersion)
 	if err != nil {
-		return "", errors.Wrap(err, "rmap.NewFromBytes() failed")
+		return "", errors.Wrap(err, "migrateAsset() failed")
+	}
+
+	// JSON patch is converted to JSON merge patch
+	patch, err := makeMergePatch(asset, patchB)
+	if err != nil {
+		return "", errors.Wrap(err, "makeMergePatch() failed")
 	}
 
 	if HasServiceKeys(patch) {
 		return "", ErrorBadRequest("patch contains service key(s)")
 	}
 
+	// restricted fields changed by patch require field grant, changes done by migration definitions are not checked
+	assetMigrated := asset.Copy()
+
 	if err := asset.ApplyMergePatchBytes(patch.Bytes()); err != nil {
 		return "", errors.Wrap(err, "asset.ApplyMergePatchBytes() failed")
 	}
 
+	if err := reg.enforceRestrictedFields(&assetMigrated, asset, UpdateAction); err != nil {
+		return "", err
+	}
+
+	asset, err = ctx.GetConfiguration().BusinessExecutor.Execute(ctx, BeforeMigrate, &assetPre, asset)
+	if err != nil {
+		return "", errors.Wrap(err, "bexec.Execute(), stage: BeforeMigrate failed")
+	}
+
 	if err := reg.PutAsset(asset, false); err != nil {
 		return "", errors.Wrap(err, "reg.PutAsset() failed")
 	}
 
+	asset, err = ctx.GetConfiguration().BusinessExecutor.Execute(ctx, AfterMigrate, &assetPre, asset)
+	if err != nil {
+		return "", errors.Wrap(err, "bexec.Execute(), stage: AfterMigrate failed")
+	}
+
+	asset, err = reg.maskRestrictedFields(asset)
+	if err != nil {
+		return "", errors.Wrap(err, "reg.maskRestrictedFields() failed")
+	}
+
 	return string(asset.WrappedResultBytes()), nil
 }
 
@@ -761,7 +837,12 @@ func assetDeleteFrontend(ctx ContextInterface) (string, error) {
 		return "", err
 	}
 
-	return assetDeleteBackend(ctx, name, id, false)
+	revision, err := getExpectedRevision(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return assetDeleteBackend(ctx, name, id, revision, false)
 }
 
 func assetDeleteDirectFrontend(ctx ContextInterface) (string, error) {
@@ -775,37 +856,61 @@ func assetDeleteDirectFrontend(ctx ContextInterface) (string, error) {
 		return "", err
 	}
 
-	return assetDeleteBackend(ctx, name, id, true)
+	revision, err := getExpectedRevision(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return assetDeleteBackend(ctx, name, id, revision, true)
 }
 
-func assetDeleteBackend(ctx ContextInterface, name, id string, isDirect bool) (string, error) {
-	asset, err := ctx.GetRegistry().GetAsset(name, id, false, true)
+func assetDeleteBackend(ctx ContextInterface, name, id string, revision int, isDirect bool) (string, error) {
+	if err := checkProposalWrite(name); err != nil {
+		return "", err
+	}
+
+	var asset rmap.Rmap
+	var err error
+
+	if isDirect {
+		// direct delete can also purge soft deleted asset
+		asset, err = ctx.GetRegistry().GetDeletedAsset(name, id)
+	} else {
+		asset, err = ctx.GetRegistry().GetAsset(name, id, false, true)
+	}
 	if err != nil {
 		return "", errors.Wrap(err, "reg.GetAsset() failed")
 	}
 
+	if err := checkRevision(asset, revision); err != nil {
+		return "", err
+	}
+
 	if isDirect {
 		// when isDirect, explicit permission is required
-		thisIdentity, err := ctx.GetRegistry().GetThisIdentityResolved()
+		auth, err := ctx.GetAuthorization()
 		if err != nil {
-			return "", errors.Wrap(err, "reg.GetThisIdentityResolved() failed")
+			return "", errors.Wrap(err, "ctx.GetAuthorization() failed")
 		}
 
-		kmpg, err := kompiguard.New()
+		granted, reason, err := auth.EnforceAsset(asset, "delete_direct")
 		if err != nil {
-			return "", errors.Wrap(err, "kompiguard.New() failed")
-		}
-
-		granted, reason, err := kmpg.EnforceAsset(asset, thisIdentity, "delete_direct")
-		if err != nil {
-			return "", errors.Wrap(err, "kmpg.EnforceCustom()")
+			return "", errors.Wrap(err, "auth.EnforceAsset()")
 		}
 
 		if !granted {
-			return "", ErrorForbidden(reason)
+			return "", ErrorAssetPermissionDenied(reason, asset, "delete_direct")
 		}
 	}
 
+	if err := checkApproval(ctx, newApprovalOperation(ApprovalAssetDelete, name, asset)); err != nil {
+		return "", err
+	}
+
+	if isDirect {
+		markDirect(ctx)
+	}
+
 	if !isDirect {
 		_, err = ctx.GetConfiguration().BusinessExecutor.Execute(ctx, BeforeDelete, nil, asset)
 		if err != nil {
@@ -813,8 +918,27 @@ func assetDeleteBackend(ctx ContextInterface, name, id string, isDirect bool) (s
 		}
 	}
 
-	if err := ctx.GetRegistry().DeleteAsset(asset); err != nil {
-		return "", errors.Wrap(err, "reg.DeleteAsset() failed")
+	softDelete := false
+	if !isDirect {
+		softDelete, err = ctx.GetRegistry().isSoftDeleteEnabled(asset)
+		if err != nil {
+			return "", errors.Wrap(err, "reg.isSoftDeleteEnabled() failed")
+		}
+	}
+
+	if softDelete {
+		if err := ctx.GetRegistry().SoftDeleteAsset(asset); err != nil {
+			return "", errors.Wrap(err, "reg.SoftDeleteAsset() failed")
+		}
+	} else {
+		// references to soft deleted asset stay valid, policies are applied only when asset is really removed
+		if err := applyDeletePolicies(ctx, asset, isDirect); err != nil {
+			return "", errors.Wrap(err, "applyDeletePolicies() failed")
+		}
+
+		if err := ctx.GetRegistry().DeleteAsset(asset); err != nil {
+			return "", errors.Wrap(err, "reg.DeleteAsset() failed")
+		}
 	}
 
 	output := rmap.NewFromMap(map[string]interface{}{
@@ -825,6 +949,53 @@ func assetDeleteBackend(ctx ContextInterface, name, id string, isDirect bool) (s
 	return string(output.Bytes()), nil
 }
 
+func assetRestoreFrontend(ctx ContextInterface) (string, error) {
+	name, err := ctx.ParamString(NameParam)
+	if err != nil {
+		return "", err
+	}
+
+	id, err := ctx.ParamString(IdParam)
+	if err != nil {
+		return "", err
+	}
+
+	return assetRestoreBackend(ctx, name, id)
+}
+
+func assetRestoreBackend(ctx ContextInterface, name, id string) (string, error) {
+	reg := ctx.GetRegistry()
+
+	asset, err := reg.GetDeletedAsset(name, id)
+	if err != nil {
+		return "", errors.Wrap(err, "reg.GetDeletedAsset() failed")
+	}
+
+	if err := enforceAssetAccess(reg, asset, RestoreAction); err != nil {
+		return "", err
+	}
+
+	if err := checkApproval(ctx, newApprovalOperation(ApprovalAssetRestore, name, asset)); err != nil {
+		return "", err
+	}
+
+	if err := reg.RestoreAsset(asset); err != nil {
+		return "", errors.Wrap(err, "reg.RestoreAsset() failed")
+	}
+
+	asset, err = ctx.GetConfiguration().BusinessExecutor.Execute(ctx, AfterRestore, nil, asset)
+	if err != nil {
+		return "", errors.Wrap(err, "bexec.Execute(), stage: AfterRestore failed")
+	}
+
+	asset, err = reg.maskRestrictedFields(asset)
+	if err != nil {
+		return "", errors.Wrap(err, "reg.maskRestrictedFields() failed")
+	}
+
+	return string(asset.WrappedResultBytes()), nil
+}
+
 func assetHistoryFrontend(ctx ContextInterface) (string, error) {
 	name, err := ctx.ParamString(NameParam)
 	if err != nil {
@@ -858,3 +1029,90 @@ func assetHistoryFrontend(ctx ContextInterface) (string, error) {
 
 	return string(output.Bytes()), nil
 }
+
+func assetReferrersFrontend(ctx ContextInterface) (string, error) {
+	name, err := ctx.ParamString(NameParam)
+	if err != nil {
+		return "", err
+	}
+
+	id, err := ctx.ParamString(IdParam)
+	if err != nil {
+		return "", err
+	}
+
+	// bookmark is optional, first page is returned without it
+	bookmark, err := ctx.ParamString(BookmarkParam)
+	if err != nil {
+		bookmark = ""
+	}
+
+	return assetReferrersBackend(ctx, name, id, bookmark, PageSize)
+}
+
+// assetReferrersBackend returns page of asset instances with reference to asset name, id
+// referencing assets that cannot be read by this identity are filtered by kompiguard, soft deleted ones are skipped
+func assetReferrersBackend(ctx ContextInterface, name, id, bookmark string, pageSize int) (string, error) {
+	reg := ctx.GetRegistry()
+
+	target, err := reg.GetAsset(name, id, false, true)
+	if err != nil {
+		return "", errors.Wrap(err, "reg.GetAsset() failed")
+	}
+
+	if err := enforceAssetAccess(reg, target, ReadAction); err != nil {
+		return "", err
+	}
+
+	referrers, bookmark, err := reg.getReferrersPage(name, id, bookmark, pageSize)
+	if err != nil {
+		return "", errors.Wrap(err, "reg.getReferrersPage() failed")
+	}
+
+	// soft deleted assets are hidden, visible holds referrers of loaded assets
+	assets := make([]rmap.Rmap, 0, len(referrers))
+	visible := make([]referrer, 0, len(referrers))
+	for _, ref := range referrers {
+		asset, err := reg.GetAsset(ref.name, ref.id, false, false)
+		if err != nil {
+			return "", errors.Wrap(err, "reg.GetAsset() failed")
+		}
+
+		if !asset.IsEmpty() {
+			assets = append(assets, asset)
+			visible = append(visible, ref)
+		}
+	}
+
+	auth, err := ctx.GetAuthorization()
+	if err != nil {
+		return "", errors.Wrap(err, "ctx.GetAuthorization() failed")
+	}
+
+	// FilterAssets keeps order and replaces assets that cannot be read by ID and error message
+	assets, err = auth.FilterAssets(assets, ReadAction)
+	if err != nil {
+		return "", errors.Wrap(err, "auth.FilterAssets() failed")
+	}
+
+	outputSlice := make([]interface{}, 0, len(assets))
+	for i, asset := range assets {
+		if asset.Exists(FilteredKey) {
+			outputSlice = append(outputSlice, asset.Mapa)
+			continue
+		}
+
+		outputSlice = append(outputSlice, map[string]interface{}{
+			AssetDocTypeKey:    strings.ToUpper(visible[i].name),
+			AssetIdKey:         visible[i].id,
+			ReferrerPointerKey: visible[i].pointer,
+		})
+	}
+
+	output := rmap.NewFromMap(map[string]interface{}{
+		OutputResultKey:   outputSlice,
+		OutputBookmarkKey: bookmark,
+	})
+
+	return string(output.Bytes()), nil
+}
@@ -0,0 +1,221 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+
+	. "github.com/KompiTech/fabric-cc-core/v2/pkg/konst"
+	"github.com/KompiTech/rmap"
+	"github.com/pkg/errors"
+)
+
+// batchPlaceholder matches ${N} placeholder in batch operation, which is replaced by ID of asset from N-th operation
+var batchPlaceholder = regexp.MustCompile(`\$\{(\d+)\}`)
+
+func assetBatchFrontend(ctx ContextInterface) (string, error) {
+	operations, err := ctx.ParamString(OperationsParam)
+	if err != nil {
+		return "", err
+	}
+
+	return assetBatchBackend(ctx, operations)
+}
+
+// assetBatchBackend executes JSON array of operations in order, all of them in the current TX
+// if any operation fails, error is returned and whole TX fails
+func assetBatchBackend(ctx ContextInterface, operationsB string) (string, error) {
+	var operations []json.RawMessage
+	if err := json.Unmarshal([]byte(operationsB), &operations); err != nil {
+		return "", ErrorBadRequest(fmt.Sprintf("operations must be JSON array: %s", err))
+	}
+
+	if len(operations) == 0 {
+		return "", ErrorBadRequest("operations are empty")
+	}
+
+	ids := make([]string, 0, len(operations))
+	results := make([]interface{}, 0, len(operations))
+
+	for index, operationB := range operations {
+		operation, err := resolveBatchPlaceholders(string(operationB), ids)
+		if err != nil {
+			return "", errors.Wrapf(err, "operation %d failed", index)
+		}
+
+		id, result, err := assetBatchOperation(ctx, operation)
+		if err != nil {
+			return "", errors.Wrapf(err, "operation %d failed", index)
+		}
+
+		ids = append(ids, id)
+		results = append(results, result)
+	}
+
+	return string(rmap.NewFromMap(map[string]interface{}{
+		OutputResultKey: results,
+	}).Bytes()), nil
+}
+
+// resolveBatchPlaceholders replaces all ${N} placeholders in operation with ID of N-th operation
+// only operations that were already executed can be referenced
+func resolveBatchPlaceholders(operation string, ids []string) (rmap.Rmap, error) {
+	var err error
+
+	resolved := batchPlaceholder.ReplaceAllStringFunc(operation, func(placeholder string) string {
+		index, _ := strconv.Atoi(batchPlaceholder.FindStringSubmatch(placeholder)[1])
+		if index >= len(ids) {
+			err = ErrorBadRequest(fmt.Sprintf("placeholder: %s refers to operation that was not executed yet", placeholder))
+			return placeholder
+		}
+		return ids[index]
+	})
+
+	if err != nil {
+		return rmap.Rmap{}, err
+	}
+
+	op, err := rmap.NewFromString(resolved)
+	if err != nil {
+		return rmap.Rmap{}, ErrorBadRequest(fmt.Sprintf("operation must be JSON object: %s", err))
+	}
+
+	return op, nil
+}
+
+// assetBatchOperation executes one operation from batch using appropriate backend
+// returns ID of affected asset and unwrapped result of backend
+func assetBatchOperation(ctx ContextInterface, operation rmap.Rmap) (string, interface{}, error) {
+	opType, err := operation.GetString(BatchOpKey)
+	if err != nil {
+		return "", nil, ErrorBadRequest(fmt.Sprintf("operation has missing or invalid key: %s", BatchOpKey))
+	}
+
+	name, err := operation.GetString(BatchNameKey)
+	if err != nil {
+		return "", nil, ErrorBadRequest(fmt.Sprintf("operation has missing or invalid key: %s", BatchNameKey))
+	}
+
+	id := ""
+	if operation.Exists(BatchIdKey) {
+		id, err = operation.GetString(BatchIdKey)
+		if err != nil {
+			return "", nil, ErrorBadRequest(fmt.Sprintf("operation has invalid key: %s", BatchIdKey))
+		}
+	} else if opType != BatchCreateOperation {
+		return "", nil, ErrorBadRequest(fmt.Sprintf("operation: %s requires key: %s", opType, BatchIdKey))
+	}
+
+	version := -1
+	if operation.Exists(BatchVersionKey) {
+		version, err = operation.GetInt(BatchVersionKey)
+		if err != nil {
+			return "", nil, ErrorBadRequest(fmt.Sprintf("operation has invalid key: %s", BatchVersionKey))
+		}
+	}
+
+	revision := anyRevision
+	if operation.Exists(BatchRevisionKey) {
+		// revision must be non-negative integer, fractional number is not truncated
+		revisionF, isNumber := operation.Mapa[BatchRevisionKey].(float64)
+		if !isNumber || revisionF < 0 || revisionF != math.Trunc(revisionF) {
+			return "", nil, ErrorBadRequest(fmt.Sprintf("operation has invalid key: %s", BatchRevisionKey))
+		}
+		revision = int(revisionF)
+	}
+
+	var output string
+
+	switch opType {
+	case BatchCreateOperation:
+		data, err := getBatchObject(operation, BatchDataKey)
+		if err != nil {
+			return "", nil, err
+		}
+
+		output, err = assetCreateBackend(ctx, name, data, version, id, false)
+		if err != nil {
+			return "", nil, errors.Wrap(err, "assetCreateBackend() failed")
+		}
+	case BatchUpdateOperation:
+		patch, err := getBatchPatch(operation)
+		if err != nil {
+			return "", nil, err
+		}
+
+		output, err = assetUpdateBackend(ctx, name, id, patch, revision, false)
+		if err != nil {
+			return "", nil, errors.Wrap(err, "assetUpdateBackend() failed")
+		}
+	case BatchMigrateOperation:
+		patch, err := getBatchPatch(operation)
+		if err != nil {
+			return "", nil, err
+		}
+
+		output, err = assetMigrateBackend(ctx, name, id, patch, version, revision)
+		if err != nil {
+			return "", nil, errors.Wrap(err, "assetMigrateBackend() failed")
+		}
+	case BatchDeleteOperation:
+		output, err = assetDeleteBackend(ctx, name, id, revision, false)
+		if err != nil {
+			return "", nil, errors.Wrap(err, "assetDeleteBackend() failed")
+		}
+	default:
+		return "", nil, ErrorBadRequest(fmt.Sprintf("unknown operation: %s", opType))
+	}
+
+	outputRm, err := rmap.NewFromString(output)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "rmap.NewFromString() failed")
+	}
+
+	result := outputRm.Mapa[OutputResultKey]
+
+	// created, updated or migrated asset carries its ID, delete returns only status
+	if asset, ok := result.(map[string]interface{}); ok {
+		id, err = AssetGetID(rmap.NewFromMap(asset))
+		if err != nil {
+			return "", nil, errors.Wrap(err, "konst.AssetGetID() failed")
+		}
+	}
+
+	return id, result, nil
+}
+
+// getBatchObject returns JSON object stored under key in operation as string, or empty object if key is not present
+func getBatchObject(operation rmap.Rmap, key string) (string, error) {
+	if !operation.Exists(key) {
+		return rmap.NewEmpty().String(), nil
+	}
+
+	object, err := operation.GetRmap(key)
+	if err != nil {
+		return "", ErrorBadRequest(fmt.Sprintf("operation has invalid key: %s, JSON object expected", key))
+	}
+
+	return object.String(), nil
+}
+
+// getBatchPatch returns patch stored in operation as string, or empty JSON merge patch if key is not present
+// patch is either JSON merge patch (object) or JSON patch (array of operations), backend recognizes it by isJSONPatch
+func getBatchPatch(operation rmap.Rmap) (string, error) {
+	if operations, isArray := operation.Mapa[BatchPatchKey].([]interface{}); isArray {
+		patchBytes, err := json.Marshal(operations)
+		if err != nil {
+			return "", errors.Wrap(err, "json.Marshal() failed")
+		}
+
+		return string(patchBytes), nil
+	}
+
+	patch, err := getBatchObject(operation, BatchPatchKey)
+	if err != nil {
+		return "", ErrorBadRequest(fmt.Sprintf("operation has invalid key: %s, JSON object or array expected", BatchPatchKey))
+	}
+
+	return patch, nil
+}
@@ -0,0 +1,249 @@
+package engine
+
+import (
+	"fmt"
+
+	. "github.com/KompiTech/fabric-cc-core/v2/pkg/konst"
+	"github.com/KompiTech/rmap"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/pkg/errors"
+)
+
+// writeTrackingStub passes everything to wrapped stub and remembers, if any write was staged in TX
+type writeTrackingStub struct {
+	shim.ChaincodeStubInterface
+	isWritten bool
+}
+
+func (s *writeTrackingStub) PutState(key string, value []byte) error {
+	s.isWritten = true
+	return s.ChaincodeStubInterface.PutState(key, value)
+}
+
+func (s *writeTrackingStub) DelState(key string) error {
+	s.isWritten = true
+	return s.ChaincodeStubInterface.DelState(key)
+}
+
+func (s *writeTrackingStub) PutPrivateData(collection string, key string, value []byte) error {
+	s.isWritten = true
+	return s.ChaincodeStubInterface.PutPrivateData(collection, key, value)
+}
+
+func (s *writeTrackingStub) DelPrivateData(collection string, key string) error {
+	s.isWritten = true
+	return s.ChaincodeStubInterface.DelPrivateData(collection, key)
+}
+
+func (s *writeTrackingStub) SetStateValidationParameter(key string, ep []byte) error {
+	s.isWritten = true
+	return s.ChaincodeStubInterface.SetStateValidationParameter(key, ep)
+}
+
+func (s *writeTrackingStub) SetPrivateDataValidationParameter(collection, key string, ep []byte) error {
+	s.isWritten = true
+	return s.ChaincodeStubInterface.SetPrivateDataValidationParameter(collection, key, ep)
+}
+
+func assetMigrateAllFrontend(ctx ContextInterface) (string, error) {
+	name, err := ctx.ParamString(NameParam)
+	if err != nil {
+		return "", err
+	}
+
+	version, err := ctx.ParamInt(VersionParam)
+	if err != nil {
+		return "", err
+	}
+
+	// bookmark and limit are optional
+	params := ctx.Params()
+
+	bookmark := ""
+	if bookmarkI, exists := params[BookmarkParam]; exists {
+		bookmark = string(bookmarkI.([]byte))
+	}
+
+	limit := PageSize
+	if _, exists := params[LimitParam]; exists {
+		limit, err = ctx.ParamInt(LimitParam)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	output, err := assetMigrateAllBackend(ctx, name, version, bookmark, limit)
+	if err != nil {
+		return "", err
+	}
+
+	return string(output.Bytes()), nil
+}
+
+// migrateAllFunc is built-in function with the same functionality as assetMigrateAll method
+var migrateAllFunc = func(ctx ContextInterface, input rmap.Rmap, output rmap.Rmap) (rmap.Rmap, error) {
+	null := rmap.Rmap{}
+
+	name, err := input.GetString(NameParam)
+	if err != nil {
+		return null, ErrorBadRequest(err.Error())
+	}
+
+	version := -1
+	if input.Exists(VersionParam) {
+		version, err = input.GetInt(VersionParam)
+		if err != nil {
+			return null, ErrorBadRequest(err.Error())
+		}
+	}
+
+	bookmark := ""
+	if input.Exists(BookmarkParam) {
+		bookmark, err = input.GetString(BookmarkParam)
+		if err != nil {
+			return null, ErrorBadRequest(err.Error())
+		}
+	}
+
+	limit := PageSize
+	if input.Exists(LimitParam) {
+		limit, err = input.GetInt(LimitParam)
+		if err != nil {
+			return null, ErrorBadRequest(err.Error())
+		}
+	}
+
+	return assetMigrateAllBackend(ctx, name, version, bookmark, limit)
+}
+
+// assetMigrateAllBackend migrates up to limit instances of asset name with version lower than target version
+// instances are processed in order of UUID, bookmark is UUID of last processed instance and it is empty, when there are no more instances to process
+// failure of one instance before any of its writes is staged does not abort migration of others, it is reported in result
+// staged writes cannot be undone, so failure after them (e.g. in AfterMigrate) fails whole TX
+func assetMigrateAllBackend(ctx ContextInterface, name string, version int, bookmark string, limit int) (rmap.Rmap, error) {
+	reg := ctx.GetRegistry()
+
+	if err := enforceCustomAccess(reg, "/"+name+"/*", MigrateAction); err != nil {
+		return rmap.Rmap{}, err
+	}
+
+	if limit <= 0 {
+		return rmap.Rmap{}, ErrorBadRequest(fmt.Sprintf("invalid limit: %d, must be greater than 0", limit))
+	}
+
+	_, targetVersion, err := reg.GetItem(name, version)
+	if err != nil {
+		return rmap.Rmap{}, errors.Wrap(err, "reg.GetItem() failed")
+	}
+
+	selector := map[string]interface{}{
+		AssetVersionKey: map[string]interface{}{"$lt": targetVersion},
+	}
+
+	if bookmark != "" {
+		selector[AssetIdKey] = map[string]interface{}{"$gt": bookmark}
+	}
+
+	// one more instance is fetched to know if there are more of them
+	// pagination of rich queries cannot be used, because it makes TX read-only
+	// sort requires CouchDB index on docType and uuid, it is generated by metainfgen as default index uuid
+	query := rmap.NewFromMap(map[string]interface{}{
+		QuerySelectorKey: selector,
+		QueryFieldsKey:   []interface{}{AssetIdKey},
+		QuerySortKey:     []interface{}{map[string]interface{}{AssetDocTypeKey: "asc"}, map[string]interface{}{AssetIdKey: "asc"}},
+		QueryLimitKey:    limit + 1,
+	})
+
+	ids, err := queryAssetIDs(reg, name, query, limit+1)
+	if err != nil {
+		return rmap.Rmap{}, err
+	}
+
+	bookmark = ""
+	if len(ids) > limit {
+		ids = ids[:limit]
+		bookmark = ids[limit-1]
+	}
+
+	outputSlice := make([]interface{}, 0, len(ids))
+	for _, id := range ids {
+		migrated, isWritten, err := migrateInstance(ctx, name, id, targetVersion)
+		if err != nil {
+			if isWritten {
+				return rmap.Rmap{}, errors.Wrapf(err, "migration of asset: %s failed after its writes were staged", id)
+			}
+
+			outputSlice = append(outputSlice, map[string]interface{}{
+				AssetIdKey:     id,
+				OutputErrorKey: err.Error(),
+			})
+			continue
+		}
+
+		migratedAsset, err := rmap.NewFromString(migrated)
+		if err != nil {
+			return rmap.Rmap{}, errors.Wrap(err, "rmap.NewFromString() failed")
+		}
+
+		migratedVersion, err := migratedAsset.GetJPtr("/" + OutputResultKey + "/" + AssetVersionKey)
+		if err != nil {
+			return rmap.Rmap{}, errors.Wrap(err, "migratedAsset.GetJPtr() failed")
+		}
+
+		outputSlice = append(outputSlice, map[string]interface{}{
+			AssetIdKey:      id,
+			AssetVersionKey: migratedVersion,
+		})
+	}
+
+	return rmap.NewFromMap(map[string]interface{}{
+		OutputResultKey:   outputSlice,
+		OutputBookmarkKey: bookmark,
+	}), nil
+}
+
+// migrateInstance migrates one instance like assetMigrate with empty patch and returns, if any write was staged by it
+func migrateInstance(ctx ContextInterface, name, id string, version int) (string, bool, error) {
+	stub := ctx.GetStub()
+	tracker := &writeTrackingStub{ChaincodeStubInterface: stub}
+
+	ctx.SetStub(tracker)
+	migrated, err := assetMigrateBackend(ctx, name, id, "{}", version, anyRevision)
+	ctx.SetStub(stub)
+
+	return migrated, tracker.isWritten, err
+}
+
+// queryAssetIDs returns UUIDs of up to max assets matching the query, iterator is closed before assets are modified
+func queryAssetIDs(reg *Registry, name string, query rmap.Rmap, max int) ([]string, error) {
+	iter, _, err := reg.GetQueryIterator(name, query, "", 0)
+	if err != nil {
+		return nil, errors.Wrap(err, "reg.GetQueryIterator() failed")
+	}
+
+	defer (func() {
+		_ = iter.Close()
+	})()
+
+	ids := make([]string, 0, max)
+	for len(ids) < max {
+		asset, err := iter.Next(false)
+		if err != nil {
+			return nil, errors.Wrap(err, "iter.Next() failed")
+		}
+
+		if asset == nil {
+			// end of iterator
+			break
+		}
+
+		id, err := AssetGetID(*asset)
+		if err != nil {
+			return nil, errors.Wrap(err, "konst.AssetGetID() failed")
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
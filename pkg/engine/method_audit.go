@@ -0,0 +1,267 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	. "github.com/KompiTech/fabric-cc-core/v2/pkg/konst"
+	"github.com/KompiTech/rmap"
+	"github.com/pkg/errors"
+)
+
+// auditFilter selects audit records returned by auditQuery, empty fields do not filter anything
+type auditFilter struct {
+	fingerprint string
+	docType     string
+	id          string
+	pointer     string
+	from        time.Time
+	to          time.Time
+	bookmark    string // key of the first record of requested page
+	limit       int    // maximum number of records read for one page
+}
+
+// newAuditFilter parses filter from JSON input of auditQuery, empty input selects all records
+func newAuditFilter(inputB string) (auditFilter, error) {
+	filter := auditFilter{limit: PageSize}
+
+	if inputB == "" {
+		return filter, nil
+	}
+
+	input, err := rmap.NewFromString(inputB)
+	if err != nil {
+		return filter, ErrorBadRequest(fmt.Sprintf("input must be JSON object: %s", err))
+	}
+
+	if input.Exists(QueryLimitKey) {
+		filter.limit, err = input.GetInt(QueryLimitKey)
+		if err != nil || filter.limit < 1 {
+			return filter, ErrorBadRequest(fmt.Sprintf("input key: %s must be positive integer", QueryLimitKey))
+		}
+		delete(input.Mapa, QueryLimitKey)
+	}
+
+	for key, value := range input.Mapa {
+		valueS, isString := value.(string)
+		if !isString {
+			return filter, ErrorBadRequest(fmt.Sprintf("input key: %s must be string", key))
+		}
+
+		switch key {
+		case AssetFingerprintKey:
+			filter.fingerprint = valueS
+		case AssetDocTypeKey:
+			filter.docType = strings.ToUpper(valueS)
+		case AssetIdKey:
+			filter.id = valueS
+		case AuditPointerKey:
+			filter.pointer = valueS
+		case QueryBookmarkKey:
+			filter.bookmark = valueS
+		case AuditFromKey, AuditToKey:
+			t, err := time.Parse(time.RFC3339, valueS)
+			if err != nil {
+				return filter, ErrorBadRequest(fmt.Sprintf("input key: %s must be RFC 3339 timestamp: %s", key, err))
+			}
+
+			if key == AuditFromKey {
+				filter.from = t
+			} else {
+				filter.to = t
+			}
+		default:
+			return filter, ErrorBadRequest(fmt.Sprintf("input has unknown key: %s", key))
+		}
+	}
+
+	return filter, nil
+}
+
+// matchChange returns true, if audit record change matches asset and pointer of filter
+func (f auditFilter) matchChange(change map[string]interface{}) bool {
+	if f.docType != "" && change[AssetDocTypeKey] != f.docType {
+		return false
+	}
+
+	if f.id != "" && change[AssetIdKey] != f.id {
+		return false
+	}
+
+	if f.pointer == "" {
+		return true
+	}
+
+	pointers, _ := change[AuditPointersKey].([]interface{})
+	for _, pointerI := range pointers {
+		pointer, _ := pointerI.(string)
+		if pointer == f.pointer || strings.HasPrefix(pointer, f.pointer+JPtrSeparator) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchFingerprint returns true, if identity of filter called the function or approved its proposal
+func (f auditFilter) matchFingerprint(record rmap.Rmap) bool {
+	if f.fingerprint == "" || record.Mapa[AssetFingerprintKey] == f.fingerprint {
+		return true
+	}
+
+	proposal, _ := record.Mapa[AuditProposalKey].(map[string]interface{})
+	approvedBy, _ := proposal[AuditApprovedByKey].([]interface{})
+	for _, fingerprint := range approvedBy {
+		if fingerprint == f.fingerprint {
+			return true
+		}
+	}
+
+	return false
+}
+
+// getRecordTime returns timestamp of audit record
+func getRecordTime(record rmap.Rmap) (time.Time, error) {
+	timestampS, err := record.GetString(AuditTimestampKey)
+	if err != nil {
+		return time.Time{}, errors.Wrap(err, "record.GetString() failed")
+	}
+
+	timestamp, err := time.Parse(time.RFC3339, timestampS)
+	if err != nil {
+		return time.Time{}, errors.Wrap(err, "time.Parse() failed")
+	}
+
+	return timestamp, nil
+}
+
+// getKeyRange returns range of keys of audit records from time range of filter, end key is excluded
+// record timestamp has precision of seconds, so range covers whole seconds and records are matched by filter
+func (f auditFilter) getKeyRange() (string, string) {
+	startKey := AuditRecordPrefix + ":"
+	if !f.from.IsZero() {
+		startKey = getAuditKey(f.from.Truncate(time.Second), "")
+	}
+
+	// ; follows : in ASCII, so it is after all audit record keys
+	endKey := AuditRecordPrefix + ";"
+	if !f.to.IsZero() {
+		endKey = getAuditKey(f.to.Truncate(time.Second).Add(time.Second), "")
+	}
+
+	return startKey, endKey
+}
+
+// match returns true, if audit record matches filter
+func (f auditFilter) match(record rmap.Rmap) (bool, error) {
+	if !f.matchFingerprint(record) {
+		return false, nil
+	}
+
+	if !f.from.IsZero() || !f.to.IsZero() {
+		timestamp, err := getRecordTime(record)
+		if err != nil {
+			return false, errors.Wrap(err, "getRecordTime() failed")
+		}
+
+		if (!f.from.IsZero() && timestamp.Before(f.from)) || (!f.to.IsZero() && timestamp.After(f.to)) {
+			return false, nil
+		}
+	}
+
+	if f.docType == "" && f.id == "" && f.pointer == "" {
+		return true, nil
+	}
+
+	changes, err := record.GetIterable(AuditChangesKey)
+	if err != nil {
+		return false, errors.Wrap(err, "record.GetIterable() failed")
+	}
+
+	for _, changeI := range changes {
+		change, isMap := changeI.(map[string]interface{})
+		if isMap && f.matchChange(change) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func auditQueryFrontend(ctx ContextInterface) (string, error) {
+	input, err := ctx.ParamString(InputParam)
+	if err != nil {
+		return "", err
+	}
+
+	reg := ctx.Get(RegistryKey).(*Registry)
+	if err := enforceCustomAccess(reg, "/"+AuditCasbinObject, ReadAction); err != nil {
+		return "", err
+	}
+
+	return auditQueryBackend(ctx, input)
+}
+
+// auditQueryBackend returns page of audit records matching filter in input in chronological order
+// only records in time range of filter are read, their keys start with timestamp
+// page ends after limit of records is read, even when fewer of them match, so one call never scans more than limit records
+func auditQueryBackend(ctx ContextInterface, input string) (string, error) {
+	filter, err := newAuditFilter(input)
+	if err != nil {
+		return "", err
+	}
+
+	startKey, endKey := filter.getKeyRange()
+
+	if filter.bookmark != "" {
+		if filter.bookmark < startKey || filter.bookmark >= endKey {
+			return "", ErrorBadRequest(fmt.Sprintf("input key: %s is not from time range of filter", QueryBookmarkKey))
+		}
+		startKey = filter.bookmark
+	}
+
+	iterator, err := ctx.Stub().GetStateByRange(startKey, endKey)
+	if err != nil {
+		return "", errors.Wrap(err, "ctx.Stub().GetStateByRange() failed")
+	}
+	defer func() { _ = iterator.Close() }()
+
+	records := []interface{}{}
+	bookmark := ""
+	scanned := 0
+
+	for iterator.HasNext() {
+		item, err := iterator.Next()
+		if err != nil {
+			return "", errors.Wrap(err, "iterator.Next() failed")
+		}
+
+		if scanned == filter.limit {
+			// page is read, next page starts with this record
+			bookmark = item.GetKey()
+			break
+		}
+		scanned++
+
+		record, err := rmap.NewFromBytes(item.GetValue())
+		if err != nil {
+			return "", errors.Wrap(err, "rmap.NewFromBytes() failed")
+		}
+
+		matches, err := filter.match(record)
+		if err != nil {
+			return "", errors.Wrap(err, "filter.match() failed")
+		}
+
+		if matches {
+			records = append(records, record.Mapa)
+		}
+	}
+
+	output := rmap.NewEmpty()
+	output.Mapa[OutputResultKey] = records
+	output.Mapa[OutputBookmarkKey] = bookmark
+
+	return string(output.Bytes()), nil
+}
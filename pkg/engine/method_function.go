@@ -55,6 +55,12 @@ func functionBackend(ctx ContextInterface, name string, isInvoke bool, data stri
 		}
 	}
 
+	if isInvoke {
+		if err := checkApproval(ctx, newApprovalOperation(ApprovalFunctionInvoke, name)); err != nil {
+			return "", err
+		}
+	}
+
 	var input rmap.Rmap
 
 	if len(data) == 0 {
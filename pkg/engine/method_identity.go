@@ -11,6 +11,7 @@ import (
 )
 
 // makes sure that super user role exists, if not, creates it
+// when super user role is not superuser role in configuration, it is created with grants to manage chaincode, so bootstrap still works
 func ensureSuperUserRole(reg *Registry) error {
 	superuserRole, err := reg.GetAsset(RoleAssetName, SuperuserRoleUUID, false, false)
 	if err != nil {
@@ -26,6 +27,14 @@ func ensureSuperUserRole(reg *Registry) error {
 
 		superuserRole.Mapa["name"] = "Superuser"
 
+		if !isSuperuserRole(reg.ctx, SuperuserRoleUUID) {
+			superuserRole.Mapa[GrantsKey] = getBootstrapGrants()
+		}
+
+		// superuser role is created by bootstrap, nobody can hold it yet
+		reg.superuserBootstrap = true
+		defer func() { reg.superuserBootstrap = false }()
+
 		if err := reg.PutAsset(superuserRole, true); err != nil {
 			return errors.Wrap(err, "reg.PutAsset() failed")
 		}
@@ -151,6 +160,12 @@ func identityAddMeFrontend(ctx ContextInterface) (string, error) {
 		return "", errors.Wrap(err, "processInitManagerObj() failed")
 	}
 
+	if suGrantIsNeeded {
+		// init manager is granted superuser role by bootstrap, superuser guard would refuse it
+		reg.superuserBootstrap = true
+		defer func() { reg.superuserBootstrap = false }()
+	}
+
 	if !existingIdentity.IsEmpty() {
 		// identity already exists
 		// but if SU needs granting, it must be saved here
@@ -325,16 +340,13 @@ func identityUpdateFrontend(ctx ContextInterface) (string, error) {
 		return "", err
 	}
 
-	return assetUpdateBackend(ctx, IdentityAssetName, fp, patch, false)
+	return assetUpdateBackend(ctx, IdentityAssetName, fp, patch, anyRevision, false)
 }
 
-// extra validations required for identity asset regarding SUs
-func identityExtraValidate(reg *Registry, identityAssetPre, identityAssetPost, thisIdentity rmap.Rmap) error {
-	// particular validations needs to be done:
-	// - only superUser can grant superUser to somebody else
-	// - only superUser can remove superUser from somebody else (also by disabling the identity)
-	// - last superUser cannot remove his superUser (also by disabling the identity)
-	// - nobody can disable their own identity
+// extra validations required for identity asset
+// granting and revoking of superuser role is checked by registry for every write of identity, role and group
+func identityExtraValidate(identityAssetPost, thisIdentity rmap.Rmap) error {
+	// nobody can disable their own identity
 	isEnabled, err := identityAssetPost.GetBool(IsEnabledKey)
 	if err != nil {
 		return errors.Wrap(err, "identityAssetPost.GetBool() failed")
@@ -357,71 +369,6 @@ func identityExtraValidate(reg *Registry, identityAssetPre, identityAssetPost, t
 		}
 	}
 
-	rolesDidExist := identityAssetPre.Exists(RolesKey)
-	rolesExist := identityAssetPost.Exists(RolesKey)
-	var revokingSU, grantingSU bool
-	if !rolesDidExist && !rolesExist {
-		// no granted roles are present and nothing is changed
-		revokingSU = false
-		grantingSU = false
-	} else if !rolesDidExist && rolesExist {
-		// no roles existed and some were granted
-		revokingSU = false
-		grantingSU, err = identityAssetPost.Contains(RolesKey, SuperuserRoleUUID)
-		if err != nil {
-			return errors.Wrap(err, "identityAssetPost.Contains() failed")
-		}
-	} else if rolesDidExist && !rolesExist {
-		// some roles existed and all were removed
-		grantingSU = false
-		revokingSU, err = identityAssetPre.Contains(RolesKey, SuperuserRoleUUID)
-		if err != nil {
-			return errors.Wrap(err, "identityAssetPre.Contains() failed")
-		}
-	} else {
-		// some roles existed and they still exist
-		suWasGranted, err := identityAssetPre.Contains(RolesKey, SuperuserRoleUUID)
-		if err != nil {
-			return errors.Wrap(err, "identityAssetPre.Contains() failed")
-		}
-
-		suIsGranted, err := identityAssetPost.Contains(RolesKey, SuperuserRoleUUID)
-		if err != nil {
-			return errors.Wrap(err, "identityAssetPost.Contains() failed")
-		}
-
-		grantingSU = !suWasGranted && suIsGranted
-		revokingSU = suWasGranted && !suIsGranted
-		// SU is granted if role is granted and identity asset is enabled
-		grantingSU = isEnabled && !suWasGranted && suIsGranted
-
-		// SU is revoked if role is removed on enabled asset OR if role with SU present is disabled
-		revokingSU = (isEnabled && suWasGranted && !suIsGranted) || (!isEnabled && suIsGranted)
-	}
-
-	if grantingSU || revokingSU {
-		// when revoking or granting SU, current role MUST be SU
-		thisIsSU, err := thisIdentity.ContainsJPtrKV(RolesJPtr, "/"+AssetIdKey, SuperuserRoleUUID)
-		if err != nil {
-			return errors.Wrap(err, "thisIdentity.ContainsJPtr() failed")
-		}
-
-		if !thisIsSU {
-			return ErrorForbidden("to manage SuperUser role, you must have it granted")
-		}
-
-		if revokingSU {
-			SUs, _, err := reg.QueryAssets(IdentityAssetName, rmap.NewFromMap(getSUQuery()), "", false, false, PageSize)
-			if err != nil {
-				return errors.Wrap(err, "reg.QueryAssets() failed")
-			}
-
-			if len(SUs) == 1 {
-				return ErrorBadRequest("unable to remove last superuser role")
-			}
-		}
-	}
-
 	return nil
 }
 
@@ -442,11 +389,6 @@ func identityQueryFrontend(ctx ContextInterface) (string, error) {
 
 // deprecated, use assetCreateDirect(identity, ...)
 func identityCreateDirectFrontend(ctx ContextInterface) (string, error) {
-	now, err := ctx.Time()
-	if err != nil {
-		return "", errors.Wrap(err, "ctx.Time() failed")
-	}
-
 	data, err := ctx.ParamString(DataParam)
 	if err != nil {
 		return "", err
@@ -457,7 +399,7 @@ func identityCreateDirectFrontend(ctx ContextInterface) (string, error) {
 		return "", err
 	}
 
-	return assetCreateBackend(ctx, now, IdentityAssetName, data, 1, id, true)
+	return assetCreateBackend(ctx, IdentityAssetName, data, 1, id, true)
 }
 
 // deprecated, use assetUpdateDirect(identity, ...)
@@ -472,7 +414,7 @@ func identityUpdateDirectFrontend(ctx ContextInterface) (string, error) {
 		return "", err
 	}
 
-	return assetUpdateBackend(ctx, IdentityAssetName, id, patch, true)
+	return assetUpdateBackend(ctx, IdentityAssetName, id, patch, anyRevision, true)
 }
 
 // attempts to migrate identity identified by eng.PreviousIDFunc to a new one identified by eng.CurrentIDFunc
@@ -503,6 +445,10 @@ func migrateOldIdentity(ctx ContextInterface, oldIdentity rmap.Rmap) (rmap.Rmap,
 		return null, nil
 	}
 
+	// roles of old identity are moved to new identity of the same holder, superuser guard would refuse it
+	reg.superuserBootstrap = true
+	defer func() { reg.superuserBootstrap = false }()
+
 	// create new identity asset by copying old and using new fingerprint
 	newIdentity := oldIdentity.Copy()
 	newIdentity.Mapa["fingerprint"] = newFP
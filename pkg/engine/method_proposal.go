@@ -0,0 +1,839 @@
+package engine
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	. "github.com/KompiTech/fabric-cc-core/v2/pkg/konst"
+	"github.com/KompiTech/rmap"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/pkg/errors"
+)
+
+// approvalPolicy defines how many approvals operation requires and how long its proposal waits for them
+type approvalPolicy struct {
+	quorum  int
+	timeout time.Duration
+}
+
+// approvalOperations are operations, that can require approval, every chaincode function executing them is checked
+var approvalOperations = map[string]bool{
+	ApprovalAssetCreate:     true,
+	ApprovalAssetUpdate:     true,
+	ApprovalAssetDelete:     true,
+	ApprovalAssetMigrate:    true,
+	ApprovalAssetRestore:    true,
+	ApprovalRegistryUpsert:  true,
+	ApprovalSingletonUpsert: true,
+	ApprovalFunctionInvoke:  true,
+}
+
+// approvalOperation is operation with its target, that is checked against approval policies
+type approvalOperation struct {
+	operation  string
+	target     string // asset, registry or singleton name, or function name
+	systemRole bool   // target is role and role is system
+}
+
+// newApprovalOperation returns operation on target, assets are instances before or after operation
+// role operation is on system role, when any of assets is system role
+func newApprovalOperation(operation, target string, assets ...rmap.Rmap) approvalOperation {
+	if operation != ApprovalFunctionInvoke {
+		target = strings.ToLower(target)
+	}
+
+	systemRole := false
+	if target == RoleAssetName {
+		for _, asset := range assets {
+			if isSystem, _ := asset.Mapa[RoleIsSystemKey].(bool); isSystem {
+				systemRole = true
+			}
+		}
+	}
+
+	return approvalOperation{
+		operation:  operation,
+		target:     target,
+		systemRole: systemRole,
+	}
+}
+
+// policyKeys returns keys of approval policies, that apply to operation
+func (o approvalOperation) policyKeys() []string {
+	keys := []string{o.operation, o.operation + "/" + o.target}
+	if o.systemRole {
+		keys = append(keys, o.operation+"/"+o.target+"/"+ApprovalSystemScope)
+	}
+	return keys
+}
+
+// isAuthorizationChange returns true, when operation changes approval policies or casbin model
+// such operation requires the highest quorum of current policies, so single identity cannot relax them
+func (o approvalOperation) isAuthorizationChange() bool {
+	return o.operation == ApprovalSingletonUpsert && (o.target == ApprovalPolicySingletonName || o.target == CasbinModelSingletonName)
+}
+
+func (o approvalOperation) String() string {
+	return o.operation + "/" + o.target
+}
+
+// normalizeFunctionName returns chaincode function name with lowercase first letter, router accepts both variants
+func normalizeFunctionName(name string) string {
+	if name == "" {
+		return name
+	}
+
+	return strings.ToLower(name[:1]) + name[1:]
+}
+
+// normalizePolicyKey validates key of approval policy and returns it in form used by approvalOperation.policyKeys
+// key is operation, operation/target or role operation limited to system roles: assetUpdate/role/system
+func normalizePolicyKey(key string) (string, error) {
+	parts := strings.Split(key, "/")
+
+	operation := normalizeFunctionName(parts[0])
+	if !approvalOperations[operation] {
+		return "", fmt.Errorf("operation: %s cannot require approval", parts[0])
+	}
+
+	if len(parts) == 1 {
+		return operation, nil
+	}
+
+	if len(parts) > 3 || parts[1] == "" {
+		return "", fmt.Errorf("approval policy key: %s must be operation, operation/target or operation/role/%s", key, ApprovalSystemScope)
+	}
+
+	target := newApprovalOperation(operation, parts[1])
+	if len(parts) == 3 {
+		if parts[2] != ApprovalSystemScope || target.target != RoleAssetName || !strings.HasPrefix(operation, "asset") {
+			return "", fmt.Errorf("approval policy key: %s can limit only asset operation on role to: %s", key, ApprovalSystemScope)
+		}
+
+		target.systemRole = true
+		return target.policyKeys()[2], nil
+	}
+
+	return target.String(), nil
+}
+
+// getApprovalPolicies returns approval policies from value of approval_policy singleton, key is normalized policy key
+func getApprovalPolicies(singleton rmap.Rmap) (map[string]approvalPolicy, error) {
+	value, err := singleton.GetRmap(SingletonValueKey)
+	if err != nil {
+		return nil, fmt.Errorf("singleton: %s must contain object mapping operation to approval policy in key: %s", ApprovalPolicySingletonName, SingletonValueKey)
+	}
+
+	policies := map[string]approvalPolicy{}
+	for key := range value.Mapa {
+		normalized, err := normalizePolicyKey(key)
+		if err != nil {
+			return nil, err
+		}
+
+		entry, err := value.GetRmap(key)
+		if err != nil {
+			return nil, fmt.Errorf("approval policy of: %s must be object", key)
+		}
+
+		quorum, err := entry.GetInt(ApprovalQuorumKey)
+		if err != nil || quorum < 1 {
+			return nil, fmt.Errorf("approval policy of: %s must contain positive integer in key: %s", key, ApprovalQuorumKey)
+		}
+
+		timeoutS := ApprovalDefaultTimeout
+		if entry.Exists(ApprovalTimeoutKey) {
+			timeoutS, err = entry.GetString(ApprovalTimeoutKey)
+			if err != nil {
+				return nil, fmt.Errorf("approval policy of: %s must contain duration string in key: %s", key, ApprovalTimeoutKey)
+			}
+		}
+
+		timeout, err := time.ParseDuration(timeoutS)
+		if err != nil || timeout <= 0 {
+			return nil, fmt.Errorf("approval policy of: %s has invalid timeout: %s", key, timeoutS)
+		}
+
+		policies[normalized] = approvalPolicy{
+			quorum:  quorum,
+			timeout: timeout,
+		}
+	}
+
+	return policies, nil
+}
+
+// getApprovalPolicy returns the strictest approval policy of operations, false is returned when no operation requires approval
+// policy with the highest quorum applies, shorter timeout wins when quorums are equal
+// change of approval policies or casbin model is checked against all of them, even when no policy matches it
+func getApprovalPolicy(ctx ContextInterface, operations ...approvalOperation) (approvalPolicy, bool, error) {
+	reg := ctx.GetRegistry()
+
+	exists, err := reg.ExistsSingleton(ApprovalPolicySingletonName, -1)
+	if err != nil {
+		return approvalPolicy{}, false, errors.Wrap(err, "reg.ExistsSingleton() failed")
+	}
+
+	if !exists {
+		return approvalPolicy{}, false, nil
+	}
+
+	singleton, _, err := reg.GetSingleton(ApprovalPolicySingletonName, -1)
+	if err != nil {
+		return approvalPolicy{}, false, errors.Wrap(err, "reg.GetSingleton() failed")
+	}
+
+	policies, err := getApprovalPolicies(singleton)
+	if err != nil {
+		return approvalPolicy{}, false, errors.Wrap(err, "getApprovalPolicies() failed")
+	}
+
+	strictest := approvalPolicy{}
+	required := false
+
+	for _, operation := range operations {
+		keys := operation.policyKeys()
+		if operation.isAuthorizationChange() {
+			keys = make([]string, 0, len(policies))
+			for key := range policies {
+				keys = append(keys, key)
+			}
+		}
+
+		for _, key := range keys {
+			policy, exists := policies[key]
+			if !exists {
+				continue
+			}
+
+			if !required || policy.quorum > strictest.quorum || (policy.quorum == strictest.quorum && policy.timeout < strictest.timeout) {
+				strictest = policy
+				required = true
+			}
+		}
+	}
+
+	return strictest, required, nil
+}
+
+// checkApproval refuses operation, that requires approval, unless it is executed by proposal approved by enough identities
+// it is called by every backend executing the operation, so all chaincode functions doing the same operation are checked
+// init is not checked, chaincode must be always possible to bootstrap
+func checkApproval(ctx ContextInterface, operation approvalOperation) error {
+	if isInitFunction(ctx) {
+		return nil
+	}
+
+	policy, required, err := getApprovalPolicy(ctx, operation)
+	if err != nil {
+		return errors.Wrap(err, "getApprovalPolicy() failed")
+	}
+
+	if !required {
+		return nil
+	}
+
+	if approvals, _ := ctx.Get(ApprovalsKey).(int); approvals >= policy.quorum {
+		return nil
+	}
+
+	return ErrorForbidden(fmt.Sprintf("operation: %s requires approval of %d identities, submit it by proposalCreate", operation, policy.quorum))
+}
+
+// proposalStub executes proposed function with its arguments and with identity of requester
+// everything else is passed to wrapped stub of TX, so operation is part of TX that approved it
+type proposalStub struct {
+	shim.ChaincodeStubInterface
+	args    [][]byte
+	creator []byte
+}
+
+func (s *proposalStub) GetArgs() [][]byte {
+	return s.args
+}
+
+func (s *proposalStub) GetStringArgs() []string {
+	strArgs := make([]string, 0, len(s.args))
+	for _, arg := range s.args {
+		strArgs = append(strArgs, string(arg))
+	}
+	return strArgs
+}
+
+func (s *proposalStub) GetFunctionAndParameters() (string, []string) {
+	strArgs := s.GetStringArgs()
+	if len(strArgs) == 0 {
+		return "", []string{}
+	}
+	return strArgs[0], strArgs[1:]
+}
+
+func (s *proposalStub) GetCreator() ([]byte, error) {
+	return s.creator, nil
+}
+
+// getProposedOperations returns operations, that are executed by call of chaincode function with args
+// policies of all of them apply to proposal, function without any operation does not need approval
+func getProposedOperations(ctx ContextInterface, function string, args []string) ([]approvalOperation, error) {
+	function = normalizeFunctionName(function)
+
+	params := map[string]string{}
+	for index, name := range getFunctionArgNames(function) {
+		if index < len(args) {
+			params[name] = args[index]
+		}
+	}
+
+	switch function {
+	case "assetCreate", "assetCreateDirect":
+		return []approvalOperation{newApprovalOperation(ApprovalAssetCreate, params[NameParam], parseProposedObject(params[DataParam]))}, nil
+	case "roleCreate":
+		return []approvalOperation{newApprovalOperation(ApprovalAssetCreate, RoleAssetName, parseProposedObject(params[DataParam]))}, nil
+	case "identityCreateDirect":
+		return []approvalOperation{newApprovalOperation(ApprovalAssetCreate, IdentityAssetName)}, nil
+	case "assetUpdate", "assetUpdateDirect":
+		return getProposedAssetOperation(ctx, ApprovalAssetUpdate, params[NameParam], params[IdParam], params[PatchParam])
+	case "roleUpdate":
+		return getProposedAssetOperation(ctx, ApprovalAssetUpdate, RoleAssetName, params[IdParam], params[PatchParam])
+	case "identityUpdate":
+		return getProposedAssetOperation(ctx, ApprovalAssetUpdate, IdentityAssetName, params[FingerprintParam], "")
+	case "identityUpdateDirect":
+		return getProposedAssetOperation(ctx, ApprovalAssetUpdate, IdentityAssetName, params[IdParam], "")
+	case "assetDelete", "assetDeleteDirect":
+		return getProposedAssetOperation(ctx, ApprovalAssetDelete, params[NameParam], params[IdParam], "")
+	case "assetMigrate":
+		return getProposedAssetOperation(ctx, ApprovalAssetMigrate, params[NameParam], params[IdParam], params[PatchParam])
+	case "assetRestore":
+		return getProposedAssetOperation(ctx, ApprovalAssetRestore, params[NameParam], params[IdParam], "")
+	case "assetMigrateAll":
+		return []approvalOperation{newApprovalOperation(ApprovalAssetMigrate, params[NameParam])}, nil
+	case "assetBatch":
+		return getProposedBatchOperations(ctx, params[OperationsParam])
+	case "registryUpsert":
+		return []approvalOperation{newApprovalOperation(ApprovalRegistryUpsert, params[NameParam])}, nil
+	case "singletonUpsert":
+		return []approvalOperation{newApprovalOperation(ApprovalSingletonUpsert, params[NameParam])}, nil
+	case "functionInvoke":
+		return getProposedFunctionOperations(params[NameParam], params[InputParam]), nil
+	}
+
+	return []approvalOperation{}, nil
+}
+
+// parseProposedObject returns JSON object from argument of proposed function, anything else is returned as empty object
+// invalid arguments are refused when proposal is executed
+func parseProposedObject(data string) rmap.Rmap {
+	object, err := rmap.NewFromString(data)
+	if err != nil {
+		return rmap.NewEmpty()
+	}
+
+	return object
+}
+
+// getProposedAssetOperation returns operation on existing asset instance, role is loaded to check if it is system role
+func getProposedAssetOperation(ctx ContextInterface, operation, name, id, patch string) ([]approvalOperation, error) {
+	if strings.ToLower(name) != RoleAssetName || id == "" {
+		return []approvalOperation{newApprovalOperation(operation, name)}, nil
+	}
+
+	role, err := ctx.GetRegistry().GetAsset(RoleAssetName, id, false, false)
+	if err != nil {
+		return nil, errors.Wrap(err, "reg.GetAsset() failed")
+	}
+
+	return []approvalOperation{newApprovalOperation(operation, name, role, parseProposedObject(patch))}, nil
+}
+
+// getProposedBatchOperations returns operations of all items of assetBatch
+func getProposedBatchOperations(ctx ContextInterface, operationsB string) ([]approvalOperation, error) {
+	var items []map[string]interface{}
+	if err := json.Unmarshal([]byte(operationsB), &items); err != nil {
+		return nil, ErrorBadRequest(fmt.Sprintf("operations must be JSON array of objects: %s", err))
+	}
+
+	batchOperations := map[string]string{
+		BatchCreateOperation:  ApprovalAssetCreate,
+		BatchUpdateOperation:  ApprovalAssetUpdate,
+		BatchDeleteOperation:  ApprovalAssetDelete,
+		BatchMigrateOperation: ApprovalAssetMigrate,
+	}
+
+	operations := []approvalOperation{}
+	for _, itemM := range items {
+		item := rmap.NewFromMap(itemM)
+		opType, _ := item.Mapa[BatchOpKey].(string)
+		name, _ := item.Mapa[BatchNameKey].(string)
+		id, _ := item.Mapa[BatchIdKey].(string)
+
+		operation, exists := batchOperations[opType]
+		if !exists {
+			return nil, ErrorBadRequest(fmt.Sprintf("unknown operation: %s", opType))
+		}
+
+		if operation == ApprovalAssetCreate {
+			data, _ := item.Mapa[BatchDataKey].(map[string]interface{})
+			operations = append(operations, newApprovalOperation(operation, name, rmap.NewFromMap(data)))
+			continue
+		}
+
+		patch, _ := getBatchPatch(item)
+		itemOperations, err := getProposedAssetOperation(ctx, operation, name, id, patch)
+		if err != nil {
+			return nil, err
+		}
+		operations = append(operations, itemOperations...)
+	}
+
+	return operations, nil
+}
+
+// getProposedFunctionOperations returns invoke of function, built-in functions add operations they execute on registries, singletons or assets
+func getProposedFunctionOperations(name, inputB string) []approvalOperation {
+	operations := []approvalOperation{newApprovalOperation(ApprovalFunctionInvoke, name)}
+	input := parseProposedObject(inputB)
+
+	var operation, key string
+	switch name {
+	case UpsertRegistriesFuncName:
+		operation, key = ApprovalRegistryUpsert, InitRegistriesKey
+	case UpsertSingletonsFuncName:
+		operation, key = ApprovalSingletonUpsert, InitSingletonsKey
+	case MigrateAllFuncName:
+		assetName, _ := input.Mapa[NameParam].(string)
+		return append(operations, newApprovalOperation(ApprovalAssetMigrate, assetName))
+	default:
+		return operations
+	}
+
+	items, _ := input.Mapa[key].(map[string]interface{})
+	names := make([]string, 0, len(items))
+	for itemName := range items {
+		names = append(names, itemName)
+	}
+	sort.Strings(names)
+
+	for _, itemName := range names {
+		operations = append(operations, newApprovalOperation(operation, itemName))
+	}
+
+	return operations
+}
+
+// getProposalObject returns casbin object of proposals of chaincode function
+func getProposalObject(function string) string {
+	return "/" + ProposalCasbinObject + "/" + normalizeFunctionName(function)
+}
+
+// checkProposalWrite refuses changes of proposals by asset methods, so their history cannot be changed
+func checkProposalWrite(name string) error {
+	if strings.ToLower(name) == ProposalAssetName {
+		return ErrorForbidden("proposal can be changed only by proposal methods")
+	}
+
+	return nil
+}
+
+func proposalCreateFrontend(ctx ContextInterface) (string, error) {
+	now, err := ctx.Time()
+	if err != nil {
+		return "", errors.Wrap(err, "ctx.Time() failed")
+	}
+
+	input, err := ctx.ParamString(InputParam)
+	if err != nil {
+		return "", err
+	}
+
+	return proposalCreateBackend(ctx, now, input)
+}
+
+// proposalCreateBackend stores proposal of chaincode function call, that requires approval
+// requester needs create grant on /proposal/<function>, permissions of the function itself are checked when it is executed
+func proposalCreateBackend(ctx ContextInterface, now time.Time, inputB string) (string, error) {
+	reg := ctx.GetRegistry()
+
+	input, err := rmap.NewFromString(inputB)
+	if err != nil {
+		return "", ErrorBadRequest(fmt.Sprintf("input must be JSON object: %s", err))
+	}
+
+	function, err := input.GetString(ProposalFunctionKey)
+	if err != nil || function == "" {
+		return "", ErrorBadRequest(fmt.Sprintf("input has missing or invalid key: %s", ProposalFunctionKey))
+	}
+
+	args := []interface{}{}
+	stringArgs := []string{}
+	if input.Exists(ProposalArgsKey) {
+		argsI, err := input.GetIterable(ProposalArgsKey)
+		if err != nil {
+			return "", ErrorBadRequest(fmt.Sprintf("input has invalid key: %s", ProposalArgsKey))
+		}
+
+		for _, argI := range argsI {
+			arg, ok := argI.(string)
+			if !ok {
+				return "", ErrorBadRequest(fmt.Sprintf("all %s must be strings", ProposalArgsKey))
+			}
+			args = append(args, arg)
+			stringArgs = append(stringArgs, arg)
+		}
+	}
+
+	operations, err := getProposedOperations(ctx, function, stringArgs)
+	if err != nil {
+		return "", err
+	}
+
+	policy, required, err := getApprovalPolicy(ctx, operations...)
+	if err != nil {
+		return "", errors.Wrap(err, "getApprovalPolicy() failed")
+	}
+
+	if !required {
+		return "", ErrorBadRequest(fmt.Sprintf("function: %s does not require approval, call it directly", function))
+	}
+
+	if err := enforceCustomAccess(reg, getProposalObject(function), CreateAction); err != nil {
+		return "", err
+	}
+
+	myFP, err := GetMyFingerprint(ctx)
+	if err != nil {
+		return "", errors.Wrap(err, "GetMyFingerprint() failed")
+	}
+
+	creator, err := ctx.Stub().GetCreator()
+	if err != nil {
+		return "", errors.Wrap(err, "ctx.Stub().GetCreator() failed")
+	}
+
+	id, err := generateUUID(ctx)
+	if err != nil {
+		return "", errors.Wrap(err, "generateUUID() failed")
+	}
+
+	proposal, err := reg.MakeAsset(ProposalAssetName, id, -1)
+	if err != nil {
+		return "", errors.Wrap(err, "reg.MakeAsset() failed")
+	}
+
+	proposal.Mapa[ProposalFunctionKey] = normalizeFunctionName(function)
+	proposal.Mapa[ProposalArgsKey] = args
+	proposal.Mapa[ProposalStatusKey] = ProposalPending
+	proposal.Mapa[ProposalRequestedByKey] = myFP
+	proposal.Mapa[ProposalCreatorKey] = base64.StdEncoding.EncodeToString(creator)
+	proposal.Mapa[ProposalQuorumKey] = policy.quorum
+	proposal.Mapa[ProposalExpiresAtKey] = now.Add(policy.timeout).Format(time.RFC3339)
+	proposal.Mapa[ProposalApprovalsKey] = []interface{}{}
+
+	if input.Exists(ProposalDescriptionKey) {
+		description, err := input.GetString(ProposalDescriptionKey)
+		if err != nil {
+			return "", ErrorBadRequest(fmt.Sprintf("input has invalid key: %s", ProposalDescriptionKey))
+		}
+		proposal.Mapa[ProposalDescriptionKey] = description
+	}
+
+	if err := reg.PutAsset(proposal, true); err != nil {
+		return "", errors.Wrap(err, "reg.PutAsset() failed")
+	}
+
+	return string(proposal.WrappedResultBytes()), nil
+}
+
+func proposalApproveFrontend(ctx ContextInterface) (string, error) {
+	now, err := ctx.Time()
+	if err != nil {
+		return "", errors.Wrap(err, "ctx.Time() failed")
+	}
+
+	id, err := ctx.ParamString(IdParam)
+	if err != nil {
+		return "", err
+	}
+
+	return proposalApproveBackend(ctx, now, id)
+}
+
+// proposalApproveBackend adds approval of current identity to pending proposal
+// approval, that reaches quorum, executes proposed function in the same TX, error of the function fails the approval
+func proposalApproveBackend(ctx ContextInterface, now time.Time, id string) (string, error) {
+	reg := ctx.GetRegistry()
+
+	proposal, expired, err := getPendingProposal(ctx, now, id)
+	if err != nil {
+		return "", err
+	}
+
+	if expired {
+		return string(proposal.WrappedResultBytes()), nil
+	}
+
+	function, err := proposal.GetString(ProposalFunctionKey)
+	if err != nil {
+		return "", errors.Wrap(err, "proposal.GetString() failed")
+	}
+
+	if err := enforceCustomAccess(reg, getProposalObject(function), ApproveAction); err != nil {
+		return "", err
+	}
+
+	myFP, err := GetMyFingerprint(ctx)
+	if err != nil {
+		return "", errors.Wrap(err, "GetMyFingerprint() failed")
+	}
+
+	requestedBy, err := proposal.GetString(ProposalRequestedByKey)
+	if err != nil {
+		return "", errors.Wrap(err, "proposal.GetString() failed")
+	}
+
+	if requestedBy == myFP {
+		return "", ErrorForbidden("requester cannot approve own proposal")
+	}
+
+	approvals, err := proposal.GetIterable(ProposalApprovalsKey)
+	if err != nil {
+		return "", errors.Wrap(err, "proposal.GetIterable() failed")
+	}
+
+	for _, approvalI := range approvals {
+		approval, err := rmap.NewFromInterface(approvalI)
+		if err != nil {
+			return "", errors.Wrap(err, "rmap.NewFromInterface() failed")
+		}
+
+		if approval.Mapa[AssetFingerprintKey] == myFP {
+			return "", ErrorConflict("proposal is already approved by this identity")
+		}
+	}
+
+	approvals = append(approvals, map[string]interface{}{
+		AssetFingerprintKey:  myFP,
+		ProposalTimestampKey: now.Format(time.RFC3339),
+	})
+	proposal.Mapa[ProposalApprovalsKey] = approvals
+
+	quorum, err := proposal.GetInt(ProposalQuorumKey)
+	if err != nil {
+		return "", errors.Wrap(err, "proposal.GetInt() failed")
+	}
+
+	if len(approvals) >= quorum {
+		result, err := executeProposal(ctx, proposal)
+		if err != nil {
+			return "", errors.Wrap(err, "executeProposal() failed")
+		}
+
+		proposal.Mapa[ProposalStatusKey] = ProposalExecuted
+		proposal.Mapa[ProposalExecutedByKey] = myFP
+		proposal.Mapa[ProposalExecutedAtKey] = now.Format(time.RFC3339)
+		if result != nil {
+			proposal.Mapa[ProposalResultKey] = result
+		}
+	}
+
+	if err := reg.PutAsset(proposal, false); err != nil {
+		return "", errors.Wrap(err, "reg.PutAsset() failed")
+	}
+
+	return string(proposal.WrappedResultBytes()), nil
+}
+
+func proposalRejectFrontend(ctx ContextInterface) (string, error) {
+	now, err := ctx.Time()
+	if err != nil {
+		return "", errors.Wrap(err, "ctx.Time() failed")
+	}
+
+	id, err := ctx.ParamString(IdParam)
+	if err != nil {
+		return "", err
+	}
+
+	// input with reason is optional
+	input, err := ctx.ParamString(InputParam)
+	if err != nil {
+		input = ""
+	}
+
+	return proposalRejectBackend(ctx, now, id, input)
+}
+
+// proposalRejectBackend rejects pending proposal, it can be done by requester or by identity with approve grant
+func proposalRejectBackend(ctx ContextInterface, now time.Time, id string, inputB string) (string, error) {
+	reg := ctx.GetRegistry()
+
+	input := rmap.NewEmpty()
+	if inputB != "" {
+		var err error
+		input, err = rmap.NewFromString(inputB)
+		if err != nil {
+			return "", ErrorBadRequest(fmt.Sprintf("input must be JSON object: %s", err))
+		}
+	}
+
+	proposal, expired, err := getPendingProposal(ctx, now, id)
+	if err != nil {
+		return "", err
+	}
+
+	if expired {
+		return string(proposal.WrappedResultBytes()), nil
+	}
+
+	myFP, err := GetMyFingerprint(ctx)
+	if err != nil {
+		return "", errors.Wrap(err, "GetMyFingerprint() failed")
+	}
+
+	requestedBy, err := proposal.GetString(ProposalRequestedByKey)
+	if err != nil {
+		return "", errors.Wrap(err, "proposal.GetString() failed")
+	}
+
+	if requestedBy != myFP {
+		// requester can withdraw own proposal, others must be approvers
+		function, err := proposal.GetString(ProposalFunctionKey)
+		if err != nil {
+			return "", errors.Wrap(err, "proposal.GetString() failed")
+		}
+
+		if err := enforceCustomAccess(reg, getProposalObject(function), ApproveAction); err != nil {
+			return "", err
+		}
+	}
+
+	proposal.Mapa[ProposalStatusKey] = ProposalRejected
+	proposal.Mapa[ProposalRejectedByKey] = myFP
+
+	if input.Exists(ProposalReasonKey) {
+		reason, err := input.GetString(ProposalReasonKey)
+		if err != nil {
+			return "", ErrorBadRequest(fmt.Sprintf("input has invalid key: %s", ProposalReasonKey))
+		}
+		proposal.Mapa[ProposalReasonKey] = reason
+	}
+
+	if err := reg.PutAsset(proposal, false); err != nil {
+		return "", errors.Wrap(err, "reg.PutAsset() failed")
+	}
+
+	return string(proposal.WrappedResultBytes()), nil
+}
+
+// getPendingProposal returns proposal, that can be approved or rejected
+// proposal after its timeout is marked as expired and stored, true is returned in this case and proposal cannot be changed anymore
+func getPendingProposal(ctx ContextInterface, now time.Time, id string) (rmap.Rmap, bool, error) {
+	reg := ctx.GetRegistry()
+
+	proposal, err := reg.GetAsset(ProposalAssetName, id, false, true)
+	if err != nil {
+		return rmap.Rmap{}, false, errors.Wrap(err, "reg.GetAsset() failed")
+	}
+
+	status, err := proposal.GetString(ProposalStatusKey)
+	if err != nil {
+		return rmap.Rmap{}, false, errors.Wrap(err, "proposal.GetString() failed")
+	}
+
+	if status != ProposalPending {
+		return rmap.Rmap{}, false, ErrorConflict(fmt.Sprintf("proposal is not pending, status: %s", status))
+	}
+
+	expiresAtS, err := proposal.GetString(ProposalExpiresAtKey)
+	if err != nil {
+		return rmap.Rmap{}, false, errors.Wrap(err, "proposal.GetString() failed")
+	}
+
+	expiresAt, err := time.Parse(time.RFC3339, expiresAtS)
+	if err != nil {
+		return rmap.Rmap{}, false, errors.Wrap(err, "time.Parse() failed")
+	}
+
+	if now.After(expiresAt) {
+		proposal.Mapa[ProposalStatusKey] = ProposalExpired
+
+		if err := reg.PutAsset(proposal, false); err != nil {
+			return rmap.Rmap{}, false, errors.Wrap(err, "reg.PutAsset() failed")
+		}
+
+		return proposal, true, nil
+	}
+
+	return proposal, false, nil
+}
+
+// executeProposal calls proposed function through router with identity of requester and returns its result
+// authorization is built again for requester, so the function is checked with permissions of requester
+func executeProposal(ctx ContextInterface, proposal rmap.Rmap) (interface{}, error) {
+	function, err := proposal.GetString(ProposalFunctionKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "proposal.GetString() failed")
+	}
+
+	argsI, err := proposal.GetIterable(ProposalArgsKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "proposal.GetIterable() failed")
+	}
+
+	args := [][]byte{[]byte(function)}
+	for _, argI := range argsI {
+		args = append(args, []byte(argI.(string)))
+	}
+
+	creatorS, err := proposal.GetString(ProposalCreatorKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "proposal.GetString() failed")
+	}
+
+	creator, err := base64.StdEncoding.DecodeString(creatorS)
+	if err != nil {
+		return nil, errors.Wrap(err, "base64.StdEncoding.DecodeString() failed")
+	}
+
+	approvals, err := proposal.GetIterable(ProposalApprovalsKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "proposal.GetIterable() failed")
+	}
+
+	stub := ctx.GetStub()
+	ctx.SetStub(&proposalStub{
+		ChaincodeStubInterface: stub,
+		args:                   args,
+		creator:                creator,
+	})
+	ctx.Set(AuthorizationKey, nil)
+	ctx.Set(KompiGuardsKey, nil)
+	ctx.Set(ApprovalsKey, len(approvals))
+	// audit trail records requester, approvers and proposed function instead of proposalApprove
+	ctx.Set(ExecutedProposalKey, proposal)
+
+	ret, err := route(ctx)
+
+	ctx.SetStub(stub)
+	ctx.Set(AuthorizationKey, nil)
+	ctx.Set(KompiGuardsKey, nil)
+	ctx.Set(ApprovalsKey, nil)
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "function: %s failed", function)
+	}
+
+	if ret == "" {
+		return nil, nil
+	}
+
+	// result is stored as JSON, when function returns JSON
+	if result, err := rmap.NewFromString(ret); err == nil {
+		return result.Mapa, nil
+	}
+
+	return ret, nil
+}
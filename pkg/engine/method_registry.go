@@ -1,6 +1,7 @@
 package engine
 
 import (
+	"fmt"
 	"strings"
 
 	. "github.com/KompiTech/fabric-cc-core/v2/pkg/konst"
@@ -71,6 +72,15 @@ func registryUpsertFrontend(ctx ContextInterface) (string, error) {
 		return "", err
 	}
 
+	// force is optional, changes of schema, that are not backward compatible, are rejected by default
+	force := false
+	if _, exists := ctx.Params()[ForceParam]; exists {
+		force, err = ctx.ParamBool(ForceParam)
+		if err != nil {
+			return "", ErrorBadRequest(fmt.Sprintf("invalid value of: %s param: %s", ForceParam, err))
+		}
+	}
+
 	name = strings.ToLower(name)
 
 	if err := enforceCustomAccess(reg, "/"+RegistryCasbinObject+"/"+name, UpsertAction); err != nil {
@@ -82,14 +92,17 @@ func registryUpsertFrontend(ctx ContextInterface) (string, error) {
 		return "", errors.Wrap(err, "rmap.NewFromBytes() failed")
 	}
 
-	item, version, err := reg.UpsertItem(itemToUpsert, name)
+	item, version, report, err := reg.UpsertItemWithReport(itemToUpsert, name, force)
 	if err != nil {
-		return "", errors.Wrap(err, "reg.UpsertItem() failed")
+		return "", errors.Wrap(err, "reg.UpsertItemWithReport() failed")
 	}
 
-	// decorate item with version, name
+	// decorate item with version, name and compatibility report, when new version of existing item was created
 	item.Mapa[RegistryItemVersionKey] = version
 	item.Mapa[RegistryItemNameKey] = name
+	if report.Compatibility != "" {
+		item.Mapa[RegistryCompatibilityKey] = report.Rmap().Mapa
+	}
 
 	return string(item.WrappedResultBytes()), nil
 }
@@ -2,16 +2,10 @@ package engine
 
 import (
 	. "github.com/KompiTech/fabric-cc-core/v2/pkg/konst"
-	"github.com/pkg/errors"
 )
 
 // deprecated, use assetCreate(role, ...)
 func roleCreateFrontend(ctx ContextInterface) (string, error) {
-	now, err := ctx.Time()
-	if err != nil {
-		return "", errors.Wrap(err, "ctx.Time() failed")
-	}
-
 	data, err := ctx.ParamString(DataParam)
 	if err != nil {
 		return "", err
@@ -22,7 +16,7 @@ func roleCreateFrontend(ctx ContextInterface) (string, error) {
 		return "", err
 	}
 
-	return assetCreateBackend(ctx, now, RoleAssetName, data, -1, id, false)
+	return assetCreateBackend(ctx, RoleAssetName, data, -1, id, false)
 }
 
 // deprecated, use assetUpdate(role, ...)
@@ -37,7 +31,7 @@ func roleUpdateFrontend(ctx ContextInterface) (string, error) {
 		return "", err
 	}
 
-	return assetUpdateBackend(ctx, RoleAssetName, id, patch, false)
+	return assetUpdateBackend(ctx, RoleAssetName, id, patch, anyRevision, false)
 }
 
 // deprecated, use assetGet(role, ...)
@@ -0,0 +1,279 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	. "github.com/KompiTech/fabric-cc-core/v2/pkg/konst"
+	"github.com/KompiTech/rmap"
+	jsonpatch "github.com/evanphx/json-patch"
+	"github.com/pkg/errors"
+)
+
+// validateMigration checks migration definition of registry item, that is being upserted
+func validateMigration(ctx ContextInterface, registryItem rmap.Rmap) error {
+	if !registryItem.Exists(RegistryItemMigrationKey) {
+		return nil
+	}
+
+	migration, err := registryItem.GetRmap(RegistryItemMigrationKey)
+	if err != nil {
+		return errors.Wrap(err, "registryItem.GetRmap() failed")
+	}
+
+	if migration.Exists(MigrationPatchKey) {
+		patchI, err := migration.Get(MigrationPatchKey)
+		if err != nil {
+			return errors.Wrap(err, "migration.Get() failed")
+		}
+
+		patchB, err := json.Marshal(patchI)
+		if err != nil {
+			return errors.Wrap(err, "json.Marshal() failed")
+		}
+
+		patch, err := jsonpatch.DecodePatch(patchB)
+		if err != nil {
+			return ErrorBadRequest(fmt.Sprintf("invalid JSON patch in migration: %s", err))
+		}
+
+		// operations are otherwise validated only when patch is applied
+		for index, op := range patch {
+			if err := validatePatchOperation(op); err != nil {
+				return ErrorBadRequest(fmt.Sprintf("invalid JSON patch in migration, operation: %d: %s", index, err))
+			}
+		}
+	}
+
+	if migration.Exists(MigrationFunctionKey) {
+		function, err := migration.GetString(MigrationFunctionKey)
+		if err != nil {
+			return errors.Wrap(err, "migration.GetString() failed")
+		}
+
+		if _, exists := ctx.GetConfiguration().Migrations[function]; !exists {
+			return ErrorBadRequest(fmt.Sprintf("migration function: %s is not registered", function))
+		}
+	}
+
+	return nil
+}
+
+// validatePatchOperation checks that JSON patch operation has known kind and all pointers it requires
+func validatePatchOperation(op jsonpatch.Operation) error {
+	switch op.Kind() {
+	case "add", "replace", "test":
+		if _, err := op.ValueInterface(); err != nil {
+			return err
+		}
+	case "move", "copy":
+		if _, err := op.From(); err != nil {
+			return err
+		}
+	case "remove":
+	default:
+		return fmt.Errorf("unknown op: %s", op.Kind())
+	}
+
+	if _, err := op.Path(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// migrateAsset migrates asset step by step from its version to targetVersion using migration definitions of registry items
+// after each step, asset is validated against schema of that version, except for targetVersion, which is validated when asset is stored
+// migration to older version only changes version
+func migrateAsset(ctx ContextInterface, asset rmap.Rmap, targetVersion int) (rmap.Rmap, error) {
+	reg := ctx.GetRegistry()
+
+	name, err := AssetGetDocType(asset)
+	if err != nil {
+		return rmap.Rmap{}, errors.Wrap(err, "konst.AssetGetDocType() failed")
+	}
+
+	thisVersion, err := AssetGetVersion(asset)
+	if err != nil {
+		return rmap.Rmap{}, errors.Wrap(err, "konst.AssetGetVersion() failed")
+	}
+
+	if targetVersion < thisVersion {
+		asset.Mapa[AssetVersionKey] = targetVersion
+		return asset, nil
+	}
+
+	for version := thisVersion + 1; version <= targetVersion; version++ {
+		regItem, _, err := reg.GetItem(name, version)
+		if err != nil {
+			return rmap.Rmap{}, errors.Wrap(err, "reg.GetItem() failed")
+		}
+
+		asset.Mapa[AssetVersionKey] = version
+
+		if regItem.Exists(RegistryItemMigrationKey) {
+			migration, err := regItem.GetRmap(RegistryItemMigrationKey)
+			if err != nil {
+				return rmap.Rmap{}, errors.Wrap(err, "regItem.GetRmap() failed")
+			}
+
+			asset, err = applyMigration(ctx, asset, migration)
+			if err != nil {
+				return rmap.Rmap{}, errors.Wrapf(err, "migration to version: %d failed", version)
+			}
+		}
+
+		if version == targetVersion {
+			break
+		}
+
+		schema, err := reg.getAssetSchema(name, regItem)
+		if err != nil {
+			return rmap.Rmap{}, errors.Wrap(err, "reg.getAssetSchema() failed")
+		}
+
+		fields, err := validateSchema(asset, schema)
+		if err != nil {
+			return rmap.Rmap{}, errors.Wrap(err, "validateSchema() failed")
+		}
+
+		if len(fields) > 0 {
+			return rmap.Rmap{}, ErrorValidationFields(fmt.Sprintf("asset is not valid after migration to version: %d", version), fields)
+		}
+	}
+
+	return asset, nil
+}
+
+// applyMigration applies one migration definition to asset. Steps are applied in order: rename, defaults, patch, function
+func applyMigration(ctx ContextInterface, asset rmap.Rmap, migration rmap.Rmap) (rmap.Rmap, error) {
+	assetPre := asset.Copy()
+
+	if migration.Exists(MigrationRenameKey) {
+		renames, err := migration.GetRmap(MigrationRenameKey)
+		if err != nil {
+			return rmap.Rmap{}, errors.Wrap(err, "migration.GetRmap() failed")
+		}
+
+		// keys are sorted to get the same result on every peer
+		for _, oldPtr := range sortedKeys(renames) {
+			newPtr, err := renames.GetString(oldPtr)
+			if err != nil {
+				return rmap.Rmap{}, errors.Wrap(err, "renames.GetString() failed")
+			}
+
+			exists, err := asset.ExistsJPtr(oldPtr)
+			if err != nil || !exists {
+				continue
+			}
+
+			value, err := asset.GetJPtr(oldPtr)
+			if err != nil {
+				return rmap.Rmap{}, errors.Wrap(err, "asset.GetJPtr() failed")
+			}
+
+			if err := asset.DeleteJPtr(oldPtr); err != nil {
+				return rmap.Rmap{}, errors.Wrap(err, "asset.DeleteJPtr() failed")
+			}
+
+			if err := asset.SetJPtrRecursive(newPtr, value); err != nil {
+				return rmap.Rmap{}, ErrorUnprocessableEntity(fmt.Sprintf("unable to rename: %s to: %s: %s", oldPtr, newPtr, err))
+			}
+		}
+	}
+
+	if migration.Exists(MigrationDefaultsKey) {
+		defaults, err := migration.GetRmap(MigrationDefaultsKey)
+		if err != nil {
+			return rmap.Rmap{}, errors.Wrap(err, "migration.GetRmap() failed")
+		}
+
+		for _, ptr := range sortedKeys(defaults) {
+			exists, err := asset.ExistsJPtr(ptr)
+			if err == nil && exists {
+				continue
+			}
+
+			if err := asset.SetJPtrRecursive(ptr, defaults.Mapa[ptr]); err != nil {
+				return rmap.Rmap{}, ErrorUnprocessableEntity(fmt.Sprintf("unable to set default of: %s: %s", ptr, err))
+			}
+		}
+	}
+
+	if migration.Exists(MigrationPatchKey) {
+		patchB, err := json.Marshal(migration.Mapa[MigrationPatchKey])
+		if err != nil {
+			return rmap.Rmap{}, errors.Wrap(err, "json.Marshal() failed")
+		}
+
+		patch, err := jsonpatch.DecodePatch(patchB)
+		if err != nil {
+			return rmap.Rmap{}, errors.Wrap(err, "jsonpatch.DecodePatch() failed")
+		}
+
+		patchedB, err := patch.Apply(asset.Bytes())
+		if err != nil {
+			return rmap.Rmap{}, ErrorUnprocessableEntity(fmt.Sprintf("unable to apply migration patch: %s", err))
+		}
+
+		asset, err = rmap.NewFromBytes(patchedB)
+		if err != nil {
+			return rmap.Rmap{}, errors.Wrap(err, "rmap.NewFromBytes() failed")
+		}
+	}
+
+	if migration.Exists(MigrationFunctionKey) {
+		name, err := migration.GetString(MigrationFunctionKey)
+		if err != nil {
+			return rmap.Rmap{}, errors.Wrap(err, "migration.GetString() failed")
+		}
+
+		function, exists := ctx.GetConfiguration().Migrations[name]
+		if !exists {
+			return rmap.Rmap{}, fmt.Errorf("migration function: %s is not registered", name)
+		}
+
+		asset, err = function(ctx, asset)
+		if err != nil {
+			return rmap.Rmap{}, errors.Wrapf(err, "migration function: %s failed", name)
+		}
+	}
+
+	// migration cannot change identity, version, revision or soft delete marker of asset
+	if isServiceKeyChanged(assetPre, asset) {
+		return rmap.Rmap{}, ErrorUnprocessableEntity("migration must not change service keys")
+	}
+
+	return asset, nil
+}
+
+// isServiceKeyChanged returns true, if any protected service key is added, removed or changed in post
+// values are compared as JSON, because numbers can be stored as int or float64
+func isServiceKeyChanged(pre, post rmap.Rmap) bool {
+	for _, key := range ProtectedServiceKeys() {
+		preValue, preExists := pre.Mapa[key]
+		postValue, postExists := post.Mapa[key]
+
+		if preExists != postExists {
+			return true
+		}
+
+		preB, _ := json.Marshal(preValue)
+		postB, _ := json.Marshal(postValue)
+
+		if !bytes.Equal(preB, postB) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// sortedKeys returns keys of rm in sorted order
+func sortedKeys(rm rmap.Rmap) []string {
+	keys := rm.KeysSliceString()
+	sort.Strings(keys)
+	return keys
+}
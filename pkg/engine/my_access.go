@@ -3,6 +3,7 @@ package engine
 import (
 	"fmt"
 	"sort"
+	"time"
 
 	"github.com/KompiTech/fabric-cc-core/v2/pkg/kompiguard"
 	"github.com/KompiTech/fabric-cc-core/v2/pkg/konst"
@@ -21,7 +22,11 @@ import (
 	"functions_invoke": ["generateBillingReport"],
 	"custom_grants": {
 		"view_sensitive": ["/user/*"]
-	}
+	},
+	"expiring": [
+		{"role": "<uuid>", "valid_until": "2021-06-30T00:00:00Z"},
+		{"role": "<uuid>", "object": "/incident/*", "action": "read", "valid_until": "2021-07-31T00:00:00Z"}
+	]
 }
 */
 var myAccessFunc = func(ctx ContextInterface, input rmap.Rmap, output rmap.Rmap) (rmap.Rmap, error) {
@@ -47,7 +52,7 @@ var identityAccessFunc = func(ctx ContextInterface, input rmap.Rmap, output rmap
 
 	}
 
-	identityAsset, err := reg.GetAsset(konst.IdentityAssetName, identityFP, true, true)
+	identityAsset, err := reg.GetIdentityResolved(identityFP)
 	if err != nil {
 		return null, ErrorBadRequest(fmt.Sprintf("cannot get identity for fingerprint: %s", identityFP))
 	}
@@ -56,8 +61,9 @@ var identityAccessFunc = func(ctx ContextInterface, input rmap.Rmap, output rmap
 }
 
 // getCustomGrants collects all unique grants for identity that are not standard asset and function grants (CRUD + function execute)
+// grants of parent roles are included, grants and role assignments that are not valid at time now are skipped
 // returns map with key being grant name and value being list of all objects that have this grant
-func getCustomGrants(identity rmap.Rmap) (rmap.Rmap, error) {
+func getCustomGrants(identity rmap.Rmap, now time.Time) (rmap.Rmap, error) {
 	null := rmap.Rmap{}
 	stdGrants := map[string]struct{}{ // these grant names are processed elsewhere, so they will be skipped
 		"create":  {},
@@ -69,21 +75,13 @@ func getCustomGrants(identity rmap.Rmap) (rmap.Rmap, error) {
 
 	output := rmap.NewEmpty() // first level: grant name -> map with objects (will be converted to list before returning)
 
-	if !identity.Exists("roles") {
-		return output, nil // no roles, nothing can be granted
-	}
-
-	roles, err := identity.GetIterable("roles")
+	// grants of roles inherited from parent roles are included
+	roles, err := kompiguard.EffectiveRoles(identity, now)
 	if err != nil {
 		return null, err
 	}
 
-	for _, roleI := range roles {
-		role, err := rmap.NewFromInterface(roleI)
-		if err != nil {
-			return null, err
-		}
-
+	for _, role := range roles {
 		if !role.Exists("grants") {
 			continue
 		}
@@ -99,6 +97,15 @@ func getCustomGrants(identity rmap.Rmap) (rmap.Rmap, error) {
 				return null, err
 			}
 
+			isValid, err := kompiguard.IsValidAt(grant, now)
+			if err != nil {
+				return null, err
+			}
+
+			if !isValid {
+				continue
+			}
+
 			action, err := grant.GetString("action")
 			if err != nil {
 				return null, err
@@ -144,13 +151,143 @@ func getCustomGrants(identity rmap.Rmap) (rmap.Rmap, error) {
 	return output, nil
 }
 
-// isIdentitySU checks if identity is SU
-func isIdentitySU(identity rmap.Rmap) (bool, error) {
-	if !identity.Exists("roles") {
-		return false, nil // no roles, cannot be SU
+// isRoleAssignmentValid checks if assignment of resolved role to identity is valid at time now
+func isRoleAssignmentValid(identity, role rmap.Rmap, now time.Time) (bool, error) {
+	uuid, err := role.GetString("uuid")
+	if err != nil {
+		return false, err
+	}
+
+	validity, err := kompiguard.GetRoleValidity(identity, uuid)
+	if err != nil {
+		return false, err
+	}
+
+	return kompiguard.IsValidAt(validity, now)
+}
+
+// getExpiringAccess lists role assignments and grants of identity valid at time now, that have valid_until set
+// grants of parent roles are included with UUID of role, that defines them
+// list is sorted by valid_until, so UI can warn about access that expires soon
+func getExpiringAccess(identity rmap.Rmap, now time.Time) ([]interface{}, error) {
+	type expiring struct {
+		entry map[string]interface{}
+		until time.Time
+	}
+
+	expirings := []expiring{}
+
+	add := func(entry map[string]interface{}, validity rmap.Rmap) error {
+		if !validity.Exists(konst.ValidUntilKey) {
+			return nil
+		}
+
+		untilS, err := validity.GetString(konst.ValidUntilKey)
+		if err != nil {
+			return err
+		}
+
+		until, err := time.Parse(time.RFC3339, untilS)
+		if err != nil {
+			return err
+		}
+
+		entry[konst.ValidUntilKey] = untilS
+		expirings = append(expirings, expiring{entry: entry, until: until})
+		return nil
+	}
+
+	if identity.Exists("roles") {
+		roles, err := identity.GetIterableRmap("roles")
+		if err != nil {
+			return nil, err
+		}
+
+		for _, role := range roles {
+			isValid, err := isRoleAssignmentValid(identity, role, now)
+			if err != nil {
+				return nil, err
+			}
+
+			if !isValid {
+				continue
+			}
+
+			uuid, err := role.GetString("uuid")
+			if err != nil {
+				return nil, err
+			}
+
+			validity, err := kompiguard.GetRoleValidity(identity, uuid)
+			if err != nil {
+				return nil, err
+			}
+
+			if err := add(map[string]interface{}{"role": uuid}, validity); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// grants of roles inherited from parent roles are included
+	roles, err := kompiguard.EffectiveRoles(identity, now)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, role := range roles {
+		if !role.Exists("grants") {
+			continue
+		}
+
+		uuid, err := role.GetString("uuid")
+		if err != nil {
+			return nil, err
+		}
+
+		grants, err := role.GetIterableRmap("grants")
+		if err != nil {
+			return nil, err
+		}
+
+		for _, grant := range grants {
+			isValid, err := kompiguard.IsValidAt(grant, now)
+			if err != nil {
+				return nil, err
+			}
+
+			if !isValid {
+				continue
+			}
+
+			entry := map[string]interface{}{
+				"role":   uuid,
+				"object": grant.Mapa["object"],
+				"action": grant.Mapa["action"],
+			}
+
+			if err := add(entry, grant); err != nil {
+				return nil, err
+			}
+		}
 	}
 
-	roles, err := identity.GetIterableRmap("roles")
+	sort.SliceStable(expirings, func(i, j int) bool {
+		return expirings[i].until.Before(expirings[j].until)
+	})
+
+	output := make([]interface{}, 0, len(expirings))
+	for _, e := range expirings {
+		output = append(output, e.entry)
+	}
+
+	return output, nil
+}
+
+// isIdentitySU checks if identity holds any superuser role from configuration
+// assignment of SU role, that is not valid at time now, is ignored, SU role can be also inherited from parent role
+func isIdentitySU(ctx ContextInterface, identity rmap.Rmap, now time.Time) (bool, error) {
+	roles, err := kompiguard.EffectiveRoles(identity, now)
 	if err != nil {
 		return false, err
 	}
@@ -161,7 +298,7 @@ func isIdentitySU(identity rmap.Rmap) (bool, error) {
 			return false, err
 		}
 
-		if uuid == konst.SuperuserRoleUUID {
+		if isSuperuserRole(ctx, uuid) {
 			return true, nil
 		}
 	}
@@ -178,16 +315,16 @@ func getStaticSuperUserGrants() rmap.Rmap {
 
 func accessFuncImpl(ctx ContextInterface, identity rmap.Rmap, output rmap.Rmap) (rmap.Rmap, error) {
 	null := rmap.Rmap{}
-	kmpg := kompiguard.KompiGuard{}
+	authorizer := getAuthorizer(ctx)
 
-	isSU, err := isIdentitySU(identity)
+	now, err := ctx.Time()
 	if err != nil {
-		return null, err
+		return null, errors.Wrap(err, "ctx.Time() failed")
 	}
 
-	thisFP, err := identity.GetString("fingerprint")
+	isSU, err := isIdentitySU(ctx, identity, now)
 	if err != nil {
-		return null, errors.Wrap(err, "could not get fingerprint from Identity")
+		return null, err
 	}
 
 	reg := ctx.Get("registry").(*Registry)
@@ -199,19 +336,6 @@ func accessFuncImpl(ctx ContextInterface, identity rmap.Rmap, output rmap.Rmap)
 
 	allFunctions := ctx.GetConfiguration().FunctionExecutor.List()
 
-	if !isSU {
-		// only load kompiguard if role is not SU
-		// because SU can do anything, so no point in asking kompiguard
-		kmpg, err = kompiguard.New()
-		if err != nil {
-			return null, errors.Wrap(err, "kompiguard.New() failed")
-		}
-
-		if err := kmpg.LoadRoles(identity); err != nil {
-			return null, errors.Wrap(err, "kmpg.LoadRoles() failed")
-		}
-	}
-
 	output = rmap.NewEmpty()
 	var granted bool
 
@@ -223,7 +347,8 @@ func accessFuncImpl(ctx ContextInterface, identity rmap.Rmap, output rmap.Rmap)
 			if isSU {
 				granted = true
 			} else {
-				granted, _, err = kmpg.EnforceCustom(obj, thisFP, "execute", nil)
+				// SU can do anything, so authorizer is asked only for other identities
+				granted, _, err = authorizer.EnforceCustom(ctx, identity, obj, "execute", nil)
 				if err != nil {
 					return null, err
 				}
@@ -249,7 +374,7 @@ func accessFuncImpl(ctx ContextInterface, identity rmap.Rmap, output rmap.Rmap)
 			if isSU {
 				granted = true
 			} else {
-				granted, _, err = kmpg.EnforceCustom(obj, thisFP, action, nil)
+				granted, _, err = authorizer.EnforceCustom(ctx, identity, obj, action, nil)
 				if err != nil {
 					return null, err
 				}
@@ -277,7 +402,7 @@ func accessFuncImpl(ctx ContextInterface, identity rmap.Rmap, output rmap.Rmap)
 	var customGrants rmap.Rmap
 
 	if !isSU {
-		customGrants, err = getCustomGrants(identity)
+		customGrants, err = getCustomGrants(identity, now)
 		if err != nil {
 			return null, err
 		}
@@ -287,5 +412,12 @@ func accessFuncImpl(ctx ContextInterface, identity rmap.Rmap, output rmap.Rmap)
 
 	output.Mapa["custom_grants"] = customGrants.Mapa
 
+	expiring, err := getExpiringAccess(identity, now)
+	if err != nil {
+		return null, err
+	}
+
+	output.Mapa["expiring"] = expiring
+
 	return output, nil
 }
@@ -0,0 +1,534 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	. "github.com/KompiTech/fabric-cc-core/v2/pkg/konst"
+	"github.com/KompiTech/rmap"
+	"github.com/pkg/errors"
+)
+
+// referrer is asset instance containing reference to some other asset instance
+type referrer struct {
+	name    string // lowercase name of referencing asset
+	id      string // ID of referencing asset
+	pointer string // JSON pointer of reference in referencing asset
+}
+
+// getReferenceIndexKey returns state key of reverse reference index entry
+func (r *Registry) getReferenceIndexKey(targetName, targetID, sourceName, sourceID, pointer string) (string, error) {
+	return r.ctx.Stub().CreateCompositeKey(ReferenceIndexPrefix, []string{strings.ToUpper(targetName), strings.ToLower(targetID), strings.ToUpper(sourceName), strings.ToLower(sourceID), pointer})
+}
+
+// getIndexedReferences returns references of asset stored under key, that are currently present in reverse reference index
+func (r *Registry) getIndexedReferences(key string, isCreate bool) ([]reference, error) {
+	if refs, exists := r.references[key]; exists {
+		return refs, nil
+	}
+
+	if isCreate {
+		return nil, nil
+	}
+
+	// asset was not written in this TX yet, index contains references of its stored value
+	stored, err := newRmapFromState(r.ctx, key, false)
+	if err != nil {
+		return nil, errors.Wrap(err, "newRmapFromState() failed")
+	}
+
+	if stored.IsEmpty() {
+		return nil, nil
+	}
+
+	return (resolver{}).FindReferences(r.ctx, stored)
+}
+
+// writeReferenceIndex replaces reverse reference index entries for references in old by entries for references in new
+func (r *Registry) writeReferenceIndex(name, id string, old, new []reference) error {
+	oldKeys := map[string]bool{}
+	for _, ref := range old {
+		indexKey, err := r.getReferenceIndexKey(ref.targetName, ref.targetID, name, id, ref.pointer)
+		if err != nil {
+			return errors.Wrap(err, "r.getReferenceIndexKey() failed")
+		}
+		oldKeys[indexKey] = true
+	}
+
+	newKeys := map[string]bool{}
+	for _, ref := range new {
+		indexKey, err := r.getReferenceIndexKey(ref.targetName, ref.targetID, name, id, ref.pointer)
+		if err != nil {
+			return errors.Wrap(err, "r.getReferenceIndexKey() failed")
+		}
+		newKeys[indexKey] = true
+	}
+
+	for indexKey := range oldKeys {
+		if newKeys[indexKey] {
+			continue
+		}
+
+		if err := r.ctx.Stub().DelState(indexKey); err != nil {
+			return errors.Wrap(err, "r.ctx.Stub().DelState() failed")
+		}
+		r.referenceIndex[indexKey] = false
+	}
+
+	for indexKey := range newKeys {
+		if oldKeys[indexKey] {
+			continue
+		}
+
+		// all information is in key, value is only placeholder
+		if err := r.ctx.Stub().PutState(indexKey, rmap.NewEmpty().Bytes()); err != nil {
+			return errors.Wrap(err, "r.ctx.Stub().PutState() failed")
+		}
+		r.referenceIndex[indexKey] = true
+	}
+
+	return nil
+}
+
+// updateReferenceIndex updates reverse reference index with references of asset, that is being written
+func (r *Registry) updateReferenceIndex(name, id, key string, isCreate bool, asset rmap.Rmap) error {
+	old, err := r.getIndexedReferences(key, isCreate)
+	if err != nil {
+		return errors.Wrap(err, "r.getIndexedReferences() failed")
+	}
+
+	new, err := (resolver{}).FindReferences(r.ctx, asset)
+	if err != nil {
+		return errors.Wrap(err, "(resolver{}).FindReferences() failed")
+	}
+
+	if err := r.writeReferenceIndex(name, id, old, new); err != nil {
+		return errors.Wrap(err, "r.writeReferenceIndex() failed")
+	}
+
+	r.references[key] = new
+	return nil
+}
+
+// deleteReferenceIndex removes all reverse reference index entries of asset, that is being deleted
+func (r *Registry) deleteReferenceIndex(name, id, key string, asset rmap.Rmap) error {
+	old, exists := r.references[key]
+	if !exists {
+		var err error
+		old, err = (resolver{}).FindReferences(r.ctx, asset)
+		if err != nil {
+			return errors.Wrap(err, "(resolver{}).FindReferences() failed")
+		}
+	}
+
+	if err := r.writeReferenceIndex(name, id, old, nil); err != nil {
+		return errors.Wrap(err, "r.writeReferenceIndex() failed")
+	}
+
+	r.references[key] = nil
+	return nil
+}
+
+// indexStoredReferences writes reverse reference index entries for all references of asset stored under key, existing entries are written again
+// returns false, if asset is not stored
+func (r *Registry) indexStoredReferences(name, id string) (bool, error) {
+	key, err := r.getAssetCompositeKey(name, id)
+	if err != nil {
+		return false, errors.Wrap(err, "r.getAssetCompositeKey() failed")
+	}
+
+	stored, err := newRmapFromState(r.ctx, key, false)
+	if err != nil {
+		return false, errors.Wrap(err, "newRmapFromState() failed")
+	}
+
+	if stored.IsEmpty() {
+		return false, nil
+	}
+
+	refs, err := (resolver{}).FindReferences(r.ctx, stored)
+	if err != nil {
+		return false, errors.Wrap(err, "(resolver{}).FindReferences() failed")
+	}
+
+	if err := r.writeReferenceIndex(name, id, nil, refs); err != nil {
+		return false, errors.Wrap(err, "r.writeReferenceIndex() failed")
+	}
+
+	r.references[key] = refs
+	return true, nil
+}
+
+// isReferenceIndexComplete returns true, if reverse reference index contains references of all assets
+// chaincode upgraded from version without the index has incomplete index, until it is backfilled by indexReferences function
+func isReferenceIndexComplete(ctx ContextInterface) (bool, error) {
+	marker, err := newRmapFromState(ctx, ReferenceIndexStateKey, false)
+	if err != nil {
+		return false, errors.Wrap(err, "newRmapFromState() failed")
+	}
+
+	if marker.IsEmpty() {
+		return false, nil
+	}
+
+	return marker.GetBool(ReferenceIndexCompleteKey)
+}
+
+// markReferenceIndexComplete stores marker of complete reverse reference index, delete policies are applied from now on
+func markReferenceIndexComplete(ctx ContextInterface) error {
+	marker := rmap.NewFromMap(map[string]interface{}{
+		ReferenceIndexCompleteKey: true,
+	})
+
+	if err := ctx.Stub().PutState(ReferenceIndexStateKey, marker.Bytes()); err != nil {
+		return errors.Wrap(err, "ctx.Stub().PutState() failed")
+	}
+
+	return nil
+}
+
+// initReferenceIndex marks reverse reference index of new chaincode as complete, it has no assets stored without the index
+// it must be called before registries from init are upserted
+func initReferenceIndex(ctx ContextInterface) error {
+	isComplete, err := isReferenceIndexComplete(ctx)
+	if err != nil {
+		return errors.Wrap(err, "isReferenceIndexComplete() failed")
+	}
+
+	if isComplete {
+		return nil
+	}
+
+	items, err := ctx.GetRegistry().ListItems()
+	if err != nil {
+		return errors.Wrap(err, "reg.ListItems() failed")
+	}
+
+	if len(items) > 0 {
+		// upgraded chaincode can have assets stored before the index was introduced
+		return nil
+	}
+
+	return markReferenceIndexComplete(ctx)
+}
+
+// newReferrer parses reverse reference index key
+func (r *Registry) newReferrer(indexKey string) (referrer, error) {
+	_, elems, err := r.ctx.Stub().SplitCompositeKey(indexKey)
+	if err != nil {
+		return referrer{}, errors.Wrap(err, "ctx.Stub().SplitCompositeKey() failed")
+	}
+
+	if len(elems) != 5 {
+		return referrer{}, fmt.Errorf("invalid reference index key len(): %d, expected: 5, elems: %+v", len(elems), elems)
+	}
+
+	return referrer{
+		name:    strings.ToLower(elems[2]),
+		id:      elems[3],
+		pointer: elems[4],
+	}, nil
+}
+
+// getReferrers returns all asset instances with reference to asset name, id
+func (r *Registry) getReferrers(name, id string) ([]referrer, error) {
+	attributes := []string{strings.ToUpper(name), strings.ToLower(id)}
+
+	prefix, err := r.ctx.Stub().CreateCompositeKey(ReferenceIndexPrefix, attributes)
+	if err != nil {
+		return nil, errors.Wrap(err, "ctx.Stub().CreateCompositeKey() failed")
+	}
+
+	iterator, err := r.ctx.Stub().GetStateByPartialCompositeKey(ReferenceIndexPrefix, attributes)
+	if err != nil {
+		return nil, errors.Wrap(err, "ctx.Stub().GetStateByPartialCompositeKey() failed")
+	}
+	defer func() { _ = iterator.Close() }()
+
+	seen := map[string]bool{}
+	referrers := []referrer{}
+
+	for iterator.HasNext() {
+		item, err := iterator.Next()
+		if err != nil {
+			return nil, errors.Wrap(err, "iterator.Next() failed")
+		}
+
+		indexKey := item.GetKey()
+		seen[indexKey] = true
+
+		if exists, written := r.referenceIndex[indexKey]; written && !exists {
+			// removed in this TX
+			continue
+		}
+
+		ref, err := r.newReferrer(indexKey)
+		if err != nil {
+			return nil, errors.Wrap(err, "r.newReferrer() failed")
+		}
+
+		referrers = append(referrers, ref)
+	}
+
+	// index entries written in this TX are not visible in state yet
+	written := []string{}
+	for indexKey, exists := range r.referenceIndex {
+		if exists && !seen[indexKey] && strings.HasPrefix(indexKey, prefix) {
+			written = append(written, indexKey)
+		}
+	}
+	sort.Strings(written)
+
+	for _, indexKey := range written {
+		ref, err := r.newReferrer(indexKey)
+		if err != nil {
+			return nil, errors.Wrap(err, "r.newReferrer() failed")
+		}
+
+		referrers = append(referrers, ref)
+	}
+
+	return referrers, nil
+}
+
+// getReferrersPage returns one page of asset instances with reference to asset name, id and bookmark of next page
+// TX cannot be RW after this
+func (r *Registry) getReferrersPage(name, id, bookmark string, pageSize int) ([]referrer, string, error) {
+	iterator, metadata, err := r.ctx.Stub().GetStateByPartialCompositeKeyWithPagination(ReferenceIndexPrefix, []string{strings.ToUpper(name), strings.ToLower(id)}, int32(pageSize), bookmark)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "ctx.Stub().GetStateByPartialCompositeKeyWithPagination() failed")
+	}
+	defer func() { _ = iterator.Close() }()
+
+	referrers := []referrer{}
+
+	for iterator.HasNext() {
+		item, err := iterator.Next()
+		if err != nil {
+			return nil, "", errors.Wrap(err, "iterator.Next() failed")
+		}
+
+		ref, err := r.newReferrer(item.GetKey())
+		if err != nil {
+			return nil, "", errors.Wrap(err, "r.newReferrer() failed")
+		}
+
+		referrers = append(referrers, ref)
+	}
+
+	return referrers, metadata.GetBookmark(), nil
+}
+
+// getDeletePolicy returns delete policy for reference on pointer in asset
+// policy is taken from registryItem on_delete key, then from ON_DELETE-> word in schema description
+// empty string is returned, when no policy is declared, such reference is not enforced and is left dangling
+func (r *Registry) getDeletePolicy(asset rmap.Rmap, pointer string) (string, error) {
+	name, err := AssetGetDocType(asset)
+	if err != nil {
+		return "", errors.Wrap(err, "konst.AssetGetDocType() failed")
+	}
+
+	version, err := AssetGetVersion(asset)
+	if err != nil {
+		return "", errors.Wrap(err, "konst.AssetGetVersion() failed")
+	}
+
+	item, _, err := r.GetItem(name, version)
+	if err != nil {
+		return "", errors.Wrap(err, "r.GetItem() failed")
+	}
+
+	if item.Exists(RegistryItemOnDeleteKey) {
+		policies, err := item.GetRmap(RegistryItemOnDeleteKey)
+		if err != nil {
+			return "", errors.Wrap(err, "item.GetRmap() failed")
+		}
+
+		// policies are declared for fields, array indexes are not part of the key
+		fields := []string{}
+		for _, elem := range strings.Split(pointer, JPtrSeparator) {
+			if _, err := strconv.Atoi(elem); err != nil {
+				fields = append(fields, elem)
+			}
+		}
+
+		field := strings.Join(fields, JPtrSeparator)
+		if policies.Exists(field) {
+			return policies.GetString(field)
+		}
+	}
+
+	schema, err := item.GetRmap(RegistryItemSchemaKey)
+	if err != nil {
+		return "", errors.Wrap(err, "item.GetRmap() failed")
+	}
+
+	desc, err := (resolver{}).getDescription(schema, pointer)
+	if err != nil {
+		return "", errors.Wrap(err, "(resolver{}).getDescription() failed")
+	}
+
+	for _, word := range strings.Fields(desc) {
+		if !strings.HasPrefix(word, OnDeleteDescriptionPrefix) {
+			continue
+		}
+
+		policy := strings.ToLower(word[len(OnDeleteDescriptionPrefix):])
+		switch policy {
+		case OnDeleteRestrict, OnDeleteCascade, OnDeleteSetNull:
+			return policy, nil
+		default:
+			return "", fmt.Errorf("unknown delete policy: %s in description of: %s", policy, pointer)
+		}
+	}
+
+	return "", nil
+}
+
+// removeReference removes reference to id on pointer from asset. Reference in array is removed from array, otherwise the key is removed
+// returns true, if asset was changed
+func removeReference(asset rmap.Rmap, pointer, id string) (bool, error) {
+	sepIndex := strings.LastIndex(pointer, JPtrSeparator)
+	parentPointer := pointer[:sepIndex]
+	field := pointer[sepIndex+1:]
+
+	var parent interface{} = asset.Mapa
+	if parentPointer != "" {
+		exists, err := asset.ExistsJPtr(parentPointer)
+		if err != nil || !exists {
+			return false, nil
+		}
+
+		parent, err = asset.GetJPtr(parentPointer)
+		if err != nil {
+			return false, errors.Wrap(err, "asset.GetJPtr() failed")
+		}
+	}
+
+	switch el := parent.(type) {
+	case []interface{}:
+		kept := make([]interface{}, 0, len(el))
+		for _, item := range el {
+			if itemS, ok := item.(string); ok && strings.EqualFold(itemS, id) {
+				continue
+			}
+			kept = append(kept, item)
+		}
+
+		if len(kept) == len(el) {
+			return false, nil
+		}
+
+		if err := asset.SetJPtr(parentPointer, kept); err != nil {
+			return false, errors.Wrap(err, "asset.SetJPtr() failed")
+		}
+		return true, nil
+	case map[string]interface{}:
+		value, ok := el[field].(string)
+		if !ok || !strings.EqualFold(value, id) {
+			return false, nil
+		}
+
+		delete(el, field)
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// applyDeletePolicies enforces delete policies of all references to asset, that is going to be deleted
+// restrict fails, cascade deletes referencing asset, set-null removes the reference from referencing asset, reference without policy is ignored
+// policies are not applied, until reverse reference index is complete, it would miss references of assets stored before it was introduced
+func applyDeletePolicies(ctx ContextInterface, asset rmap.Rmap, isDirect bool) error {
+	reg := ctx.GetRegistry()
+
+	isComplete, err := isReferenceIndexComplete(ctx)
+	if err != nil {
+		return errors.Wrap(err, "isReferenceIndexComplete() failed")
+	}
+
+	if !isComplete {
+		return nil
+	}
+
+	name, err := AssetGetDocType(asset)
+	if err != nil {
+		return errors.Wrap(err, "konst.AssetGetDocType() failed")
+	}
+
+	id, err := AssetGetID(asset)
+	if err != nil {
+		return errors.Wrap(err, "konst.AssetGetID() failed")
+	}
+
+	key, err := reg.getAssetCompositeKey(name, id)
+	if err != nil {
+		return errors.Wrap(err, "reg.getAssetCompositeKey() failed")
+	}
+
+	// protect against cycles of cascade policies
+	reg.deleted[key] = true
+
+	referrers, err := reg.getReferrers(name, id)
+	if err != nil {
+		return errors.Wrap(err, "reg.getReferrers() failed")
+	}
+
+	for _, ref := range referrers {
+		refKey, err := reg.getAssetCompositeKey(ref.name, ref.id)
+		if err != nil {
+			return errors.Wrap(err, "reg.getAssetCompositeKey() failed")
+		}
+
+		if reg.deleted[refKey] {
+			continue
+		}
+
+		// soft deleted asset keeps its references as they were
+		source, err := reg.GetAsset(ref.name, ref.id, false, false)
+		if err != nil {
+			return errors.Wrap(err, "reg.GetAsset() failed")
+		}
+
+		if source.IsEmpty() {
+			continue
+		}
+
+		policy, err := reg.getDeletePolicy(source, ref.pointer)
+		if err != nil {
+			return errors.Wrap(err, "reg.getDeletePolicy() failed")
+		}
+
+		switch policy {
+		case OnDeleteRestrict:
+			return ErrorConflict(fmt.Sprintf("asset is referenced by: %s, id: %s, field: %s", strings.ToUpper(ref.name), ref.id, ref.pointer))
+		case OnDeleteCascade:
+			if _, err := assetDeleteBackend(ctx, ref.name, ref.id, anyRevision, isDirect); err != nil {
+				return errors.Wrapf(err, "cascade delete of: %s, id: %s failed", strings.ToUpper(ref.name), ref.id)
+			}
+		case OnDeleteSetNull:
+			changed, err := removeReference(source, ref.pointer, id)
+			if err != nil {
+				return errors.Wrap(err, "removeReference() failed")
+			}
+
+			if !changed {
+				continue
+			}
+
+			if !isDirect {
+				if err := enforceAssetAccess(reg, source, UpdateAction); err != nil {
+					return err
+				}
+			}
+
+			if err := reg.PutAsset(source, false); err != nil {
+				return errors.Wrap(err, "reg.PutAsset() failed")
+			}
+		}
+	}
+
+	return nil
+}
@@ -6,9 +6,11 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/KompiTech/fabric-cc-core/v2/pkg/kompiguard"
 	. "github.com/KompiTech/fabric-cc-core/v2/pkg/konst"
+	"github.com/KompiTech/fabric-cc-core/v2/pkg/schemacompat"
 	. "github.com/KompiTech/rmap"
 	lru "github.com/hashicorp/golang-lru"
 	"github.com/hyperledger/fabric-chaincode-go/shim"
@@ -26,6 +28,18 @@ type Registry struct {
 	ctx       ContextInterface
 	changelog *Changelog      // changelog handler is lazy initialized when it is needed
 	changeSet map[string]Rmap // modifications done to state in this TX. key: composite state key
+	revised   map[string]bool // assets that already had revision incremented in this TX. key: composite state key
+
+	changes    map[string]*assetChange // modifications of assets with events enabled done in this TX. key: composite state key
+	changeKeys []string                // keys of changes in order of first modification
+
+	references     map[string][]reference // references of assets written in this TX. key: composite state key of referencing asset
+	referenceIndex map[string]bool        // reverse reference index entries written (true) or removed (false) in this TX. key: index key
+	deleted        map[string]bool        // assets deleted in this TX, delete policies are not applied to them again. key: composite state key
+
+	configChanges []Rmap // new versions of registry items and singletons upserted in this TX, they are always audited
+
+	superuserBootstrap bool // superuser role is granted by bootstrap or identity migration, superuser guard is skipped
 
 	riCache  *lru.Cache // caches recently used registryItems. key: composite state key, value: Rmap
 	sCache   *lru.Cache // caches recently used singletons. key: composite state key, value: Rmap
@@ -37,6 +51,7 @@ type Registry struct {
 type RegistryInterface interface {
 	BulkUpsertItems(items []bulkItem) error
 	UpsertItem(registryItemToUpsert Rmap, assetName string) (Rmap, int, error)
+	UpsertItemWithReport(registryItemToUpsert Rmap, assetName string, force bool) (Rmap, int, schemacompat.Report, error)
 	GetThisIdentity() (Rmap, error)
 	GetThisIdentityResolved() (Rmap, error)
 	GetItem(name string, requestedVersion int) (Rmap, int, error)
@@ -49,6 +64,9 @@ type RegistryInterface interface {
 	GetQueryIterator(name string, query Rmap, bookmark string, pageSize int) (IteratorInterface, string, error)
 	QueryAssets(name string, query Rmap, bookmark string, resolve bool, paginate bool, pageSize int) ([]Rmap, string, error)
 	DeleteAsset(asset Rmap) error
+	SoftDeleteAsset(asset Rmap) error
+	RestoreAsset(asset Rmap) error
+	GetDeletedAsset(name, id string) (Rmap, error)
 	GetAssetHistory(asset Rmap) ([]Rmap, error)
 	UpsertSingleton(singletonItemToUpsert Rmap, singletonName string) (int, error)
 	BulkUpsertSingletons(items []bulkItem) error
@@ -61,6 +79,7 @@ type RegistryInterface interface {
 type bulkItem struct {
 	Name  string
 	Value Rmap
+	Force bool // upsert even if schema change is not backward compatible
 }
 
 // NewEngine initializes Registry from existing state or bootstraps empty one
@@ -94,6 +113,14 @@ func newRegistry(ctx ContextInterface) (RegistryInterface, error) {
 		ctx,
 		nil,
 		map[string]Rmap{},
+		map[string]bool{},
+		map[string]*assetChange{},
+		nil,
+		map[string][]reference{},
+		map[string]bool{},
+		map[string]bool{},
+		nil,
+		false,
 		riCache,
 		sCache,
 		aCache,
@@ -137,7 +164,7 @@ func (r *Registry) BulkUpsertItems(items []bulkItem) error {
 	}
 
 	for _, bui := range items {
-		_, change, _, err := r.upsertItem(bui.Value, bui.Name)
+		_, change, _, _, err := r.upsertItem(bui.Value, bui.Name, bui.Force)
 		if err != nil {
 			return errors.Wrap(err, "r.upsertItem() failed")
 		}
@@ -159,17 +186,29 @@ func (r *Registry) BulkUpsertItems(items []bulkItem) error {
 }
 
 // UpsertItem upsert single item, updates lvm and changelog
+// change of schema, that is not backward compatible, is rejected
 func (r *Registry) UpsertItem(registryItemToUpsert Rmap, assetName string) (Rmap, int, error) {
-	regItem, change, version, err := r.upsertItem(registryItemToUpsert, assetName)
+	regItem, version, _, err := r.UpsertItemWithReport(registryItemToUpsert, assetName, false)
+	if err != nil {
+		return Rmap{}, -1, errors.Wrap(err, "r.UpsertItemWithReport() failed")
+	}
+
+	return regItem, version, nil
+}
+
+// UpsertItemWithReport upsert single item, updates lvm and changelog
+// change of schema, that is not backward compatible, is rejected, unless force is true. Returns compatibility report of changes against previous version
+func (r *Registry) UpsertItemWithReport(registryItemToUpsert Rmap, assetName string, force bool) (Rmap, int, schemacompat.Report, error) {
+	regItem, change, version, report, err := r.upsertItem(registryItemToUpsert, assetName, force)
 	if err != nil {
-		return Rmap{}, -1, errors.Wrap(err, "r.upsertItem() failed")
+		return Rmap{}, -1, schemacompat.Report{}, errors.Wrap(err, "r.upsertItem() failed")
 	}
 
 	if !change.IsEmpty() {
 		// only write to changelog if there are changes
 		now, err := r.ctx.Time()
 		if err != nil {
-			return Rmap{}, -1, errors.Wrap(err, "ctx.Time() failed")
+			return Rmap{}, -1, schemacompat.Report{}, errors.Wrap(err, "ctx.Time() failed")
 		}
 
 		ci := ChangelogItem{
@@ -179,11 +218,11 @@ func (r *Registry) UpsertItem(registryItemToUpsert Rmap, assetName string) (Rmap
 		}
 
 		if err := r.writeChangelog(ci); err != nil {
-			return Rmap{}, -1, errors.Wrap(err, "r.writeChangelog() failed")
+			return Rmap{}, -1, schemacompat.Report{}, errors.Wrap(err, "r.writeChangelog() failed")
 		}
 	}
 
-	return regItem, version, nil
+	return regItem, version, report, nil
 }
 
 // GetThisIdentity returns identity asset for current user
@@ -213,77 +252,211 @@ func (r Registry) GetThisIdentityResolved() (Rmap, error) {
 		return Rmap{}, errors.Wrap(err, "r.GetThisIdentity() failed")
 	}
 
-	if thisIdentity.Exists(IdentityRolesKey) {
+	if err := r.resolveIdentityRoles(thisIdentity); err != nil {
+		return Rmap{}, errors.Wrap(err, "r.resolveIdentityRoles() failed")
+	}
+
+	return thisIdentity, nil
+}
+
+// GetIdentityResolved returns identity asset with fingerprint and resolves all roles it has the same way as GetThisIdentityResolved
+func (r Registry) GetIdentityResolved(fingerprint string) (Rmap, error) {
+	identity, err := r.GetAsset(IdentityAssetName, fingerprint, false, true)
+	if err != nil {
+		return Rmap{}, errors.Wrap(err, "r.GetAsset() failed")
+	}
+
+	if err := r.resolveIdentityRoles(identity); err != nil {
+		return Rmap{}, errors.Wrap(err, "r.resolveIdentityRoles() failed")
+	}
+
+	return identity, nil
+}
+
+// resolveIdentityRoles replaces references to roles in identity by role assets with resolved parent roles
+// groups, that identity is member of, are added with their resolved roles
+func (r Registry) resolveIdentityRoles(identity Rmap) error {
+	if identity.Exists(IdentityRolesKey) {
 		// manual resolve of roles, to prevent infinite recursion if some user business logic does the same
-		roles, err := thisIdentity.GetIterable(IdentityRolesKey)
+		roles, err := identity.GetIterable(IdentityRolesKey)
 		if err != nil {
-			return Rmap{}, errors.Wrap(err, "thisIdentity.GetIterable() failed")
+			return errors.Wrap(err, "identity.GetIterable() failed")
 		}
 
 		for roleIndex, roleI := range roles {
-			roleAsset, err := r.GetAsset(RoleAssetName, roleI.(string), false, true)
+			roleAsset, err := r.resolveRole(roleI.(string), map[string]struct{}{})
 			if err != nil {
-				return Rmap{}, errors.Wrap(err, "reg.GetAsset() failed")
+				return errors.Wrap(err, "r.resolveRole() failed")
 			}
 
 			// set resolved role asset
-			if err := thisIdentity.SetJPtr("/"+IdentityRolesKey+"/"+strconv.Itoa(roleIndex), roleAsset.Mapa); err != nil {
-				return Rmap{}, errors.Wrap(err, "thisIdentity.SetJPtr() failed")
+			if err := identity.SetJPtr("/"+IdentityRolesKey+"/"+strconv.Itoa(roleIndex), roleAsset.Mapa); err != nil {
+				return errors.Wrap(err, "identity.SetJPtr() failed")
 			}
 		}
 	}
 
-	return thisIdentity, nil
+	groups, err := r.getIdentityGroups(identity)
+	if err != nil {
+		return errors.Wrap(err, "r.getIdentityGroups() failed")
+	}
+
+	identity.Mapa[IdentityMemberOfKey] = groups
+	return nil
+}
+
+// getIdentityGroups returns groups with identity in members, roles of groups are resolved
+// groups are found by reverse reference index, so they do not have to be queried
+func (r Registry) getIdentityGroups(identity Rmap) ([]interface{}, error) {
+	fingerprint, err := AssetGetID(identity)
+	if err != nil {
+		return nil, errors.Wrap(err, "AssetGetID(identity) failed")
+	}
+
+	referrers, err := r.getReferrers(IdentityAssetName, fingerprint)
+	if err != nil {
+		return nil, errors.Wrap(err, "r.getReferrers() failed")
+	}
+
+	groups := []interface{}{}
+	seen := map[string]bool{}
+
+	for _, ref := range referrers {
+		if ref.name != GroupAssetName || !strings.HasPrefix(ref.pointer, "/"+GroupMembersKey+"/") || seen[ref.id] {
+			continue
+		}
+		seen[ref.id] = true
+
+		group, err := r.GetAsset(GroupAssetName, ref.id, false, false)
+		if err != nil {
+			return nil, errors.Wrap(err, "r.GetAsset() failed")
+		}
+
+		if group.IsEmpty() {
+			continue
+		}
+
+		if group.Exists(GroupRolesKey) {
+			roles, err := group.GetIterable(GroupRolesKey)
+			if err != nil {
+				return nil, errors.Wrap(err, "group.GetIterable() failed")
+			}
+
+			resolved := make([]interface{}, 0, len(roles))
+			for _, roleI := range roles {
+				roleAsset, err := r.resolveRole(roleI.(string), map[string]struct{}{})
+				if err != nil {
+					return nil, errors.Wrap(err, "r.resolveRole() failed")
+				}
+				resolved = append(resolved, roleAsset.Mapa)
+			}
+
+			group.Mapa[GroupRolesKey] = resolved
+		}
+
+		groups = append(groups, group.Mapa)
+	}
+
+	return groups, nil
+}
+
+// resolveRole returns role asset with its parent roles resolved recursively
+// path contains UUIDs of roles being resolved, parent already in path is left unresolved, so cycle does not cause infinite recursion
+func (r Registry) resolveRole(id string, path map[string]struct{}) (Rmap, error) {
+	role, err := r.GetAsset(RoleAssetName, id, false, true)
+	if err != nil {
+		return Rmap{}, errors.Wrap(err, "reg.GetAsset() failed")
+	}
+
+	if !role.Exists(ParentsKey) {
+		return role, nil
+	}
+
+	parents, err := role.GetIterable(ParentsKey)
+	if err != nil {
+		return Rmap{}, errors.Wrap(err, "role.GetIterable() failed")
+	}
+
+	path[id] = struct{}{}
+	defer delete(path, id)
+
+	resolved := make([]interface{}, 0, len(parents))
+	for _, parentI := range parents {
+		parentID, ok := parentI.(string)
+		if !ok {
+			return Rmap{}, fmt.Errorf("parent role is not string: %v", parentI)
+		}
+
+		if _, inPath := path[parentID]; inPath {
+			resolved = append(resolved, parentID)
+			continue
+		}
+
+		parent, err := r.resolveRole(parentID, path)
+		if err != nil {
+			return Rmap{}, errors.Wrapf(err, "r.resolveRole() failed for parent: %s", parentID)
+		}
+
+		resolved = append(resolved, parent.Mapa)
+	}
+
+	role.Mapa[ParentsKey] = resolved
+	return role, nil
 }
 
 // upsertItem creates new or updates existing registryItem
 // If latest version matches the one being upserted, nothing is done, no error is returned
-// returns RegItem, Change, actual version
-func (r *Registry) upsertItem(registryItemToUpsert Rmap, assetName string) (Rmap, Change, int, error) {
+// Changes of schema, that are not backward compatible, are rejected, unless force is true
+// returns RegItem, Change, actual version, compatibility report of changes against previous version
+func (r *Registry) upsertItem(registryItemToUpsert Rmap, assetName string, force bool) (Rmap, Change, int, schemacompat.Report, error) {
 	assetName = strings.ToLower(assetName)
 
-	if assetName == RoleAssetName || assetName == IdentityAssetName {
-		return Rmap{}, Change{}, -1, fmt.Errorf("unable to upsert registry for internal asset name: %s", assetName)
+	if assetName == RoleAssetName || assetName == IdentityAssetName || assetName == GroupAssetName || assetName == ProposalAssetName {
+		return Rmap{}, Change{}, -1, schemacompat.Report{}, fmt.Errorf("unable to upsert registry for internal asset name: %s", assetName)
 	}
 
 	// validate registryItem form
 	if err := registryItemToUpsert.ValidateSchemaBytes([]byte(RegistryItemSchema)); err != nil {
-		return Rmap{}, Change{}, -1, errors.Wrap(err, "registryItemToUpsert.ValidateSchemaBytes() failed")
+		return Rmap{}, Change{}, -1, schemacompat.Report{}, errors.Wrap(err, "registryItemToUpsert.ValidateSchemaBytes() failed")
+	}
+
+	if err := validateMigration(r.ctx, registryItemToUpsert); err != nil {
+		return Rmap{}, Change{}, -1, schemacompat.Report{}, err
 	}
 
 	// validate schema itself
 	sch, err := registryItemToUpsert.GetRmap("schema")
 	if err != nil {
-		return Rmap{}, Change{}, -1, errors.Wrap(err, "registryItemToUpsert.GetRmap() failed")
+		return Rmap{}, Change{}, -1, schemacompat.Report{}, errors.Wrap(err, "registryItemToUpsert.GetRmap() failed")
 	}
 
 	if !sch.IsValidJSONSchema() {
-		return Rmap{}, Change{}, -1, fmt.Errorf("schema for: %s is not a valid JSON schema", assetName)
+		return Rmap{}, Change{}, -1, schemacompat.Report{}, fmt.Errorf("schema for: %s is not a valid JSON schema", assetName)
 	}
 
 	// JSONSchema "type" must be "object"
 	typ, err := registryItemToUpsert.GetJPtrString(SchemaTypeJPtr)
 	if err != nil {
-		return Rmap{}, Change{}, -1, errors.Wrap(err, "registryItemToUpsert.GetJPtrString() failed")
+		return Rmap{}, Change{}, -1, schemacompat.Report{}, errors.Wrap(err, "registryItemToUpsert.GetJPtrString() failed")
 	}
 
 	if typ != "object" {
-		return Rmap{}, Change{}, -1, fmt.Errorf("value: %s, type on top level is not: object", registryItemToUpsert.String())
+		return Rmap{}, Change{}, -1, schemacompat.Report{}, fmt.Errorf("value: %s, type on top level is not: object", registryItemToUpsert.String())
 	}
 
 	// JSONSchema "additionalProperties" must be false
 	ap, err := registryItemToUpsert.GetJPtrBool(SchemaAdditionalPropertiesJPtr)
 	if err != nil {
-		return Rmap{}, Change{}, -1, errors.Wrap(err, "registryItemToUpsert.GetJPtrBool() failed")
+		return Rmap{}, Change{}, -1, schemacompat.Report{}, errors.Wrap(err, "registryItemToUpsert.GetJPtrBool() failed")
 	}
 	if ap {
-		return Rmap{}, Change{}, -1, fmt.Errorf("value: %s, additionalProperties on top level must be set to false", registryItemToUpsert.String())
+		return Rmap{}, Change{}, -1, schemacompat.Report{}, fmt.Errorf("value: %s, additionalProperties on top level must be set to false", registryItemToUpsert.String())
 	}
 
 	// get iterator of existing registry items
 	iterator, err := r.ctx.Stub().GetStateByPartialCompositeKey(RegistryItemPrefix, []string{strings.ToUpper(assetName)})
 	if err != nil {
-		return Rmap{}, Change{}, -1, errors.Wrap(err, "ctx.Stub().GetStateByPartialCompositeKey() failed")
+		return Rmap{}, Change{}, -1, schemacompat.Report{}, errors.Wrap(err, "ctx.Stub().GetStateByPartialCompositeKey() failed")
 	}
 	defer func() { _ = iterator.Close() }()
 
@@ -293,17 +466,17 @@ func (r *Registry) upsertItem(registryItemToUpsert Rmap, assetName string) (Rmap
 	for iterator.HasNext() {
 		item, err := iterator.Next()
 		if err != nil {
-			return Rmap{}, Change{}, -1, errors.Wrap(err, "iterator.Next() failed")
+			return Rmap{}, Change{}, -1, schemacompat.Report{}, errors.Wrap(err, "iterator.Next() failed")
 		}
 		_, elems, err := r.ctx.Stub().SplitCompositeKey(item.GetKey())
 		if err != nil {
-			return Rmap{}, Change{}, -1, errors.Wrap(err, "ctx.Stub().SplitCompositeKey() failed")
+			return Rmap{}, Change{}, -1, schemacompat.Report{}, errors.Wrap(err, "ctx.Stub().SplitCompositeKey() failed")
 		}
 
 		// parse version as int from last elem of composite key
 		version, err := strconv.Atoi(elems[len(elems)-1])
 		if err != nil {
-			return Rmap{}, Change{}, -1, errors.Wrap(err, "strconv.Atoi() failed")
+			return Rmap{}, Change{}, -1, schemacompat.Report{}, errors.Wrap(err, "strconv.Atoi() failed")
 		}
 
 		// update latest version info if newer
@@ -315,6 +488,7 @@ func (r *Registry) upsertItem(registryItemToUpsert Rmap, assetName string) (Rmap
 
 	var targetVersion int
 	var isCreate bool
+	var report schemacompat.Report
 
 	if latestVersion == 0 {
 		// no version exists, create version 1
@@ -324,47 +498,67 @@ func (r *Registry) upsertItem(registryItemToUpsert Rmap, assetName string) (Rmap
 		// some version already exists, latestVersion contains the latest number
 		latestRegistryItem, err := NewFromBytes(latestData)
 		if err != nil {
-			return Rmap{}, Change{}, -1, errors.Wrap(err, "rmap.NewFromBytes() failed")
+			return Rmap{}, Change{}, -1, schemacompat.Report{}, errors.Wrap(err, "rmap.NewFromBytes() failed")
 		}
 
 		// compare latest by hash with one being upserted
 		if registryItemToUpsert.Hash() == latestRegistryItem.Hash() {
 			// has is identical to latest, do not update, do not fail
-			return latestRegistryItem, Change{}, latestVersion, nil
+			return latestRegistryItem, Change{}, latestVersion, schemacompat.Report{}, nil
 		}
 		// new version is +1 latest
 		targetVersion = latestVersion + 1
 		// it is not allowed to change destination between versions
 		latestDestination, err := latestRegistryItem.GetString(RegistryItemDestinationKey)
 		if err != nil {
-			return Rmap{}, Change{}, -1, errors.Wrap(err, "latestRegistryItem.GetString() failed")
+			return Rmap{}, Change{}, -1, schemacompat.Report{}, errors.Wrap(err, "latestRegistryItem.GetString() failed")
 		}
 
 		newDestination, err := registryItemToUpsert.GetString(RegistryItemDestinationKey)
 		if err != nil {
-			return Rmap{}, Change{}, -1, errors.Wrap(err, "registryItemToUpsert.GetString() failed")
+			return Rmap{}, Change{}, -1, schemacompat.Report{}, errors.Wrap(err, "registryItemToUpsert.GetString() failed")
 		}
 
 		if latestDestination != newDestination {
-			return Rmap{}, Change{}, -1, fmt.Errorf("unable to change destination of: %s, from: %s, to: %s", assetName, latestDestination, newDestination)
+			return Rmap{}, Change{}, -1, schemacompat.Report{}, fmt.Errorf("unable to change destination of: %s, from: %s, to: %s", assetName, latestDestination, newDestination)
+		}
+
+		// check that existing instances and clients are not broken by new version
+		report, err = schemacompat.Analyze(latestRegistryItem, registryItemToUpsert)
+		if err != nil {
+			return Rmap{}, Change{}, -1, schemacompat.Report{}, errors.Wrap(err, "schemacompat.Analyze() failed")
+		}
+
+		if !report.IsBackward() && !force {
+			incompatible := []string{}
+			for _, change := range report.IncompatibleChanges() {
+				incompatible = append(incompatible, change.String())
+			}
+			return Rmap{}, Change{}, -1, schemacompat.Report{}, ErrorConflict(fmt.Sprintf("change(s) of schema not backward compatible: %s, use force to upsert anyway", strings.Join(incompatible, ", ")))
 		}
 		isCreate = false
 	}
+
+	// approval is required only for new version, upsert of unchanged item is always allowed
+	if err := checkApproval(r.ctx, newApprovalOperation(ApprovalRegistryUpsert, assetName)); err != nil {
+		return Rmap{}, Change{}, -1, schemacompat.Report{}, err
+	}
+
 	// create composite key for new registry item: RegistryItemPrefix | ASSET_NAME | VERSION
 	key, err := r.ctx.Stub().CreateCompositeKey(RegistryItemPrefix, []string{strings.ToUpper(assetName), strconv.Itoa(targetVersion)})
 	if err != nil {
-		return Rmap{}, Change{}, -1, errors.Wrap(err, "ctx.Stub().CreateCompositeKey(RegistryItemPrefix, ...) failed")
+		return Rmap{}, Change{}, -1, schemacompat.Report{}, errors.Wrap(err, "ctx.Stub().CreateCompositeKey(RegistryItemPrefix, ...) failed")
 	}
 
 	// write new registryItem
 	if err := putRmapToState(r.ctx, key, true, registryItemToUpsert); err != nil {
-		return Rmap{}, Change{}, -1, errors.Wrap(err, "putRmapToState(registryItem) failed")
+		return Rmap{}, Change{}, -1, schemacompat.Report{}, errors.Wrap(err, "putRmapToState(registryItem) failed")
 	}
 
 	// create composite key for new latest item: LatestRegistryItemPrefix | ASSET_NAME
 	latestKey, err := r.ctx.Stub().CreateCompositeKey(LatestRegistryItemPrefix, []string{strings.ToUpper(assetName)})
 	if err != nil {
-		return Rmap{}, Change{}, -1, errors.Wrap(err, "r.ctx.Stub().CreateCompositeKey(LatestRegistryItemPrefix, ...) failed")
+		return Rmap{}, Change{}, -1, schemacompat.Report{}, errors.Wrap(err, "r.ctx.Stub().CreateCompositeKey(LatestRegistryItemPrefix, ...) failed")
 	}
 
 	// create latest registry obj
@@ -375,12 +569,14 @@ func (r *Registry) upsertItem(registryItemToUpsert Rmap, assetName string) (Rmap
 
 	// create/update latestObj
 	if err := putRmapToState(r.ctx, latestKey, isCreate, latestObj); err != nil {
-		return Rmap{}, Change{}, -1, errors.Wrap(err, "putRmapToState(latestObj) failed")
+		return Rmap{}, Change{}, -1, schemacompat.Report{}, errors.Wrap(err, "putRmapToState(latestObj) failed")
 	}
 
 	// write to cache
 	r.riCache.Add(key, registryItemToUpsert)
 
+	r.configChanges = append(r.configChanges, makeConfigAuditChange(AuditRegistryKey, assetName, targetVersion, isCreate))
+
 	var operation string
 	if isCreate {
 		operation = ChangelogCreateOperation
@@ -392,15 +588,15 @@ func (r *Registry) upsertItem(registryItemToUpsert Rmap, assetName string) (Rmap
 		AssetName: assetName,
 		Version:   targetVersion,
 		Operation: operation,
-	}, targetVersion, nil
+	}, targetVersion, report, nil
 }
 
 // GetItem loads existing registryItem from state
 // returns registry item and its actual version
 func (r *Registry) GetItem(name string, requestedVersion int) (Rmap, int, error) {
 	name = strings.ToLower(name)
-	// identity, role assets have their schema hardcoded
-	if name == IdentityAssetName || name == RoleAssetName {
+	// identity, role, group, proposal assets have their schema hardcoded
+	if name == IdentityAssetName || name == RoleAssetName || name == GroupAssetName || name == ProposalAssetName {
 		var hardcodedSchema []byte
 		if name == IdentityAssetName {
 			hardcodedSchema = []byte(IdentitySchema)
@@ -410,6 +606,14 @@ func (r *Registry) GetItem(name string, requestedVersion int) (Rmap, int, error)
 			hardcodedSchema = []byte(RoleSchema)
 		}
 
+		if name == GroupAssetName {
+			hardcodedSchema = []byte(GroupSchema)
+		}
+
+		if name == ProposalAssetName {
+			hardcodedSchema = []byte(ProposalSchema)
+		}
+
 		schema, err := NewFromBytes(hardcodedSchema)
 		if err != nil {
 			return Rmap{}, -1, errors.Wrap(err, "rmap.NewFromBytes() failed")
@@ -536,7 +740,17 @@ func (r *Registry) MarkAssetAsExisting(name, id string, data Rmap) error {
 }
 
 // GetAsset loads asset instance from persistent storage (state or private data - depends on configuration)
+// soft deleted asset is handled as if it does not exist
 func (r *Registry) GetAsset(name, id string, resolve bool, failOnNotFound bool) (Rmap, error) {
+	return r.getAsset(name, id, resolve, failOnNotFound, false)
+}
+
+// GetDeletedAsset loads asset instance including soft deleted one, it fails if asset does not exist
+func (r *Registry) GetDeletedAsset(name, id string) (Rmap, error) {
+	return r.getAsset(name, id, false, true, true)
+}
+
+func (r *Registry) getAsset(name, id string, resolve bool, failOnNotFound bool, includeDeleted bool) (Rmap, error) {
 	// get composite state key
 	key, err := r.getAssetCompositeKey(name, id)
 	if err != nil {
@@ -585,6 +799,13 @@ func (r *Registry) GetAsset(name, id string, resolve bool, failOnNotFound bool)
 		}
 	}
 
+	if !includeDeleted && AssetIsDeleted(asset) {
+		if failOnNotFound {
+			return Rmap{}, ErrorNotFound(fmt.Sprintf("asset is deleted: %s", strings.Replace(key, ZeroByte, "", -1)))
+		}
+		return NewEmpty(), nil
+	}
+
 	// make a copy, so record in cache is not modified by resolving
 	asset = asset.Copy()
 
@@ -667,62 +888,31 @@ func (r *Registry) putAsset(asset Rmap, isCreate bool, skipWalkReferences bool)
 		return errors.Wrap(err, "r.GetItem() failed")
 	}
 
-	schema, err := regItem.GetRmap(RegistryItemSchemaKey)
+	schema, err := r.getAssetSchema(name, regItem)
 	if err != nil {
-		return errors.Wrap(err, "regItem.GetRmap() failed")
+		return errors.Wrap(err, "r.getAssetSchema() failed")
 	}
 
-	if err := r.addGlobalDefinitionsToSchema(schema); err != nil {
-		return errors.Wrap(err, "r.addGlobalDefinitionsToSchema() failed")
+	// validate JSON schema
+	fields, err := validateSchema(asset, schema)
+	if err != nil {
+		return errors.Wrap(err, "validateSchema() failed")
 	}
 
-	if name != IdentityAssetName {
-		// identity is the only asset with service keys hardcoded
-		if err := r.addServiceKeysToSchema(schema); err != nil {
-			return errors.Wrap(err, "r.addServiceKeysToSchema() failed")
-		}
+	if len(fields) > 0 {
+		return ErrorValidationFields(fmt.Sprintf("asset.ValidateSchema() failed on assetName: %s", name), fields)
 	}
 
-	oldDefsKey := r.ctx.GetConfiguration().SchemaDefinitionCompatibility
-
-	if oldDefsKey != "" && oldDefsKey != SchemaDefinitionsKey {
-		// if SchemaDefinitionCompatibility is set to same value as $defs, it is obvious error and do not replace anything
-		// handle compat with pre draft-07 schemas, if enabled
-		// first step - replace legacy definitions target to proper $defs form
-		template := "#/%s/"
-
-		oldD := []byte(fmt.Sprintf(template, oldDefsKey))
-		newD := []byte(fmt.Sprintf(template, SchemaDefinitionsKey))
-
-		// replacement is done on bytes form
-		schemaBytes := bytes.Replace(schema.Bytes(), oldD, newD, -1)
-
-		schema, err = NewFromBytes(schemaBytes)
-		if err != nil {
+	if name == RoleAssetName {
+		if err := validateGrantConditions(asset); err != nil {
 			return err
 		}
 
-		// second step - add any legacy definitions to $defs already containing injected global definitions
-		oldDefsI, exists := schema.Mapa[oldDefsKey]
-		if exists {
-			legacyDefs, err := NewFromInterface(oldDefsI)
-			if err != nil {
-				return err
-			}
-
-			delete(schema.Mapa, oldDefsKey)
-
-			if err := schema.Inject(SchemaDefinitionsJPtr, legacyDefs); err != nil {
-				return err
-			}
+		if err := r.validateRoleParents(asset); err != nil {
+			return err
 		}
 	}
 
-	// validate JSON schema
-	if err := asset.ValidateSchema(schema); err != nil {
-		return errors.Wrapf(err, "asset.ValidateSchema() failed on assetName: %s", name)
-	}
-
 	if !skipWalkReferences {
 		if err := (resolver{}).WalkReferences(r.ctx, asset, false); err != nil {
 			return errors.Wrap(err, "(resolver{}).WalkReferences() failed")
@@ -749,13 +939,53 @@ func (r *Registry) putAsset(asset Rmap, isCreate bool, skipWalkReferences bool)
 		return errors.Wrap(err, "r.getAssetCompositeKey() failed")
 	}
 
+	// changes of identities, roles and groups granting or revoking superuser role are compared with state before this write
+	checkSuperuser := false
+	if isSuperuserGuarded(name) {
+		pre := NewEmpty()
+		if !isCreate {
+			pre, err = r.getAsset(name, id, false, false, true)
+			if err != nil {
+				return errors.Wrap(err, "r.getAsset() failed")
+			}
+		}
+
+		checkSuperuser, err = r.guardSuperuser(name, pre, asset)
+		if err != nil {
+			return err
+		}
+	}
+
 	// determine storage location
 	destination, err := regItem.GetString(RegistryItemDestinationKey)
 	if err != nil {
 		return errors.Wrap(err, "regItem.GetString() failed")
 	}
 
+	// revision is incremented only once per TX, further modifications of the same asset in this TX keep it
+	if !r.revised[key] {
+		revision, err := AssetGetRevision(asset)
+		if err != nil {
+			return errors.Wrap(err, "konst.AssetGetRevision() failed")
+		}
+
+		asset.Mapa[AssetRevisionKey] = revision + 1
+		r.revised[key] = true
+	}
+
+	if isChangeRecorded(r.ctx, name) {
+		if err := r.recordPut(name, key, destination, isCreate, asset); err != nil {
+			return errors.Wrap(err, "r.recordPut() failed")
+		}
+	}
+
 	if destination == StateDestinationValue {
+		// index is updated before asset is written, because references of previous value are loaded from state
+		// private data references are not indexed, index in state would disclose them
+		if err := r.updateReferenceIndex(name, id, key, isCreate, asset); err != nil {
+			return errors.Wrap(err, "r.updateReferenceIndex() failed")
+		}
+
 		if err := putRmapToState(r.ctx, key, isCreate, asset); err != nil {
 			return errors.Wrap(err, "putRmapToState() failed")
 		}
@@ -769,6 +999,115 @@ func (r *Registry) putAsset(asset Rmap, isCreate bool, skipWalkReferences bool)
 	r.changeSet[key] = asset
 	r.aCache.Add(key, asset)
 
+	invalidateAuthorization(r.ctx, name)
+
+	if checkSuperuser {
+		if err := r.checkSuperuserRemains(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getAssetSchema returns schema from registryItem of asset name prepared for validation of asset instance
+func (r *Registry) getAssetSchema(name string, regItem Rmap) (Rmap, error) {
+	schema, err := regItem.GetRmap(RegistryItemSchemaKey)
+	if err != nil {
+		return Rmap{}, errors.Wrap(err, "regItem.GetRmap() failed")
+	}
+
+	if err := r.addGlobalDefinitionsToSchema(schema); err != nil {
+		return Rmap{}, errors.Wrap(err, "r.addGlobalDefinitionsToSchema() failed")
+	}
+
+	if name != IdentityAssetName {
+		// identity is the only asset with service keys hardcoded
+		if err := r.addServiceKeysToSchema(schema); err != nil {
+			return Rmap{}, errors.Wrap(err, "r.addServiceKeysToSchema() failed")
+		}
+	}
+
+	oldDefsKey := r.ctx.GetConfiguration().SchemaDefinitionCompatibility
+
+	if oldDefsKey != "" && oldDefsKey != SchemaDefinitionsKey {
+		// if SchemaDefinitionCompatibility is set to same value as $defs, it is obvious error and do not replace anything
+		// handle compat with pre draft-07 schemas, if enabled
+		// first step - replace legacy definitions target to proper $defs form
+		template := "#/%s/"
+
+		oldD := []byte(fmt.Sprintf(template, oldDefsKey))
+		newD := []byte(fmt.Sprintf(template, SchemaDefinitionsKey))
+
+		// replacement is done on bytes form
+		schemaBytes := bytes.Replace(schema.Bytes(), oldD, newD, -1)
+
+		schema, err = NewFromBytes(schemaBytes)
+		if err != nil {
+			return Rmap{}, err
+		}
+
+		// second step - add any legacy definitions to $defs already containing injected global definitions
+		oldDefsI, exists := schema.Mapa[oldDefsKey]
+		if exists {
+			legacyDefs, err := NewFromInterface(oldDefsI)
+			if err != nil {
+				return Rmap{}, err
+			}
+
+			delete(schema.Mapa, oldDefsKey)
+
+			if err := schema.Inject(SchemaDefinitionsJPtr, legacyDefs); err != nil {
+				return Rmap{}, err
+			}
+		}
+	}
+
+	return schema, nil
+}
+
+// recordPut records create, update or migrate of asset for chaincode event
+func (r *Registry) recordPut(name, key, destination string, isCreate bool, asset Rmap) error {
+	if isCreate {
+		r.recordChange(key, EventCreateOperation, destination, Rmap{}, asset.Copy())
+		return nil
+	}
+
+	var pre Rmap
+	if existing, exists := r.changes[key]; exists {
+		pre = existing.pre
+	} else {
+		// asset was not modified in this TX yet, load its original value
+		var err error
+		pre, err = newRmapFromDestination(r.ctx, name, key, destination, false)
+		if err != nil {
+			return errors.Wrap(err, "newRmapFromDestination() failed")
+		}
+	}
+
+	operation := EventUpdateOperation
+	if AssetIsDeleted(asset) && !AssetIsDeleted(pre) {
+		r.recordChange(key, EventDeleteOperation, destination, pre, asset.Copy())
+		return nil
+	} else if !AssetIsDeleted(asset) && AssetIsDeleted(pre) {
+		operation = EventRestoreOperation
+	} else if !pre.IsEmpty() {
+		preVersion, err := AssetGetVersion(pre)
+		if err != nil {
+			return errors.Wrap(err, "konst.AssetGetVersion() failed")
+		}
+
+		postVersion, err := AssetGetVersion(asset)
+		if err != nil {
+			return errors.Wrap(err, "konst.AssetGetVersion() failed")
+		}
+
+		if preVersion != postVersion {
+			operation = EventMigrateOperation
+		}
+	}
+
+	r.recordChange(key, operation, destination, pre, asset.Copy())
 	return nil
 }
 
@@ -780,7 +1119,7 @@ func (r *Registry) GetQueryIterator(name string, query Rmap, bookmark string, pa
 
 	// check query for not unexpected keys, these will make chaincode panic if sent to CouchDB, which we do not want
 	var invalidKeys []string
-	allowedKeys, _ := NewFromSlice([]interface{}{QuerySelectorKey, QueryFieldsKey, QueryBookmarkKey, QueryLimitKey, QuerySortKey})
+	allowedKeys, _ := NewFromSlice([]interface{}{QuerySelectorKey, QueryFieldsKey, QueryBookmarkKey, QueryLimitKey, QuerySortKey, QueryIncludeDeletedKey})
 
 	for k := range query.Mapa {
 		if !allowedKeys.Exists(k) {
@@ -866,6 +1205,39 @@ func (r *Registry) GetQueryIterator(name string, query Rmap, bookmark string, pa
 		return null, "", errors.Wrap(err, "registryItem.GetString() failed")
 	}
 
+	includeDeleted := false
+	if query.Exists(QueryIncludeDeletedKey) {
+		includeDeleted, err = query.GetBool(QueryIncludeDeletedKey)
+		if err != nil {
+			return null, "", errors.Wrap(err, "query.GetBool() failed")
+		}
+		delete(query.Mapa, QueryIncludeDeletedKey)
+	}
+
+	softDelete := false
+	if registryItem.Exists(RegistryItemSoftDeleteKey) {
+		softDelete, err = registryItem.GetBool(RegistryItemSoftDeleteKey)
+		if err != nil {
+			return null, "", errors.Wrap(err, "registryItem.GetBool() failed")
+		}
+	}
+
+	// soft deleted assets are hidden, unless client asks for them or selects by deleted mark itself
+	if softDelete && !includeDeleted {
+		deletedJPtr := "/" + QuerySelectorKey + "/" + AssetDeletedKey
+
+		selectsDeleted, err := query.ExistsJPtr(deletedJPtr)
+		if err != nil {
+			return null, "", errors.Wrap(err, "query.ExistsJPtr() failed")
+		}
+
+		if !selectsDeleted {
+			if err := query.SetJPtr(deletedJPtr, map[string]interface{}{"$exists": false}); err != nil {
+				return null, "", errors.Wrap(err, "query.SetJPtr() failed")
+			}
+		}
+	}
+
 	var (
 		metadata *pb.QueryResponseMetadata
 		iter     shim.StateQueryIteratorInterface
@@ -1026,40 +1398,123 @@ func (r *Registry) DeleteAsset(asset Rmap) error {
 		return errors.Wrap(err, "r.getAssetCompositeKey() failed")
 	}
 
+	checkSuperuser, err := r.guardSuperuser(docType, asset, NewEmpty())
+	if err != nil {
+		return err
+	}
+
 	if destination == StateDestinationValue {
 		if err := r.ctx.Stub().DelState(key); err != nil {
 			return errors.Wrap(err, "r.ctx.Stub().DelState() failed")
 		}
+
+		if err := r.deleteReferenceIndex(docType, id, key, asset); err != nil {
+			return errors.Wrap(err, "r.deleteReferenceIndex() failed")
+		}
 	} else {
 		if err := r.ctx.Stub().DelPrivateData(docType, key); err != nil {
 			return errors.Wrap(err, "r.ctx.Stub().DelPrivateData() failed")
 		}
 	}
 
+	if isChangeRecorded(r.ctx, docType) {
+		r.recordChange(key, EventDeleteOperation, destination, asset.Copy(), Rmap{})
+	}
+
 	delete(r.changeSet, key)
+	delete(r.revised, key)
+	r.aCache.Remove(key)
+
+	invalidateAuthorization(r.ctx, strings.ToLower(docType))
+
+	if checkSuperuser {
+		if err := r.checkSuperuserRemains(); err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
 
-// GetAssetHistory returns history for some asset instance
-func (r Registry) GetAssetHistory(asset Rmap) ([]Rmap, error) {
-	thisIdentity, err := r.GetThisIdentityResolved()
+// isSoftDeleteEnabled returns true, if registryItem of asset has soft delete enabled
+func (r *Registry) isSoftDeleteEnabled(asset Rmap) (bool, error) {
+	docType, err := AssetGetDocType(asset)
+	if err != nil {
+		return false, errors.Wrap(err, "asset.GetDocType() failed")
+	}
+
+	version, err := AssetGetVersion(asset)
+	if err != nil {
+		return false, errors.Wrap(err, "asset.GetVersion() failed")
+	}
+
+	item, _, err := r.GetItem(docType, version)
 	if err != nil {
-		return nil, errors.Wrap(err, "r.GetThisIdentityResolved() failed")
+		return false, errors.Wrap(err, "r.GetItem() failed")
+	}
+
+	if !item.Exists(RegistryItemSoftDeleteKey) {
+		return false, nil
 	}
 
-	kmpg, err := kompiguard.New()
+	return item.GetBool(RegistryItemSoftDeleteKey)
+}
+
+// SoftDeleteAsset marks existing asset instance as deleted. Asset is kept in persistent storage, but it is hidden from GetAsset and queries
+func (r *Registry) SoftDeleteAsset(asset Rmap) error {
+	fingerprint, err := GetMyFingerprint(r.ctx)
 	if err != nil {
-		return nil, errors.Wrap(err, "kompiguard.New() failed")
+		return errors.Wrap(err, "GetMyFingerprint() failed")
 	}
 
-	granted, reason, err := kmpg.EnforceAsset(asset, thisIdentity, "get_history")
+	now, err := r.ctx.Time()
 	if err != nil {
-		return nil, errors.Wrap(err, "kompiguard.New().EnforceAsset() failed")
+		return errors.Wrap(err, "r.ctx.Time() failed")
+	}
+
+	asset.Mapa[AssetDeletedKey] = true
+	asset.Mapa[AssetDeletedByKey] = fingerprint
+	asset.Mapa[AssetDeletedAtKey] = now.UTC().Format(time.RFC3339)
+
+	// references are not checked, deleted asset is not required to be valid
+	if err := r.putAsset(asset, false, true); err != nil {
+		return errors.Wrap(err, "r.putAsset() failed")
+	}
+
+	return nil
+}
+
+// RestoreAsset removes deleted mark from soft deleted asset instance
+func (r *Registry) RestoreAsset(asset Rmap) error {
+	if !AssetIsDeleted(asset) {
+		return ErrorBadRequest("asset is not deleted")
+	}
+
+	delete(asset.Mapa, AssetDeletedKey)
+	delete(asset.Mapa, AssetDeletedByKey)
+	delete(asset.Mapa, AssetDeletedAtKey)
+
+	if err := r.putAsset(asset, false, false); err != nil {
+		return errors.Wrap(err, "r.putAsset() failed")
+	}
+
+	return nil
+}
+
+// GetAssetHistory returns history for some asset instance
+func (r Registry) GetAssetHistory(asset Rmap) ([]Rmap, error) {
+	auth, err := r.ctx.GetAuthorization()
+	if err != nil {
+		return nil, errors.Wrap(err, "r.ctx.GetAuthorization() failed")
+	}
+
+	granted, reason, err := auth.EnforceAsset(asset, "get_history")
+	if err != nil {
+		return nil, errors.Wrap(err, "auth.EnforceAsset() failed")
 	}
 
 	if !granted {
-		return nil, ErrorForbidden(reason)
+		return nil, ErrorAssetPermissionDenied(reason, asset, "get_history")
 	}
 
 	docType, err := AssetGetDocType(asset)
@@ -1097,6 +1552,13 @@ func (r Registry) GetAssetHistory(asset Rmap) ([]Rmap, error) {
 			return nil, errors.Wrap(err, "NewFromBytes() failed")
 		}
 
+		if !value.IsEmpty() {
+			value, err = r.maskRestrictedFields(value)
+			if err != nil {
+				return nil, errors.Wrap(err, "r.maskRestrictedFields() failed")
+			}
+		}
+
 		hItem := NewFromMap(map[string]interface{}{
 			HistoryItemIsDeleteKey:  next.GetIsDelete(),
 			HistoryItemTimestampKey: next.GetTimestamp(),
@@ -1116,6 +1578,25 @@ func (r *Registry) upsertSingleton(singletonItemToUpsert Rmap, singletonName str
 		return -1, errors.Wrap(err, "singletonItemToUpsert.ValidateSchemaBytes() failed")
 	}
 
+	if strings.ToLower(singletonName) == CasbinModelSingletonName {
+		// invalid casbin model would deny every access check including upsert of fixed model
+		modelText, err := getSingletonCasbinModel(singletonItemToUpsert)
+		if err != nil {
+			return -1, ErrorBadRequest(err.Error())
+		}
+
+		if err := kompiguard.ValidateModel(modelText); err != nil {
+			return -1, ErrorBadRequest(fmt.Sprintf("invalid casbin model: %s", err))
+		}
+	}
+
+	if strings.ToLower(singletonName) == ApprovalPolicySingletonName {
+		// invalid approval policy would refuse every function call
+		if _, err := getApprovalPolicies(singletonItemToUpsert); err != nil {
+			return -1, ErrorBadRequest(fmt.Sprintf("invalid approval policy: %s", err))
+		}
+	}
+
 	// get iterator of existing singletons
 	iterator, err := r.ctx.Stub().GetStateByPartialCompositeKey(SingletonItemPrefix, []string{strings.ToUpper(singletonName)})
 	if err != nil {
@@ -1174,6 +1655,18 @@ func (r *Registry) upsertSingleton(singletonItemToUpsert Rmap, singletonName str
 		isLatestCreate = false
 	}
 
+	if strings.ToLower(singletonName) == CasbinModelSingletonName && !isInitFunction(r.ctx) {
+		// matcher of model decides every access check, so grant to upsert singletons must not be enough to change it
+		if err := r.requireSuperuser("casbin model"); err != nil {
+			return -1, err
+		}
+	}
+
+	// approval is required only for new version, upsert of unchanged singleton is always allowed
+	if err := checkApproval(r.ctx, newApprovalOperation(ApprovalSingletonUpsert, singletonName)); err != nil {
+		return -1, err
+	}
+
 	// create composite key for new singleton: PREFIX | NAME | VERSION
 	key, err := r.ctx.Stub().CreateCompositeKey(SingletonItemPrefix, []string{strings.ToUpper(singletonName), strconv.Itoa(targetVersion)})
 	if err != nil {
@@ -1200,6 +1693,8 @@ func (r *Registry) upsertSingleton(singletonItemToUpsert Rmap, singletonName str
 		return -1, errors.Wrap(err, "putRmapToState() failed")
 	}
 
+	r.configChanges = append(r.configChanges, makeConfigAuditChange(AuditSingletonKey, singletonName, targetVersion, isLatestCreate))
+
 	return targetVersion, nil
 }
 
@@ -1294,6 +1789,7 @@ func (r *Registry) ExistsSingleton(name string, version int) (bool, error) {
 	return true, nil
 }
 
+// ExistsAsset returns true, if asset instance exists. Soft deleted asset also exists, so references to it stay valid
 func (r *Registry) ExistsAsset(name, id string) (bool, error) {
 	key, err := r.getAssetCompositeKey(name, id)
 	if err != nil {
@@ -36,15 +36,17 @@ func (r resolver) WalkReferences(ctx ContextInterface, asset Rmap, resolve bool)
 	}
 
 	var errs []string
+	var pointers []string
 
-	err = r.walkReferences(ctx, assetName, nil, asset, asset.Mapa, assetSchema, resolve, &errs)
+	err = r.walkReferences(ctx, assetName, nil, asset, asset.Mapa, assetSchema, resolve, &errs, &pointers)
 	sort.Strings(errs)
+	sort.Strings(pointers)
 	if err != nil && len(errs) > 0 {
 		return errors.Wrapf(err, "r.walkReferences() failed, refs failed: %s", strings.Join(errs, ","))
 	} else if err != nil && len(errs) == 0 {
 		return errors.Wrap(err, "r.walkReferences() failed")
 	} else if len(errs) > 0 {
-		return fmt.Errorf("r.walkReferences() failed, refs failed: %s", strings.Join(errs, ","))
+		return ErrorRefNotFound(fmt.Sprintf("r.walkReferences() failed, refs failed: %s", strings.Join(errs, ",")), pointers)
 	}
 
 	return nil
@@ -60,7 +62,8 @@ func (r resolver) WalkReferences(ctx ContextInterface, asset Rmap, resolve bool)
 // schema - schema structure
 // resolve - mode of operation, if false, then refs are only validated, if true, refs are replaced with resolved variants
 // errs - list of errors that is populated with all non-valid refs
-func (r resolver) walkReferences(ctx ContextInterface, thisAssetName string, pathJPtrSlice []string, root Rmap, dataPtr interface{}, schema Rmap, resolve bool, errs *[]string) error {
+// pointers - list of JSON pointers that is populated with all non-valid refs
+func (r resolver) walkReferences(ctx ContextInterface, thisAssetName string, pathJPtrSlice []string, root Rmap, dataPtr interface{}, schema Rmap, resolve bool, errs *[]string, pointers *[]string) error {
 	registry := ctx.Get(konst.RegistryKey).(*Registry)
 	eng := ctx.GetConfiguration()
 
@@ -89,14 +92,14 @@ func (r resolver) walkReferences(ctx ContextInterface, thisAssetName string, pat
 	case map[string]interface{}:
 		// nested object, engage recursion
 		for k, vI := range el {
-			if err := r.walkReferences(ctx, thisAssetName, append(pathJPtrSlice, k), root, vI, schema, resolve, errs); err != nil {
+			if err := r.walkReferences(ctx, thisAssetName, append(pathJPtrSlice, k), root, vI, schema, resolve, errs, pointers); err != nil {
 				return err
 			}
 		}
 	case []interface{}:
 		// nested array, engage recursion
 		for i, iface := range el {
-			if err := r.walkReferences(ctx, thisAssetName, append(pathJPtrSlice, strconv.Itoa(i)), root, iface, schema, resolve, errs); err != nil {
+			if err := r.walkReferences(ctx, thisAssetName, append(pathJPtrSlice, strconv.Itoa(i)), root, iface, schema, resolve, errs, pointers); err != nil {
 				return err
 			}
 		}
@@ -157,16 +160,22 @@ func (r resolver) walkReferences(ctx ContextInterface, thisAssetName string, pat
 			}
 
 			*errs = append(*errs, fmt.Sprintf("Referenced asset '%s' with ID '%s' not found (currently resolved asset name: %s, uuid: %s)", targetName, targetUUID, thisAssetName, thisId))
+			*pointers = append(*pointers, pathJPtr)
 			return nil // stop recursion here because this is dead end
 		}
 
 		if resolve {
 			// resolve true requires actual asset, fetch it
-			target, err := registry.GetAsset(targetName, targetUUID, false, true)
+			target, err := registry.GetAsset(targetName, targetUUID, false, false)
 			if err != nil {
 				return errors.Wrap(err, "registry.GetAsset() failed")
 			}
 
+			if target.IsEmpty() {
+				// reference to soft deleted asset is valid, but it is not resolved
+				return nil
+			}
+
 			// check if assetName.fieldName is allowed in recursive resolve whitelist
 			fieldName := ""
 			if len(pathJPtrSlice) > 0 {
@@ -225,6 +234,12 @@ func (r resolver) walkReferences(ctx ContextInterface, thisAssetName string, pat
 				return errors.Wrap(err, `ctx.GetConfiguration().BusinessExecutor.Execute(AfterResolve) failed`)
 			}
 
+			// resolved asset is part of output, so its restricted fields are masked the same as when it is read
+			target, err = registry.maskRestrictedFields(target)
+			if err != nil {
+				return errors.Wrap(err, "registry.maskRestrictedFields() failed")
+			}
+
 			// replace the key with its resolved value
 			if err := root.SetJPtr(pathJPtr, target); err != nil {
 				return errors.Wrap(err, "root.SetJPtr() failed")
@@ -234,6 +249,109 @@ func (r resolver) walkReferences(ctx ContextInterface, thisAssetName string, pat
 	return nil
 }
 
+// reference is single reference from asset instance to another asset instance
+type reference struct {
+	pointer    string // JSON pointer of reference in referencing asset
+	targetName string // lowercase name of referenced asset
+	targetID   string // ID of referenced asset
+}
+
+// FindReferences returns all references contained in asset, references are not validated
+// references to blacklisted asset names are skipped, because their targets are not managed by this chaincode
+func (r resolver) FindReferences(ctx ContextInterface, asset Rmap) ([]reference, error) {
+	assetName, err := konst.AssetGetDocType(asset)
+	if err != nil {
+		return nil, errors.Wrap(err, "konst.AssetGetDocType() failed")
+	}
+
+	assetVersion, err := konst.AssetGetVersion(asset)
+	if err != nil {
+		return nil, errors.Wrap(err, "konst.AssetGetVersion() failed")
+	}
+
+	assetRegItem, _, err := ctx.Get(konst.RegistryKey).(*Registry).GetItem(assetName, assetVersion)
+	if err != nil {
+		return nil, errors.Wrap(err, "reg.GetItem(assetName, assetVersion) failed")
+	}
+
+	assetSchema, err := assetRegItem.GetRmap(konst.RegistryItemSchemaKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "regItem.GetRmap(schema) failed")
+	}
+
+	refs := []reference{}
+	if err := r.findReferences(ctx, nil, asset.Mapa, assetSchema, &refs); err != nil {
+		return nil, errors.Wrap(err, "r.findReferences() failed")
+	}
+
+	return refs, nil
+}
+
+// findReferences recursively visits all attributes on asset and collects the ones, that are references according to schema
+func (r resolver) findReferences(ctx ContextInterface, pathJPtrSlice []string, dataPtr interface{}, schema Rmap, refs *[]reference) error {
+	switch el := dataPtr.(type) {
+	case map[string]interface{}:
+		for k, vI := range el {
+			if err := r.findReferences(ctx, append(pathJPtrSlice, k), vI, schema, refs); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for i, iface := range el {
+			if err := r.findReferences(ctx, append(pathJPtrSlice, strconv.Itoa(i)), iface, schema, refs); err != nil {
+				return err
+			}
+		}
+	case string:
+		pathJPtr := konst.JPtrSeparator + strings.Join(pathJPtrSlice, konst.JPtrSeparator)
+
+		desc, err := r.getDescription(schema, pathJPtr)
+		if err != nil {
+			return err
+		}
+
+		isRef, targetName, targetUUID, err := r.analyzeRef(desc, el)
+		if err != nil {
+			return errors.Wrap(err, "r.analyzeRef() failed")
+		}
+
+		if !isRef || ctx.GetConfiguration().ResolveBlacklist.Exists(targetName) {
+			return nil
+		}
+
+		*refs = append(*refs, reference{
+			pointer:    pathJPtr,
+			targetName: targetName,
+			targetID:   targetUUID,
+		})
+	}
+	return nil
+}
+
+// getDescription returns description from schema for field on JSON pointer in asset, or empty string if there is none
+func (r resolver) getDescription(schema Rmap, pathJPtr string) (string, error) {
+	descJPtr, err := r.getDescriptionJPtr(pathJPtr)
+	if err != nil {
+		return "", err
+	}
+
+	descExists, err := schema.ExistsJPtr(descJPtr)
+	if err != nil {
+		return "", err
+	}
+
+	if !descExists {
+		return "", nil
+	}
+
+	desc, err := schema.GetJPtrString(descJPtr)
+	if err != nil {
+		return "", errors.Wrap(err, "sch.GetDescription() failed")
+	}
+
+	return desc, nil
+}
+
 func (r resolver) ParseEntityField(entity string) (entityName, entityUUID string, err error) {
 	fields := strings.Split(entity, ":")
 	if len(fields) != 2 {
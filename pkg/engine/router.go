@@ -44,7 +44,9 @@ func route(ctx ContextInterface) (string, error) {
 	}
 
 	if matchPrefixI("asset") {
-		if matchPrefix("Create") {
+		if matchPrefix("Batch") && isEmpty() {
+			ret, err = assetBatchFrontend(ctx)
+		} else if matchPrefix("Create") {
 			if isDirect() && isEmpty() {
 				ret, err = assetCreateDirectFrontend(ctx)
 			} else if isEmpty() {
@@ -70,8 +72,18 @@ func route(ctx ContextInterface) (string, error) {
 			}
 		} else if matchPrefix("History") && isEmpty() {
 			ret, err = assetHistoryFrontend(ctx)
-		} else if matchPrefix("Migrate") && isEmpty() {
-			ret, err = assetMigrateFrontend(ctx)
+		} else if matchPrefix("Migrate") {
+			if matchPrefix("All") && isEmpty() {
+				ret, err = assetMigrateAllFrontend(ctx)
+			} else if isEmpty() {
+				ret, err = assetMigrateFrontend(ctx)
+			} else {
+				err = uerr
+			}
+		} else if matchPrefix("Referrers") && isEmpty() {
+			ret, err = assetReferrersFrontend(ctx)
+		} else if matchPrefix("Restore") && isEmpty() {
+			ret, err = assetRestoreFrontend(ctx)
 		} else if matchPrefix("Update") {
 			if isDirect() && isEmpty() {
 				ret, err = assetUpdateDirectFrontend(ctx)
@@ -91,6 +103,12 @@ func route(ctx ContextInterface) (string, error) {
 		} else {
 			err = uerr
 		}
+	} else if matchPrefixI("audit") {
+		if matchPrefix("Query") && isEmpty() {
+			ret, err = auditQueryFrontend(ctx)
+		} else {
+			err = uerr
+		}
 	} else if matchPrefixI("changelog") {
 		if matchPrefix("Get") && isEmpty() {
 			ret, err = changelogGetFrontend(ctx)
@@ -131,6 +149,16 @@ func route(ctx ContextInterface) (string, error) {
 		}
 	} else if matchPrefixI("init") && isEmpty() {
 		err = initChaincode(ctx)
+	} else if matchPrefixI("proposal") {
+		if matchPrefix("Create") && isEmpty() {
+			ret, err = proposalCreateFrontend(ctx)
+		} else if matchPrefix("Approve") && isEmpty() {
+			ret, err = proposalApproveFrontend(ctx)
+		} else if matchPrefix("Reject") && isEmpty() {
+			ret, err = proposalRejectFrontend(ctx)
+		} else {
+			err = uerr
+		}
 	} else if matchPrefixI("registry") {
 		if matchPrefix("Get") && isEmpty() {
 			ret, err = registryGetFrontend(ctx)
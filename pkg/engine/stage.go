@@ -25,4 +25,9 @@ const (
 	// special stage which has asset present in prePatch param, and the patch present in postPatch param. Result of this execution is not saved. Should be used for patch validation only.
 	PatchCreate
 	PatchUpdate
+
+	AfterRestore // execute after soft deleted asset was restored and saved to state
+
+	BeforeMigrate // executed after asset was migrated to target version and client patch was applied, before saving to state. Original asset is present in prePatch param.
+	AfterMigrate  // executed after BeforeMigrate, after saving asset to state
 )
@@ -0,0 +1,642 @@
+package engine
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/KompiTech/fabric-cc-core/v2/pkg/kompiguard"
+	. "github.com/KompiTech/fabric-cc-core/v2/pkg/konst"
+	"github.com/KompiTech/rmap"
+	"github.com/pkg/errors"
+)
+
+// getSuperuserRoles returns UUIDs of roles, whose holders are granted every action, nil is returned when superuser shortcut is disabled
+func getSuperuserRoles(ctx ContextInterface) []string {
+	conf := ctx.GetConfiguration()
+
+	if conf.DisableSuperuser {
+		return nil
+	}
+
+	if conf.SuperuserRoles == nil {
+		return []string{SuperuserRoleUUID}
+	}
+
+	return conf.SuperuserRoles
+}
+
+// isSuperuserRole checks if holders of role are granted every action
+func isSuperuserRole(ctx ContextInterface, roleUUID string) bool {
+	for _, suRole := range getSuperuserRoles(ctx) {
+		if suRole == roleUUID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// getRoleSet returns set of role UUIDs
+func getRoleSet(roles []string) map[string]bool {
+	set := make(map[string]bool, len(roles))
+	for _, role := range roles {
+		set[role] = true
+	}
+
+	return set
+}
+
+// getRoleHoldersQuery returns query selecting identities or groups, that have any of roles assigned directly
+func getRoleHoldersQuery(roles []string) map[string]interface{} {
+	return map[string]interface{}{
+		QuerySelectorKey: map[string]interface{}{
+			RolesKey: map[string]interface{}{
+				"$elemMatch": map[string]interface{}{
+					"$in": roles,
+				},
+			},
+		},
+	}
+}
+
+// isSuperuserGuarded returns true for assets, whose changes can grant or revoke superuser role
+func isSuperuserGuarded(name string) bool {
+	name = strings.ToLower(name)
+	return name == IdentityAssetName || name == RoleAssetName || name == GroupAssetName
+}
+
+// holdsAnyRole checks if enabled identity holds any of roles at time now, directly, through parent roles or through groups
+// identity is not resolved, its roles and groups are loaded in the state of current TX
+func (r *Registry) holdsAnyRole(identity rmap.Rmap, roles map[string]bool, now time.Time) (bool, error) {
+	if identity.IsEmpty() || AssetIsDeleted(identity) || len(roles) == 0 {
+		return false, nil
+	}
+
+	if identity.Exists(IsEnabledKey) {
+		isEnabled, err := identity.GetBool(IsEnabledKey)
+		if err != nil {
+			return false, errors.Wrap(err, "identity.GetBool() failed")
+		}
+
+		if !isEnabled {
+			return false, nil
+		}
+	}
+
+	resolved := identity.Copy()
+	if err := r.resolveIdentityRoles(resolved); err != nil {
+		return false, errors.Wrap(err, "r.resolveIdentityRoles() failed")
+	}
+
+	effective, err := kompiguard.EffectiveRoles(resolved, now)
+	if err != nil {
+		return false, errors.Wrap(err, "kompiguard.EffectiveRoles() failed")
+	}
+
+	for _, role := range effective {
+		roleUUID, err := AssetGetID(role)
+		if err != nil {
+			return false, errors.Wrap(err, "AssetGetID(role) failed")
+		}
+
+		if roles[roleUUID] {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// hasRoleHolder checks if at least one enabled identity holds any of roles at time now in the state of current TX
+// holders are searched among identities and groups with assigned role, that is one of roles or inherits from it
+func (r *Registry) hasRoleHolder(roles map[string]bool, now time.Time) (bool, error) {
+	if len(roles) == 0 {
+		return false, nil
+	}
+
+	leading := make([]string, 0, len(roles))
+	for role := range roles {
+		leading = append(leading, role)
+	}
+
+	allRoles, _, err := r.QueryAssets(RoleAssetName, rmap.NewEmpty(), "", false, false, 0)
+	if err != nil {
+		return false, errors.Wrap(err, "r.QueryAssets() failed")
+	}
+
+	for _, role := range allRoles {
+		roleUUID, err := AssetGetID(role)
+		if err != nil {
+			return false, errors.Wrap(err, "AssetGetID(role) failed")
+		}
+
+		if roles[roleUUID] {
+			continue
+		}
+
+		leads, err := r.rolesLeadToAny([]interface{}{roleUUID}, roles, map[string]bool{})
+		if err != nil {
+			return false, errors.Wrap(err, "r.rolesLeadToAny() failed")
+		}
+
+		if leads {
+			leading = append(leading, roleUUID)
+		}
+	}
+
+	query := rmap.NewFromMap(getRoleHoldersQuery(leading))
+
+	candidates := []string{}
+
+	identities, _, err := r.QueryAssets(IdentityAssetName, query.Copy(), "", false, false, 0)
+	if err != nil {
+		return false, errors.Wrap(err, "r.QueryAssets() failed")
+	}
+
+	for _, identity := range identities {
+		fingerprint, err := AssetGetID(identity)
+		if err != nil {
+			return false, errors.Wrap(err, "AssetGetID(identity) failed")
+		}
+
+		candidates = append(candidates, fingerprint)
+	}
+
+	groups, _, err := r.QueryAssets(GroupAssetName, query.Copy(), "", false, false, 0)
+	if err != nil {
+		return false, errors.Wrap(err, "r.QueryAssets() failed")
+	}
+
+	for _, group := range groups {
+		groupUUID, err := AssetGetID(group)
+		if err != nil {
+			return false, errors.Wrap(err, "AssetGetID(group) failed")
+		}
+
+		// members are taken from the state of current TX
+		group, err = r.GetAsset(GroupAssetName, groupUUID, false, false)
+		if err != nil {
+			return false, errors.Wrap(err, "r.GetAsset() failed")
+		}
+
+		if !group.Exists(GroupMembersKey) {
+			continue
+		}
+
+		members, err := group.GetIterable(GroupMembersKey)
+		if err != nil {
+			return false, errors.Wrap(err, "group.GetIterable() failed")
+		}
+
+		for _, memberI := range members {
+			if member, ok := memberI.(string); ok {
+				candidates = append(candidates, member)
+			}
+		}
+	}
+
+	checked := map[string]bool{}
+	for _, fingerprint := range candidates {
+		if checked[fingerprint] {
+			continue
+		}
+		checked[fingerprint] = true
+
+		// identity is checked in the state of current TX, query returns state before it
+		identity, err := r.GetAsset(IdentityAssetName, fingerprint, false, false)
+		if err != nil {
+			return false, errors.Wrap(err, "r.GetAsset() failed")
+		}
+
+		holds, err := r.holdsAnyRole(identity, roles, now)
+		if err != nil {
+			return false, errors.Wrap(err, "r.holdsAnyRole() failed")
+		}
+
+		if holds {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// rolesLeadToAny checks if any of role UUIDs is one of roles or inherits from it through parent roles
+// visited contains UUIDs already walked, so cycle does not cause infinite recursion
+func (r *Registry) rolesLeadToAny(roleUUIDs []interface{}, roles map[string]bool, visited map[string]bool) (bool, error) {
+	for _, roleUUIDI := range roleUUIDs {
+		roleUUID, ok := roleUUIDI.(string)
+		if !ok || visited[roleUUID] {
+			continue
+		}
+		visited[roleUUID] = true
+
+		if roles[roleUUID] {
+			return true, nil
+		}
+
+		role, err := r.GetAsset(RoleAssetName, roleUUID, false, false)
+		if err != nil {
+			return false, errors.Wrap(err, "r.GetAsset() failed")
+		}
+
+		if !role.Exists(ParentsKey) {
+			continue
+		}
+
+		parents, err := role.GetIterable(ParentsKey)
+		if err != nil {
+			return false, errors.Wrap(err, "role.GetIterable() failed")
+		}
+
+		leads, err := r.rolesLeadToAny(parents, roles, visited)
+		if err != nil {
+			return false, err
+		}
+
+		if leads {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// getSuperuserValidity maps assigned roles of identity, that are superuser roles or inherit from them, to their validity
+// validity is part of assignment, so its change grants or revokes superuser role, even if it is not visible at time of TX
+func (r *Registry) getSuperuserValidity(identity rmap.Rmap, roles map[string]bool) (map[string]interface{}, error) {
+	validities := map[string]interface{}{}
+
+	if identity.IsEmpty() || AssetIsDeleted(identity) || !identity.Exists(RolesKey) {
+		return validities, nil
+	}
+
+	assigned, err := identity.GetIterable(RolesKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "identity.GetIterable() failed")
+	}
+
+	for _, roleUUIDI := range assigned {
+		roleUUID, ok := roleUUIDI.(string)
+		if !ok {
+			continue
+		}
+
+		leads, err := r.rolesLeadToAny([]interface{}{roleUUID}, roles, map[string]bool{})
+		if err != nil {
+			return nil, errors.Wrap(err, "r.rolesLeadToAny() failed")
+		}
+
+		if !leads {
+			continue
+		}
+
+		validity, err := kompiguard.GetRoleValidity(identity, roleUUID)
+		if err != nil {
+			return nil, errors.Wrap(err, "kompiguard.GetRoleValidity() failed")
+		}
+
+		validities[roleUUID] = validity.Mapa
+	}
+
+	return validities, nil
+}
+
+// roleBearsSuperuser checks if role is superuser role or inherits from it through parent roles, deleted role does not bear anything
+func (r *Registry) roleBearsSuperuser(role rmap.Rmap, roles map[string]bool) (bool, error) {
+	if role.IsEmpty() || AssetIsDeleted(role) {
+		return false, nil
+	}
+
+	roleUUID, err := AssetGetID(role)
+	if err != nil {
+		return false, errors.Wrap(err, "AssetGetID(role) failed")
+	}
+
+	if roles[roleUUID] || !role.Exists(ParentsKey) {
+		return roles[roleUUID], nil
+	}
+
+	parents, err := role.GetIterable(ParentsKey)
+	if err != nil {
+		return false, errors.Wrap(err, "role.GetIterable() failed")
+	}
+
+	// parents are walked in the state of current TX, so whole chain of ancestors is checked
+	return r.rolesLeadToAny(parents, roles, map[string]bool{roleUUID: true})
+}
+
+// groupBearsSuperuser checks if any role of group is superuser role or inherits from it, deleted group does not bear anything
+func (r *Registry) groupBearsSuperuser(group rmap.Rmap, roles map[string]bool) (bool, error) {
+	if group.IsEmpty() || AssetIsDeleted(group) || !group.Exists(GroupRolesKey) {
+		return false, nil
+	}
+
+	groupRoles, err := group.GetIterable(GroupRolesKey)
+	if err != nil {
+		return false, errors.Wrap(err, "group.GetIterable() failed")
+	}
+
+	return r.rolesLeadToAny(groupRoles, roles, map[string]bool{})
+}
+
+// getGroupMembers returns set of fingerprints of group members, deleted group has no members
+func getGroupMembers(group rmap.Rmap) (map[string]bool, error) {
+	members := map[string]bool{}
+
+	if group.IsEmpty() || AssetIsDeleted(group) || !group.Exists(GroupMembersKey) {
+		return members, nil
+	}
+
+	membersI, err := group.GetIterable(GroupMembersKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "group.GetIterable() failed")
+	}
+
+	for _, memberI := range membersI {
+		if member, ok := memberI.(string); ok {
+			members[member] = true
+		}
+	}
+
+	return members, nil
+}
+
+// superuserChange describes how write of identity, role or group changes holders of superuser role
+type superuserChange struct {
+	managed  bool // change grants or revokes superuser role, only superuser can do it
+	revoking bool // some identity can lose superuser role, at least one superuser must remain after it
+}
+
+// getSuperuserChange compares asset before and after write, empty pre means create, empty post means delete
+func (r *Registry) getSuperuserChange(name string, pre, post rmap.Rmap, roles map[string]bool, now time.Time) (superuserChange, error) {
+	switch strings.ToLower(name) {
+	case IdentityAssetName:
+		wasSU, err := r.holdsAnyRole(pre, roles, now)
+		if err != nil {
+			return superuserChange{}, errors.Wrap(err, "r.holdsAnyRole() failed")
+		}
+
+		isSU, err := r.holdsAnyRole(post, roles, now)
+		if err != nil {
+			return superuserChange{}, errors.Wrap(err, "r.holdsAnyRole() failed")
+		}
+
+		preValidity, err := r.getSuperuserValidity(pre, roles)
+		if err != nil {
+			return superuserChange{}, errors.Wrap(err, "r.getSuperuserValidity() failed")
+		}
+
+		postValidity, err := r.getSuperuserValidity(post, roles)
+		if err != nil {
+			return superuserChange{}, errors.Wrap(err, "r.getSuperuserValidity() failed")
+		}
+
+		// assignment or its validity changed, superuser role is granted or revoked now or in the future
+		managed := wasSU != isSU || !reflect.DeepEqual(preValidity, postValidity)
+
+		return superuserChange{managed: managed, revoking: wasSU && !isSU}, nil
+	case RoleAssetName:
+		preBears, err := r.roleBearsSuperuser(pre, roles)
+		if err != nil {
+			return superuserChange{}, errors.Wrap(err, "r.roleBearsSuperuser() failed")
+		}
+
+		postBears, err := r.roleBearsSuperuser(post, roles)
+		if err != nil {
+			return superuserChange{}, errors.Wrap(err, "r.roleBearsSuperuser() failed")
+		}
+
+		// holders of role and of roles inheriting from it gain or lose superuser role
+		return superuserChange{managed: preBears != postBears, revoking: preBears && !postBears}, nil
+	case GroupAssetName:
+		preBears, err := r.groupBearsSuperuser(pre, roles)
+		if err != nil {
+			return superuserChange{}, errors.Wrap(err, "r.groupBearsSuperuser() failed")
+		}
+
+		postBears, err := r.groupBearsSuperuser(post, roles)
+		if err != nil {
+			return superuserChange{}, errors.Wrap(err, "r.groupBearsSuperuser() failed")
+		}
+
+		if !preBears && !postBears {
+			return superuserChange{}, nil
+		}
+
+		preMembers, err := getGroupMembers(pre)
+		if err != nil {
+			return superuserChange{}, errors.Wrap(err, "getGroupMembers() failed")
+		}
+
+		postMembers, err := getGroupMembers(post)
+		if err != nil {
+			return superuserChange{}, errors.Wrap(err, "getGroupMembers() failed")
+		}
+
+		// members of group bearing superuser role gain or lose it, when roles or members of group change
+		removesMember := false
+		for member := range preMembers {
+			if !postMembers[member] {
+				removesMember = true
+			}
+		}
+
+		managed := preBears != postBears || !reflect.DeepEqual(preMembers, postMembers)
+
+		return superuserChange{managed: managed, revoking: preBears && (!postBears || removesMember)}, nil
+	}
+
+	return superuserChange{}, nil
+}
+
+// guardSuperuser checks, that write of identity, role or group granting or revoking superuser role is done by superuser
+// it is called before the write, returned true means that checkSuperuserRemains must be called after the write
+func (r *Registry) guardSuperuser(name string, pre, post rmap.Rmap) (bool, error) {
+	roles := getRoleSet(getSuperuserRoles(r.ctx))
+	if r.superuserBootstrap || !isSuperuserGuarded(name) || len(roles) == 0 {
+		return false, nil
+	}
+
+	now, err := r.ctx.Time()
+	if err != nil {
+		return false, errors.Wrap(err, "r.ctx.Time() failed")
+	}
+
+	change, err := r.getSuperuserChange(name, pre, post, roles, now)
+	if err != nil {
+		return false, errors.Wrap(err, "r.getSuperuserChange() failed")
+	}
+
+	if !change.managed {
+		return false, nil
+	}
+
+	thisIdentity, err := r.GetThisIdentity()
+	if err != nil {
+		return false, errors.Wrap(err, "r.GetThisIdentity() failed")
+	}
+
+	thisIsSU, err := r.holdsAnyRole(thisIdentity, roles, now)
+	if err != nil {
+		return false, errors.Wrap(err, "r.holdsAnyRole() failed")
+	}
+
+	if !thisIsSU {
+		return false, ErrorForbidden("to manage SuperUser role, you must have it granted")
+	}
+
+	return change.revoking, nil
+}
+
+// requireSuperuser refuses change, that can be done only by holder of superuser role, what is the change is described by subject
+// when superuser shortcut is disabled, holder of role created by bootstrap is required
+func (r *Registry) requireSuperuser(subject string) error {
+	roles := getSuperuserRoles(r.ctx)
+	if len(roles) == 0 {
+		roles = []string{SuperuserRoleUUID}
+	}
+
+	now, err := r.ctx.Time()
+	if err != nil {
+		return errors.Wrap(err, "r.ctx.Time() failed")
+	}
+
+	thisIdentity, err := r.GetThisIdentity()
+	if err != nil {
+		return errors.Wrap(err, "r.GetThisIdentity() failed")
+	}
+
+	thisIsSU, err := r.holdsAnyRole(thisIdentity, getRoleSet(roles), now)
+	if err != nil {
+		return errors.Wrap(err, "r.holdsAnyRole() failed")
+	}
+
+	if !thisIsSU {
+		return ErrorForbidden(fmt.Sprintf("to change %s, you must have SuperUser role granted", subject))
+	}
+
+	return nil
+}
+
+// checkSuperuserRemains refuses change, after which no identity holds valid superuser role
+func (r *Registry) checkSuperuserRemains() error {
+	now, err := r.ctx.Time()
+	if err != nil {
+		return errors.Wrap(err, "r.ctx.Time() failed")
+	}
+
+	remains, err := r.hasRoleHolder(getRoleSet(getSuperuserRoles(r.ctx)), now)
+	if err != nil {
+		return errors.Wrap(err, "r.hasRoleHolder() failed")
+	}
+
+	if !remains {
+		return ErrorBadRequest("unable to remove last superuser role")
+	}
+
+	return nil
+}
+
+// getBootstrapGrants returns grants of role created by bootstrap, when this role is not superuser role
+// they allow to manage registries, singletons, identities, roles and groups, so chaincode can be set up without superuser shortcut
+func getBootstrapGrants() []interface{} {
+	grants := []interface{}{}
+
+	for _, object := range []string{RegistryCasbinObject, SingletonCasbinObject} {
+		for _, action := range []string{ReadAction, UpsertAction} {
+			grants = append(grants, map[string]interface{}{
+				ObjectKey: "/" + object + "/*",
+				ActionKey: action,
+			})
+		}
+	}
+
+	for _, object := range []string{IdentityAssetName, RoleAssetName, GroupAssetName} {
+		for _, action := range []string{CreateAction, ReadAction, UpdateAction, DeleteAction} {
+			grants = append(grants, map[string]interface{}{
+				ObjectKey: "/" + object + "/*",
+				ActionKey: action,
+			})
+		}
+	}
+
+	return grants
+}
+
+// makeSuperuserAudit returns audit func, that collects uses of superuser shortcut by current identity in TX, they are stored by flushBreakGlass
+func makeSuperuserAudit(ctx ContextInterface) kompiguard.AuditFunc {
+	return func(subject, role, object, action string) error {
+		uses, _ := ctx.Get(BreakGlassKey).([]rmap.Rmap)
+
+		// the same action on the same object is recorded once per TX
+		for _, use := range uses {
+			if use.Mapa[ObjectKey] == object && use.Mapa[ActionKey] == action {
+				return nil
+			}
+		}
+
+		uses = append(uses, rmap.NewFromMap(map[string]interface{}{
+			BreakGlassRoleKey: role,
+			ObjectKey:         object,
+			ActionKey:         action,
+		}))
+		ctx.Set(BreakGlassKey, uses)
+
+		return nil
+	}
+}
+
+// flushBreakGlass stores audit record with all uses of superuser shortcut in TX and emits it as custom event
+// it is called when TX ends successfully, so actions of failed TX are not recorded
+// record is a write, so TX evaluated only as query (not committed) leaves no record, see METHODS.md
+func flushBreakGlass(ctx ContextInterface) error {
+	uses, _ := ctx.Get(BreakGlassKey).([]rmap.Rmap)
+	if len(uses) == 0 {
+		return nil
+	}
+
+	myFP, err := GetMyFingerprint(ctx)
+	if err != nil {
+		return errors.Wrap(err, "GetMyFingerprint() failed")
+	}
+
+	now, err := ctx.Time()
+	if err != nil {
+		return errors.Wrap(err, "ctx.Time() failed")
+	}
+
+	funcName, _ := ctx.Stub().GetFunctionAndParameters()
+	txID := ctx.Stub().GetTxID()
+
+	usesI := make([]interface{}, 0, len(uses))
+	for _, use := range uses {
+		usesI = append(usesI, use.Mapa)
+	}
+
+	record := rmap.NewFromMap(map[string]interface{}{
+		AssetFingerprintKey:    myFP,
+		BreakGlassFunctionKey:  funcName,
+		BreakGlassTimestampKey: now.Format(time.RFC3339),
+		BreakGlassTxIdKey:      txID,
+		BreakGlassUsesKey:      usesI,
+	})
+
+	key, err := ctx.Stub().CreateCompositeKey(BreakGlassPrefix, []string{txID})
+	if err != nil {
+		return errors.Wrap(err, "ctx.Stub().CreateCompositeKey() failed")
+	}
+
+	if err := ctx.Stub().PutState(key, record.Bytes()); err != nil {
+		return errors.Wrap(err, "ctx.Stub().PutState() failed")
+	}
+
+	if err := ctx.EmitEvent(BreakGlassEventName, record); err != nil {
+		return errors.Wrap(err, "ctx.EmitEvent() failed")
+	}
+
+	ctx.Set(BreakGlassKey, nil)
+	return nil
+}
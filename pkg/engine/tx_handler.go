@@ -5,6 +5,7 @@ import (
 	"strconv"
 	"strings"
 
+	. "github.com/KompiTech/fabric-cc-core/v2/pkg/konst"
 	"github.com/KompiTech/rmap"
 )
 
@@ -19,10 +20,12 @@ func makeInitializationFunc(config Configuration) func(ContextInterface) error {
 	return func(ctx ContextInterface) error {
 		reg, err := newRegistry(ctx)
 		if err != nil {
-			return err
+			return formatError(config, err, nil)
 		}
 
 		ctx.Set("registry", reg)
+		ctx.Set(AuthorizationKey, nil)
+		ctx.Set(BreakGlassKey, nil)
 		ctx.SetConfFunc(confFunc)
 
 		funcName, _ := ctx.GetStub().GetFunctionAndParameters()
@@ -39,7 +42,7 @@ func makeInitializationFunc(config Configuration) func(ContextInterface) error {
 		if !isSkip {
 			_, err := reg.GetThisIdentity()
 			if err != nil {
-				return err
+				return formatError(config, err, nil)
 			}
 		}
 
@@ -64,26 +67,62 @@ func iCompare(us1, s2 string) bool {
 func unknownTransactionHandler(ctx ContextInterface) (string, error) {
 	args := ctx.GetStub().GetArgs()
 	traceEnabled := false
+	dryRunEnabled := false
 
 	tracingInfo, err := rmap.NewFromBytes(args[len(args)-1])
 	if err == nil {
 		// tracing info is a JSON, continue
-		traceRequested, err := tracingInfo.GetBool("trace")
+		traceRequested, err := tracingInfo.GetBool(TracingTraceKey)
 		if err == nil {
 			// tracing key "trace" was found
 			if traceRequested {
 				// tracing key has boolean true value, enable
 				traceEnabled = true
-				delete(tracingInfo.Mapa, "trace")
+				delete(tracingInfo.Mapa, TracingTraceKey)
 			}
 		}
+
+		dryRunRequested, err := tracingInfo.GetBool(TracingDryRunKey)
+		if err == nil && dryRunRequested {
+			// dry run key "dryRun" was found with boolean true value, enable
+			dryRunEnabled = true
+			delete(tracingInfo.Mapa, TracingDryRunKey)
+		}
+	}
+
+	var dryRun *dryRunStub
+	if dryRunEnabled {
+		// all writes and event are only recorded and returned together with response
+		dryRun = newDryRunStub(ctx.GetStub())
+		ctx.SetStub(dryRun)
 	}
 
 	// call correct CC method and get response
 	ret, err := route(ctx)
-	if err != nil && traceEnabled {
-		// some CC error occured and tracing is enabled, append to error message
-		err = errors.New(addTracingMessage(err.Error(), tracingInfo.String()))
+	if err == nil {
+		// all changes, events and uses of superuser shortcut are known when TX ends successfully
+		err = flushBreakGlass(ctx)
+	}
+
+	if err == nil {
+		err = flushAudit(ctx)
+	}
+
+	if err == nil {
+		err = flushEvents(ctx)
+	}
+
+	if err == nil && dryRunEnabled {
+		ret, err = makeDryRunResponse(ctx, ret, dryRun)
+	}
+
+	if err != nil {
+		var tracing *rmap.Rmap
+		if traceEnabled {
+			tracing = &tracingInfo
+		}
+
+		err = formatError(ctx.GetConfiguration(), err, tracing)
 	}
 
 	// TODO logic of setting correct HTTP status must be probably done on rest server
@@ -92,6 +131,20 @@ func unknownTransactionHandler(ctx ContextInterface) (string, error) {
 	return ret, err
 }
 
+// formatError converts error to format expected by clients, tracing info is added to it, if it is not nil
+func formatError(config Configuration, err error, tracingInfo *rmap.Rmap) error {
+	if !config.LegacyErrorFormat {
+		return errors.New(makeErrorJSON(err, tracingInfo))
+	}
+
+	if tracingInfo != nil {
+		// some CC error occured and tracing is enabled, append to error message
+		return errors.New(addTracingMessage(err.Error(), tracingInfo.String()))
+	}
+
+	return err
+}
+
 func addTracingMessage(msg, tmsg string) string {
 	// message could contain our error code in format |||NNN. If that is the case, we want to move the code to the end of the error message
 	if len(msg) < 6 {
@@ -0,0 +1,102 @@
+package engine
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strings"
+
+	. "github.com/KompiTech/fabric-cc-core/v2/pkg/konst"
+	"github.com/KompiTech/rmap"
+	"github.com/pkg/errors"
+	"github.com/qri-io/jsonschema"
+)
+
+// validationKeywords infers JSONSchema keyword from message of validation error, validator does not report it in structured form
+// patterns match messages of qri-io/jsonschema v0.2.1 verbatim (including its typos), every one is pinned by TestValidateSchema_Keywords
+// order matters, more specific patterns must be before more generic ones
+var validationKeywords = []struct {
+	pattern *regexp.Regexp
+	keyword string
+}{
+	{regexp.MustCompile(`^type should be `), "type"},
+	{regexp.MustCompile(`^should be one of `), "enum"},
+	{regexp.MustCompile(`^must equal `), "const"},
+	{regexp.MustCompile(`" value is required$`), "required"},
+	{regexp.MustCompile(`" property is required$`), "dependentRequired"},
+	{regexp.MustCompile(`^additional properties are not allowed`), "additionalProperties"},
+	{regexp.MustCompile(`^unevaluated properties are not allowed`), "unevaluatedProperties"},
+	{regexp.MustCompile(`object Properties exceed`), "maxProperties"},
+	{regexp.MustCompile(`object Properties below`), "minProperties"},
+	{regexp.MustCompile(`^max length of `), "maxLength"},
+	{regexp.MustCompile(`^min length of `), "minLength"},
+	{regexp.MustCompile(`^regexp pattern `), "pattern"},
+	{regexp.MustCompile(`^invalid \S+: `), "format"},
+	{regexp.MustCompile(`^must be a multiple of `), "multipleOf"},
+	{regexp.MustCompile(`^must be less than or equal to `), "maximum"},
+	{regexp.MustCompile(`^must be greater than or equal to `), "minimum"},
+	{regexp.MustCompile(` must be less than `), "exclusiveMaximum"},
+	{regexp.MustCompile(` must be greater than `), "exclusiveMinimum"},
+	{regexp.MustCompile(`^array length \d+ exceeds`), "maxItems"},
+	{regexp.MustCompile(`^array length \d+ below`), "minItems"},
+	{regexp.MustCompile(`^array items must be unique`), "uniqueItems"},
+	{regexp.MustCompile(`^must contain at least one of`), "contains"},
+	{regexp.MustCompile(`^contained items \d+ exceeds`), "maxContains"},
+	{regexp.MustCompile(`^contained items \d+ bellow`), "minContains"},
+	{regexp.MustCompile(`^additional items are not allowed`), "additionalItems"},
+	{regexp.MustCompile(`^unevaluated items are not allowed`), "unevaluatedItems"},
+	{regexp.MustCompile(`(?i)anyOf schemas`), "anyOf"},
+	{regexp.MustCompile(`OneOf schemas`), "oneOf"},
+	{regexp.MustCompile(`\('not'\) expected invalid`), "not"},
+	{regexp.MustCompile(`^failed to resolve schema for ref `), "$ref"},
+	{regexp.MustCompile(`^schema is nil$`), "$ref"}, // reported together with unresolved $ref
+}
+
+// requiredPropertyRegexp extracts name of missing property from message of required keyword
+var requiredPropertyRegexp = regexp.MustCompile(`^"(.*)" value is required$`)
+
+// validateSchema validates data against JSONSchema and returns all validation errors sorted by JSON pointer
+// every validation error contains instance JSON pointer, schema keyword and message
+// error is returned only if validation itself cannot be done
+func validateSchema(data, schema rmap.Rmap) ([]rmap.Rmap, error) {
+	rSchema := &jsonschema.Schema{}
+	if err := json.Unmarshal(schema.Bytes(), rSchema); err != nil {
+		return nil, errors.Wrap(err, "json.Unmarshal() failed")
+	}
+
+	keyErrs, err := rSchema.ValidateBytes(context.Background(), data.Bytes())
+	if err != nil {
+		return nil, errors.Wrap(err, "rSchema.ValidateBytes() failed")
+	}
+
+	fields := make([]rmap.Rmap, 0, len(keyErrs))
+	for _, keyErr := range keyErrs {
+		pointer := keyErr.PropertyPath
+		keyword := ""
+
+		for _, candidate := range validationKeywords {
+			if candidate.pattern.MatchString(keyErr.Message) {
+				keyword = candidate.keyword
+				break
+			}
+		}
+
+		if match := requiredPropertyRegexp.FindStringSubmatch(keyErr.Message); match != nil {
+			// point to missing property instead of its parent
+			pointer = strings.TrimSuffix(pointer, JPtrSeparator) + JPtrSeparator + match[1]
+		}
+
+		fields = append(fields, rmap.NewFromMap(map[string]interface{}{
+			ValidationPointerKey: pointer,
+			ValidationKeywordKey: keyword,
+			ValidationMessageKey: keyErr.Message,
+		}))
+	}
+
+	sort.SliceStable(fields, func(i, j int) bool {
+		return fields[i].Mapa[ValidationPointerKey].(string) < fields[j].Mapa[ValidationPointerKey].(string)
+	})
+
+	return fields, nil
+}
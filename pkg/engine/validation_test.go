@@ -0,0 +1,86 @@
+package engine
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/KompiTech/fabric-cc-core/v2/pkg/konst"
+	"github.com/KompiTech/rmap"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestValidateSchema_Keywords pins messages of validator to keywords, every keyword in validationKeywords must have its case here
+// messages are specific to qri-io/jsonschema v0.2.1, when validator is upgraded and changes any message, this test fails instead of silently reporting wrong or empty keyword
+func TestValidateSchema_Keywords(t *testing.T) {
+	cases := []struct {
+		keyword string
+		schema  string
+		data    string
+		pointer string
+		message string // prefix of message, some messages contain details of validator internals
+	}{
+		{"type", `{"properties": {"a": {"type": "string"}}}`, `{"a": 1}`, "/a", "type should be string, got integer"},
+		{"enum", `{"properties": {"a": {"enum": ["x", "y"]}}}`, `{"a": "z"}`, "/a", "should be one of [\"x\", \"y\"]"},
+		{"const", `{"properties": {"a": {"const": "x"}}}`, `{"a": "z"}`, "/a", "must equal \"x\""},
+		{"required", `{"required": ["a"]}`, `{}`, "/a", "\"a\" value is required"},
+		{"dependentRequired", `{"dependentRequired": {"a": ["b"]}}`, `{"a": 1}`, "/", "\"b\" property is required"},
+		{"additionalProperties", `{"additionalProperties": false}`, `{"a": 1}`, "/", "additional properties are not allowed"},
+		{"unevaluatedProperties", `{"unevaluatedProperties": false}`, `{"a": 1}`, "/", "unevaluated properties are not allowed"},
+		{"maxProperties", `{"maxProperties": 1}`, `{"a": 1, "b": 2}`, "/", "2 object Properties exceed 1 maximum"},
+		{"minProperties", `{"minProperties": 2}`, `{"a": 1}`, "/", "1 object Properties below 2 minimum"},
+		{"maxLength", `{"properties": {"a": {"maxLength": 1}}}`, `{"a": "xy"}`, "/a", "max length of 1 characters exceeded: xy"},
+		{"minLength", `{"properties": {"a": {"minLength": 3}}}`, `{"a": "xy"}`, "/a", "min length of 3 characters required: xy"},
+		{"pattern", `{"properties": {"a": {"pattern": "^[0-9]+$"}}}`, `{"a": "xy"}`, "/a", "regexp pattern ^[0-9]+$ mismatch on string: xy"},
+		{"format", `{"properties": {"a": {"format": "date-time"}}}`, `{"a": "xy"}`, "/a", "invalid date-time: date-time incorrectly Formatted"},
+		{"multipleOf", `{"properties": {"a": {"multipleOf": 2}}}`, `{"a": 3}`, "/a", "must be a multiple of 2"},
+		{"maximum", `{"properties": {"a": {"maximum": 2}}}`, `{"a": 3}`, "/a", "must be less than or equal to 2"},
+		{"minimum", `{"properties": {"a": {"minimum": 4}}}`, `{"a": 3}`, "/a", "must be greater than or equal to 4"},
+		{"exclusiveMaximum", `{"properties": {"a": {"exclusiveMaximum": 3}}}`, `{"a": 3}`, "/a", "3 must be less than 3"},
+		{"exclusiveMinimum", `{"properties": {"a": {"exclusiveMinimum": 3}}}`, `{"a": 3}`, "/a", "3 must be greater than 3"},
+		{"maxItems", `{"properties": {"a": {"maxItems": 1}}}`, `{"a": [1, 2]}`, "/a", "array length 2 exceeds 1 max"},
+		{"minItems", `{"properties": {"a": {"minItems": 3}}}`, `{"a": [1, 2]}`, "/a", "array length 2 below 3 minimum items"},
+		{"uniqueItems", `{"properties": {"a": {"uniqueItems": true}}}`, `{"a": [1, 1]}`, "/a", "array items must be unique. duplicated entry: 1"},
+		{"contains", `{"properties": {"a": {"contains": {"const": 3}}}}`, `{"a": [1, 2]}`, "/a", "must contain at least one of: "},
+		{"maxContains", `{"properties": {"a": {"contains": {"const": 1}, "maxContains": 1}}}`, `{"a": [1, 1]}`, "/a", "contained items 2 exceeds 1 max"},
+		{"minContains", `{"properties": {"a": {"contains": {"const": 1}, "minContains": 3}}}`, `{"a": [1, 1]}`, "/a", "contained items 2 bellow 3 min"},
+		{"additionalItems", `{"properties": {"a": {"items": [{"type": "integer"}], "additionalItems": false}}}`, `{"a": [1, 2]}`, "/a", "additional items are not allowed"},
+		{"unevaluatedItems", `{"properties": {"a": {"items": [{"type": "integer"}], "unevaluatedItems": false}}}`, `{"a": [1, 2]}`, "/a", "unevaluated items are not allowed"},
+		{"anyOf", `{"properties": {"a": {"anyOf": [{"type": "string"}, {"type": "boolean"}]}}}`, `{"a": 1}`, "/a", "did Not match any specified AnyOf schemas"},
+		{"oneOf", `{"properties": {"a": {"oneOf": [{"type": "string"}, {"type": "boolean"}]}}}`, `{"a": 1}`, "/a", "did not match any of the specified OneOf schemas"},
+		{"not", `{"properties": {"a": {"not": {"type": "integer"}}}}`, `{"a": 1}`, "/a", "result was valid, ('not') expected invalid"},
+		{"$ref", `{"properties": {"a": {"$ref": "#/$defs/missing"}}}`, `{"a": 1}`, "/a", "failed to resolve schema for ref #/$defs/missing"},
+	}
+
+	covered := map[string]bool{}
+
+	for _, c := range cases {
+		covered[c.keyword] = true
+
+		fields, err := validateSchema(rmap.MustNewFromBytes([]byte(c.data)), rmap.MustNewFromBytes([]byte(c.schema)))
+		assert.Nil(t, err, c.keyword)
+
+		isMatched := false
+		for _, field := range fields {
+			keyword := field.Mapa[ValidationKeywordKey].(string)
+			message := field.Mapa[ValidationMessageKey].(string)
+			assert.NotEmpty(t, keyword, "message: %s is not mapped to keyword", message)
+
+			if keyword == c.keyword && strings.HasPrefix(message, c.message) {
+				assert.Equal(t, c.pointer, field.Mapa[ValidationPointerKey], c.keyword)
+				isMatched = true
+			}
+		}
+		assert.True(t, isMatched, "message: %s is not mapped to keyword: %s, fields: %v", c.message, c.keyword, fields)
+	}
+
+	for _, candidate := range validationKeywords {
+		assert.True(t, covered[candidate.keyword], "keyword: %s has no test case", candidate.keyword)
+	}
+}
+
+func TestValidateSchema_UnknownMessage(t *testing.T) {
+	// message without mapping is still returned, with empty keyword
+	for _, candidate := range validationKeywords {
+		assert.False(t, candidate.pattern.MatchString("some future message"), candidate.keyword)
+	}
+}
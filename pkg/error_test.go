@@ -0,0 +1,119 @@
+package cc_core
+
+import (
+	testdata2 "github.com/KompiTech/fabric-cc-core/v2/internal/testdata"
+	"github.com/KompiTech/fabric-cc-core/v2/pkg/engine"
+	"github.com/KompiTech/fabric-cc-core/v2/pkg/konst"
+	. "github.com/KompiTech/fabric-cc-core/v2/pkg/testing"
+	"github.com/KompiTech/rmap"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("error format tests", func() {
+	var tctx *TestContext
+
+	Context("When default error format is used", func() {
+		BeforeEach(func() {
+			tctx = getDefaultTextContext()
+			tctx.InitOk(tctx.GetInit("../internal/testdata/assets", "").Bytes())
+			tctx.RegisterAllActors()
+		})
+
+		It("Should return JSON error with code, status and message without wrapping", func() {
+			errJSON := tctx.ErrorJSON("state entry not found", "assetGet", "mockincident", "some_id", false, "")
+			Expect(errJSON.Mapa).To(HaveKeyWithValue(konst.ErrorCodeKey, string(engine.ErrorCodeNotFound)))
+			Expect(errJSON.Mapa).To(HaveKeyWithValue(konst.ErrorStatusKey, float64(404)))
+			Expect(errJSON.Mapa).To(HaveKeyWithValue(konst.ErrorMessageKey, "state entry not found: MOCKINCIDENTsome_id"))
+			Expect(errJSON.Mapa).To(HaveKeyWithValue(konst.ErrorChainKey, ContainSubstring("failed: state entry not found: MOCKINCIDENTsome_id")))
+			Expect(errJSON.Mapa).NotTo(HaveKey(konst.ErrorDetailsKey))
+		})
+
+		It("Should return required grant when permission is denied", func() {
+			tctx.SetActor("ordinaryUser")
+			errJSON := tctx.ErrorJSON("permission denied", "functionInvoke", "MockFunc", rmap.NewEmpty().Bytes())
+			Expect(errJSON.Mapa).To(HaveKeyWithValue(konst.ErrorCodeKey, string(engine.ErrorCodeForbidden)))
+			Expect(errJSON.Mapa).To(HaveKeyWithValue(konst.ErrorStatusKey, float64(403)))
+			Expect(errJSON.MustGetJPtr("/" + konst.ErrorDetailsKey + "/" + konst.ErrorGrantKey)).To(Equal(map[string]interface{}{
+				konst.ErrorGrantObjectKey: "/function/invoke/MockFunc",
+				konst.ErrorGrantActionKey: "execute",
+			}))
+		})
+
+		It("Should return pointers of invalid references", func() {
+			incidentReq := rmap.NewFromMap(map[string]interface{}{"assigned_to": "WRONG", "description": "ABCD"})
+			errJSON := tctx.ErrorJSON("Referenced asset 'mockuser' with ID 'wrong' not found", "assetCreate", "mockincident", incidentReq.Bytes(), -1, "")
+			Expect(errJSON.Mapa).To(HaveKeyWithValue(konst.ErrorCodeKey, string(engine.ErrorCodeRefNotFound)))
+			Expect(errJSON.Mapa).To(HaveKeyWithValue(konst.ErrorStatusKey, float64(422)))
+			Expect(errJSON.MustGetJPtr("/" + konst.ErrorDetailsKey + "/" + konst.ErrorPointersKey)).To(Equal([]interface{}{"/assigned_to"}))
+		})
+
+		It("Should return all invalid fields of asset", func() {
+			incidentReq := rmap.NewFromMap(map[string]interface{}{"assigned_to": 1, "timelogs": []interface{}{"a", "a"}})
+			errJSON := tctx.ErrorJSON("asset.ValidateSchema() failed on assetName: mockincident", "assetCreate", "mockincident", incidentReq.Bytes(), -1, "")
+			Expect(errJSON.Mapa).To(HaveKeyWithValue(konst.ErrorCodeKey, string(engine.ErrorCodeSchemaValidation)))
+			Expect(errJSON.Mapa).To(HaveKeyWithValue(konst.ErrorStatusKey, float64(422)))
+			Expect(errJSON.MustGetJPtr("/" + konst.ErrorDetailsKey + "/" + konst.ErrorFieldsKey)).To(Equal([]interface{}{
+				map[string]interface{}{
+					konst.ValidationPointerKey: "/assigned_to",
+					konst.ValidationKeywordKey: "type",
+					konst.ValidationMessageKey: "type should be string, got integer",
+				},
+				map[string]interface{}{
+					konst.ValidationPointerKey: "/description",
+					konst.ValidationKeywordKey: "required",
+					konst.ValidationMessageKey: `"description" value is required`,
+				},
+				map[string]interface{}{
+					konst.ValidationPointerKey: "/timelogs",
+					konst.ValidationKeywordKey: "uniqueItems",
+					konst.ValidationMessageKey: "array items must be unique. duplicated entry: a",
+				},
+			}))
+			Expect(errJSON.Mapa).To(HaveKeyWithValue(konst.ErrorMessageKey, ContainSubstring("/assigned_to: type should be string, got integer, /description:")))
+		})
+
+		It("Should distinguish schema validation and business logic failures", func() {
+			errJSON := tctx.ErrorJSON("asset.ValidateSchema() failed on assetName: mockincident", "assetCreate", "mockincident", "", -1, "")
+			Expect(errJSON.Mapa).To(HaveKeyWithValue(konst.ErrorCodeKey, string(engine.ErrorCodeSchemaValidation)))
+			Expect(errJSON.Mapa).To(HaveKeyWithValue(konst.ErrorStatusKey, float64(422)))
+
+			id := MustGetID(tctx.Rmap("assetCreate", "mockevent", `{"text":"hello"}`, -1, ""))
+			errJSON = tctx.ErrorJSON("business logic created fail", "assetDelete", "mockevent", id)
+			Expect(errJSON.Mapa).To(HaveKeyWithValue(konst.ErrorCodeKey, string(engine.ErrorCodeBlogicFailed)))
+			Expect(errJSON.Mapa).To(HaveKeyWithValue(konst.ErrorStatusKey, float64(500)))
+		})
+
+		It("Should add tracing info as JSON", func() {
+			tracingInfo := rmap.NewFromMap(map[string]interface{}{
+				konst.TracingTraceKey: true,
+				"request":             "some-request",
+			})
+
+			errJSON := tctx.ErrorJSON("state entry not found", "assetGet", "mockincident", "some_id", false, "", tracingInfo.Bytes())
+			Expect(errJSON.Mapa).To(HaveKeyWithValue(konst.ErrorTracingKey, map[string]interface{}{"request": "some-request"}))
+		})
+	})
+
+	Context("When legacy error format is enabled", func() {
+		BeforeEach(func() {
+			config := testdata2.GetConfiguration()
+			config.CurrentIDFunc = engine.CertSHA512IDFunc
+			config.LegacyErrorFormat = true
+
+			tctx = NewTestContext("mock", config, nil, nil)
+			tctx.InitOk(tctx.GetInit("../internal/testdata/assets", "").Bytes())
+			tctx.RegisterAllActors()
+		})
+
+		It("Should return error message with HTTP status code", func() {
+			tctx.Error("failed: state entry not found: MOCKINCIDENTsome_id|||404", "assetGet", "mockincident", "some_id", false, "")
+
+			tracingInfo := rmap.NewFromMap(map[string]interface{}{
+				konst.TracingTraceKey: true,
+				"request":             "some-request",
+			})
+			tctx.Error(`MOCKINCIDENTsome_id{"request":"some-request"}|||404`, "assetGet", "mockincident", "some_id", false, "", tracingInfo.Bytes())
+		})
+	})
+})
@@ -0,0 +1,116 @@
+package cc_core
+
+import (
+	"github.com/KompiTech/fabric-cc-core/v2/pkg/konst"
+	. "github.com/KompiTech/fabric-cc-core/v2/pkg/testing"
+	"github.com/KompiTech/rmap"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("chaincode events", func() {
+	var tctx *TestContext
+
+	BeforeEach(func() {
+		tctx = getDefaultTextContext()
+		tctx.InitOk(tctx.GetInit("../internal/testdata/assets", "").Bytes())
+		tctx.RegisterAllActors()
+
+		// version 2 of mockstate has some property to update
+		regItemV2 := rmap.MustNewFromYAMLFile("../internal/testdata/assets/mockstate.yaml")
+		regItemV2.MustSetJPtr("/schema/properties", map[string]interface{}{
+			"text": map[string]interface{}{"type": "string"},
+		})
+		tctx.Ok("registryUpsert", "mockstate", regItemV2.Bytes())
+	})
+
+	It("Should emit envelope with TX ID", func() {
+		tctx.Ok("assetCreate", "mockstate", rmap.NewEmpty().Bytes(), 1, "")
+		Expect(tctx.GetCC().ChaincodeEvent.EventName).To(Equal(konst.EventEnvelopeName))
+		Expect(tctx.GetLastEventPayload()).To(HaveKey(konst.EventTxIdKey))
+		Expect(tctx.GetLastEvents()).To(BeEmpty())
+	})
+
+	It("Should emit event with diff for create, update and delete of whitelisted asset", func() {
+		state := tctx.Rmap("assetCreate", "mockstate", `{"text":"created"}`, -1, "")
+		id := MustGetID(state)
+
+		changes := tctx.GetLastAssetChanges()
+		Expect(changes).To(HaveLen(1))
+		change := changes[0]
+		Expect(change.Mapa).To(HaveKeyWithValue(konst.AssetDocTypeKey, "MOCKSTATE"))
+		Expect(change.Mapa).To(HaveKeyWithValue(konst.AssetIdKey, id))
+		Expect(change.Mapa).To(HaveKeyWithValue(konst.EventOperationKey, konst.EventCreateOperation))
+		Expect(change.Mapa).To(HaveKeyWithValue(konst.EventVersionKey, float64(2)))
+		Expect(change.Mapa).To(HaveKeyWithValue(konst.EventDiffKey, HaveKeyWithValue("text", "created")))
+
+		tctx.Ok("assetUpdate", "mockstate", id, `{"text":"updated"}`)
+		changes = tctx.GetLastAssetChanges()
+		Expect(changes).To(HaveLen(1))
+		change = changes[0]
+		Expect(change.Mapa).To(HaveKeyWithValue(konst.EventOperationKey, konst.EventUpdateOperation))
+		Expect(change.Mapa).To(HaveKeyWithValue(konst.EventDiffKey, map[string]interface{}{
+			konst.AssetRevisionKey: float64(2),
+			"text":                 "updated",
+		}))
+
+		tctx.Ok("assetDelete", "mockstate", id)
+		changes = tctx.GetLastAssetChanges()
+		Expect(changes).To(HaveLen(1))
+		change = changes[0]
+		Expect(change.Mapa).To(HaveKeyWithValue(konst.EventOperationKey, konst.EventDeleteOperation))
+		Expect(change.Mapa).NotTo(HaveKey(konst.EventDiffKey))
+	})
+
+	It("Should emit event with migrate operation", func() {
+		id := MustGetID(tctx.Rmap("assetCreate", "mockstate", rmap.NewEmpty().Bytes(), 1, ""))
+
+		tctx.Ok("assetMigrate", "mockstate", id, `{"text":"migrated"}`, 2)
+		changes := tctx.GetLastAssetChanges()
+		Expect(changes).To(HaveLen(1))
+		change := changes[0]
+		Expect(change.Mapa).To(HaveKeyWithValue(konst.EventOperationKey, konst.EventMigrateOperation))
+		Expect(change.Mapa).To(HaveKeyWithValue(konst.EventVersionKey, float64(2)))
+		Expect(change.Mapa).To(HaveKeyWithValue(konst.EventDiffKey, map[string]interface{}{
+			konst.AssetVersionKey:  float64(2),
+			konst.AssetRevisionKey: float64(2),
+			"text":                 "migrated",
+		}))
+	})
+
+	It("Should not include diff for private data asset", func() {
+		tctx.Ok("assetCreate", "mockpd", rmap.NewEmpty().Bytes(), -1, "")
+		changes := tctx.GetLastAssetChanges()
+		Expect(changes).To(HaveLen(1))
+		Expect(changes[0].Mapa).NotTo(HaveKey(konst.EventDiffKey))
+	})
+
+	It("Should not emit event for asset that is not whitelisted", func() {
+		tctx.Ok("assetCreate", "mockcomment", `{"text":"hello"}`, -1, "")
+		Expect(tctx.GetCC().ChaincodeEvent).To(BeNil())
+	})
+
+	It("Should aggregate custom events emitted by business logic", func() {
+		id := MustGetID(tctx.Rmap("assetCreate", "mockevent", `{"text":"hello"}`, -1, ""))
+
+		events := tctx.GetLastEvents()
+		Expect(events).To(HaveLen(2))
+		Expect(events[0].Mapa).To(HaveKeyWithValue(konst.EventNameKey, "mockevent_created"))
+		Expect(events[1].Mapa).To(HaveKeyWithValue(konst.EventNameKey, "mockevent_text"))
+
+		Expect(tctx.GetLastEventPayloads("mockevent_created")).To(ConsistOf(
+			rmap.NewFromMap(map[string]interface{}{konst.AssetIdKey: id}),
+		))
+		Expect(tctx.GetLastEventPayloads("mockevent_text")).To(ConsistOf(
+			rmap.NewFromMap(map[string]interface{}{"text": "hello"}),
+		))
+		Expect(tctx.GetLastAssetChanges()).To(BeEmpty())
+	})
+
+	It("Should not emit custom events when transaction fails", func() {
+		id := MustGetID(tctx.Rmap("assetCreate", "mockevent", `{"text":"hello"}`, -1, ""))
+
+		tctx.Error("business logic created fail", "assetDelete", "mockevent", id)
+		Expect(tctx.GetCC().ChaincodeEvent).To(BeNil())
+	})
+})
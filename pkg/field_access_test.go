@@ -0,0 +1,151 @@
+package cc_core
+
+import (
+	"github.com/KompiTech/fabric-cc-core/v2/pkg/konst"
+	. "github.com/KompiTech/fabric-cc-core/v2/pkg/testing"
+	"github.com/KompiTech/rmap"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("field access tests", func() {
+	var tctx *TestContext
+	var id string
+
+	grantRole := func(grants []map[string]interface{}) {
+		tctx.SetActor("superUser")
+		role := rmap.NewFromMap(map[string]interface{}{
+			"name":   "Field reader",
+			"grants": grants,
+		})
+		roleID := MustGetID(tctx.Rmap("assetCreate", "role", role.Bytes(), -1, ""))
+		tctx.Ok("assetUpdate", "identity", tctx.GetActorFingerprint("ordinaryUser"), rmap.NewFromMap(map[string]interface{}{"roles": []string{roleID}}).Bytes())
+		tctx.SetActor("ordinaryUser")
+	}
+
+	BeforeEach(func() {
+		tctx = getDefaultTextContext()
+		tctx.InitOk(tctx.GetInit("../internal/testdata/assets", "").Bytes())
+		tctx.RegisterAllActors()
+
+		// version 2 of mockstate has restricted fields
+		regItemV2 := rmap.MustNewFromYAMLFile("../internal/testdata/assets/mockstate.yaml")
+		regItemV2.MustSetJPtr("/schema/properties", map[string]interface{}{
+			"text":   map[string]interface{}{"type": "string"},
+			"salary": map[string]interface{}{"type": "integer"},
+			"details": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"public": map[string]interface{}{"type": "string"},
+					"secret": map[string]interface{}{"type": "string"},
+				},
+			},
+			"overrides": map[string]interface{}{"$ref": "#/$defs/overrides"},
+		})
+		regItemV2.Mapa[konst.RegistryItemRestrictedFieldsKey] = []interface{}{"/salary", "/details/secret"}
+		tctx.Ok("registryUpsert", "mockstate", regItemV2.Bytes())
+
+		id = MustGetID(tctx.Rmap("assetCreate", "mockstate", `{"text":"hello","salary":1000,"details":{"public":"a","secret":"b"}}`, -1, ""))
+	})
+
+	It("Should return all fields to superuser", func() {
+		asset := tctx.JSON("assetGet", "mockstate", id, false, "")
+		Expect(asset).To(HaveKeyWithValue("salary", float64(1000)))
+		Expect(asset).To(HaveKeyWithValue("details", HaveKeyWithValue("secret", "b")))
+	})
+
+	It("Should mask restricted fields without field grant", func() {
+		grantRole([]map[string]interface{}{{"object": "/mockstate/*", "action": "read"}})
+
+		asset := tctx.JSON("assetGet", "mockstate", id, false, "")
+		Expect(asset).To(HaveKeyWithValue("text", "hello"))
+		Expect(asset).NotTo(HaveKey("salary"))
+		Expect(asset).To(HaveKeyWithValue("details", Equal(map[string]interface{}{"public": "a"})))
+
+		result := tctx.RmapNoResult("assetQuery", "mockstate", rmap.NewEmpty().Bytes(), false)
+		Expect(result.Mapa).To(HaveKeyWithValue(konst.OutputResultKey, ContainElement(And(
+			HaveKeyWithValue(konst.AssetIdKey, id),
+			Not(HaveKey("salary")),
+		))))
+	})
+
+	It("Should return restricted fields with field grant", func() {
+		grantRole([]map[string]interface{}{{"object": "/mockstate/*#/salary", "action": "read"}})
+
+		asset := tctx.JSON("assetGet", "mockstate", id, false, "")
+		Expect(asset).To(HaveKeyWithValue("salary", float64(1000)))
+		Expect(asset).To(HaveKeyWithValue("details", Not(HaveKey("secret"))))
+	})
+
+	It("Should match nested fields by field grant of parent", func() {
+		grantRole([]map[string]interface{}{{"object": "/mockstate/" + id + "#/details", "action": "read"}})
+
+		asset := tctx.JSON("assetGet", "mockstate", id, false, "")
+		Expect(asset).NotTo(HaveKey("salary"))
+		Expect(asset).To(HaveKeyWithValue("details", HaveKeyWithValue("secret", "b")))
+	})
+
+	It("Should reject update of restricted field without field grant", func() {
+		grantRole([]map[string]interface{}{{"object": "/mockstate/*#/salary", "action": "read"}})
+
+		errJSON := tctx.ErrorJSON("permission denied", "assetUpdate", "mockstate", id, `{"salary":2000}`)
+		Expect(errJSON.Mapa).To(HaveKeyWithValue(konst.ErrorStatusKey, float64(403)))
+		Expect(errJSON.MustGetJPtr("/" + konst.ErrorDetailsKey + "/" + konst.ErrorGrantKey)).To(Equal(map[string]interface{}{
+			konst.ErrorGrantObjectKey: "/mockstate/" + id + "#/salary",
+			konst.ErrorGrantActionKey: "update",
+		}))
+
+		// patch keeping restricted field unchanged is allowed and its output is masked
+		asset := tctx.JSON("assetUpdate", "mockstate", id, `{"text":"updated","salary":1000}`)
+		Expect(asset).To(HaveKeyWithValue("text", "updated"))
+		Expect(asset).To(HaveKeyWithValue("details", Not(HaveKey("secret"))))
+
+		tctx.Error("permission denied", "assetUpdate", "mockstate", id, `{"details":{"secret":null}}`)
+		tctx.Error("permission denied", "assetCreate", "mockstate", `{"salary":1}`, -1, "")
+	})
+
+	It("Should reject migration patching restricted field without field grant", func() {
+		regItemV3 := tctx.Rmap("registryGet", "mockstate", -1)
+		delete(regItemV3.Mapa, "name")
+		delete(regItemV3.Mapa, "version")
+		regItemV3.MustSetJPtr("/schema/properties/note", map[string]interface{}{"type": "string"})
+		tctx.Ok("registryUpsert", "mockstate", regItemV3.Bytes())
+
+		grantRole([]map[string]interface{}{
+			{"object": "/mockstate/*", "action": "migrate"},
+			{"object": "/mockstate/*#/salary", "action": "read"},
+		})
+
+		tctx.Error("permission denied", "assetMigrate", "mockstate", id, `{"salary":2000}`, 3)
+		tctx.Error("permission denied", "assetMigrate", "mockstate", id, `{"details":{"secret":"c"}}`, 3)
+
+		asset := tctx.JSON("assetMigrate", "mockstate", id, `{"text":"migrated"}`, 3)
+		Expect(asset).To(HaveKeyWithValue("text", "migrated"))
+		Expect(asset).To(HaveKeyWithValue("salary", float64(1000)))
+	})
+
+	It("Should allow update of restricted field with field grant", func() {
+		grantRole([]map[string]interface{}{{"object": "/mockstate/*#/salary", "action": "update"}})
+
+		tctx.Ok("assetUpdate", "mockstate", id, `{"salary":2000}`)
+
+		tctx.SetActor("superUser")
+		Expect(tctx.JSON("assetGet", "mockstate", id, false, "")).To(HaveKeyWithValue("salary", float64(2000)))
+	})
+
+	It("Should allow update of restricted field with override on field", func() {
+		override := rmap.NewFromMap(map[string]interface{}{
+			"overrides": []interface{}{map[string]interface{}{
+				konst.SubjectKey: tctx.GetActorFingerprint("ordinaryUser"),
+				konst.ActionKey:  "update",
+				konst.EffectKey:  "allow",
+				konst.PointerKey: "/salary",
+			}},
+		})
+		tctx.Ok("assetUpdate", "mockstate", id, override.Bytes())
+
+		tctx.SetActor("ordinaryUser")
+		tctx.Ok("assetUpdate", "mockstate", id, `{"salary":2000}`)
+		tctx.Error("permission denied", "assetUpdate", "mockstate", id, `{"details":{"secret":"c"}}`)
+	})
+})
@@ -1,9 +1,14 @@
 package cc_core
 
 import (
+	"strings"
+
 	testdata2 "github.com/KompiTech/fabric-cc-core/v2/internal/testdata"
 	"github.com/KompiTech/fabric-cc-core/v2/pkg/engine"
+	"github.com/KompiTech/fabric-cc-core/v2/pkg/konst"
 	"github.com/KompiTech/fabric-cc-core/v2/pkg/testing"
+	"github.com/KompiTech/rmap"
+	. "github.com/onsi/gomega"
 )
 
 // returns default TestContext for most tests
@@ -12,3 +17,20 @@ func getDefaultTextContext() *testing.TestContext {
 	eng.CurrentIDFunc = engine.CertSHA512IDFunc
 	return testing.NewTestContext("mock", eng, nil, nil)
 }
+
+// removes revision from stored asset with id, as if it was stored before revisions were introduced
+func removeStoredRevision(tctx *testing.TestContext, id string) {
+	cc := tctx.GetCC()
+	cc.MockTransactionStart("legacy")
+	defer cc.MockTransactionEnd("legacy")
+
+	for key, value := range cc.State {
+		if !strings.Contains(key, id) {
+			continue
+		}
+
+		asset := rmap.MustNewFromBytes(value)
+		delete(asset.Mapa, konst.AssetRevisionKey)
+		Expect(cc.PutState(key, asset.Bytes())).To(Succeed())
+	}
+}
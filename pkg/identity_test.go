@@ -134,7 +134,7 @@ var _ = Describe("identity* method family tests", func() {
 
 		It("Should return error if attempting to remove last superuser", func() {
 			tctx.SetActor("superUser")
-			tctx.Error("identityExtraValidate() failed: unable to remove last superuser role", "assetUpdate", "identity", tctx.GetCurrentActorFingerprint(), revoke.Bytes())
+			tctx.Error("unable to remove last superuser role", "assetUpdate", "identity", tctx.GetCurrentActorFingerprint(), revoke.Bytes())
 		})
 
 		It("Should return error if attempting to remove last superuser by clearing all roles", func() {
@@ -145,7 +145,7 @@ var _ = Describe("identity* method family tests", func() {
 		It("Should return error if attempting to remove last superuser by clearing all roles", func() {
 			removeAllRoles := rmap.NewFromMap(map[string]interface{}{"roles": nil})
 			tctx.SetActor("superUser")
-			tctx.Error("identityExtraValidate() failed: unable to remove last superuser role", "assetUpdate", "identity", tctx.GetCurrentActorFingerprint(), removeAllRoles.Bytes())
+			tctx.Error("unable to remove last superuser role", "assetUpdate", "identity", tctx.GetCurrentActorFingerprint(), removeAllRoles.Bytes())
 		})
 
 		It("Should require permission", func() {
@@ -156,11 +156,11 @@ var _ = Describe("identity* method family tests", func() {
 		It("Should return error if somebody without SU attempts to update SU", func() {
 			tctx.SetActor("ordinaryUser")
 			// ordinaryUser cannot grant SU role to nobodyUser
-			tctx.Error("identityExtraValidate() failed: to manage SuperUser role, you must have it granted", "identityUpdate", tctx.GetActorFingerprint("nobodyUser"), grantSU.Bytes())
+			tctx.Error("to manage SuperUser role, you must have it granted", "identityUpdate", tctx.GetActorFingerprint("nobodyUser"), grantSU.Bytes())
 			// ordinaryUser cannot revoke SU role from superUser
-			tctx.Error("identityExtraValidate() failed: to manage SuperUser role, you must have it granted", "identityUpdate", tctx.GetActorFingerprint("superUser"), revoke.Bytes())
+			tctx.Error("to manage SuperUser role, you must have it granted", "identityUpdate", tctx.GetActorFingerprint("superUser"), revoke.Bytes())
 			// ordinaryUser cannot disable SU role
-			tctx.Error("identityExtraValidate() failed: to manage SuperUser role, you must have it granted", "identityUpdate", tctx.GetActorFingerprint("superUser"), disable.Bytes())
+			tctx.Error("to manage SuperUser role, you must have it granted", "identityUpdate", tctx.GetActorFingerprint("superUser"), disable.Bytes())
 			// ordinaryUser can grant non-SU role to nobodyUser because he has identityUpdate grant
 			tctx.Ok("identityUpdate", tctx.GetActorFingerprint("nobodyUser"), grantRole.Bytes())
 		})
@@ -356,7 +356,7 @@ var _ = Describe("identity* method family tests", func() {
 			req := rmap.NewFromMap(map[string]interface{}{
 				"roles": nil,
 			})
-			tctx.Error("identityExtraValidate() failed: unable to remove last superuser role", "identityUpdateDirect", tctx.GetActorFingerprint("superUser"), req.Bytes())
+			tctx.Error("unable to remove last superuser role", "identityUpdateDirect", tctx.GetActorFingerprint("superUser"), req.Bytes())
 		})
 	})
 
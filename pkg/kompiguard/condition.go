@@ -0,0 +1,123 @@
+package kompiguard
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/Knetic/govaluate"
+	. "github.com/KompiTech/fabric-cc-core/v2/pkg/konst"
+	"github.com/KompiTech/rmap"
+	"github.com/pkg/errors"
+)
+
+// conditionVariableRegexp matches variables of condition, they are wrapped in brackets, so govaluate does not treat them as struct accessors
+// string literals are matched too, so variables are not replaced inside of them
+var conditionVariableRegexp = regexp.MustCompile(`'[^']*'|"[^"]*"|\[?\b(?:` + ConditionIdentityPrefix + `|` + ConditionCertPrefix + `|` + ConditionAssetPrefix + `)(?:\.\w+)+\]?`)
+
+// attributes are values available to conditions of grants and validity checks
+// they are shared by all copies of KompiGuard
+type attributes struct {
+	identity    rmap.Rmap                                 // identity that was loaded by LoadRoles
+	cert        map[string]string                         // attributes of certificate of current identity
+	now         time.Time                                 // time of transaction for validity of grants and role assignments
+	expressions map[string]*govaluate.EvaluableExpression // already parsed conditions
+}
+
+// ValidateCondition checks, that condition of grant is valid expression and uses only known variables
+func ValidateCondition(condition string) error {
+	expression, err := parseCondition(condition)
+	if err != nil {
+		return err
+	}
+
+	for _, variable := range expression.Vars() {
+		root := strings.SplitN(variable, ".", 2)[0]
+		if !strings.Contains(variable, ".") || (root != ConditionIdentityPrefix && root != ConditionCertPrefix && root != ConditionAssetPrefix) {
+			return fmt.Errorf("unknown variable: %s in condition: %s, use identity.<key>, cert.<attribute> or asset.<key>", variable, condition)
+		}
+	}
+
+	return nil
+}
+
+// parseCondition parses condition of grant to govaluate expression
+func parseCondition(condition string) (*govaluate.EvaluableExpression, error) {
+	bracketed := conditionVariableRegexp.ReplaceAllStringFunc(condition, func(variable string) string {
+		if strings.HasPrefix(variable, "'") || strings.HasPrefix(variable, `"`) || (strings.HasPrefix(variable, "[") && strings.HasSuffix(variable, "]")) {
+			return variable
+		}
+		return "[" + strings.Trim(variable, "[]") + "]"
+	})
+
+	expression, err := govaluate.NewEvaluableExpression(bracketed)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid condition: %s", condition)
+	}
+
+	return expression, nil
+}
+
+// SetCertAttributes sets attributes of certificate of current identity, that are available in conditions as cert.<attribute>
+func (k KompiGuard) SetCertAttributes(cert map[string]string) {
+	k.attributes.cert = cert
+}
+
+// conditionParameters returns flattened parameters for evaluation of conditions
+// nested keys are joined by dot, for example asset.details.owner
+func (k KompiGuard) conditionParameters(asset *rmap.Rmap) map[string]interface{} {
+	params := map[string]interface{}{}
+
+	if k.attributes.identity.Mapa != nil {
+		flattenParameters(ConditionIdentityPrefix, k.attributes.identity.Mapa, params)
+	}
+
+	for key, value := range k.attributes.cert {
+		params[ConditionCertPrefix+"."+key] = value
+	}
+
+	if asset != nil {
+		flattenParameters(ConditionAssetPrefix, asset.Mapa, params)
+	}
+
+	return params
+}
+
+// flattenParameters adds all keys of input to params with prefix, nested objects are added recursively
+func flattenParameters(prefix string, input map[string]interface{}, params map[string]interface{}) {
+	for key, value := range input {
+		name := prefix + "." + key
+		params[name] = value
+
+		if nested, ok := value.(map[string]interface{}); ok {
+			flattenParameters(name, nested, params)
+		}
+	}
+}
+
+// conditionMatch evaluates condition of policy with parameters of request
+// policy without condition always matches, condition that cannot be evaluated (for example missing field) does not match
+func (k KompiGuard) conditionMatch(condition string, params map[string]interface{}) bool {
+	if condition == "" {
+		return true
+	}
+
+	expression, exists := k.attributes.expressions[condition]
+	if !exists {
+		var err error
+		expression, err = parseCondition(condition)
+		if err != nil {
+			return false
+		}
+		k.attributes.expressions[condition] = expression
+	}
+
+	result, err := expression.Evaluate(params)
+	if err != nil {
+		return false
+	}
+
+	matched, ok := result.(bool)
+	return ok && matched
+}
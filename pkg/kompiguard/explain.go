@@ -0,0 +1,405 @@
+package kompiguard
+
+import (
+	"fmt"
+	"strings"
+
+	. "github.com/KompiTech/fabric-cc-core/v2/pkg/konst"
+	"github.com/KompiTech/rmap"
+	"github.com/pkg/errors"
+)
+
+// Explain evaluates action on object for identity in the same way as EnforceCustom and returns why it was granted or denied
+// identity must be resolved, roles are loaded from it by Explain
+// if asset argument is present, overrides are loaded from it and its fields are available to conditions of grants
+// explanation contains all roles and groups of identity, grants and overrides matching object and action, superuser shortcut,
+// policy that decided the result as reported by casbin and the final decision
+func (k KompiGuard) Explain(identity rmap.Rmap, object, action string, asset *rmap.Rmap) (rmap.Rmap, error) {
+	action = strings.ToLower(action)
+
+	subject, err := AssetGetID(identity)
+	if err != nil {
+		return rmap.Rmap{}, errors.Wrap(err, "AssetGetID(identity) failed")
+	}
+
+	isEnabled, err := identity.GetBool(IsEnabledKey)
+	if err != nil {
+		return rmap.Rmap{}, errors.Wrap(err, "identity.GetBool() failed")
+	}
+
+	output := rmap.NewFromMap(map[string]interface{}{
+		SubjectKey:                subject,
+		ObjectKey:                 object,
+		ActionKey:                 action,
+		ExplainIdentityEnabledKey: isEnabled,
+		ExplainSuperuserKey:       false,
+		ExplainRolesKey:           []interface{}{},
+		ExplainGroupsKey:          []interface{}{},
+		ExplainGrantsKey:          []interface{}{},
+		ExplainOverridesKey:       []interface{}{},
+		ExplainPolicyKey:          nil,
+	})
+
+	if !isEnabled {
+		// LoadRoles refuses disabled identity, so nothing else is evaluated
+		output.Mapa[ExplainDecisionKey] = DenyEffect
+		output.Mapa[ExplainReasonKey] = fmt.Sprintf("identity: %s is not enabled", subject)
+		return output, nil
+	}
+
+	if err := k.LoadRoles(identity); err != nil {
+		return rmap.Rmap{}, errors.Wrap(err, "k.LoadRoles() failed")
+	}
+
+	roles, grants, err := k.explainRoles(identity, object, action, asset)
+	if err != nil {
+		return rmap.Rmap{}, errors.Wrap(err, "k.explainRoles() failed")
+	}
+	output.Mapa[ExplainRolesKey] = roles
+	output.Mapa[ExplainGrantsKey] = grants
+
+	groups, err := GetIdentityGroups(identity)
+	if err != nil {
+		return rmap.Rmap{}, errors.Wrap(err, "GetIdentityGroups() failed")
+	}
+
+	explainedGroups := make([]interface{}, 0, len(groups))
+	for _, group := range groups {
+		explainedGroup := map[string]interface{}{ExplainGroupKey: group.Mapa[AssetIdKey]}
+		if group.Exists(ExplainRoleNameKey) {
+			explainedGroup[ExplainRoleNameKey] = group.Mapa[ExplainRoleNameKey]
+		}
+		explainedGroups = append(explainedGroups, explainedGroup)
+	}
+	output.Mapa[ExplainGroupsKey] = explainedGroups
+
+	roleManager := k.enforcer.GetRoleManager()
+
+	superuserRole, err := k.SuperuserRole(subject)
+	if err != nil {
+		return rmap.Rmap{}, errors.Wrap(err, "k.SuperuserRole() failed")
+	}
+	output.Mapa[ExplainSuperuserKey] = superuserRole != ""
+
+	if asset != nil {
+		overrides, err := getOverrides(*asset)
+		if err != nil {
+			return rmap.Rmap{}, errors.Wrap(err, "getOverrides() failed")
+		}
+
+		explained := []interface{}{}
+		for _, ovr := range overrides {
+			if ovr.action != action || !objectMatches(object, ovr.object) {
+				continue
+			}
+
+			// override applies to identity itself and to any of its roles
+			applies, err := roleManager.HasLink(subject, ovr.subject)
+			if err != nil {
+				return rmap.Rmap{}, errors.Wrap(err, "roleManager.HasLink() failed")
+			}
+
+			explained = append(explained, map[string]interface{}{
+				SubjectKey:        ovr.subject,
+				ObjectKey:         ovr.object,
+				ActionKey:         ovr.action,
+				EffectKey:         ovr.effect,
+				ExplainAppliesKey: applies,
+			})
+		}
+		output.Mapa[ExplainOverridesKey] = explained
+
+		if _, err := k.loadOverrides(*asset); err != nil {
+			return rmap.Rmap{}, errors.Wrap(err, "k.loadOverrides() failed")
+		}
+	}
+
+	params := k.conditionParameters(asset)
+
+	// same as EnforceCustom, lowercase object is tried for backwards compatibility with existing objects
+	granted, policy, err := k.enforcer.EnforceEx(subject, object, action, params)
+	if err != nil {
+		return rmap.Rmap{}, errors.Wrap(err, "k.enforcer.EnforceEx() failed")
+	}
+
+	if !granted {
+		granted, policy, err = k.enforcer.EnforceEx(subject, strings.ToLower(object), action, params)
+		if err != nil {
+			return rmap.Rmap{}, errors.Wrap(err, "k.enforcer.EnforceEx() failed")
+		}
+	}
+
+	// superuser is granted also actions, that are not granted by policy, but not actions denied by policy
+	grantedSU := !granted && superuserRole != ""
+	if grantedSU {
+		denied, err := k.deniedByPolicy(subject, object, action, params)
+		if err != nil {
+			return rmap.Rmap{}, errors.Wrap(err, "k.deniedByPolicy() failed")
+		}
+
+		grantedSU = !denied
+	}
+
+	if grantedSU {
+		granted = true
+		policy = nil
+	}
+
+	var reason string
+	switch {
+	case grantedSU:
+		reason = "granted by superuser role"
+	case granted:
+		reason = fmt.Sprintf("granted by policy of subject: %s, obj: %s", policy[0], policy[1])
+	case len(policy) > 0:
+		reason = fmt.Sprintf("denied by policy of subject: %s, obj: %s with effect: %s", policy[0], policy[1], policy[3])
+	default:
+		reason = fmt.Sprintf("no grant or override allows action, permission denied, sub: %s, obj: %s, act: %s", subject, object, action)
+	}
+
+	if len(policy) > 0 {
+		output.Mapa[ExplainPolicyKey] = explainPolicy(policy)
+	}
+
+	if granted {
+		output.Mapa[ExplainDecisionKey] = AllowEffect
+	} else {
+		output.Mapa[ExplainDecisionKey] = DenyEffect
+	}
+	output.Mapa[ExplainReasonKey] = reason
+
+	return output, nil
+}
+
+// explainRoles returns all roles assigned to identity with their validity, roles of groups identity is member of,
+// roles inherited by them and all grants of these roles matching object and action
+// roles must be already loaded, so conditions can be evaluated
+func (k KompiGuard) explainRoles(identity rmap.Rmap, object, action string, asset *rmap.Rmap) ([]interface{}, []interface{}, error) {
+	roles := []interface{}{}
+	grants := []interface{}{}
+
+	roleList, err := getRoleRefs(identity, RolesKey)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "getRoleRefs() failed")
+	}
+
+	params := k.conditionParameters(asset)
+	inherited := map[string]struct{}{}
+
+	// explainInherited adds roles inherited from parents of role
+	explainInherited := func(role rmap.Rmap) error {
+		parents, err := GetRoleParents(role)
+		if err != nil {
+			return errors.Wrap(err, "GetRoleParents() failed")
+		}
+
+		for _, parent := range parents {
+			if err := walkRoles(parent, inherited, func(parent rmap.Rmap) error {
+				explainedParent := explainRole(parent, true)
+				explainedParent[ExplainInheritedKey] = true
+				roles = append(roles, explainedParent)
+
+				parentGrants, err := k.explainGrants(parent, true, object, action, params)
+				if err != nil {
+					return errors.Wrap(err, "k.explainGrants() failed")
+				}
+				grants = append(grants, parentGrants...)
+
+				return nil
+			}); err != nil {
+				return errors.Wrap(err, "walkRoles() failed")
+			}
+		}
+
+		return nil
+	}
+
+	for _, role := range roleList {
+		roleUUID, err := AssetGetID(role)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "AssetGetID(role) failed")
+		}
+
+		validity, err := GetRoleValidity(identity, roleUUID)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "GetRoleValidity() failed")
+		}
+
+		isRoleValid, err := k.isValid(validity)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "k.isValid() failed for role: %s", roleUUID)
+		}
+
+		explainedRole := explainRole(role, isRoleValid)
+		for key, value := range validity.Mapa {
+			explainedRole[key] = value
+		}
+		roles = append(roles, explainedRole)
+
+		roleGrants, err := k.explainGrants(role, isRoleValid, object, action, params)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "k.explainGrants() failed")
+		}
+		grants = append(grants, roleGrants...)
+
+		if !isRoleValid {
+			// roles inherited by assignment, that is not valid, are not loaded
+			continue
+		}
+
+		inherited[roleUUID] = struct{}{}
+
+		if err := explainInherited(role); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	groups, err := GetIdentityGroups(identity)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "GetIdentityGroups() failed")
+	}
+
+	for _, group := range groups {
+		groupUUID, err := AssetGetID(group)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "AssetGetID(group) failed")
+		}
+
+		groupRoles, err := GetGroupRoles(group)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "GetGroupRoles() failed")
+		}
+
+		for _, role := range groupRoles {
+			roleUUID, err := AssetGetID(role)
+			if err != nil {
+				return nil, nil, errors.Wrap(err, "AssetGetID(role) failed")
+			}
+
+			explainedRole := explainRole(role, true)
+			explainedRole[ExplainGroupKey] = groupUUID
+			roles = append(roles, explainedRole)
+
+			roleGrants, err := k.explainGrants(role, true, object, action, params)
+			if err != nil {
+				return nil, nil, errors.Wrap(err, "k.explainGrants() failed")
+			}
+			grants = append(grants, roleGrants...)
+
+			inherited[roleUUID] = struct{}{}
+
+			if err := explainInherited(role); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+
+	return roles, grants, nil
+}
+
+// explainRole returns role with its UUID, name and validity
+func explainRole(role rmap.Rmap, isValid bool) map[string]interface{} {
+	explained := map[string]interface{}{
+		ExplainRoleKey:  role.Mapa[AssetIdKey],
+		ExplainValidKey: isValid,
+	}
+
+	if role.Exists(ExplainRoleNameKey) {
+		explained[ExplainRoleNameKey] = role.Mapa[ExplainRoleNameKey]
+	}
+
+	return explained
+}
+
+// explainGrants returns grants of role matching object and action
+func (k KompiGuard) explainGrants(role rmap.Rmap, isRoleValid bool, object, action string, params map[string]interface{}) ([]interface{}, error) {
+	grants := []interface{}{}
+
+	if !role.Exists(GrantsKey) {
+		return grants, nil
+	}
+
+	roleUUID, err := AssetGetID(role)
+	if err != nil {
+		return nil, errors.Wrap(err, "AssetGetID(role) failed")
+	}
+
+	grantList, err := role.GetIterableRmap(GrantsKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "role.GetIterableRmap() failed")
+	}
+
+	for _, grant := range grantList {
+		grantObject, err := grant.GetString(ObjectKey)
+		if err != nil {
+			return nil, errors.Wrap(err, "grant.GetString() failed")
+		}
+
+		grantAction, err := grant.GetString(ActionKey)
+		if err != nil {
+			return nil, errors.Wrap(err, "grant.GetString() failed")
+		}
+
+		if grantAction != action || !objectMatches(object, grantObject) {
+			continue
+		}
+
+		isGrantValid, err := k.isValid(grant)
+		if err != nil {
+			return nil, errors.Wrapf(err, "k.isValid() failed for grant of role: %s", roleUUID)
+		}
+
+		explainedGrant := map[string]interface{}{
+			ExplainRoleKey: roleUUID,
+			ObjectKey:      grantObject,
+			ActionKey:      grantAction,
+			EffectKey:      AllowEffect,
+		}
+
+		conditionMet := true
+		if grant.Exists(ConditionKey) {
+			condition, err := grant.GetString(ConditionKey)
+			if err != nil {
+				return nil, errors.Wrap(err, "grant.GetString() failed")
+			}
+
+			explainedGrant[ConditionKey] = condition
+			conditionMet = k.conditionMatch(condition, params)
+		}
+
+		for _, key := range []string{ValidFromKey, ValidUntilKey} {
+			if grant.Exists(key) {
+				explainedGrant[key] = grant.Mapa[key]
+			}
+		}
+
+		explainedGrant[ExplainValidKey] = isGrantValid
+		explainedGrant[ExplainConditionMetKey] = conditionMet
+		explainedGrant[ExplainAppliesKey] = isRoleValid && isGrantValid && conditionMet
+
+		grants = append(grants, explainedGrant)
+	}
+
+	return grants, nil
+}
+
+// objectMatches checks if object or its lowercase form is matched by object of policy, same as EnforceCustom does
+func objectMatches(object, policy string) bool {
+	return ObjectMatch(object, policy) || ObjectMatch(strings.ToLower(object), policy)
+}
+
+// explainPolicy converts casbin policy (sub, obj, act, eft, cond) to map
+func explainPolicy(policy []string) map[string]interface{} {
+	explained := map[string]interface{}{
+		SubjectKey: policy[0],
+		ObjectKey:  policy[1],
+		ActionKey:  policy[2],
+		EffectKey:  policy[3],
+	}
+
+	if len(policy) > 4 && policy[4] != "" {
+		explained[ConditionKey] = policy[4]
+	}
+
+	return explained
+}
@@ -4,21 +4,32 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/Knetic/govaluate"
 	. "github.com/KompiTech/fabric-cc-core/v2/pkg/konst"
 	"github.com/KompiTech/rmap"
 	"github.com/casbin/casbin/v2"
 	"github.com/casbin/casbin/v2/model"
+	"github.com/casbin/casbin/v2/util"
 	"github.com/pkg/errors"
 )
 
 type KompiGuard struct {
-	enforcer *casbin.Enforcer
+	enforcer   *casbin.Enforcer
+	attributes *attributes
+	superuser  *superuser
 }
 
+// New returns KompiGuard with default casbin model konst.CasbinModel
+// holders of role konst.SuperuserRoleUUID are superusers, see SetSuperuserRoles
 func New() (KompiGuard, error) {
-	m, err := model.NewModelFromString(CasbinModel)
+	return NewWithModel(CasbinModel)
+}
+
+// NewWithModel returns KompiGuard with custom casbin model, see ValidateModel for its requirements
+func NewWithModel(modelText string) (KompiGuard, error) {
+	m, err := parseModel(modelText)
 	if err != nil {
-		return KompiGuard{}, errors.Wrap(err, "model.NewModelFromString() failed")
+		return KompiGuard{}, errors.Wrap(err, "parseModel() failed")
 	}
 
 	enf, err := casbin.NewEnforcer(m)
@@ -26,7 +37,93 @@ func New() (KompiGuard, error) {
 		return KompiGuard{}, errors.Wrap(err, "casbin.NewEnforcer() failed")
 	}
 
-	return KompiGuard{enf}, nil
+	k := KompiGuard{
+		enforcer: enf,
+		attributes: &attributes{
+			expressions: map[string]*govaluate.EvaluableExpression{},
+		},
+		superuser: &superuser{
+			roles: []string{SuperuserRoleUUID},
+		},
+	}
+
+	enf.AddFunction("objectMatch", func(args ...interface{}) (interface{}, error) {
+		return ObjectMatch(args[0].(string), args[1].(string)), nil
+	})
+
+	enf.AddFunction("conditionMatch", func(args ...interface{}) (interface{}, error) {
+		params, _ := args[1].(map[string]interface{})
+		return k.conditionMatch(args[0].(string), params), nil
+	})
+
+	return k, nil
+}
+
+// ValidateModel checks, that custom casbin model can be used by KompiGuard
+// grants, overrides and role links are loaded by KompiGuard, so model must keep request, policy and role definitions of konst.CasbinModel
+// policy effect and matchers can be changed
+func ValidateModel(modelText string) error {
+	_, err := parseModel(modelText)
+	return err
+}
+
+// parseModel parses casbin model and checks its definitions
+func parseModel(modelText string) (model.Model, error) {
+	m, err := model.NewModelFromString(modelText)
+	if err != nil {
+		return nil, errors.Wrap(err, "model.NewModelFromString() failed")
+	}
+
+	// request and policy must have fields, that KompiGuard enforces and loads
+	for section, tokens := range map[string]int{
+		"r": 4, // sub, obj, act, env
+		"p": 5, // sub, obj, act, eft, cond
+	} {
+		if len(m[section][section].Tokens) != tokens {
+			return nil, fmt.Errorf("definition: %s of casbin model must have %d fields, got: %d", section, tokens, len(m[section][section].Tokens))
+		}
+	}
+
+	// roles, groups and their parents are loaded as links of role manager
+	if _, exists := m["g"]["g"]; !exists {
+		return nil, fmt.Errorf("casbin model is missing role definition: g = _, _")
+	}
+
+	return m, nil
+}
+
+// FieldObject returns casbin object of field on JSON pointer inside of asset with casbin object
+func FieldObject(object, pointer string) string {
+	return object + FieldObjectSeparator + pointer
+}
+
+// ObjectMatch checks if requested object is matched by object of policy
+// objects of assets are matched only by asset policies and objects of fields only by field policies,
+// so grant on whole asset does not grant its restricted fields and grant on field does not grant whole asset
+// asset part of object can use wildcards, field policy matches its field and all fields nested in it
+func ObjectMatch(requested, policy string) bool {
+	reqObject, reqPointer, reqIsField := splitFieldObject(requested)
+	polObject, polPointer, polIsField := splitFieldObject(policy)
+
+	if reqIsField != polIsField || !util.KeyMatch(reqObject, polObject) {
+		return false
+	}
+
+	if !reqIsField {
+		return true
+	}
+
+	return reqPointer == polPointer || strings.HasPrefix(reqPointer, polPointer+JPtrSeparator)
+}
+
+// splitFieldObject splits object to asset object and JSON pointer of field, isField is false for objects without field
+func splitFieldObject(object string) (assetObject, pointer string, isField bool) {
+	idx := strings.Index(object, FieldObjectSeparator)
+	if idx == -1 {
+		return object, "", false
+	}
+
+	return object[:idx], object[idx+len(FieldObjectSeparator):], true
 }
 
 // LoadRoles loads roles from identity asset into enforcer
@@ -46,6 +143,26 @@ func (k KompiGuard) LoadRoles(thisIdentity rmap.Rmap) error {
 		return fmt.Errorf("current identity: %s is not enabled", fp)
 	}
 
+	// identity is available to conditions of grants
+	k.attributes.identity = thisIdentity
+
+	subject, err := AssetGetID(thisIdentity)
+	if err != nil {
+		return errors.Wrap(err, "AssetGetID(thisIdentity) failed")
+	}
+
+	// roles inherited by more assigned roles are loaded once
+	loaded := map[string]struct{}{}
+
+	groups, err := GetIdentityGroups(thisIdentity)
+	if err != nil {
+		return errors.Wrap(err, "GetIdentityGroups() failed")
+	}
+
+	if err := k.loadGroups(subject, groups, loaded); err != nil {
+		return errors.Wrap(err, "k.loadGroups() failed")
+	}
+
 	if !thisIdentity.Exists(RolesKey) {
 		// identity does not define any roles, finished
 		return nil
@@ -56,11 +173,6 @@ func (k KompiGuard) LoadRoles(thisIdentity rmap.Rmap) error {
 		return errors.Wrap(err, "thisIdentity.GetJPtrIterable() failed")
 	}
 
-	subject, err := AssetGetID(thisIdentity)
-	if err != nil {
-		return errors.Wrap(err, "AssetGetID(thisIdentity) failed")
-	}
-
 	for _, roleI := range roleList {
 		// for each role assigned to user:
 		// load all grants from each role
@@ -75,92 +187,133 @@ func (k KompiGuard) LoadRoles(thisIdentity rmap.Rmap) error {
 			return errors.Wrap(err, "role.GetID() failed")
 		}
 
-		// add mapping of user-role to enforcer
-		_, err = k.enforcer.AddRoleForUser(subject, roleUUID)
+		// role assignment that is not valid at time of transaction is ignored with all its grants
+		roleValidity, err := GetRoleValidity(thisIdentity, roleUUID)
 		if err != nil {
-			return errors.Wrap(err, "k.enforcer.AddRoleForUser() failed")
+			return errors.Wrap(err, "GetRoleValidity() failed")
+		}
+
+		isValid, err := k.isValid(roleValidity)
+		if err != nil {
+			return errors.Wrapf(err, "k.isValid() failed for role: %s", roleUUID)
 		}
 
-		if !role.Exists(GrantsKey) {
-			// role does not have grants key, finished
+		if !isValid {
 			continue
 		}
 
-		grantsList, err := role.GetIterable(GrantsKey)
+		// add mapping of user-role to enforcer
+		_, err = k.enforcer.AddRoleForUser(subject, roleUUID)
 		if err != nil {
-			return errors.Wrap(err, "role.GetJPtrIterable() failed")
+			return errors.Wrap(err, "k.enforcer.AddRoleForUser() failed")
 		}
 
-		for _, grantI := range grantsList {
-			grant, err := rmap.NewFromInterface(grantI)
-			if err != nil {
-				return errors.Wrap(err, "NewFromInterface() failed")
-			}
-
-			object, err := grant.GetString(ObjectKey)
-			if err != nil {
-				return errors.Wrap(err, "grant.GetJPtrString() failed")
-			}
-
-			action, err := grant.GetString(ActionKey)
-			if err != nil {
-				return errors.Wrap(err, "grant.GetJPtrString() failed")
-			}
-
-			// add every grant to Enforcer
-			_, err = k.enforcer.AddPermissionForUser(roleUUID, object, action, "allow")
-			if err != nil {
-				return errors.Wrap(err, "k.enforcer.AddPermissionForUser() failed")
-			}
+		// load grants of role and all roles it inherits from
+		if err := walkRoles(role, loaded, k.loadRole); err != nil {
+			return errors.Wrap(err, "walkRoles() failed")
 		}
 	}
 
 	return nil
 }
 
-// Load overrides from asset into enforcer
-func (k KompiGuard) loadOverrides(asset rmap.Rmap) error {
+// override is policy of asset, that allows or denies action on asset or its field to subject
+type override struct {
+	subject string
+	object  string
+	action  string
+	effect  string
+}
+
+// getOverrides parses overrides defined on asset
+func getOverrides(asset rmap.Rmap) ([]override, error) {
 	if !asset.Exists(OverridesKey) {
 		// no overrides defined on asset, finished
-		return nil
+		return nil, nil
 	}
 
 	object, err := AssetGetCasbinObject(asset)
 	if err != nil {
-		return errors.Wrap(err, "asset.GetCasbinObject() failed")
+		return nil, errors.Wrap(err, "asset.GetCasbinObject() failed")
 	}
 
 	overridesList, err := asset.GetIterable(OverridesKey)
 	if err != nil {
-		return errors.Wrap(err, "asset.GetJPtrIterable() failed")
+		return nil, errors.Wrap(err, "asset.GetJPtrIterable() failed")
 	}
 
+	overrides := make([]override, 0, len(overridesList))
 	for _, overrideI := range overridesList {
-		override, err := rmap.NewFromInterface(overrideI)
+		ovr, err := rmap.NewFromInterface(overrideI)
+		if err != nil {
+			return nil, errors.Wrap(err, "NewFromInterface() failed")
+		}
+
+		subject, err := ovr.GetString(SubjectKey)
 		if err != nil {
-			return errors.Wrap(err, "NewFromInterface() failed")
+			return nil, errors.Wrap(err, "override.GetJPtrString() failed")
 		}
 
-		subject, err := override.GetString(SubjectKey)
+		action, err := ovr.GetString(ActionKey)
 		if err != nil {
-			return errors.Wrap(err, "override.GetJPtrString() failed")
+			return nil, errors.Wrap(err, "override.GetJPtrString() failed")
 		}
-		subject = strings.ToLower(subject)
 
-		action, err := override.GetString(ActionKey)
+		effect, err := ovr.GetString(EffectKey)
 		if err != nil {
-			return errors.Wrap(err, "override.GetJPtrString() failed")
+			return nil, errors.Wrap(err, "override.GetJPtrString() failed")
 		}
-		action = strings.ToLower(action)
 
-		effect, err := override.GetString(EffectKey)
+		// override with pointer targets restricted field instead of whole asset
+		overrideObject := object
+		if ovr.Exists(PointerKey) {
+			pointer, err := ovr.GetString(PointerKey)
+			if err != nil {
+				return nil, errors.Wrap(err, "override.GetJPtrString() failed")
+			}
+			overrideObject = FieldObject(object, pointer)
+		}
+
+		overrides = append(overrides, override{
+			subject: strings.ToLower(subject),
+			object:  overrideObject,
+			action:  strings.ToLower(action),
+			effect:  strings.ToLower(effect),
+		})
+	}
+
+	return overrides, nil
+}
+
+// Load overrides from asset into enforcer
+// returns overrides, that were not present in enforcer before, these must be removed by unloadOverrides after asset is evaluated
+func (k KompiGuard) loadOverrides(asset rmap.Rmap) ([]override, error) {
+	overrides, err := getOverrides(asset)
+	if err != nil {
+		return nil, errors.Wrap(err, "getOverrides() failed")
+	}
+
+	loaded := make([]override, 0, len(overrides))
+	for _, ovr := range overrides {
+		added, err := k.enforcer.AddPermissionForUser(ovr.subject, ovr.object, ovr.action, ovr.effect, "")
 		if err != nil {
-			return errors.Wrap(err, "override.GetJPtrString() failed")
+			return loaded, errors.Wrap(err, "k.enforcer.AddPermissionForUser() failed")
+		}
+
+		if added {
+			loaded = append(loaded, ovr)
 		}
-		effect = strings.ToLower(effect)
+	}
+
+	return loaded, nil
+}
 
-		if _, err := k.enforcer.AddPermissionForUser(subject, object, action, effect); err != nil {
-			return errors.Wrap(err, "k.enforcer.AddPermissionForUser() failed")
+// unloadOverrides removes overrides of asset from enforcer, so they do not affect evaluation of other assets
+// policy of roles stays loaded and enforcer can be reused for any number of assets
+func (k KompiGuard) unloadOverrides(overrides []override) error {
+	for _, ovr := range overrides {
+		if _, err := k.enforcer.DeletePermissionForUser(ovr.subject, ovr.object, ovr.action, ovr.effect, ""); err != nil {
+			return errors.Wrap(err, "k.enforcer.DeletePermissionForUser() failed")
 		}
 	}
 
@@ -188,37 +341,66 @@ func (k KompiGuard) EnforceAsset(asset, thisIdentity rmap.Rmap, action string) (
 	return k.EnforceCustom(object, subject, action, &asset)
 }
 
+// EnforceField checks if action is allowed on field on JSON pointer in asset for subject
+// roles must be already loaded, overrides are loaded from asset, if present
+func (k KompiGuard) EnforceField(asset rmap.Rmap, subject, pointer, action string) (bool, string, error) {
+	object, err := AssetGetCasbinObject(asset)
+	if err != nil {
+		return false, "", errors.Wrap(err, "AssetGetCasbinObject(asset) failed")
+	}
+
+	return k.EnforceCustom(FieldObject(object, pointer), subject, action, &asset)
+}
+
 // EnforceCustom checks if enforcer allows action on object for subject
-// if asset argument is present, overrides are loaded from it
+// if asset argument is present, overrides are loaded from it and its fields are available to conditions of grants
 // returns (granted, reason, error)
 // granted - true or false if operation was granted
 // reason - if granted == false && err != nil then it contains which permission is required for action to be granted
 // error - some other error has occurred
-func (k KompiGuard) EnforceCustom(object, subject, action string, asset *rmap.Rmap) (bool, string, error) {
+func (k KompiGuard) EnforceCustom(object, subject, action string, asset *rmap.Rmap) (granted bool, reason string, err error) {
 	action = strings.ToLower(action)
 
 	if asset != nil {
-		if err := k.loadOverrides(*asset); err != nil {
-			return false, "", errors.Wrap(err, "k.loadOverrides() failed")
+		overrides, loadErr := k.loadOverrides(*asset)
+		defer func() {
+			// overrides are evaluated in isolation, enforcer is left with policy of roles only
+			if unloadErr := k.unloadOverrides(overrides); unloadErr != nil && err == nil {
+				granted, reason, err = false, "", errors.Wrap(unloadErr, "k.unloadOverrides() failed")
+			}
+		}()
+
+		if loadErr != nil {
+			return false, "", errors.Wrap(loadErr, "k.loadOverrides() failed")
 		}
 	}
 
+	params := k.conditionParameters(asset)
+
 	// try enforcing with mixed case object
-	granted, err := k.enforcer.Enforce(subject, object, action)
+	granted, err = k.enforcer.Enforce(subject, object, action, params)
 	if err != nil {
 		return false, "", errors.Wrap(err, "k.enforcer.Enforce() failed")
 	}
 
 	if !granted {
 		// try enforcing with lowercase object for backwards compatibility with existing objects
-		grantedLower, err := k.enforcer.Enforce(subject, strings.ToLower(object), action)
+		grantedLower, err := k.enforcer.Enforce(subject, strings.ToLower(object), action, params)
 		if err != nil {
 			return false, "", errors.Wrap(err, "k.enforcer.Enforce() failed")
 		}
 
 		if !grantedLower {
-			// still denied, return error with original mixed case
-			return false, fmt.Sprintf("permission denied, sub: %s, obj: %s, act: %s", subject, object, action), nil
+			// superuser is granted also actions, that are not granted by policy, but not actions denied by policy
+			grantedSU, err := k.enforceSuperuser(subject, object, action, params)
+			if err != nil {
+				return false, "", errors.Wrap(err, "k.enforceSuperuser() failed")
+			}
+
+			if !grantedSU {
+				// still denied, return error with original mixed case
+				return false, fmt.Sprintf("permission denied, sub: %s, obj: %s, act: %s", subject, object, action), nil
+			}
 		}
 	}
 
@@ -238,6 +420,11 @@ func (k KompiGuard) FilterAssets(input []rmap.Rmap, identityAsset rmap.Rmap, act
 		return nil, errors.Wrap(err, "AssetGetID() failed")
 	}
 
+	return k.FilterAssetsOfSubject(input, subject, action)
+}
+
+// FilterAssetsOfSubject works the same way as FilterAssets, but roles of subject must be already loaded
+func (k KompiGuard) FilterAssetsOfSubject(input []rmap.Rmap, subject, action string) ([]rmap.Rmap, error) {
 	output := make([]rmap.Rmap, 0, len(input))
 	for _, asset := range input {
 		object, err := AssetGetCasbinObject(asset)
@@ -287,8 +474,8 @@ func (k KompiGuard) FilterAssets(input []rmap.Rmap, identityAsset rmap.Rmap, act
 			}
 
 			insertee = rmap.NewFromMap(map[string]interface{}{
-				idKey:   id,
-				"error": reason,
+				idKey:       id,
+				FilteredKey: reason,
 			})
 		}
 
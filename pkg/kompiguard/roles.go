@@ -0,0 +1,279 @@
+package kompiguard
+
+import (
+	"fmt"
+	"time"
+
+	. "github.com/KompiTech/fabric-cc-core/v2/pkg/konst"
+	"github.com/KompiTech/rmap"
+	"github.com/pkg/errors"
+)
+
+// GetRoleParents returns parent roles of role
+// parent that is not resolved is returned only with docType and UUID, so it has no grants and no parents
+func GetRoleParents(role rmap.Rmap) ([]rmap.Rmap, error) {
+	return getRoleRefs(role, ParentsKey)
+}
+
+// GetGroupRoles returns roles assigned to group
+// role that is not resolved is returned only with docType and UUID, so it has no grants and no parents
+func GetGroupRoles(group rmap.Rmap) ([]rmap.Rmap, error) {
+	return getRoleRefs(group, GroupRolesKey)
+}
+
+// GetIdentityGroups returns groups, that resolved identity is member of
+func GetIdentityGroups(identity rmap.Rmap) ([]rmap.Rmap, error) {
+	if !identity.Exists(IdentityMemberOfKey) {
+		return nil, nil
+	}
+
+	groups, err := identity.GetIterableRmap(IdentityMemberOfKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "identity.GetIterableRmap() failed")
+	}
+
+	return groups, nil
+}
+
+// getRoleRefs returns roles referenced by key of asset, that can be resolved or not
+func getRoleRefs(asset rmap.Rmap, key string) ([]rmap.Rmap, error) {
+	if !asset.Exists(key) {
+		return nil, nil
+	}
+
+	refs, err := asset.GetIterable(key)
+	if err != nil {
+		return nil, errors.Wrap(err, "asset.GetIterable() failed")
+	}
+
+	roles := make([]rmap.Rmap, 0, len(refs))
+	for _, refI := range refs {
+		switch ref := refI.(type) {
+		case string:
+			roles = append(roles, rmap.NewFromMap(map[string]interface{}{
+				AssetDocTypeKey: RoleAssetName,
+				AssetIdKey:      ref,
+			}))
+		case map[string]interface{}:
+			roles = append(roles, rmap.NewFromMap(ref))
+		default:
+			return nil, fmt.Errorf("unexpected type of role reference: %T", refI)
+		}
+	}
+
+	return roles, nil
+}
+
+// walkRoles calls fn for role and for all its parent roles recursively
+// visited contains UUIDs of already visited roles, so role inherited by more roles is visited once and cycle does not cause infinite recursion
+func walkRoles(role rmap.Rmap, visited map[string]struct{}, fn func(role rmap.Rmap) error) error {
+	roleUUID, err := AssetGetID(role)
+	if err != nil {
+		return errors.Wrap(err, "AssetGetID(role) failed")
+	}
+
+	if _, isVisited := visited[roleUUID]; isVisited {
+		return nil
+	}
+	visited[roleUUID] = struct{}{}
+
+	if err := fn(role); err != nil {
+		return err
+	}
+
+	parents, err := GetRoleParents(role)
+	if err != nil {
+		return errors.Wrap(err, "GetRoleParents() failed")
+	}
+
+	for _, parent := range parents {
+		if err := walkRoles(parent, visited, fn); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// EffectiveRoles returns roles assigned to identity, that are valid at time now, and roles of groups identity is member of,
+// together with all roles they inherit from
+// identity must be resolved including parents of roles and groups, every role is returned once
+func EffectiveRoles(identity rmap.Rmap, now time.Time) ([]rmap.Rmap, error) {
+	roles := []rmap.Rmap{}
+	visited := map[string]struct{}{}
+
+	roleList, err := getRoleRefs(identity, RolesKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "getRoleRefs() failed")
+	}
+
+	for _, role := range roleList {
+		roleUUID, err := AssetGetID(role)
+		if err != nil {
+			return nil, errors.Wrap(err, "AssetGetID(role) failed")
+		}
+
+		validity, err := GetRoleValidity(identity, roleUUID)
+		if err != nil {
+			return nil, errors.Wrap(err, "GetRoleValidity() failed")
+		}
+
+		isValid, err := IsValidAt(validity, now)
+		if err != nil {
+			return nil, errors.Wrapf(err, "IsValidAt() failed for role: %s", roleUUID)
+		}
+
+		if !isValid {
+			continue
+		}
+
+		if err := walkRoles(role, visited, func(role rmap.Rmap) error {
+			roles = append(roles, role)
+			return nil
+		}); err != nil {
+			return nil, errors.Wrap(err, "walkRoles() failed")
+		}
+	}
+
+	groups, err := GetIdentityGroups(identity)
+	if err != nil {
+		return nil, errors.Wrap(err, "GetIdentityGroups() failed")
+	}
+
+	for _, group := range groups {
+		groupRoles, err := GetGroupRoles(group)
+		if err != nil {
+			return nil, errors.Wrap(err, "GetGroupRoles() failed")
+		}
+
+		for _, role := range groupRoles {
+			if err := walkRoles(role, visited, func(role rmap.Rmap) error {
+				roles = append(roles, role)
+				return nil
+			}); err != nil {
+				return nil, errors.Wrap(err, "walkRoles() failed")
+			}
+		}
+	}
+
+	return roles, nil
+}
+
+// loadRole loads grants of role and links to its parent roles into enforcer
+// grants of parent roles are inherited transitively through links of casbin role manager
+func (k KompiGuard) loadRole(role rmap.Rmap) error {
+	roleUUID, err := AssetGetID(role)
+	if err != nil {
+		return errors.Wrap(err, "role.GetID() failed")
+	}
+
+	parents, err := GetRoleParents(role)
+	if err != nil {
+		return errors.Wrap(err, "GetRoleParents() failed")
+	}
+
+	for _, parent := range parents {
+		parentUUID, err := AssetGetID(parent)
+		if err != nil {
+			return errors.Wrap(err, "parent.GetID() failed")
+		}
+
+		// add mapping of role-parent to enforcer
+		if _, err := k.enforcer.AddRoleForUser(roleUUID, parentUUID); err != nil {
+			return errors.Wrap(err, "k.enforcer.AddRoleForUser() failed")
+		}
+	}
+
+	if !role.Exists(GrantsKey) {
+		// role does not have grants key, finished
+		return nil
+	}
+
+	grantsList, err := role.GetIterable(GrantsKey)
+	if err != nil {
+		return errors.Wrap(err, "role.GetJPtrIterable() failed")
+	}
+
+	for _, grantI := range grantsList {
+		grant, err := rmap.NewFromInterface(grantI)
+		if err != nil {
+			return errors.Wrap(err, "NewFromInterface() failed")
+		}
+
+		// grant that is not valid at time of transaction is ignored
+		isValid, err := k.isValid(grant)
+		if err != nil {
+			return errors.Wrapf(err, "k.isValid() failed for grant of role: %s", roleUUID)
+		}
+
+		if !isValid {
+			continue
+		}
+
+		object, err := grant.GetString(ObjectKey)
+		if err != nil {
+			return errors.Wrap(err, "grant.GetJPtrString() failed")
+		}
+
+		action, err := grant.GetString(ActionKey)
+		if err != nil {
+			return errors.Wrap(err, "grant.GetJPtrString() failed")
+		}
+
+		// grant without condition is unconditional
+		condition := ""
+		if grant.Exists(ConditionKey) {
+			condition, err = grant.GetString(ConditionKey)
+			if err != nil {
+				return errors.Wrap(err, "grant.GetJPtrString() failed")
+			}
+		}
+
+		// add every grant to Enforcer
+		_, err = k.enforcer.AddPermissionForUser(roleUUID, object, action, AllowEffect, condition)
+		if err != nil {
+			return errors.Wrap(err, "k.enforcer.AddPermissionForUser() failed")
+		}
+	}
+
+	return nil
+}
+
+// loadGroups loads membership of subject in groups and roles of these groups into enforcer
+// overrides with UUID of group as subject apply to all members through this membership
+func (k KompiGuard) loadGroups(subject string, groups []rmap.Rmap, loaded map[string]struct{}) error {
+	for _, group := range groups {
+		groupUUID, err := AssetGetID(group)
+		if err != nil {
+			return errors.Wrap(err, "group.GetID() failed")
+		}
+
+		// add mapping of user-group to enforcer
+		if _, err := k.enforcer.AddRoleForUser(subject, groupUUID); err != nil {
+			return errors.Wrap(err, "k.enforcer.AddRoleForUser() failed")
+		}
+
+		roles, err := GetGroupRoles(group)
+		if err != nil {
+			return errors.Wrap(err, "GetGroupRoles() failed")
+		}
+
+		for _, role := range roles {
+			roleUUID, err := AssetGetID(role)
+			if err != nil {
+				return errors.Wrap(err, "role.GetID() failed")
+			}
+
+			// add mapping of group-role to enforcer
+			if _, err := k.enforcer.AddRoleForUser(groupUUID, roleUUID); err != nil {
+				return errors.Wrap(err, "k.enforcer.AddRoleForUser() failed")
+			}
+
+			if err := walkRoles(role, loaded, k.loadRole); err != nil {
+				return errors.Wrap(err, "walkRoles() failed")
+			}
+		}
+	}
+
+	return nil
+}
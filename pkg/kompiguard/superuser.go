@@ -0,0 +1,95 @@
+package kompiguard
+
+import (
+	"strings"
+
+	. "github.com/KompiTech/fabric-cc-core/v2/pkg/konst"
+	"github.com/pkg/errors"
+)
+
+// AuditFunc is called, when action on object is granted to subject only because it holds superuser role
+type AuditFunc func(subject, role, object, action string) error
+
+// superuser configures superuser shortcut, it is shared by all copies of KompiGuard
+type superuser struct {
+	roles []string  // UUIDs of roles, whose holders are granted every action
+	audit AuditFunc // called on every use of superuser shortcut (break-glass), can be nil
+}
+
+// SetSuperuserRoles sets UUIDs of roles, whose holders are granted every action, that is not granted by grants and overrides
+// superuser role can be held directly, through parent roles or through groups, empty roles disable superuser shortcut
+func (k KompiGuard) SetSuperuserRoles(roles []string) {
+	k.superuser.roles = roles
+}
+
+// SetSuperuserAudit sets func called on every use of superuser shortcut, error returned by it fails the check
+func (k KompiGuard) SetSuperuserAudit(audit AuditFunc) {
+	k.superuser.audit = audit
+}
+
+// SuperuserRole returns UUID of superuser role held by subject, empty string is returned when subject is not superuser
+// roles of subject must be already loaded
+func (k KompiGuard) SuperuserRole(subject string) (string, error) {
+	roleManager := k.enforcer.GetRoleManager()
+
+	for _, role := range k.superuser.roles {
+		isSU, err := roleManager.HasLink(subject, role)
+		if err != nil {
+			return "", errors.Wrap(err, "roleManager.HasLink() failed")
+		}
+
+		if isSU {
+			return role, nil
+		}
+	}
+
+	return "", nil
+}
+
+// deniedByPolicy checks if action on object is denied for subject by policy with deny effect (override), not just missing grant
+// both mixed case and lowercase object are checked, as in EnforceCustom
+func (k KompiGuard) deniedByPolicy(subject, object, action string, params map[string]interface{}) (bool, error) {
+	for _, obj := range []string{object, strings.ToLower(object)} {
+		granted, policy, err := k.enforcer.EnforceEx(subject, obj, action, params)
+		if err != nil {
+			return false, errors.Wrap(err, "k.enforcer.EnforceEx() failed")
+		}
+
+		// with deny-override effect, denied result reports policy only when it was decided by deny
+		if !granted && len(policy) > 3 && policy[3] == DenyEffect {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// enforceSuperuser grants action not granted by policy, when subject is superuser, and reports this use of superuser shortcut to audit
+// action denied by override applying to subject stays denied also for superuser
+func (k KompiGuard) enforceSuperuser(subject, object, action string, params map[string]interface{}) (bool, error) {
+	role, err := k.SuperuserRole(subject)
+	if err != nil {
+		return false, errors.Wrap(err, "k.SuperuserRole() failed")
+	}
+
+	if role == "" {
+		return false, nil
+	}
+
+	denied, err := k.deniedByPolicy(subject, object, action, params)
+	if err != nil {
+		return false, errors.Wrap(err, "k.deniedByPolicy() failed")
+	}
+
+	if denied {
+		return false, nil
+	}
+
+	if k.superuser.audit != nil {
+		if err := k.superuser.audit(subject, role, object, action); err != nil {
+			return false, errors.Wrap(err, "audit of superuser failed")
+		}
+	}
+
+	return true, nil
+}
@@ -0,0 +1,72 @@
+package kompiguard
+
+import (
+	"time"
+
+	. "github.com/KompiTech/fabric-cc-core/v2/pkg/konst"
+	"github.com/KompiTech/rmap"
+	"github.com/pkg/errors"
+)
+
+// SetTime sets time of transaction, that is used to check validity of grants and role assignments
+// if it is not set, all grants and role assignments with validity are ignored
+func (k KompiGuard) SetTime(now time.Time) {
+	k.attributes.now = now
+}
+
+// IsValidAt checks if entry (grant or role assignment) with optional valid_from and valid_until RFC 3339 timestamps is valid at time now
+// valid_from is inclusive, valid_until is exclusive, entry with any timestamp is not valid at zero time
+func IsValidAt(entry rmap.Rmap, now time.Time) (bool, error) {
+	for _, key := range []string{ValidFromKey, ValidUntilKey} {
+		if !entry.Exists(key) {
+			continue
+		}
+
+		if now.IsZero() {
+			return false, nil
+		}
+
+		value, err := entry.GetString(key)
+		if err != nil {
+			return false, errors.Wrap(err, "entry.GetString() failed")
+		}
+
+		bound, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return false, errors.Wrapf(err, "invalid timestamp in key: %s", key)
+		}
+
+		if key == ValidFromKey && now.Before(bound) {
+			return false, nil
+		}
+
+		if key == ValidUntilKey && !now.Before(bound) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// GetRoleValidity returns validity of assignment of role to identity, it is empty if assignment is not time-bounded
+func GetRoleValidity(identity rmap.Rmap, roleUUID string) (rmap.Rmap, error) {
+	if !identity.Exists(IdentityRoleValidityKey) {
+		return rmap.NewEmpty(), nil
+	}
+
+	validities, err := identity.GetRmap(IdentityRoleValidityKey)
+	if err != nil {
+		return rmap.Rmap{}, errors.Wrap(err, "identity.GetRmap() failed")
+	}
+
+	if !validities.Exists(roleUUID) {
+		return rmap.NewEmpty(), nil
+	}
+
+	return validities.GetRmap(roleUUID)
+}
+
+// isValid checks if entry is valid at time of transaction
+func (k KompiGuard) isValid(entry rmap.Rmap) (bool, error) {
+	return IsValidAt(entry, k.attributes.now)
+}
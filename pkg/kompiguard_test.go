@@ -1,11 +1,32 @@
 package cc_core
 
 import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/KompiTech/fabric-cc-core/v2/internal/testdata"
+	"github.com/KompiTech/fabric-cc-core/v2/pkg/engine"
+	"github.com/KompiTech/fabric-cc-core/v2/pkg/konst"
 	. "github.com/KompiTech/fabric-cc-core/v2/pkg/testing"
 	"github.com/KompiTech/rmap"
 	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
 )
 
+// denyingAuthorizer denies read of mockincident and leaves all other decisions to default Authorizer
+type denyingAuthorizer struct {
+	engine.KompiGuardAuthorizer
+}
+
+func (a denyingAuthorizer) EnforceAsset(ctx engine.ContextInterface, identity, asset rmap.Rmap, action string) (bool, string, error) {
+	if docType, _ := konst.AssetGetDocType(asset); strings.EqualFold(docType, "mockincident") && action == konst.ReadAction {
+		return false, "denied by custom authorizer", nil
+	}
+
+	return a.KompiGuardAuthorizer.EnforceAsset(ctx, identity, asset, action)
+}
+
 var _ = Describe("kompiguard", func() {
 	var tctx *TestContext
 
@@ -93,4 +114,802 @@ var _ = Describe("kompiguard", func() {
 			tctx.Ok("functionInvoke", "MockFunc", rmap.NewEmpty().Bytes())
 		})
 	})
+
+	Describe("when grants have conditions", func() {
+		var requestUUID, otherUUID string
+
+		grantRole := func(grants []map[string]interface{}) {
+			role := rmap.NewFromMap(map[string]interface{}{
+				"name":   "Conditional reader",
+				"grants": grants,
+			})
+			roleUUID := MustGetID(tctx.Rmap("assetCreate", "role", role.Bytes(), -1, ""))
+			tctx.Ok("assetUpdate", "identity", tctx.GetActorFingerprint("ordinaryUser"), rmap.NewFromMap(map[string]interface{}{"roles": []string{roleUUID}}).Bytes())
+			tctx.SetActor("ordinaryUser")
+		}
+
+		BeforeEach(func() {
+			requestUUID = MustGetID(tctx.Rmap("assetCreate", "mockrequest", rmap.NewFromMap(map[string]interface{}{"number": "1234"}).Bytes(), -1, ""))
+			otherUUID = MustGetID(tctx.Rmap("assetCreate", "mockrequest", rmap.NewFromMap(map[string]interface{}{"number": "5678"}).Bytes(), -1, ""))
+		})
+
+		It("Should grant action only on assets matching condition on asset fields", func() {
+			grantRole([]map[string]interface{}{{
+				"object":    "/mockrequest/*",
+				"action":    "read",
+				"condition": "asset.number == '1234'",
+			}})
+
+			tctx.Ok("assetGet", "mockrequest", requestUUID, false, rmap.NewEmpty().Bytes())
+			tctx.Error("permission denied", "assetGet", "mockrequest", otherUUID, false, rmap.NewEmpty().Bytes())
+
+			result := tctx.RmapNoResult("assetQuery", "mockrequest", rmap.NewEmpty().Bytes(), false)
+			Expect(result.Mapa).To(HaveKeyWithValue(konst.OutputResultKey, ConsistOf(
+				HaveKeyWithValue("number", "1234"),
+				And(HaveKeyWithValue(konst.AssetIdKey, otherUUID), HaveKey("error")),
+			)))
+		})
+
+		It("Should evaluate conditions on identity and certificate attributes", func() {
+			grantRole([]map[string]interface{}{{
+				"object":    "/mockrequest/*",
+				"action":    "read",
+				"condition": "cert.mspid == 'SOME_MSP' && identity.fingerprint == '" + tctx.GetActorFingerprint("ordinaryUser") + "'",
+			}, {
+				"object":    "/mockrequest/*",
+				"action":    "update",
+				"condition": "cert.mspid == 'OTHER_MSP'",
+			}})
+
+			tctx.Ok("assetGet", "mockrequest", otherUUID, false, rmap.NewEmpty().Bytes())
+			tctx.Error("permission denied", "assetUpdate", "mockrequest", otherUUID, rmap.NewFromMap(map[string]interface{}{"number": "9999"}).Bytes())
+		})
+
+		It("Should not match condition using missing field", func() {
+			grantRole([]map[string]interface{}{{
+				"object":    "/mockrequest/*",
+				"action":    "read",
+				"condition": "asset.missing.key == 'value'",
+			}})
+
+			tctx.Error("permission denied", "assetGet", "mockrequest", requestUUID, false, rmap.NewEmpty().Bytes())
+		})
+
+		It("Should reject role with malformed condition", func() {
+			role := rmap.NewFromMap(map[string]interface{}{
+				"name": "Broken",
+				"grants": []interface{}{map[string]interface{}{
+					"object": "/mockrequest/*",
+					"action": "read",
+				}, map[string]interface{}{
+					"object":    "/mockrequest/*",
+					"action":    "read",
+					"condition": "asset.number ==",
+				}},
+			})
+
+			errJSON := tctx.ErrorJSON("invalid condition of grant", "assetCreate", "role", role.Bytes(), -1, "")
+			Expect(errJSON.MustGetJPtr("/" + konst.ErrorDetailsKey + "/" + konst.ErrorFieldsKey)).To(ConsistOf(
+				HaveKeyWithValue(konst.ValidationPointerKey, "/grants/1/condition"),
+			))
+
+			role.MustSetJPtr("/grants/1/condition", "unknown == 1")
+			tctx.Error("unknown variable: unknown", "assetCreate", "role", role.Bytes(), -1, "")
+
+			role.MustSetJPtr("/grants/1/condition", "asset.number == 'identity.fingerprint'")
+			roleUUID := MustGetID(tctx.Rmap("assetCreate", "role", role.Bytes(), -1, ""))
+
+			role.MustSetJPtr("/grants/1/condition", "(asset.number")
+			tctx.Error("invalid condition of grant", "assetUpdate", "role", roleUUID, role.Bytes())
+		})
+	})
+
+	Describe("when grants and role assignments are time-bounded", func() {
+		var now time.Time
+		var requestUUID, roleUUID string
+
+		BeforeEach(func() {
+			now = time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+			tctx.SetTime(now)
+
+			requestUUID = MustGetID(tctx.Rmap("assetCreate", "mockrequest", rmap.NewFromMap(map[string]interface{}{"number": "1234"}).Bytes(), -1, ""))
+
+			role := rmap.NewFromMap(map[string]interface{}{
+				"name": "Temporary access",
+				"grants": []map[string]interface{}{{
+					"object":            "/mockrequest/*",
+					"action":            "read",
+					konst.ValidUntilKey: now.Add(time.Hour).Format(time.RFC3339),
+				}, {
+					"object":           "/function/invoke/MockFunc",
+					"action":           "execute",
+					konst.ValidFromKey: now.Add(time.Hour).Format(time.RFC3339),
+				}},
+			})
+			roleUUID = MustGetID(tctx.Rmap("assetCreate", "role", role.Bytes(), -1, ""))
+			tctx.Ok("assetUpdate", "identity", tctx.GetActorFingerprint("ordinaryUser"), rmap.NewFromMap(map[string]interface{}{"roles": []string{roleUUID}}).Bytes())
+		})
+
+		AfterEach(func() {
+			tctx.ResetTime()
+		})
+
+		It("Should ignore grants that are not valid at transaction time", func() {
+			tctx.SetActor("ordinaryUser")
+			tctx.Ok("assetGet", "mockrequest", requestUUID, false, rmap.NewEmpty().Bytes())
+			tctx.Error("permission denied", "functionInvoke", "MockFunc", rmap.NewEmpty().Bytes())
+
+			tctx.TravelInTime(3600)
+			tctx.Error("permission denied", "assetGet", "mockrequest", requestUUID, false, rmap.NewEmpty().Bytes())
+			tctx.Ok("functionInvoke", "MockFunc", rmap.NewEmpty().Bytes())
+		})
+
+		It("Should ignore role assignments that are not valid at transaction time", func() {
+			validity := rmap.NewFromMap(map[string]interface{}{
+				konst.IdentityRoleValidityKey: map[string]interface{}{
+					roleUUID: map[string]interface{}{
+						konst.ValidUntilKey: now.Add(30 * time.Minute).Format(time.RFC3339),
+					},
+				},
+			})
+			tctx.Ok("assetUpdate", "identity", tctx.GetActorFingerprint("ordinaryUser"), validity.Bytes())
+
+			tctx.SetActor("ordinaryUser")
+			tctx.Ok("assetGet", "mockrequest", requestUUID, false, rmap.NewEmpty().Bytes())
+
+			myAccess := tctx.Rmap("functionQuery", "myAccess", rmap.NewEmpty().Bytes())
+			Expect(myAccess.Mapa).To(HaveKeyWithValue("expiring", Equal([]interface{}{
+				map[string]interface{}{
+					"role":              roleUUID,
+					konst.ValidUntilKey: now.Add(30 * time.Minute).Format(time.RFC3339),
+				},
+				map[string]interface{}{
+					"role":              roleUUID,
+					"object":            "/mockrequest/*",
+					"action":            "read",
+					konst.ValidUntilKey: now.Add(time.Hour).Format(time.RFC3339),
+				},
+			})))
+
+			tctx.TravelInTime(1800)
+			tctx.Error("permission denied", "assetGet", "mockrequest", requestUUID, false, rmap.NewEmpty().Bytes())
+			Expect(tctx.Rmap("functionQuery", "myAccess", rmap.NewEmpty().Bytes()).Mapa).To(HaveKeyWithValue("expiring", BeEmpty()))
+		})
+
+		It("Should allow only superuser to change validity of superuser role assignment", func() {
+			tctx.TravelInTime(1)
+			manager := rmap.NewFromMap(map[string]interface{}{
+				"name": "Identity manager",
+				"grants": []map[string]interface{}{{
+					"object": "/identity/*",
+					"action": "update",
+				}},
+			})
+			managerUUID := MustGetID(tctx.Rmap("assetCreate", "role", manager.Bytes(), -1, ""))
+			tctx.Ok("assetUpdate", "identity", tctx.GetActorFingerprint("ordinaryUser"), rmap.NewFromMap(map[string]interface{}{"roles": []string{roleUUID, managerUUID}}).Bytes())
+
+			validUntil := func(until time.Time) []byte {
+				return rmap.NewFromMap(map[string]interface{}{
+					konst.IdentityRoleValidityKey: map[string]interface{}{
+						konst.SuperuserRoleUUID: map[string]interface{}{
+							konst.ValidUntilKey: until.Format(time.RFC3339),
+						},
+					},
+				}).Bytes()
+			}
+
+			nobodyFP := tctx.GetActorFingerprint("nobodyUser")
+			tctx.Ok("assetUpdate", "identity", nobodyFP, rmap.NewFromMap(map[string]interface{}{"roles": []string{konst.SuperuserRoleUUID}}).Bytes())
+			tctx.Ok("assetUpdate", "identity", nobodyFP, validUntil(now.Add(-time.Minute)))
+
+			tctx.SetActor("ordinaryUser")
+			tctx.Error("to manage SuperUser role, you must have it granted", "assetUpdate", "identity", nobodyFP, validUntil(now.Add(time.Hour)))
+
+			tctx.SetActor("superUser")
+			tctx.Ok("assetUpdate", "identity", nobodyFP, validUntil(now.Add(time.Hour)))
+
+			tctx.SetActor("ordinaryUser")
+			tctx.Error("to manage SuperUser role, you must have it granted", "assetUpdate", "identity", nobodyFP, validUntil(now.Add(2*time.Hour)))
+			tctx.Error("to manage SuperUser role, you must have it granted", "assetUpdate", "identity", nobodyFP, validUntil(now.Add(-time.Minute)))
+
+			// expired assignment does not count as superuser
+			tctx.SetActor("superUser")
+			tctx.Ok("assetUpdate", "identity", nobodyFP, validUntil(now.Add(-time.Minute)))
+			tctx.Error("unable to remove last superuser role", "assetUpdate", "identity", tctx.GetActorFingerprint("superUser"), validUntil(now.Add(-time.Minute)))
+		})
+
+		It("Should reject invalid timestamps", func() {
+			role := rmap.NewFromMap(map[string]interface{}{
+				"name": "Broken",
+				"grants": []map[string]interface{}{{
+					"object":            "/mockrequest/*",
+					"action":            "read",
+					konst.ValidUntilKey: "tomorrow",
+				}},
+			})
+			tctx.Error("asset.ValidateSchema() failed on assetName: role", "assetCreate", "role", role.Bytes(), -1, "")
+		})
+	})
+
+	Describe("when explaining access", func() {
+		var requestUUID, roleUUID, ordinaryFP string
+
+		explain := func(input map[string]interface{}) rmap.Rmap {
+			return tctx.Rmap("functionQuery", "explainAccess", rmap.NewFromMap(input).Bytes())
+		}
+
+		BeforeEach(func() {
+			requestUUID = MustGetID(tctx.Rmap("assetCreate", "mockrequest", rmap.NewFromMap(map[string]interface{}{"number": "1234"}).Bytes(), -1, ""))
+			role := rmap.NewFromMap(map[string]interface{}{
+				"name": "Request reader",
+				"grants": []interface{}{
+					map[string]interface{}{"object": "/mockrequest/*", "action": "read"},
+					map[string]interface{}{"object": "/mockrequest/*", "action": "read", "condition": "asset.number == '5678'"},
+				},
+			})
+			roleUUID = MustGetID(tctx.Rmap("assetCreate", "role", role.Bytes(), -1, ""))
+			ordinaryFP = tctx.GetActorFingerprint("ordinaryUser")
+			tctx.Ok("assetUpdate", "identity", ordinaryFP, rmap.NewFromMap(map[string]interface{}{"roles": []string{roleUUID}}).Bytes())
+		})
+
+		It("Should explain action granted by role", func() {
+			explanation := explain(map[string]interface{}{"identity": ordinaryFP, "asset": "mockrequest", "id": requestUUID, "action": "read"})
+
+			Expect(explanation.Mapa).To(HaveKeyWithValue(konst.ExplainDecisionKey, "allow"))
+			Expect(explanation.Mapa).To(HaveKeyWithValue(konst.ExplainSuperuserKey, false))
+			Expect(explanation.Mapa).To(HaveKeyWithValue(konst.ObjectKey, "/mockrequest/"+requestUUID))
+			Expect(explanation.Mapa).To(HaveKeyWithValue(konst.ExplainRolesKey, ConsistOf(
+				And(HaveKeyWithValue("role", roleUUID), HaveKeyWithValue("name", "Request reader"), HaveKeyWithValue("valid", true)),
+			)))
+			Expect(explanation.Mapa).To(HaveKeyWithValue(konst.ExplainGrantsKey, ConsistOf(
+				And(Not(HaveKey(konst.ConditionKey)), HaveKeyWithValue("applies", true)),
+				And(HaveKeyWithValue(konst.ConditionKey, "asset.number == '5678'"), HaveKeyWithValue("condition_met", false), HaveKeyWithValue("applies", false)),
+			)))
+			Expect(explanation.Mapa).To(HaveKeyWithValue(konst.ExplainPolicyKey, HaveKeyWithValue(konst.SubjectKey, roleUUID)))
+		})
+
+		It("Should explain action denied by override on asset", func() {
+			override := rmap.NewFromMap(map[string]interface{}{
+				"overrides": []interface{}{map[string]interface{}{
+					konst.SubjectKey: ordinaryFP,
+					konst.ActionKey:  "read",
+					konst.EffectKey:  "deny",
+				}},
+			})
+			tctx.Ok("assetUpdate", "mockrequest", requestUUID, override.Bytes())
+
+			explanation := explain(map[string]interface{}{"identity": ordinaryFP, "asset": "mockrequest", "id": requestUUID, "action": "read"})
+			Expect(explanation.Mapa).To(HaveKeyWithValue(konst.ExplainDecisionKey, "deny"))
+			Expect(explanation.Mapa).To(HaveKeyWithValue(konst.ExplainOverridesKey, ConsistOf(
+				And(HaveKeyWithValue(konst.EffectKey, "deny"), HaveKeyWithValue("applies", true)),
+			)))
+			Expect(explanation.Mapa).To(HaveKeyWithValue(konst.ExplainPolicyKey, HaveKeyWithValue(konst.EffectKey, "deny")))
+			Expect(explanation.Mapa).To(HaveKeyWithValue(konst.ExplainReasonKey, ContainSubstring("denied by policy of subject: "+ordinaryFP)))
+		})
+
+		It("Should explain action without grant, superuser and disabled identity", func() {
+			explanation := explain(map[string]interface{}{"identity": ordinaryFP, "object": "/function/invoke/MockFunc", "action": "execute"})
+			Expect(explanation.Mapa).To(HaveKeyWithValue(konst.ExplainDecisionKey, "deny"))
+			Expect(explanation.Mapa).To(HaveKeyWithValue(konst.ExplainGrantsKey, BeEmpty()))
+			Expect(explanation.Mapa).To(HaveKeyWithValue(konst.ExplainReasonKey, ContainSubstring("no grant or override allows action")))
+
+			// identity defaults to current one
+			explanation = explain(map[string]interface{}{"object": "/function/invoke/MockFunc", "action": "execute"})
+			Expect(explanation.Mapa).To(HaveKeyWithValue(konst.ExplainDecisionKey, "allow"))
+			Expect(explanation.Mapa).To(HaveKeyWithValue(konst.ExplainSuperuserKey, true))
+			Expect(explanation.Mapa).To(HaveKeyWithValue(konst.ExplainReasonKey, "granted by superuser role"))
+
+			tctx.Ok("assetUpdate", "identity", ordinaryFP, rmap.NewFromMap(map[string]interface{}{"is_enabled": false}).Bytes())
+			explanation = explain(map[string]interface{}{"identity": ordinaryFP, "object": "/mockrequest/*", "action": "read"})
+			Expect(explanation.Mapa).To(HaveKeyWithValue(konst.ExplainIdentityEnabledKey, false))
+			Expect(explanation.Mapa).To(HaveKeyWithValue(konst.ExplainDecisionKey, "deny"))
+		})
+
+		It("Should require grant to explain access", func() {
+			tctx.SetActor("ordinaryUser")
+			tctx.Error("permission denied", "functionQuery", "explainAccess", rmap.NewFromMap(map[string]interface{}{"object": "/mockrequest/*", "action": "read"}).Bytes())
+		})
+
+		It("Should require read grant on explained asset or object", func() {
+			incidentUUID := MustGetID(tctx.Rmap("assetCreate", "mockincident", rmap.NewFromMap(map[string]interface{}{"description": "ahoj"}).Bytes(), -1, ""))
+			role := rmap.NewFromMap(map[string]interface{}{
+				"name": "Explainer",
+				"grants": []interface{}{
+					map[string]interface{}{"object": "/function/query/explainAccess", "action": "execute"},
+					map[string]interface{}{"object": "/mockrequest/*", "action": "read"},
+				},
+			})
+			explainerUUID := MustGetID(tctx.Rmap("assetCreate", "role", role.Bytes(), -1, ""))
+			tctx.Ok("assetUpdate", "identity", ordinaryFP, rmap.NewFromMap(map[string]interface{}{"roles": []string{explainerUUID}}).Bytes())
+
+			tctx.SetActor("ordinaryUser")
+			explanation := explain(map[string]interface{}{"asset": "mockrequest", "id": requestUUID, "action": "read"})
+			Expect(explanation.Mapa).To(HaveKeyWithValue(konst.ExplainDecisionKey, "allow"))
+			explanation = explain(map[string]interface{}{"object": "/mockrequest/*", "action": "update"})
+			Expect(explanation.Mapa).To(HaveKeyWithValue(konst.ExplainDecisionKey, "deny"))
+
+			tctx.Error("permission denied", "functionQuery", "explainAccess", rmap.NewFromMap(map[string]interface{}{"asset": "mockincident", "id": incidentUUID, "action": "read"}).Bytes())
+			tctx.Error("permission denied", "functionQuery", "explainAccess", rmap.NewFromMap(map[string]interface{}{"object": "/mockincident/*", "action": "read"}).Bytes())
+		})
+	})
+
+	Describe("when roles have parents", func() {
+		createRole := func(name string, parents []string, grants []interface{}) string {
+			role := rmap.NewFromMap(map[string]interface{}{"name": name, "grants": grants})
+			if parents != nil {
+				role.Mapa["parents"] = parents
+			}
+			return MustGetID(tctx.Rmap("assetCreate", "role", role.Bytes(), -1, ""))
+		}
+
+		It("Should inherit grants of parent roles transitively", func() {
+			requestUUID := MustGetID(tctx.Rmap("assetCreate", "mockrequest", rmap.NewFromMap(map[string]interface{}{"number": "1234"}).Bytes(), -1, ""))
+
+			grandparentUUID := createRole("Grandparent", nil, []interface{}{
+				map[string]interface{}{"object": "/mockrequest/*", "action": "read"},
+				map[string]interface{}{"object": "/user/*", "action": "view_sensitive"},
+			})
+			parentUUID := createRole("Parent", []string{grandparentUUID}, []interface{}{})
+			childUUID := createRole("Child", []string{parentUUID}, []interface{}{})
+
+			ordinaryFP := tctx.GetActorFingerprint("ordinaryUser")
+			tctx.Ok("assetUpdate", "identity", ordinaryFP, rmap.NewFromMap(map[string]interface{}{"roles": []string{childUUID}}).Bytes())
+
+			explanation := tctx.Rmap("functionQuery", "explainAccess", rmap.NewFromMap(map[string]interface{}{"identity": ordinaryFP, "object": "/mockrequest/*", "action": "read"}).Bytes())
+			Expect(explanation.Mapa).To(HaveKeyWithValue(konst.ExplainDecisionKey, "allow"))
+			Expect(explanation.Mapa).To(HaveKeyWithValue(konst.ExplainRolesKey, ConsistOf(
+				And(HaveKeyWithValue("role", childUUID), Not(HaveKey(konst.ExplainInheritedKey))),
+				And(HaveKeyWithValue("role", parentUUID), HaveKeyWithValue(konst.ExplainInheritedKey, true)),
+				And(HaveKeyWithValue("role", grandparentUUID), HaveKeyWithValue(konst.ExplainInheritedKey, true)),
+			)))
+
+			identityAccess := tctx.Rmap("functionQuery", "identityAccess", rmap.NewFromMap(map[string]interface{}{"identity": ordinaryFP}).Bytes())
+			Expect(identityAccess.Mapa).To(HaveKeyWithValue("assets_read", ContainElement("mockrequest")))
+			Expect(identityAccess.Mapa).To(HaveKeyWithValue("custom_grants", HaveKeyWithValue("view_sensitive", ConsistOf("/user/*"))))
+
+			tctx.SetActor("ordinaryUser")
+			tctx.Ok("assetGet", "mockrequest", requestUUID, false, rmap.NewEmpty().Bytes())
+
+			// removing parent removes inherited grants
+			tctx.SetActor("superUser")
+			tctx.Ok("assetUpdate", "role", parentUUID, `{"parents":[]}`)
+			tctx.SetActor("ordinaryUser")
+			tctx.Error("permission denied", "assetGet", "mockrequest", requestUUID, false, rmap.NewEmpty().Bytes())
+		})
+
+		It("Should reject cycles in role parents", func() {
+			firstUUID := createRole("First", nil, []interface{}{})
+			secondUUID := createRole("Second", []string{firstUUID}, []interface{}{})
+
+			errJSON := tctx.ErrorJSON("invalid parents of role", "assetUpdate", "role", firstUUID, rmap.NewFromMap(map[string]interface{}{"parents": []string{secondUUID}}).Bytes())
+			Expect(errJSON.Mapa).To(HaveKeyWithValue(konst.ErrorStatusKey, float64(422)))
+			Expect(errJSON.MustGetJPtr("/" + konst.ErrorDetailsKey + "/" + konst.ErrorFieldsKey + "/0")).To(Equal(map[string]interface{}{
+				konst.ValidationPointerKey: "/parents",
+				konst.ValidationKeywordKey: "parents",
+				konst.ValidationMessageKey: "cycle in role parents: " + firstUUID + " -> " + secondUUID + " -> " + firstUUID,
+			}))
+
+			tctx.Error("cycle in role parents", "assetUpdate", "role", firstUUID, rmap.NewFromMap(map[string]interface{}{"parents": []string{firstUUID}}).Bytes())
+		})
+
+		It("Should allow only superuser to inherit from superuser role", func() {
+			managerUUID := createRole("Role manager", nil, []interface{}{
+				map[string]interface{}{"object": "/role/*", "action": "create"},
+				map[string]interface{}{"object": "/role/*", "action": "read"},
+				map[string]interface{}{"object": "/role/*", "action": "update"},
+			})
+			tctx.Ok("assetUpdate", "identity", tctx.GetActorFingerprint("ordinaryUser"), rmap.NewFromMap(map[string]interface{}{"roles": []string{managerUUID}}).Bytes())
+			inheritingUUID := createRole("Inheriting", []string{konst.SuperuserRoleUUID}, []interface{}{})
+
+			tctx.SetActor("ordinaryUser")
+			tctx.Error("to manage SuperUser role, you must have it granted", "assetCreate", "role", rmap.NewFromMap(map[string]interface{}{"name": "Direct", "parents": []string{konst.SuperuserRoleUUID}}).Bytes(), -1, "")
+			tctx.Error("to manage SuperUser role, you must have it granted", "assetCreate", "role", rmap.NewFromMap(map[string]interface{}{"name": "Indirect", "parents": []string{inheritingUUID}}).Bytes(), -1, "")
+
+			plainUUID := createRole("Plain", nil, []interface{}{})
+			createRole("Child", []string{plainUUID}, []interface{}{})
+			tctx.Error("to manage SuperUser role, you must have it granted", "assetUpdate", "role", plainUUID, rmap.NewFromMap(map[string]interface{}{"parents": []string{inheritingUUID}}).Bytes())
+
+			tctx.SetActor("superUser")
+			tctx.Ok("assetUpdate", "role", plainUUID, rmap.NewFromMap(map[string]interface{}{"parents": []string{inheritingUUID}}).Bytes())
+
+			tctx.SetActor("ordinaryUser")
+			tctx.Error("to manage SuperUser role, you must have it granted", "assetUpdate", "role", plainUUID, `{"parents":[]}`)
+		})
+
+		It("Should reject parent role, that does not exist", func() {
+			tctx.Error("not found", "assetCreate", "role", rmap.NewFromMap(map[string]interface{}{"name": "Orphan", "parents": []string{"00000000-0000-0000-0000-000000000000"}}).Bytes(), -1, "")
+		})
+	})
+
+	Describe("when identities are members of groups", func() {
+		var requestUUID, ordinaryFP string
+
+		createGroup := func(roles []string) string {
+			group := rmap.NewFromMap(map[string]interface{}{
+				"name":    "Support team",
+				"members": []string{ordinaryFP},
+				"roles":   roles,
+			})
+			return MustGetID(tctx.Rmap("assetCreate", "group", group.Bytes(), -1, ""))
+		}
+
+		BeforeEach(func() {
+			ordinaryFP = tctx.GetActorFingerprint("ordinaryUser")
+			requestUUID = MustGetID(tctx.Rmap("assetCreate", "mockrequest", rmap.NewFromMap(map[string]interface{}{"number": "1234"}).Bytes(), -1, ""))
+		})
+
+		It("Should grant roles of group to its members", func() {
+			role := rmap.NewFromMap(map[string]interface{}{
+				"name":   "Request reader",
+				"grants": []interface{}{map[string]interface{}{"object": "/mockrequest/*", "action": "read"}},
+			})
+			roleUUID := MustGetID(tctx.Rmap("assetCreate", "role", role.Bytes(), -1, ""))
+			groupUUID := createGroup([]string{roleUUID})
+
+			explanation := tctx.Rmap("functionQuery", "explainAccess", rmap.NewFromMap(map[string]interface{}{"identity": ordinaryFP, "object": "/mockrequest/*", "action": "read"}).Bytes())
+			Expect(explanation.Mapa).To(HaveKeyWithValue(konst.ExplainDecisionKey, "allow"))
+			Expect(explanation.Mapa).To(HaveKeyWithValue(konst.ExplainGroupsKey, ConsistOf(HaveKeyWithValue(konst.ExplainGroupKey, groupUUID))))
+			Expect(explanation.Mapa).To(HaveKeyWithValue(konst.ExplainRolesKey, ConsistOf(
+				And(HaveKeyWithValue("role", roleUUID), HaveKeyWithValue(konst.ExplainGroupKey, groupUUID)),
+			)))
+
+			tctx.SetActor("ordinaryUser")
+			tctx.Ok("assetGet", "mockrequest", requestUUID, false, rmap.NewEmpty().Bytes())
+			Expect(tctx.Rmap("functionQuery", "myAccess", rmap.NewEmpty().Bytes()).Mapa).To(HaveKeyWithValue("assets_read", ContainElement("mockrequest")))
+
+			// removed member loses roles of group
+			tctx.SetActor("superUser")
+			tctx.Ok("assetUpdate", "group", groupUUID, `{"members":[]}`)
+			tctx.SetActor("ordinaryUser")
+			tctx.Error("permission denied", "assetGet", "mockrequest", requestUUID, false, rmap.NewEmpty().Bytes())
+		})
+
+		It("Should apply overrides with group as subject to all members", func() {
+			groupUUID := createGroup(nil)
+
+			override := rmap.NewFromMap(map[string]interface{}{
+				"overrides": []interface{}{map[string]interface{}{
+					konst.SubjectKey: groupUUID,
+					konst.ActionKey:  "read",
+					konst.EffectKey:  "allow",
+				}},
+			})
+			tctx.Ok("assetUpdate", "mockrequest", requestUUID, override.Bytes())
+
+			tctx.SetActor("ordinaryUser")
+			tctx.Ok("assetGet", "mockrequest", requestUUID, false, rmap.NewEmpty().Bytes())
+		})
+
+		It("Should protect groups", func() {
+			tctx.SetActor("ordinaryUser")
+			tctx.Error("permission denied", "assetCreate", "group", rmap.NewFromMap(map[string]interface{}{"name": "Own team", "members": []string{ordinaryFP}}).Bytes(), -1, "")
+
+			tctx.SetActor("superUser")
+			tctx.Error("not found", "assetCreate", "group", rmap.NewFromMap(map[string]interface{}{"name": "Broken", "members": []string{strings.Repeat("0", 128)}}).Bytes(), -1, "")
+			tctx.Error("unable to upsert registry for internal asset name: group", "registryUpsert", "group", rmap.MustNewFromYAMLFile("../internal/testdata/assets/mockstate.yaml").Bytes())
+		})
+
+		It("Should allow only superuser to manage groups bearing superuser role", func() {
+			manager := rmap.NewFromMap(map[string]interface{}{
+				"name": "Group manager",
+				"grants": []interface{}{
+					map[string]interface{}{"object": "/group/*", "action": "create"},
+					map[string]interface{}{"object": "/group/*", "action": "read"},
+					map[string]interface{}{"object": "/group/*", "action": "update"},
+				},
+			})
+			managerUUID := MustGetID(tctx.Rmap("assetCreate", "role", manager.Bytes(), -1, ""))
+			inheriting := rmap.NewFromMap(map[string]interface{}{"name": "Inheriting", "parents": []string{konst.SuperuserRoleUUID}})
+			inheritingUUID := MustGetID(tctx.Rmap("assetCreate", "role", inheriting.Bytes(), -1, ""))
+			tctx.Ok("assetUpdate", "identity", ordinaryFP, rmap.NewFromMap(map[string]interface{}{"roles": []string{managerUUID}}).Bytes())
+
+			nobodyFP := tctx.GetActorFingerprint("nobodyUser")
+			superGroupUUID := MustGetID(tctx.Rmap("assetCreate", "group", rmap.NewFromMap(map[string]interface{}{"name": "Admins", "members": []string{nobodyFP}, "roles": []string{inheritingUUID}}).Bytes(), -1, ""))
+
+			tctx.SetActor("ordinaryUser")
+			tctx.Error("to manage SuperUser role, you must have it granted", "assetCreate", "group", rmap.NewFromMap(map[string]interface{}{"name": "Own team", "members": []string{ordinaryFP}, "roles": []string{konst.SuperuserRoleUUID}}).Bytes(), -1, "")
+			tctx.Error("to manage SuperUser role, you must have it granted", "assetUpdate", "group", superGroupUUID, rmap.NewFromMap(map[string]interface{}{"members": []string{nobodyFP, ordinaryFP}}).Bytes())
+			tctx.Error("to manage SuperUser role, you must have it granted", "assetUpdate", "group", superGroupUUID, `{"members":[]}`)
+			tctx.Error("to manage SuperUser role, you must have it granted", "assetUpdate", "group", superGroupUUID, `{"roles":[]}`)
+
+			groupUUID := createGroup(nil)
+			tctx.Error("to manage SuperUser role, you must have it granted", "assetUpdate", "group", groupUUID, rmap.NewFromMap(map[string]interface{}{"roles": []string{inheritingUUID}}).Bytes())
+
+			// member of group bearing superuser role is superuser too
+			tctx.SetActor("superUser")
+			tctx.Ok("assetUpdate", "identity", tctx.GetActorFingerprint("superUser"), `{"roles":[]}`)
+
+			tctx.SetActor("nobodyUser")
+			tctx.Error("unable to remove last superuser role", "assetUpdate", "group", superGroupUUID, `{"members":[]}`)
+		})
+	})
+
+	Describe("when authorization is reused in transaction", func() {
+		It("Should apply roles changed earlier in the same transaction", func() {
+			ordinaryFP := tctx.GetActorFingerprint("ordinaryUser")
+
+			creator := rmap.NewFromMap(map[string]interface{}{
+				"name": "Incident creator",
+				"grants": []map[string]interface{}{{
+					"object": "/mockincident/*",
+					"action": "create",
+				}},
+			})
+			creatorUUID := MustGetID(tctx.Rmap("assetCreate", "role", creator.Bytes(), -1, ""))
+
+			manager := rmap.NewFromMap(map[string]interface{}{
+				"name": "Identity manager",
+				"grants": []map[string]interface{}{{
+					"object": "/identity/*",
+					"action": "update",
+				}},
+			})
+			managerUUID := MustGetID(tctx.Rmap("assetCreate", "role", manager.Bytes(), -1, ""))
+			tctx.Ok("assetUpdate", "identity", ordinaryFP, rmap.NewFromMap(map[string]interface{}{"roles": []string{managerUUID}}).Bytes())
+
+			// role assigned by first operation must grant create to second operation
+			tctx.SetActor("ordinaryUser")
+			tctx.Ok("assetBatch", fmt.Sprintf(`[
+				{"op": "update", "name": "identity", "id": "%s", "patch": {"roles": ["%s", "%s"]}},
+				{"op": "create", "name": "mockincident", "data": {"description": "batch incident"}}
+			]`, ordinaryFP, managerUUID, creatorUUID))
+
+			// role removed by first operation must not grant create to second operation
+			tctx.Error("permission denied", "assetBatch", fmt.Sprintf(`[
+				{"op": "update", "name": "identity", "id": "%s", "patch": {"roles": ["%s"]}},
+				{"op": "create", "name": "mockincident", "data": {"description": "batch incident"}}
+			]`, ordinaryFP, managerUUID))
+		})
+	})
+
+	Describe("when authorization backend is customized", func() {
+		var incidentUUID string
+
+		BeforeEach(func() {
+			incidentUUID = MustGetID(tctx.Rmap("assetCreate", "mockincident", rmap.NewFromMap(map[string]interface{}{"description": "ahoj"}).Bytes(), -1, ""))
+		})
+
+		It("Should decide access by Authorizer from configuration", func() {
+			config := testdata.GetConfiguration()
+			config.CurrentIDFunc = engine.CertSHA512IDFunc
+			config.Authorizer = denyingAuthorizer{}
+
+			tctx = NewTestContext("mock", config, tctx.GetMockStub(), tctx.GetCouchDBMock())
+			tctx.Error("denied by custom authorizer", "assetGet", "mockincident", incidentUUID, false, rmap.NewEmpty().Bytes())
+
+			// other decisions are left to default authorizer, where superuser can do anything
+			requestUUID := MustGetID(tctx.Rmap("assetCreate", "mockrequest", rmap.NewFromMap(map[string]interface{}{"number": "1234"}).Bytes(), -1, ""))
+			tctx.Ok("assetGet", "mockrequest", requestUUID, false, rmap.NewEmpty().Bytes())
+		})
+
+		It("Should load casbin model from singleton", func() {
+			reader := rmap.NewFromMap(map[string]interface{}{
+				"name": "Incident reader",
+				"grants": []map[string]interface{}{{
+					"object": "/mockincident/*",
+					"action": "read",
+				}},
+			})
+			readerUUID := MustGetID(tctx.Rmap("assetCreate", "role", reader.Bytes(), -1, ""))
+			tctx.Ok("assetUpdate", "identity", tctx.GetActorFingerprint("ordinaryUser"), rmap.NewFromMap(map[string]interface{}{"roles": []string{readerUUID}}).Bytes())
+
+			// model without wildcards
+			model := strings.Replace(konst.CasbinModel, "objectMatch(r.obj, p.obj)", "r.obj == p.obj", 1)
+			Expect(model).NotTo(Equal(konst.CasbinModel))
+
+			tctx.SetActor("ordinaryUser")
+			tctx.Ok("assetGet", "mockincident", incidentUUID, false, rmap.NewEmpty().Bytes())
+
+			tctx.SetActor("superUser")
+			tctx.Ok("singletonUpsert", konst.CasbinModelSingletonName, rmap.NewFromMap(map[string]interface{}{"value": map[string]interface{}{"model": model}}).Bytes())
+
+			tctx.SetActor("ordinaryUser")
+			tctx.Error("permission denied", "assetGet", "mockincident", incidentUUID, false, rmap.NewEmpty().Bytes())
+		})
+
+		It("Should refuse invalid casbin model", func() {
+			tctx.Error("invalid casbin model", "singletonUpsert", konst.CasbinModelSingletonName, rmap.NewFromMap(map[string]interface{}{"value": map[string]interface{}{"model": "garbage"}}).Bytes())
+			tctx.Error("definition: r of casbin model must have 4 fields", "singletonUpsert", konst.CasbinModelSingletonName, rmap.NewFromMap(map[string]interface{}{"value": map[string]interface{}{"model": strings.Replace(konst.CasbinModel, "r = sub, obj, act, env", "r = sub, obj, act", 1)}}).Bytes())
+			tctx.Error("must contain casbin model", "singletonUpsert", konst.CasbinModelSingletonName, rmap.NewFromMap(map[string]interface{}{"value": map[string]interface{}{"text": "garbage"}}).Bytes())
+		})
+
+		It("Should allow only superuser to change casbin model", func() {
+			configAdmin := rmap.NewFromMap(map[string]interface{}{
+				"name": "Config admin",
+				"grants": []map[string]interface{}{{
+					"object": "/singleton/*",
+					"action": "upsert",
+				}},
+			})
+			configAdminUUID := MustGetID(tctx.Rmap("assetCreate", "role", configAdmin.Bytes(), -1, ""))
+			tctx.Ok("assetUpdate", "identity", tctx.GetActorFingerprint("ordinaryUser"), rmap.NewFromMap(map[string]interface{}{"roles": []string{configAdminUUID}}).Bytes())
+
+			// matcher granting everything to everyone
+			model := strings.Replace(konst.CasbinModel, "m = g(r.sub, p.sub) && objectMatch(r.obj, p.obj) && r.act == p.act && conditionMatch(p.cond, r.env)", "m = true", 1)
+			Expect(model).NotTo(Equal(konst.CasbinModel))
+
+			tctx.SetActor("ordinaryUser")
+			tctx.Ok("singletonUpsert", "audited", `{"value": {}}`)
+			tctx.Error("to change casbin model, you must have SuperUser role granted", "singletonUpsert", konst.CasbinModelSingletonName, rmap.NewFromMap(map[string]interface{}{"value": map[string]interface{}{"model": model}}).Bytes())
+			tctx.Error("permission denied", "assetGet", "mockincident", incidentUUID, false, rmap.NewEmpty().Bytes())
+		})
+
+		It("Should require the highest quorum of approval policy to change casbin model", func() {
+			policy := rmap.NewFromMap(map[string]interface{}{
+				"value": map[string]interface{}{"assetDelete/mockincident": map[string]interface{}{"quorum": 2}},
+			})
+			tctx.Ok("singletonUpsert", konst.ApprovalPolicySingletonName, policy.Bytes())
+
+			model := strings.Replace(konst.CasbinModel, "objectMatch(r.obj, p.obj)", "r.obj == p.obj", 1)
+			tctx.Error("operation: singletonUpsert/casbin_model requires approval of 2 identities", "singletonUpsert", konst.CasbinModelSingletonName, rmap.NewFromMap(map[string]interface{}{"value": map[string]interface{}{"model": model}}).Bytes())
+		})
+	})
+
+	Describe("when superuser is configured", func() {
+		var incidentUUID string
+
+		BeforeEach(func() {
+			incidentUUID = MustGetID(tctx.Rmap("assetCreate", "mockincident", rmap.NewFromMap(map[string]interface{}{"description": "ahoj"}).Bytes(), -1, ""))
+		})
+
+		It("Should grant every action to holders of superuser roles from configuration", func() {
+			auditor := rmap.NewFromMap(map[string]interface{}{"name": "Auditor"})
+			auditorUUID := MustGetID(tctx.Rmap("assetCreate", "role", auditor.Bytes(), -1, ""))
+			tctx.Ok("assetUpdate", "identity", tctx.GetActorFingerprint("ordinaryUser"), rmap.NewFromMap(map[string]interface{}{"roles": []string{auditorUUID}}).Bytes())
+
+			tctx.SetActor("ordinaryUser")
+			tctx.Error("permission denied", "assetGet", "mockincident", incidentUUID, false, rmap.NewEmpty().Bytes())
+
+			config := testdata.GetConfiguration()
+			config.CurrentIDFunc = engine.CertSHA512IDFunc
+			config.SuperuserRoles = []string{auditorUUID}
+
+			tctx = NewTestContext("mock", config, tctx.GetMockStub(), tctx.GetCouchDBMock())
+			tctx.SetActor("ordinaryUser")
+			tctx.Ok("assetGet", "mockincident", incidentUUID, false, rmap.NewEmpty().Bytes())
+
+			explanation := tctx.Rmap("functionQuery", "explainAccess", rmap.NewFromMap(map[string]interface{}{"object": "/mockincident/*", "action": "delete"}).Bytes())
+			Expect(explanation.Mapa).To(HaveKeyWithValue(konst.ExplainSuperuserKey, true))
+			Expect(explanation.Mapa).To(HaveKeyWithValue(konst.ExplainDecisionKey, konst.AllowEffect))
+
+			// role of bootstrap is not superuser role anymore
+			tctx.SetActor("superUser")
+			tctx.Error("permission denied", "assetGet", "mockincident", incidentUUID, false, rmap.NewEmpty().Bytes())
+		})
+
+		It("Should not grant action denied by override to superuser", func() {
+			override := rmap.NewFromMap(map[string]interface{}{
+				"overrides": []interface{}{map[string]interface{}{
+					konst.SubjectKey: konst.SuperuserRoleUUID,
+					konst.ActionKey:  "update",
+					konst.EffectKey:  "deny",
+				}},
+			})
+			requestUUID := MustGetID(tctx.Rmap("assetCreate", "mockrequest", rmap.NewFromMap(map[string]interface{}{"number": "1234"}).Bytes(), -1, ""))
+			tctx.Ok("assetUpdate", "mockrequest", requestUUID, override.Bytes())
+
+			tctx.Error("permission denied", "assetUpdate", "mockrequest", requestUUID, rmap.NewFromMap(map[string]interface{}{"number": "9999"}).Bytes())
+
+			explanation := tctx.Rmap("functionQuery", "explainAccess", rmap.NewFromMap(map[string]interface{}{"asset": "mockrequest", "id": requestUUID, "action": "update"}).Bytes())
+			Expect(explanation.Mapa).To(HaveKeyWithValue(konst.ExplainSuperuserKey, true))
+			Expect(explanation.Mapa).To(HaveKeyWithValue(konst.ExplainDecisionKey, "deny"))
+			Expect(explanation.Mapa).To(HaveKeyWithValue(konst.ExplainReasonKey, ContainSubstring("denied by policy of subject: "+konst.SuperuserRoleUUID)))
+		})
+
+		It("Should allow only superuser to grant and revoke superuser roles from configuration", func() {
+			manager := rmap.NewFromMap(map[string]interface{}{
+				"name": "Identity manager",
+				"grants": []map[string]interface{}{{
+					"object": "/identity/*",
+					"action": "read",
+				}, {
+					"object": "/identity/*",
+					"action": "update",
+				}},
+			})
+			managerUUID := MustGetID(tctx.Rmap("assetCreate", "role", manager.Bytes(), -1, ""))
+			auditorUUID := MustGetID(tctx.Rmap("assetCreate", "role", rmap.NewFromMap(map[string]interface{}{"name": "Auditor"}).Bytes(), -1, ""))
+			tctx.Ok("assetUpdate", "identity", tctx.GetActorFingerprint("ordinaryUser"), rmap.NewFromMap(map[string]interface{}{"roles": []string{managerUUID}}).Bytes())
+
+			config := testdata.GetConfiguration()
+			config.CurrentIDFunc = engine.CertSHA512IDFunc
+			config.SuperuserRoles = []string{konst.SuperuserRoleUUID, auditorUUID}
+			tctx = NewTestContext("mock", config, tctx.GetMockStub(), tctx.GetCouchDBMock())
+
+			tctx.SetActor("ordinaryUser")
+			tctx.Error("to manage SuperUser role, you must have it granted", "assetUpdate", "identity", tctx.GetActorFingerprint("nobodyUser"), rmap.NewFromMap(map[string]interface{}{"roles": []string{auditorUUID}}).Bytes())
+			tctx.Error("to manage SuperUser role, you must have it granted", "assetUpdate", "identity", tctx.GetActorFingerprint("ordinaryUser"), rmap.NewFromMap(map[string]interface{}{"roles": []string{managerUUID, auditorUUID}}).Bytes())
+
+			tctx.SetActor("superUser")
+			tctx.Ok("assetUpdate", "identity", tctx.GetActorFingerprint("nobodyUser"), rmap.NewFromMap(map[string]interface{}{"roles": []string{auditorUUID}}).Bytes())
+
+			// holder of other superuser role remains, so bootstrap role can be removed
+			tctx.Ok("assetUpdate", "identity", tctx.GetActorFingerprint("superUser"), rmap.NewFromMap(map[string]interface{}{"roles": []string{}}).Bytes())
+
+			tctx.SetActor("nobodyUser")
+			tctx.Error("unable to remove last superuser role", "assetUpdate", "identity", tctx.GetActorFingerprint("nobodyUser"), rmap.NewFromMap(map[string]interface{}{"roles": []string{}}).Bytes())
+		})
+
+		It("Should deny actions without grant, when superuser is disabled", func() {
+			config := testdata.GetConfiguration()
+			config.CurrentIDFunc = engine.CertSHA512IDFunc
+			config.DisableSuperuser = true
+
+			tctx = NewTestContext("mock", config, tctx.GetMockStub(), tctx.GetCouchDBMock())
+			tctx.Error("permission denied", "assetGet", "mockincident", incidentUUID, false, rmap.NewEmpty().Bytes())
+			tctx.Error("permission denied", "functionQuery", "explainAccess", rmap.NewFromMap(map[string]interface{}{"object": "/mockincident/*", "action": "read"}).Bytes())
+		})
+
+		It("Should bootstrap superuser role with grants to manage chaincode, when superuser is disabled", func() {
+			config := testdata.GetConfiguration()
+			config.CurrentIDFunc = engine.CertSHA512IDFunc
+			config.DisableSuperuser = true
+
+			tctx = NewTestContext("mock", config, nil, nil)
+			tctx.InitOk(tctx.GetInit("../internal/testdata/assets", "").Bytes())
+			tctx.RegisterAllActors()
+
+			suRole := tctx.Rmap("assetGet", "role", konst.SuperuserRoleUUID, false, "")
+			Expect(suRole.Mapa).To(HaveKey(konst.GrantsKey))
+
+			// bootstrapped identity manages roles and identities
+			creator := rmap.NewFromMap(map[string]interface{}{
+				"name": "Incident creator",
+				"grants": []map[string]interface{}{{
+					"object": "/mockincident/*",
+					"action": "create",
+				}},
+			})
+			creatorUUID := MustGetID(tctx.Rmap("assetCreate", "role", creator.Bytes(), -1, ""))
+			tctx.Ok("registryGet", "mockincident", -1)
+
+			// but it is not granted actions on business assets
+			tctx.Error("permission denied", "assetCreate", "mockincident", rmap.NewFromMap(map[string]interface{}{"description": "ahoj"}).Bytes(), -1, "")
+
+			tctx.Ok("assetUpdate", "identity", tctx.GetActorFingerprint("superUser"), rmap.NewFromMap(map[string]interface{}{"roles": []string{konst.SuperuserRoleUUID, creatorUUID}}).Bytes())
+			tctx.Ok("assetCreate", "mockincident", rmap.NewFromMap(map[string]interface{}{"description": "ahoj"}).Bytes(), -1, "")
+		})
+
+		It("Should record audit of actions granted only by superuser role", func() {
+			reader := rmap.NewFromMap(map[string]interface{}{
+				"name": "Incident reader",
+				"grants": []map[string]interface{}{{
+					"object": "/mockincident/*",
+					"action": "read",
+				}},
+			})
+			readerUUID := MustGetID(tctx.Rmap("assetCreate", "role", reader.Bytes(), -1, ""))
+
+			config := testdata.GetConfiguration()
+			config.CurrentIDFunc = engine.CertSHA512IDFunc
+			config.AuditSuperuser = true
+
+			tctx = NewTestContext("mock", config, tctx.GetMockStub(), tctx.GetCouchDBMock())
+			tctx.Ok("assetGet", "mockincident", incidentUUID, false, rmap.NewEmpty().Bytes())
+
+			records := tctx.GetLastEventPayloads(konst.BreakGlassEventName)
+			Expect(records).To(HaveLen(1))
+			Expect(records[0].Mapa).To(HaveKeyWithValue(konst.AssetFingerprintKey, tctx.GetActorFingerprint("superUser")))
+			Expect(records[0].Mapa).To(HaveKeyWithValue(konst.BreakGlassFunctionKey, "assetGet"))
+
+			uses := records[0].Mapa[konst.BreakGlassUsesKey].([]interface{})
+			Expect(uses).To(HaveLen(1))
+			Expect(uses[0]).To(HaveKeyWithValue(konst.BreakGlassRoleKey, konst.SuperuserRoleUUID))
+			Expect(uses[0]).To(HaveKeyWithValue(konst.ActionKey, konst.ReadAction))
+			Expect(uses[0]).To(HaveKeyWithValue(konst.ObjectKey, ContainSubstring(incidentUUID)))
+
+			// TX, that is not committed, leaves no record, it is only part of dry run output
+			dryRun := rmap.NewFromMap(map[string]interface{}{konst.TracingDryRunKey: true}).Bytes()
+			output := tctx.RmapNoResult("assetGet", "mockincident", incidentUUID, false, rmap.NewEmpty().Bytes(), dryRun)
+			writes, err := output.GetJPtrIterable("/" + konst.OutputDryRunKey + "/" + konst.DryRunWritesKey)
+			Expect(err).To(BeNil())
+			Expect(writes).To(ConsistOf(HaveKeyWithValue(konst.DryRunKeyKey, konst.BreakGlassPrefix)))
+			Expect(tctx.GetLastEventPayloads(konst.BreakGlassEventName)).To(BeEmpty())
+
+			// action granted by role of superuser is not audited
+			tctx.Ok("assetUpdate", "identity", tctx.GetActorFingerprint("superUser"), rmap.NewFromMap(map[string]interface{}{"roles": []string{konst.SuperuserRoleUUID, readerUUID}}).Bytes())
+			tctx.Ok("assetGet", "mockincident", incidentUUID, false, rmap.NewEmpty().Bytes())
+			Expect(tctx.GetLastEventPayloads(konst.BreakGlassEventName)).To(BeEmpty())
+		})
+	})
 })
@@ -1,20 +1,30 @@
 package konst
 
 const (
-	AssetVersionKey     = "xxx_version" // which key in asset stores version
-	AssetIdKey          = "uuid"        // which key in asset stores primary key
-	AssetDocTypeKey     = "docType"     // which key in asset stores document type
-	AssetFingerprintKey = "fingerprint" // which key stores fingerprint for identity assets
+	AssetVersionKey     = "xxx_version"    // which key in asset stores version
+	AssetIdKey          = "uuid"           // which key in asset stores primary key
+	AssetDocTypeKey     = "docType"        // which key in asset stores document type
+	AssetFingerprintKey = "fingerprint"    // which key stores fingerprint for identity assets
+	AssetRevisionKey    = "xxx_revision"   // which key in asset stores revision, it is incremented by every TX modifying the asset
+	AssetDeletedKey     = "xxx_deleted"    // which key in asset stores soft delete marker
+	AssetDeletedByKey   = "xxx_deleted_by" // which key in asset stores fingerprint of identity that soft deleted it
+	AssetDeletedAtKey   = "xxx_deleted_at" // which key in asset stores timestamp of soft delete
 
-	ChangelogItemPrefix = "XXXCHANGELOG" // prefix for changelog key
+	ChangelogItemPrefix       = "XXXCHANGELOG"      // prefix for changelog key
+	ReferenceIndexPrefix      = "XXXREFERENCE"      // prefix for reverse reference index keys
+	ReferenceIndexStateKey    = "XXXREFERENCEINDEX" // state key, that marks reverse reference index as complete
+	ReferenceIndexCompleteKey = "complete"          // key in marker of reverse reference index, that is true when index contains references of all assets
+	BreakGlassPrefix          = "XXXBREAKGLASS"     // prefix for audit record keys of superuser shortcut (break-glass)
+	AuditRecordPrefix         = "XXXAUDIT"          // prefix for audit trail record keys
 
 	IdentityAssetKeyPrefix = "IDENTITY" // prefix for identity key
 
 	InitSuperuserStateKey = "INIT_MANAGER"                         // state key with initial superuser's fingerprint
-	SuperuserRoleUUID     = "a00a1f64-01a1-4153-b22e-35cf7026ba7e" // magic UUID for Superuser role (must match KompiGuard model!)
+	SuperuserRoleUUID     = "a00a1f64-01a1-4153-b22e-35cf7026ba7e" // magic UUID for Superuser role created by bootstrap, it is superuser role by default
 	InitSingletonsKey     = "singletons"                           // key in init data that contains singletons
 	InitRegistriesKey     = "registries"                           // key in init data that contains registries
 	InitSuperuserKey      = "init_manager"                         // key in init data that contains first superuser's fingerprint
+	InitForceKey          = "force"                                // key in init data that allows changes of registries, that are not backward compatible
 
 	RegistryItemPrefix       = "REGISTRY"             // prefix for all registryItem state keys
 	SingletonItemPrefix      = "SINGLETON"            // prefix for all singleton state keys
@@ -26,27 +36,43 @@ const (
 	SingletonCasbinObject = "singleton"
 	SingletonVersionKey   = "version"
 	SingletonNameKey      = "name"
+	SingletonValueKey     = "value" // key in singleton with its value
 
 	IdentityAssetName = "identity" // name of asset storing identity
 	IdentityRolesKey  = "roles"    // key in identity asset with refs to roles
 	RoleAssetName     = "role"     // name of asset storing role
+	GroupAssetName    = "group"    // name of asset storing group of identities
+	GroupMembersKey   = "members"  // key in group asset with refs to member identities
+	GroupRolesKey     = "roles"    // key in group asset with refs to roles assigned to all members
+	ProposalAssetName = "proposal" // name of asset storing proposal of operation, that requires approval
 
 	RoleIsSystemKey = "is_system_role" // key in role asset that stores bool with system status. This must match RoleSchema below
 
-	QueryFieldsKey   = "fields"
-	QuerySelectorKey = "selector"
-	QueryBookmarkKey = "bookmark"
-	QueryLimitKey    = "limit"
-	QuerySortKey     = "sort"
+	QueryFieldsKey         = "fields"
+	QuerySelectorKey       = "selector"
+	QueryBookmarkKey       = "bookmark"
+	QueryLimitKey          = "limit"
+	QuerySortKey           = "sort"
+	QueryIncludeDeletedKey = "include_deleted" // key in query that includes soft deleted assets in results
 
-	RefDescriptionPrefix       = "REF->"     // prefix of description of field containing reference
-	EntityRefDescriptionPrefix = "ENTITYREF" // prefix of description of field containing entityref
+	RefDescriptionPrefix       = "REF->"       // prefix of description of field containing reference
+	EntityRefDescriptionPrefix = "ENTITYREF"   // prefix of description of field containing entityref
+	OnDeleteDescriptionPrefix  = "ON_DELETE->" // prefix of word in description of reference field that sets its delete policy
 
-	RegistryItemDestinationKey = "destination" // key in registryItem that stores destination location
-	RegistryItemSchemaKey      = "schema"      // key in registryItem that stores schema
-	RegistryCasbinObject       = "registry"    // casbin object name for registry operations
-	RegistryItemVersionKey     = "version"
-	RegistryItemNameKey        = "name"
+	OnDeleteRestrict = "restrict" // delete policy that denies deleting referenced asset
+	OnDeleteCascade  = "cascade"  // delete policy that deletes referencing asset together with referenced asset
+	OnDeleteSetNull  = "set-null" // delete policy that removes reference from referencing asset
+
+	RegistryItemDestinationKey      = "destination"       // key in registryItem that stores destination location
+	RegistryItemSchemaKey           = "schema"            // key in registryItem that stores schema
+	RegistryItemSoftDeleteKey       = "soft_delete"       // key in registryItem that enables soft delete of asset instances
+	RegistryItemOnDeleteKey         = "on_delete"         // key in registryItem that maps JSON pointers of reference fields to delete policies
+	RegistryItemMigrationKey        = "migration"         // key in registryItem that defines migration of asset instances from previous version
+	RegistryItemRestrictedFieldsKey = "restricted_fields" // key in registryItem with JSON pointers of fields, that require field grant to read or update
+	RegistryCasbinObject            = "registry"          // casbin object name for registry operations
+	RegistryItemVersionKey          = "version"
+	RegistryItemNameKey             = "name"
+	RegistryCompatibilityKey        = "compatibility" // key in registryUpsert output with compatibility report of changes against previous version
 
 	LatestObjNameKey    = "name"    // key in latestObj that stores name
 	LatestObjVersionKey = "version" // key in latestObj that stores version
@@ -61,6 +87,90 @@ const (
 	ChangelogOperationKey    = "operation"
 	ChangelogCasbinObject    = "changelog" // object name for changelog in Casbin
 
+	EventEnvelopeName     = "EVENTS"    // name of chaincode event with all asset changes and custom events emitted in TX
+	EventTxIdKey          = "txid"      // key in event with TX ID
+	EventChangesKey       = "changes"   // key in event with list of asset changes
+	EventEventsKey        = "events"    // key in event with list of custom events
+	EventNameKey          = "name"      // key in custom event with its name
+	EventPayloadKey       = "payload"   // key in custom event with its payload
+	EventVersionKey       = "version"   // key in asset change with asset version
+	EventOperationKey     = "operation" // key in asset change with operation label
+	EventDiffKey          = "diff"      // key in asset change with JSON merge-diff of asset
+	EventCreateOperation  = "create"    // label for asset change when asset is created
+	EventUpdateOperation  = "update"    // label for asset change when asset is updated
+	EventDeleteOperation  = "delete"    // label for asset change when asset is deleted
+	EventMigrateOperation = "migrate"   // label for asset change when asset is migrated to different version
+	EventRestoreOperation = "restore"   // label for asset change when soft deleted asset is restored
+
+	MigrationRenameKey   = "rename"   // key in migration with map of JSON pointers of renamed fields: old -> new
+	MigrationDefaultsKey = "defaults" // key in migration with map of JSON pointers of fields -> value set when field is missing
+	MigrationPatchKey    = "patch"    // key in migration with JSON patch applied to asset instance
+	MigrationFunctionKey = "function" // key in migration with name of migration function registered in Configuration
+
+	BatchOpKey            = "op"       // key in batch operation with operation type
+	BatchNameKey          = "name"     // key in batch operation with asset name
+	BatchIdKey            = "id"       // key in batch operation with asset ID
+	BatchDataKey          = "data"     // key in batch operation with asset data (create)
+	BatchPatchKey         = "patch"    // key in batch operation with JSON merge patch (update, migrate)
+	BatchVersionKey       = "version"  // key in batch operation with asset version (create, migrate)
+	BatchRevisionKey      = "revision" // key in batch operation with expected asset revision (update, delete, migrate)
+	BatchCreateOperation  = "create"   // batch operation that creates asset
+	BatchUpdateOperation  = "update"   // batch operation that updates asset
+	BatchDeleteOperation  = "delete"   // batch operation that deletes asset
+	BatchMigrateOperation = "migrate"  // batch operation that migrates asset to different version
+
+	AuditFunctionKey   = "function"    // key in audit record with name of called chaincode function
+	AuditDirectKey     = "direct"      // key in audit record with flag, if called function is direct variant skipping business logic
+	AuditTimestampKey  = "timestamp"   // key in audit record with RFC 3339 timestamp of TX
+	AuditTxIdKey       = "txid"        // key in audit record with TX ID
+	AuditChangesKey    = "changes"     // key in audit record with list of changed assets
+	AuditOperationKey  = "operation"   // key in audit record change with operation, values are the same as in chaincode event
+	AuditPointersKey   = "pointers"    // key in audit record change with JSON pointers of changed fields (state destination only)
+	AuditFromKey       = "from"        // key in audit query filter with RFC 3339 timestamp, records older than it are skipped
+	AuditToKey         = "to"          // key in audit query filter with RFC 3339 timestamp, records newer than it are skipped
+	AuditPointerKey    = "pointer"     // key in audit query filter with JSON pointer, only records changing it or its subfields are returned
+	AuditProposalKey   = "proposal"    // key in audit record of function executed by approved proposal, with UUID of proposal and its approvers
+	AuditRegistryKey   = "registry"    // key in audit record change of registry item with its name
+	AuditSingletonKey  = "singleton"   // key in audit record change of singleton with its name
+	AuditVersionKey    = "version"     // key in audit record change of registry item or singleton with its new version
+	AuditApprovedByKey = "approved_by" // key in audit record proposal with fingerprints of identities, that approved it
+	AuditCasbinObject  = "audit"       // casbin object name for audit trail
+
+	ApprovalPolicySingletonName = "approval_policy" // name of singleton mapping operation to its approval policy
+	ApprovalAssetCreate         = "assetCreate"     // approval policy operation: creation of asset instance by any function
+	ApprovalAssetUpdate         = "assetUpdate"     // approval policy operation: update of asset instance by any function
+	ApprovalAssetDelete         = "assetDelete"     // approval policy operation: delete of asset instance by any function
+	ApprovalAssetMigrate        = "assetMigrate"    // approval policy operation: migration of asset instance by any function
+	ApprovalAssetRestore        = "assetRestore"    // approval policy operation: restore of soft deleted asset instance
+	ApprovalRegistryUpsert      = "registryUpsert"  // approval policy operation: new version of registry item by any function
+	ApprovalSingletonUpsert     = "singletonUpsert" // approval policy operation: new version of singleton by any function
+	ApprovalFunctionInvoke      = "functionInvoke"  // approval policy operation: invoke of function
+	ApprovalSystemScope         = "system"          // last segment of approval policy key of role operation, that applies it to system roles only
+	ApprovalQuorumKey           = "quorum"          // key in approval policy with number of approvals required to execute proposal
+	ApprovalTimeoutKey          = "timeout"         // key in approval policy with duration, for example 72h, after which proposal expires
+	ApprovalDefaultTimeout      = "168h"            // timeout of proposal, when approval policy does not set it
+
+	ProposalFunctionKey    = "function"     // key in proposal with name of proposed chaincode function
+	ProposalArgsKey        = "args"         // key in proposal with arguments of proposed chaincode function
+	ProposalDescriptionKey = "description"  // key in proposal with optional description for approvers
+	ProposalStatusKey      = "status"       // key in proposal with its status
+	ProposalRequestedByKey = "requested_by" // key in proposal with fingerprint of requester
+	ProposalCreatorKey     = "creator"      // key in proposal with serialized identity of requester, operation is executed with it
+	ProposalQuorumKey      = "quorum"       // key in proposal with number of approvals required to execute it
+	ProposalExpiresAtKey   = "expires_at"   // key in proposal with RFC 3339 timestamp, after which it cannot be approved
+	ProposalApprovalsKey   = "approvals"    // key in proposal with list of approvals, every approval has fingerprint and timestamp
+	ProposalRejectedByKey  = "rejected_by"  // key in proposal with fingerprint of identity, that rejected it
+	ProposalReasonKey      = "reason"       // key in proposal with reason of rejection
+	ProposalExecutedByKey  = "executed_by"  // key in proposal with fingerprint of identity, whose approval executed it
+	ProposalExecutedAtKey  = "executed_at"  // key in proposal with RFC 3339 timestamp of execution
+	ProposalResultKey      = "result"       // key in proposal with result of executed operation
+	ProposalTimestampKey   = "timestamp"    // key in approval with RFC 3339 timestamp of approval
+	ProposalPending        = "pending"      // status of proposal waiting for approvals
+	ProposalExecuted       = "executed"     // status of proposal, whose operation was executed after quorum was reached
+	ProposalRejected       = "rejected"     // status of rejected proposal
+	ProposalExpired        = "expired"      // status of proposal, that was not approved before its timeout
+	ProposalCasbinObject   = "proposal"     // casbin object name for proposals (full casbin object name is /proposal/<function>)
+
 	FunctionCasbinName = "function" // function object name in Casbin (full casbin object name is /function/{invoke,query}/<name>)
 	FunctionInvokeVerb = "invoke"   // function invoke name in Casbin
 	FunctionQueryVerb  = "query"    // function query name in Casbin
@@ -79,13 +189,53 @@ const (
 	SchemaTypeJPtr                 = "/schema/type"                 // jptr for root schema type
 	SchemaAdditionalPropertiesJPtr = "/schema/additionalProperties" // jptr for additionalProperties attribute of schema
 
-	RegistryKey = "registry" // key in context that contains *Registry
+	RegistryKey         = "registry"      // key in context that contains *Registry
+	EventsKey           = "events"        // key in context that contains queued custom events
+	AuthorizationKey    = "authorization" // key in context that contains *Authorization of current identity built once per transaction
+	ApprovalsKey        = "approvals"     // key in context with number of approvals of proposal, that is being executed
+	ExecutedProposalKey = "proposal"      // key in context with proposal executed by approval in current TX
+	DirectKey           = "direct"        // key in context set to true, when backend skipped business logic in current TX
+	UUIDGeneratorKey    = "uuidGenerator" // key in context with func generating UUIDs of all assets created in current TX
 
 	PageSize = 10 // size of returned array in query operations
 
 	OutputResultKey   = "result"   // key under which result is wrapped in output
 	OutputBookmarkKey = "bookmark" // key on output with bookmark
 
+	ReferrerPointerKey = "pointer" // key in assetReferrers result item with JSON pointer of reference in referencing asset
+	OutputErrorKey     = "error"   // key in result item with error of operation, that failed for this item
+
+	TracingTraceKey  = "trace"  // key in trailing tracing info JSON, that enables appending of tracing info to error message
+	TracingDryRunKey = "dryRun" // key in trailing tracing info JSON, that enables dry run of TX
+
+	OutputDryRunKey       = "dryRun"     // key on output with writes, changes and event, that TX would do if it was not dry run
+	DryRunWritesKey       = "writes"     // key in dry run output with list of intended writes in order of execution
+	DryRunEventKey        = "event"      // key in dry run output with intended chaincode event
+	DryRunOperationKey    = "operation"  // key in intended write with operation label
+	DryRunKeyKey          = "key"        // key in intended write with state key or object type of composite key
+	DryRunAttributesKey   = "attributes" // key in intended write with attributes of composite key
+	DryRunCollectionKey   = "collection" // key in intended write with private data collection name
+	DryRunChangesKey      = "changes"    // key in dry run output with intended changes of assets stored in state
+	DryRunValueKey        = "value"      // key in intended change with asset value after TX
+	DryRunPutOperation    = "put"        // label for intended write of value
+	DryRunDeleteOperation = "delete"     // label for intended delete of key
+
+	ErrorCodeKey        = "code"     // key in JSON error with stable error code
+	ErrorStatusKey      = "status"   // key in JSON error with HTTP status code
+	ErrorMessageKey     = "message"  // key in JSON error with message of error
+	ErrorChainKey       = "chain"    // key in JSON error with messages of all wrapped errors, for debugging
+	ErrorDetailsKey     = "details"  // key in JSON error with details specific for error code
+	ErrorTracingKey     = "tracing"  // key in JSON error with tracing info, when tracing is enabled
+	ErrorGrantKey       = "grant"    // key in error details with grant, that is required for denied action
+	ErrorGrantObjectKey = "object"   // key in required grant with object
+	ErrorGrantActionKey = "action"   // key in required grant with action
+	ErrorPointersKey    = "pointers" // key in error details with JSON pointers of invalid fields
+	ErrorFieldsKey      = "fields"   // key in error details with list of JSONSchema validation errors
+
+	ValidationPointerKey = "pointer" // key in JSONSchema validation error with JSON pointer of invalid field
+	ValidationKeywordKey = "keyword" // key in JSONSchema validation error with schema keyword, that failed
+	ValidationMessageKey = "message" // key in JSONSchema validation error with its message
+
 	ZeroByte      = "\x00" // zero byte used as separator in composite keys
 	JPtrSeparator = "/"    // what separates elements in JSONPointer
 
@@ -100,11 +250,19 @@ const (
 	FingerprintParam = "fingerprint"
 	InputParam       = "input"
 	NumberParam      = "number"
+	OperationsParam  = "operations"
+	RevisionParam    = "revision"
+	BookmarkParam    = "bookmark"
+	LimitParam       = "limit"
+	ForceParam       = "force"
 
 	MyAccessFuncName         = "myAccess"       // name of myAccess built-in function
 	UserAccessFuncName       = "identityAccess" // name of userAccess built-in function
+	ExplainAccessFuncName    = "explainAccess"  // name of explainAccess built-in function
 	UpsertRegistriesFuncName = "upsertRegistries"
 	UpsertSingletonsFuncName = "upsertSingletons"
+	MigrateAllFuncName       = "migrateAll"      // name of migrateAll built-in function
+	IndexReferencesFuncName  = "indexReferences" // name of indexReferences built-in function
 )
 
 // ServiceKeys returns "const []string" with service keys for asset
@@ -120,6 +278,56 @@ const RegistryItemSchema = `{
       "description": "Definition where the asset instances of the name should be stored. Possible: state or private_data",
       "pattern": "(^state$)|(^private_data)",
       "type": "string"
+    },
+    "soft_delete": {
+      "description": "If true, assetDelete keeps asset instance marked as deleted instead of removing it",
+      "type": "boolean"
+    },
+    "migration": {
+      "description": "Migration of asset instances from previous version to this version. Steps are applied in order: rename, defaults, patch, function",
+      "type": "object",
+      "properties": {
+        "rename": {
+          "description": "JSON pointers of renamed fields, old pointer -> new pointer",
+          "type": "object",
+          "additionalProperties": {
+            "type": "string"
+          }
+        },
+        "defaults": {
+          "description": "JSON pointers of fields -> value, that is set if field is missing",
+          "type": "object"
+        },
+        "patch": {
+          "description": "JSON patch (RFC 6902) applied to every asset instance",
+          "type": "array",
+          "items": {
+            "type": "object"
+          }
+        },
+        "function": {
+          "description": "Name of migration function registered in chaincode configuration",
+          "type": "string"
+        }
+      },
+      "additionalProperties": false
+    },
+    "on_delete": {
+      "description": "Delete policies of reference fields. Key is JSON pointer of field without array indexes, value is restrict, cascade or set-null",
+      "type": "object",
+      "additionalProperties": {
+        "type": "string",
+        "enum": ["restrict", "cascade", "set-null"]
+      }
+    },
+    "restricted_fields": {
+      "description": "JSON pointers of fields, that are masked on read and rejected on write unless identity has grant for object <asset object>#<pointer>",
+      "type": "array",
+      "items": {
+        "type": "string",
+        "pattern": "^/"
+      },
+      "uniqueItems": true
     },
 	"schema": {
 	  "description": "JSONSchema document describing the asset instances",
@@ -180,6 +388,11 @@ const IdentitySchema = `{
         "type": "string"
       }
     },
+    "role_validity": {
+      "description": "Optional validity of role assignments, key is UUID of role from roles, value is object with valid_from and valid_until timestamps",
+      "type": "object",
+      "additionalProperties": { "$ref": "#/$defs/validity" }
+    },
     "overrides": {
       "description": "Overrides for this Identity",
       "type": "array",
@@ -213,6 +426,14 @@ const RoleSchema = `{
       "description": "Updating system roles requires update_system grant",
       "type": "boolean"
     },
+    "parents": {
+      "description": "REF->ROLE parent roles, whose grants are inherited by this role",
+      "type": "array",
+      "uniqueItems": true,
+      "items": {
+        "type": "string"
+      }
+    },
     "grants": { "$ref": "#/$defs/grants" },
     "overrides": { "$ref": "#/$defs/overrides" }
   },
@@ -222,6 +443,104 @@ const RoleSchema = `{
   "additionalProperties": false
 }`
 
+// GroupSchema is builtin schema for group asset
+const GroupSchema = `{
+  "description": "Group of identities, that can be assigned roles and used as subject of overrides",
+  "type": "object",
+  "properties": {
+    "name": {
+      "description": "Name of the group",
+      "type": "string"
+    },
+    "description": {
+      "description": "Optional description of the group",
+      "type": "string"
+    },
+    "members": {
+      "description": "REF->IDENTITY ON_DELETE->set-null member identities",
+      "type": "array",
+      "uniqueItems": true,
+      "items": {
+        "type": "string"
+      }
+    },
+    "roles": {
+      "description": "REF->ROLE roles granted to all members",
+      "type": "array",
+      "uniqueItems": true,
+      "items": {
+        "type": "string"
+      }
+    }
+  },
+  "required": [
+    "name"
+  ],
+  "additionalProperties": false
+}`
+
+// ProposalSchema is builtin schema for proposal asset, proposals are changed only by proposal* methods
+const ProposalSchema = `{
+  "description": "Proposal of chaincode function call, that is executed when quorum of identities approves it",
+  "type": "object",
+  "properties": {
+    "function": {
+      "description": "Name of proposed chaincode function",
+      "type": "string"
+    },
+    "args": {
+      "description": "Arguments of proposed chaincode function",
+      "type": "array",
+      "items": {
+        "type": "string"
+      }
+    },
+    "description": {
+      "description": "Optional description for approvers",
+      "type": "string"
+    },
+    "status": {
+      "type": "string",
+      "enum": ["pending", "executed", "rejected", "expired"]
+    },
+    "requested_by": { "$ref": "#/$defs/fingerprint" },
+    "creator": {
+      "description": "Base64 encoded serialized identity of requester",
+      "type": "string"
+    },
+    "quorum": {
+      "type": "integer",
+      "minimum": 1
+    },
+    "expires_at": { "$ref": "#/$defs/timestamp" },
+    "approvals": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "properties": {
+          "fingerprint": { "$ref": "#/$defs/fingerprint" },
+          "timestamp": { "$ref": "#/$defs/timestamp" }
+        },
+        "required": ["fingerprint", "timestamp"],
+        "additionalProperties": false
+      }
+    },
+    "rejected_by": { "$ref": "#/$defs/fingerprint" },
+    "reason": {
+      "type": "string"
+    },
+    "executed_by": { "$ref": "#/$defs/fingerprint" },
+    "executed_at": { "$ref": "#/$defs/timestamp" },
+    "result": {
+      "description": "Result of executed operation"
+    }
+  },
+  "required": [
+    "function", "args", "status", "requested_by", "creator", "quorum", "expires_at", "approvals"
+  ],
+  "additionalProperties": false
+}`
+
 // SchemaDefinitions are reusable JSONSchema definitions injected to every JSONSchema under key "$defs".
 const SchemaDefinitions = `{
   "uuid": {
@@ -257,7 +576,13 @@ const SchemaDefinitions = `{
       "description": {
         "type": "string",
         "description": "Optional description of grant"
-      }
+      },
+      "condition": {
+        "type": "string",
+        "description": "Optional expression, that must be true for grant to apply. It can use identity.<key>, cert.<attribute> and asset.<key> variables, for example: asset.org_name == cert.org_name"
+      },
+      "valid_from": { "$ref": "#/$defs/timestamp" },
+      "valid_until": { "$ref": "#/$defs/timestamp" }
     },
     "required": [
       "object", "action"
@@ -269,13 +594,37 @@ const SchemaDefinitions = `{
     "properties": {
       "action": { "$ref": "#/$defs/action" },
       "effect": { "$ref": "#/$defs/effect" },
-      "subject": { "$ref": "#/$defs/fingerprint" }
+      "subject": {
+        "description": "Fingerprint of identity or UUID of group",
+        "anyOf": [
+          { "$ref": "#/$defs/fingerprint" },
+          { "$ref": "#/$defs/uuid" }
+        ]
+      },
+      "pointer": {
+        "type": "string",
+        "description": "Optional JSON pointer of restricted field, override then targets this field instead of whole asset"
+      }
     },
     "required": [
       "action", "subject", "effect"
     ],
     "additionalProperties": false
   },
+  "timestamp": {
+    "description": "RFC 3339 timestamp",
+    "type": "string",
+    "format": "date-time"
+  },
+  "validity": {
+    "description": "Time window, when something is valid. valid_from is inclusive, valid_until is exclusive",
+    "type": "object",
+    "properties": {
+      "valid_from": { "$ref": "#/$defs/timestamp" },
+      "valid_until": { "$ref": "#/$defs/timestamp" }
+    },
+    "additionalProperties": false
+  },
   "grants": {
     "description": "Array of grants",
     "type": "array",
@@ -300,6 +649,19 @@ const SchemaServiceKeys = `{
     "type": "integer",
     "minimum": 1
   },
+  "xxx_revision": {
+    "type": "integer",
+    "minimum": 1
+  },
+  "xxx_deleted": {
+    "type": "boolean"
+  },
+  "xxx_deleted_by": {
+    "type": "string"
+  },
+  "xxx_deleted_at": {
+    "type": "string"
+  },
   "docType": {
     "type": "string",
     "pattern": "^[A-Z0-9-_]"
@@ -321,6 +683,10 @@ const InstantiateJSONSchema = `{
     "singletons": {
       "description": "Key: singleton name, Value: singleton object",
       "type": "object"
+    },
+    "force": {
+      "description": "Upsert registries even if their schema changes are not backward compatible",
+      "type": "boolean"
     }
   },
   "additionalProperties": false
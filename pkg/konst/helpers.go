@@ -8,9 +8,16 @@ import (
 	"github.com/pkg/errors"
 )
 
+// ProtectedServiceKeys returns keys managed by chaincode, that cannot be changed by patch or migration
+func ProtectedServiceKeys() []string {
+	return []string{AssetDocTypeKey, AssetVersionKey, AssetIdKey, AssetFingerprintKey, AssetRevisionKey, AssetDeletedKey, AssetDeletedByKey, AssetDeletedAtKey}
+}
+
 func HasServiceKeys(r rmap.Rmap) bool {
-	if r.Exists(AssetDocTypeKey) || r.Exists(AssetVersionKey) || r.Exists(AssetIdKey) || r.Exists(AssetFingerprintKey) {
-		return true
+	for _, key := range ProtectedServiceKeys() {
+		if r.Exists(key) {
+			return true
+		}
 	}
 
 	return false
@@ -20,6 +27,20 @@ func AssetGetVersion(r rmap.Rmap) (int, error) {
 	return r.GetInt(AssetVersionKey)
 }
 
+// AssetGetRevision returns revision of asset, assets stored before revisions were introduced have revision 0
+func AssetGetRevision(r rmap.Rmap) (int, error) {
+	if !r.Exists(AssetRevisionKey) {
+		return 0, nil
+	}
+	return r.GetInt(AssetRevisionKey)
+}
+
+// AssetIsDeleted returns true, if asset is soft deleted
+func AssetIsDeleted(r rmap.Rmap) bool {
+	deleted, err := r.GetBool(AssetDeletedKey)
+	return err == nil && deleted
+}
+
 func AssetGetDocType(r rmap.Rmap) (string, error) {
 	val, err := r.GetString(AssetDocTypeKey)
 	if err != nil {
@@ -1,14 +1,63 @@
 package konst
 
 const (
-	RolesKey     = "roles"
-	GrantsKey    = "grants"
-	ObjectKey    = "object"
-	SubjectKey   = "subject"
-	EffectKey    = "effect"
-	ActionKey    = "action"
-	OverridesKey = "overrides"
-	IsEnabledKey = "is_enabled"
+	RolesKey      = "roles"
+	GrantsKey     = "grants"
+	ObjectKey     = "object"
+	SubjectKey    = "subject"
+	EffectKey     = "effect"
+	ActionKey     = "action"
+	OverridesKey  = "overrides"
+	IsEnabledKey  = "is_enabled"
+	FilteredKey   = "error"       // key in asset filtered out by FilterAssets with reason why it cannot be read
+	ConditionKey  = "condition"   // key in grant with expression, that must be true for grant to apply
+	ValidFromKey  = "valid_from"  // key in grant or role validity with RFC 3339 timestamp since when it is valid
+	ValidUntilKey = "valid_until" // key in grant or role validity with RFC 3339 timestamp until when it is valid
+
+	IdentityRoleValidityKey = "role_validity" // key in identity asset mapping UUID of assigned role to its validity
+	PointerKey              = "pointer"       // key in override with JSON pointer of restricted field, that override targets instead of whole asset
+	ParentsKey              = "parents"       // key in role with parent roles, whose grants are inherited by role
+	IdentityMemberOfKey     = "member_of"     // key in resolved identity with groups, that identity is member of, it is never stored
+
+	FieldObjectSeparator = "#" // separates object of asset and JSON pointer of its field, for example /incident/*#/salary
+
+	CasbinModelSingletonName = "casbin_model" // name of singleton with custom casbin model used by default Authorizer instead of CasbinModel
+	CasbinModelSingletonKey  = "model"        // key in value of casbin_model singleton with text of casbin model
+	KompiGuardsKey           = "kompiguards"  // key in context with kompiguards of identities with loaded roles, cached by default Authorizer
+
+	BreakGlassKey          = "break_glass" // key in context with uses of superuser shortcut in TX, they are stored to audit record when TX ends
+	BreakGlassEventName    = "BREAK_GLASS" // name of custom event emitted with audit record of superuser shortcut
+	BreakGlassUsesKey      = "uses"        // key in audit record with list of actions granted only by superuser role
+	BreakGlassRoleKey      = "role"        // key in use of superuser shortcut with UUID of superuser role
+	BreakGlassFunctionKey  = "function"    // key in audit record with name of called chaincode function
+	BreakGlassTimestampKey = "timestamp"   // key in audit record with RFC 3339 timestamp of TX
+	BreakGlassTxIdKey      = "txid"        // key in audit record with TX ID
+
+	ConditionIdentityPrefix = "identity" // prefix of variables in condition with keys of current identity asset
+	ConditionCertPrefix     = "cert"     // prefix of variables in condition with attributes of certificate of current identity
+	ConditionAssetPrefix    = "asset"    // prefix of variables in condition with keys of enforced asset
+
+	CertMSPIDAttribute   = "mspid"    // certificate attribute in condition with MSP ID of current identity
+	CertOrgNameAttribute = "org_name" // certificate attribute in condition with organization name of current identity
+
+	ExplainDecisionKey        = "decision"         // key in explanation with final decision, allow or deny
+	ExplainReasonKey          = "reason"           // key in explanation with human readable reason of decision
+	ExplainIdentityEnabledKey = "identity_enabled" // key in explanation with is_enabled of explained identity
+	ExplainSuperuserKey       = "superuser"        // key in explanation, true if identity has valid superuser role
+	ExplainRolesKey           = "roles"            // key in explanation with all roles assigned to identity and their validity
+	ExplainGrantsKey          = "grants"           // key in explanation with grants matching object and action
+	ExplainOverridesKey       = "overrides"        // key in explanation with overrides of asset matching object and action
+	ExplainPolicyKey          = "policy"           // key in explanation with policy, that decided the result, as reported by casbin
+	ExplainValidKey           = "valid"            // key in explained role or grant, true if it is valid at time of transaction
+	ExplainConditionMetKey    = "condition_met"    // key in explained grant, true if its condition is true
+	ExplainAppliesKey         = "applies"          // key in explained grant or override, true if it takes part in decision
+	ExplainRoleKey            = "role"             // key in explained role or grant with UUID of role
+	ExplainRoleNameKey        = "name"             // key in explained role or group with its name
+	ExplainInheritedKey       = "inherited"        // key in explained role, true if role is inherited from parent role of assigned role
+	ExplainGroupKey           = "group"            // key in explained role with UUID of group, that role is assigned to, or in explained group
+	ExplainGroupsKey          = "groups"           // key in explanation with groups, that identity is member of
+	AllowEffect               = "allow"
+	DenyEffect                = "deny"
 
 	RolesJPtr     = "/roles"
 	GrantsJPtr    = "/grants"
@@ -29,21 +78,27 @@ const (
 	UpdateSystemAction = "update_system"
 	DeleteAction       = "delete"
 	DeleteDirectAction = "delete_direct"
+	RestoreAction      = "restore"
+	MigrateAction      = "migrate"
 	ExecuteAction      = "execute"
 	UpsertAction       = "upsert"
+	ApproveAction      = "approve"
 )
 
-// casbinModel uses RBAC with deny-override, superuser and wildcards
+// casbinModel uses RBAC with deny-override and wildcards
+// superuser is not part of model, holders of superuser roles are granted actions denied by model, see kompiguard.SetSuperuserRoles
 // RBAC - subjects are assigned to roles
 // deny-override - if policy effect has "deny", then it "wins" against "allow"
-// superuser - role with UUID a00a1f64-01a1-4153-b22e-35cf7026ba7e is superuser and can do anything
+// inheritance - role is linked to its parent roles by g, so their grants are inherited transitively
 // wildcards - when object is for example /incident/* then ALL incidents are matched
+// conditions - grant with condition applies only when its expression is true, see kompiguard.ValidateCondition
+// fields - when object is for example /incident/*#/salary then field /salary of ALL incidents is matched, see kompiguard.ObjectMatch
 const CasbinModel = `
 [request_definition]
-r = sub, obj, act
+r = sub, obj, act, env
 
 [policy_definition]
-p = sub, obj, act, eft
+p = sub, obj, act, eft, cond
 
 [role_definition]
 g = _, _
@@ -52,5 +107,5 @@ g = _, _
 e = some(where (p.eft == allow)) && !some(where (p.eft == deny))
 
 [matchers]
-m = (g(r.sub, p.sub) && keyMatch(r.obj, p.obj) && r.act == p.act) || g(r.sub, "a00a1f64-01a1-4153-b22e-35cf7026ba7e")
+m = g(r.sub, p.sub) && objectMatch(r.obj, p.obj) && r.act == p.act && conditionMatch(p.cond, r.env)
 `
@@ -0,0 +1,216 @@
+package cc_core
+
+import (
+	"github.com/KompiTech/fabric-cc-core/v2/pkg/konst"
+	. "github.com/KompiTech/fabric-cc-core/v2/pkg/testing"
+	"github.com/KompiTech/rmap"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("declarative migration tests", func() {
+	var tctx *TestContext
+
+	// regItemV2 renames description to short_description and adds priority with default value
+	regItemV2 := rmap.MustNewFromYAMLFile("../internal/testdata/assets/mockincident.yaml")
+	regItemV2.MustDeleteJPtr("/schema/properties/description")
+	regItemV2.MustSetJPtr("/schema/properties/short_description", map[string]interface{}{"type": "string"})
+	regItemV2.MustSetJPtr("/schema/properties/priority", map[string]interface{}{"type": "string"})
+	regItemV2.MustSetJPtr("/schema/required", []interface{}{"short_description", "priority"})
+	regItemV2.Mapa["migration"] = map[string]interface{}{
+		"rename":   map[string]interface{}{"/description": "/short_description"},
+		"defaults": map[string]interface{}{"/priority": "low"},
+	}
+
+	// regItemV3 adds tags using JSON patch and converts priority to upper case using migration function
+	regItemV3 := regItemV2.Copy()
+	regItemV3.MustSetJPtr("/schema/properties/tags", map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}})
+	regItemV3.MustSetJPtr("/schema/required", []interface{}{"short_description", "priority", "tags"})
+	regItemV3.Mapa["migration"] = map[string]interface{}{
+		"patch":    []interface{}{map[string]interface{}{"op": "add", "path": "/tags", "value": []interface{}{}}},
+		"function": "mockincident_uppercase_priority",
+	}
+
+	BeforeEach(func() {
+		tctx = getDefaultTextContext()
+		tctx.InitOk(tctx.GetInit("../internal/testdata/assets", "").Bytes())
+		tctx.RegisterAllActors()
+	})
+
+	It("Should apply migrations of all versions in order", func() {
+		id := MustGetID(tctx.Rmap("assetCreate", "mockincident", `{"description":"foobar"}`, 1, ""))
+
+		tctx.Ok("registryUpsert", "mockincident", regItemV2.Bytes())
+		tctx.Ok("registryUpsert", "mockincident", regItemV3.Bytes())
+
+		migrated := tctx.Rmap("assetMigrate", "mockincident", id, `{}`, -1)
+		Expect(MustGetVersion(migrated)).To(Equal(3))
+		Expect(migrated.Mapa).NotTo(HaveKey("description"))
+		Expect(migrated.Mapa).To(HaveKeyWithValue("short_description", "foobar"))
+		Expect(migrated.Mapa).To(HaveKeyWithValue("priority", "LOW"))
+		Expect(migrated.Mapa).To(HaveKeyWithValue("tags", []interface{}{}))
+
+		Expect(tctx.GetLastEventPayloads("mockincident_migrated")).To(ConsistOf(
+			rmap.NewFromMap(map[string]interface{}{"from": float64(1), "to": float64(3)}),
+		))
+	})
+
+	It("Should apply client patch after migrations", func() {
+		id := MustGetID(tctx.Rmap("assetCreate", "mockincident", `{"description":"foobar"}`, 1, ""))
+
+		tctx.Ok("registryUpsert", "mockincident", regItemV2.Bytes())
+
+		migrated := tctx.Rmap("assetMigrate", "mockincident", id, `{"priority":"high"}`, 2)
+		Expect(migrated.Mapa).To(HaveKeyWithValue("short_description", "foobar"))
+		Expect(migrated.Mapa).To(HaveKeyWithValue("priority", "high"))
+	})
+
+	It("Should validate asset after each intermediate version", func() {
+		id := MustGetID(tctx.Rmap("assetCreate", "mockincident", `{"description":"foobar"}`, 1, ""))
+
+		// version 2 without migration, asset of version 1 is not valid for it
+		withoutMigration := regItemV2.Copy()
+		delete(withoutMigration.Mapa, "migration")
+		tctx.Ok("registryUpsert", "mockincident", withoutMigration.Bytes(), true)
+		tctx.Ok("registryUpsert", "mockincident", regItemV3.Bytes())
+
+		tctx.Error("asset is not valid after migration to version: 2", "assetMigrate", "mockincident", id, `{}`, 3)
+	})
+
+	It("Should not allow migration to change service keys", func() {
+		id := MustGetID(tctx.Rmap("assetCreate", "mockincident", `{"description":"foobar"}`, 1, ""))
+
+		regItem := rmap.MustNewFromYAMLFile("../internal/testdata/assets/mockincident.yaml")
+		regItem.Mapa["migration"] = map[string]interface{}{"function": "mockincident_break_identity"}
+		tctx.Ok("registryUpsert", "mockincident", regItem.Bytes())
+
+		Expect(tctx.ErrorJSON("migration must not change service keys", "assetMigrate", "mockincident", id, `{}`, 2).Mapa).To(HaveKeyWithValue(konst.ErrorStatusKey, float64(422)))
+	})
+
+	It("Should not allow migration patch to change any service key", func() {
+		id := MustGetID(tctx.Rmap("assetCreate", "mockincident", `{"description":"foobar"}`, 1, ""))
+
+		patches := []map[string]interface{}{
+			{"op": "replace", "path": "/docType", "value": "MOCKSTATE"},
+			{"op": "add", "path": "/xxx_revision", "value": 10},
+			{"op": "add", "path": "/xxx_deleted", "value": true},
+			{"op": "add", "path": "/xxx_deleted_by", "value": "someone"},
+			{"op": "add", "path": "/fingerprint", "value": "someone"},
+		}
+
+		for _, patch := range patches {
+			regItem := rmap.MustNewFromYAMLFile("../internal/testdata/assets/mockincident.yaml")
+			regItem.Mapa["migration"] = map[string]interface{}{"patch": []interface{}{patch}}
+			tctx.Ok("registryUpsert", "mockincident", regItem.Bytes())
+
+			tctx.Error("migration must not change service keys", "assetMigrate", "mockincident", id, `{}`, -1)
+		}
+	})
+
+	It("Should reject invalid migration definitions", func() {
+		regItem := rmap.MustNewFromYAMLFile("../internal/testdata/assets/mockincident.yaml")
+
+		regItem.Mapa["migration"] = map[string]interface{}{"function": "unknown"}
+		Expect(tctx.ErrorJSON("migration function: unknown is not registered", "registryUpsert", "mockincident", regItem.Bytes()).Mapa).To(HaveKeyWithValue(konst.ErrorStatusKey, float64(400)))
+
+		regItem.Mapa["migration"] = map[string]interface{}{"patch": []interface{}{map[string]interface{}{"op": "move"}}}
+		tctx.Error("invalid JSON patch in migration", "registryUpsert", "mockincident", regItem.Bytes())
+
+		regItem.Mapa["migration"] = map[string]interface{}{"unknown": true}
+		tctx.Error("registryItemToUpsert.ValidateSchemaBytes() failed", "registryUpsert", "mockincident", regItem.Bytes())
+	})
+
+	Context("assetMigrateAll method", func() {
+		var ids []string
+
+		BeforeEach(func() {
+			ids = nil
+			for _, description := range []string{"first", "second", "third"} {
+				ids = append(ids, MustGetID(tctx.Rmap("assetCreate", "mockincident", `{"description":"`+description+`"}`, 1, "")))
+			}
+
+			tctx.Ok("registryUpsert", "mockincident", regItemV2.Bytes())
+		})
+
+		It("Should migrate all instances page by page", func() {
+			output := tctx.JSONNoResult("assetMigrateAll", "mockincident", -1, "", 2)
+			Expect(output[konst.OutputResultKey]).To(HaveLen(2))
+			bookmark := output[konst.OutputBookmarkKey].(string)
+			Expect(bookmark).NotTo(BeEmpty())
+
+			output = tctx.JSONNoResult("assetMigrateAll", "mockincident", -1, bookmark, 2)
+			Expect(output[konst.OutputResultKey]).To(HaveLen(1))
+			Expect(output[konst.OutputBookmarkKey]).To(BeEmpty())
+
+			for _, id := range ids {
+				asset := tctx.Rmap("assetGet", "mockincident", id, false, "")
+				Expect(MustGetVersion(asset)).To(Equal(2))
+				Expect(asset.Mapa).To(HaveKeyWithValue("priority", "low"))
+			}
+
+			// nothing is left to migrate
+			output = tctx.JSONNoResult("assetMigrateAll", "mockincident", -1, "")
+			Expect(output[konst.OutputResultKey]).To(BeEmpty())
+			Expect(output[konst.OutputBookmarkKey]).To(BeEmpty())
+		})
+
+		It("Should report failed instances without aborting migration", func() {
+			// version 3 renames short_description back to description, which can have at most 5 characters
+			regItem := rmap.MustNewFromYAMLFile("../internal/testdata/assets/mockincident.yaml")
+			regItem.MustSetJPtr("/schema/properties/description/maxLength", 5)
+			regItem.MustSetJPtr("/schema/properties/priority", map[string]interface{}{"type": "string"})
+			regItem.Mapa["migration"] = map[string]interface{}{
+				"rename": map[string]interface{}{"/short_description": "/description"},
+			}
+			tctx.Ok("registryUpsert", "mockincident", regItem.Bytes())
+
+			output := tctx.JSONNoResult("assetMigrateAll", "mockincident", 3, "")
+			Expect(output[konst.OutputResultKey]).To(ConsistOf(
+				map[string]interface{}{konst.AssetIdKey: ids[0], konst.AssetVersionKey: float64(3)},
+				And(HaveKeyWithValue(konst.AssetIdKey, ids[1]), HaveKeyWithValue(konst.OutputErrorKey, ContainSubstring("asset.ValidateSchema() failed"))),
+				map[string]interface{}{konst.AssetIdKey: ids[2], konst.AssetVersionKey: float64(3)},
+			))
+
+			Expect(MustGetVersion(tctx.Rmap("assetGet", "mockincident", ids[1], false, ""))).To(Equal(1))
+		})
+
+		It("Should fail whole TX when instance fails after its writes were staged", func() {
+			// AfterMigrate of version 4 always fails, migrated instance is already written at that point
+			tctx.Ok("registryUpsert", "mockincident", regItemV3.Bytes())
+			regItemV4 := regItemV3.Copy()
+			delete(regItemV4.Mapa, "migration")
+			tctx.Ok("registryUpsert", "mockincident", regItemV4.Bytes())
+
+			tctx.Error("failed after its writes were staged", "assetMigrateAll", "mockincident", 4, "")
+		})
+
+		It("Should require migrate grant", func() {
+			tctx.SetActor("ordinaryUser")
+			tctx.Error("permission denied", "assetMigrateAll", "mockincident", -1, "")
+
+			tctx.SetActor("superUser")
+			role := tctx.JSON("roleCreate", rmap.NewFromMap(map[string]interface{}{
+				"name": "migrateRole",
+				"grants": []map[string]interface{}{{
+					"object": "/mockincident/*",
+					"action": "migrate",
+				}},
+			}).Bytes(), "")
+			tctx.Ok("identityUpdate", tctx.GetActorFingerprint("ordinaryUser"), rmap.NewFromMap(map[string]interface{}{
+				"roles": []string{role[konst.AssetIdKey].(string)},
+			}).Bytes())
+
+			tctx.SetActor("ordinaryUser")
+			output := tctx.JSONNoResult("assetMigrateAll", "mockincident", -1, "")
+			Expect(output[konst.OutputResultKey]).To(HaveLen(3))
+		})
+
+		It("Should migrate instances using built-in function", func() {
+			input := rmap.NewFromMap(map[string]interface{}{"name": "mockincident", "limit": 1})
+
+			output := tctx.JSONNoResult("functionInvoke", konst.MigrateAllFuncName, input.Bytes())
+			Expect(output[konst.OutputResultKey]).To(HaveLen(1))
+			Expect(output[konst.OutputBookmarkKey]).NotTo(BeEmpty())
+		})
+	})
+})
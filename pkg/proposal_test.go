@@ -0,0 +1,307 @@
+package cc_core
+
+import (
+	"fmt"
+
+	"github.com/KompiTech/fabric-cc-core/v2/pkg/konst"
+	. "github.com/KompiTech/fabric-cc-core/v2/pkg/testing"
+	"github.com/KompiTech/rmap"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("proposal* method family tests", func() {
+	var tctx *TestContext
+	var incidentUUID string
+	var systemRoleUUID string
+
+	// propose creates proposal of function call and returns its UUID
+	propose := func(function string, args ...string) string {
+		input := rmap.NewFromMap(map[string]interface{}{
+			"function":    function,
+			"args":        args,
+			"description": "please approve",
+		})
+		return MustGetID(tctx.Rmap("proposalCreate", input.Bytes()))
+	}
+
+	BeforeEach(func() {
+		tctx = getDefaultTextContext()
+		tctx.InitOk(tctx.GetInit("../internal/testdata/assets", "").Bytes())
+		tctx.RegisterAllActors()
+
+		policy := rmap.NewFromMap(map[string]interface{}{
+			"value": map[string]interface{}{
+				"registryUpsert/mockproposed": map[string]interface{}{"quorum": 2},
+				"assetDelete/mockincident":    map[string]interface{}{"quorum": 1, "timeout": "1h"},
+				"assetUpdate/role/system":     map[string]interface{}{"quorum": 1},
+			},
+		})
+		tctx.Ok("singletonUpsert", konst.ApprovalPolicySingletonName, policy.Bytes())
+
+		approver := rmap.NewFromMap(map[string]interface{}{
+			"name": "Approver",
+			"grants": []map[string]interface{}{{
+				"object": "/proposal/*",
+				"action": "approve",
+			}, {
+				"object": "/proposal/*",
+				"action": "create",
+			}, {
+				"object": "/proposal/*",
+				"action": "read",
+			}},
+		})
+		approverUUID := MustGetID(tctx.Rmap("assetCreate", "role", approver.Bytes(), -1, ""))
+
+		for _, actor := range []string{"ordinaryUser", "nobodyUser"} {
+			tctx.Ok("assetUpdate", "identity", tctx.GetActorFingerprint(actor), rmap.NewFromMap(map[string]interface{}{"roles": []string{approverUUID}}).Bytes())
+		}
+
+		incidentUUID = MustGetID(tctx.Rmap("assetCreate", "mockincident", rmap.NewFromMap(map[string]interface{}{"description": "ahoj"}).Bytes(), -1, ""))
+
+		systemRole := rmap.NewFromMap(map[string]interface{}{
+			"name":           "System",
+			"is_system_role": true,
+			"grants":         []map[string]interface{}{},
+		})
+		systemRoleUUID = MustGetID(tctx.Rmap("assetCreate", "role", systemRole.Bytes(), -1, ""))
+	})
+
+	Describe("Call to CC method requiring approval", func() {
+		It("Should be refused when called directly", func() {
+			tctx.Error("operation: assetDelete/mockincident requires approval of 1 identities", "assetDeleteDirect", "mockincident", incidentUUID)
+			tctx.Error("operation: assetDelete/mockincident requires approval of 1 identities", "assetDelete", "mockincident", incidentUUID)
+			tctx.Error("operation: registryUpsert/mockproposed requires approval of 2 identities", "registryUpsert", "mockproposed", rmap.MustNewFromYAMLFile("../internal/testdata/assets/mockstate.yaml").Bytes())
+
+			// functions without approval policy are not affected
+			tctx.Ok("assetGet", "mockincident", incidentUUID, false, "")
+			tctx.Ok("assetUpdate", "mockincident", incidentUUID, rmap.NewFromMap(map[string]interface{}{"description": "updated"}).Bytes())
+		})
+
+		It("Should be refused when registry is upserted by upsertRegistries function", func() {
+			input := rmap.NewFromMap(map[string]interface{}{
+				"registries": map[string]interface{}{
+					"mockproposed": rmap.MustNewFromYAMLFile("../internal/testdata/assets/mockstate.yaml").Mapa,
+				},
+			})
+			tctx.Error("operation: registryUpsert/mockproposed requires approval of 2 identities", "functionInvoke", konst.UpsertRegistriesFuncName, input.Bytes())
+		})
+
+		It("Should be refused when asset is deleted by assetBatch", func() {
+			operations := fmt.Sprintf(`[{"op": "delete", "name": "mockincident", "id": "%s"}]`, incidentUUID)
+			tctx.Error("operation: assetDelete/mockincident requires approval of 1 identities", "assetBatch", operations)
+		})
+
+		It("Should be refused when system role is updated by any function", func() {
+			patch := rmap.NewFromMap(map[string]interface{}{"name": "Changed"}).Bytes()
+			tctx.Error("operation: assetUpdate/role requires approval of 1 identities", "assetUpdate", "role", systemRoleUUID, patch)
+			tctx.Error("operation: assetUpdate/role requires approval of 1 identities", "assetUpdateDirect", "role", systemRoleUUID, patch)
+			tctx.Error("operation: assetUpdate/role requires approval of 1 identities", "roleUpdate", systemRoleUUID, patch)
+
+			// policy is limited to system roles
+			ordinaryRoleUUID := MustGetID(tctx.Rmap("assetCreate", "role", rmap.NewFromMap(map[string]interface{}{"name": "Ordinary", "grants": []interface{}{}}).Bytes(), -1, ""))
+			tctx.Ok("assetUpdate", "role", ordinaryRoleUUID, patch)
+			tctx.Error("operation: assetUpdate/role requires approval of 1 identities", "assetUpdate", "role", ordinaryRoleUUID, rmap.NewFromMap(map[string]interface{}{"is_system_role": true}).Bytes())
+		})
+
+		It("Should require the highest quorum to change approval policy, that does not list it", func() {
+			relaxed := rmap.NewFromMap(map[string]interface{}{
+				"value": map[string]interface{}{
+					"assetDelete/mockincident": map[string]interface{}{"quorum": 1},
+				},
+			})
+			tctx.Error("operation: singletonUpsert/approval_policy requires approval of 2 identities", "singletonUpsert", konst.ApprovalPolicySingletonName, relaxed.Bytes())
+			tctx.Error("operation: singletonUpsert/approval_policy requires approval of 2 identities", "singletonUpsert", konst.ApprovalPolicySingletonName, rmap.NewFromMap(map[string]interface{}{"value": map[string]interface{}{}}).Bytes())
+			tctx.Error("operation: registryUpsert/mockproposed requires approval of 2 identities", "registryUpsert", "mockproposed", rmap.MustNewFromYAMLFile("../internal/testdata/assets/mockstate.yaml").Bytes())
+
+			proposalUUID := propose("singletonUpsert", konst.ApprovalPolicySingletonName, string(relaxed.Bytes()))
+			proposal := tctx.Rmap("assetGet", "proposal", proposalUUID, false, "")
+			Expect(proposal.Mapa).To(HaveKeyWithValue(konst.ProposalQuorumKey, BeNumerically("==", 2)))
+
+			tctx.SetActor("ordinaryUser")
+			tctx.Ok("proposalApprove", proposalUUID)
+			tctx.SetActor("nobodyUser")
+			proposal = tctx.Rmap("proposalApprove", proposalUUID)
+			Expect(proposal.Mapa).To(HaveKeyWithValue(konst.ProposalStatusKey, konst.ProposalExecuted))
+
+			tctx.SetActor("superUser")
+			tctx.Ok("registryUpsert", "mockproposed", rmap.MustNewFromYAMLFile("../internal/testdata/assets/mockstate.yaml").Bytes())
+		})
+
+		It("Should refuse invalid approval policy", func() {
+			tctx.Error("invalid approval policy", "singletonUpsert", konst.ApprovalPolicySingletonName, rmap.NewFromMap(map[string]interface{}{"value": map[string]interface{}{"assetCreate": map[string]interface{}{"quorum": 0}}}).Bytes())
+			tctx.Error("invalid approval policy", "singletonUpsert", konst.ApprovalPolicySingletonName, rmap.NewFromMap(map[string]interface{}{"value": map[string]interface{}{"assetCreate": map[string]interface{}{"quorum": 1, "timeout": "tomorrow"}}}).Bytes())
+			tctx.Error("operation: proposalApprove cannot require approval", "singletonUpsert", konst.ApprovalPolicySingletonName, rmap.NewFromMap(map[string]interface{}{"value": map[string]interface{}{"proposalApprove": map[string]interface{}{"quorum": 1}}}).Bytes())
+			tctx.Error("operation: assetDeleteDirect cannot require approval", "singletonUpsert", konst.ApprovalPolicySingletonName, rmap.NewFromMap(map[string]interface{}{"value": map[string]interface{}{"assetDeleteDirect": map[string]interface{}{"quorum": 1}}}).Bytes())
+			tctx.Error("can limit only asset operation on role to: system", "singletonUpsert", konst.ApprovalPolicySingletonName, rmap.NewFromMap(map[string]interface{}{"value": map[string]interface{}{"assetUpdate/mockincident/system": map[string]interface{}{"quorum": 1}}}).Bytes())
+		})
+	})
+
+	Describe("Call to CC method proposalCreate", func() {
+		It("Should store pending proposal", func() {
+			proposal := tctx.Rmap("assetGet", "proposal", propose("assetDeleteDirect", "mockincident", incidentUUID), false, "")
+			Expect(proposal.Mapa).To(HaveKeyWithValue(konst.ProposalFunctionKey, "assetDeleteDirect"))
+			Expect(proposal.Mapa).To(HaveKeyWithValue(konst.ProposalStatusKey, konst.ProposalPending))
+			Expect(proposal.Mapa).To(HaveKeyWithValue(konst.ProposalRequestedByKey, tctx.GetActorFingerprint("superUser")))
+			Expect(proposal.Mapa).To(HaveKeyWithValue(konst.ProposalQuorumKey, BeNumerically("==", 1)))
+			Expect(proposal.Mapa).To(HaveKey(konst.ProposalExpiresAtKey))
+			Expect(proposal.Mapa[konst.ProposalApprovalsKey]).To(BeEmpty())
+		})
+
+		It("Should refuse function without approval policy", func() {
+			input := rmap.NewFromMap(map[string]interface{}{"function": "assetUpdate", "args": []string{"mockincident", incidentUUID, `{"description": "updated"}`}})
+			tctx.Error("function: assetUpdate does not require approval", "proposalCreate", input.Bytes())
+		})
+
+		It("Should apply approval policies of all operations executed by function", func() {
+			batch := fmt.Sprintf(`[{"op": "update", "name": "mockincident", "id": "%s", "patch": {"description": "updated"}}, {"op": "delete", "name": "mockincident", "id": "%s"}]`, incidentUUID, incidentUUID)
+			proposal := tctx.Rmap("assetGet", "proposal", propose("assetBatch", batch), false, "")
+			Expect(proposal.Mapa).To(HaveKeyWithValue(konst.ProposalQuorumKey, BeNumerically("==", 1)))
+
+			input := rmap.NewFromMap(map[string]interface{}{
+				"registries": map[string]interface{}{
+					"mockproposed": rmap.MustNewFromYAMLFile("../internal/testdata/assets/mockstate.yaml").Mapa,
+				},
+			})
+			proposal = tctx.Rmap("assetGet", "proposal", propose("functionInvoke", konst.UpsertRegistriesFuncName, string(input.Bytes())), false, "")
+			Expect(proposal.Mapa).To(HaveKeyWithValue(konst.ProposalQuorumKey, BeNumerically("==", 2)))
+
+			proposal = tctx.Rmap("assetGet", "proposal", propose("roleUpdate", systemRoleUUID, `{"name": "Changed"}`), false, "")
+			Expect(proposal.Mapa).To(HaveKeyWithValue(konst.ProposalQuorumKey, BeNumerically("==", 1)))
+		})
+
+		It("Should require create grant", func() {
+			tctx.Ok("assetUpdate", "identity", tctx.GetActorFingerprint("nobodyUser"), rmap.NewFromMap(map[string]interface{}{"roles": []string{}}).Bytes())
+
+			tctx.SetActor("nobodyUser")
+			input := rmap.NewFromMap(map[string]interface{}{"function": "assetDeleteDirect", "args": []string{"mockincident", incidentUUID}})
+			tctx.Error("permission denied", "proposalCreate", input.Bytes())
+		})
+
+		It("Should not allow to change proposal by asset methods", func() {
+			proposalUUID := propose("assetDeleteDirect", "mockincident", incidentUUID)
+
+			tctx.Error("proposal can be changed only by proposal methods", "assetUpdate", "proposal", proposalUUID, rmap.NewFromMap(map[string]interface{}{"status": "executed"}).Bytes())
+			tctx.Error("proposal can be changed only by proposal methods", "assetDelete", "proposal", proposalUUID)
+			tctx.Error("proposal can be changed only by proposal methods", "assetCreate", "proposal", rmap.NewFromMap(map[string]interface{}{"function": "assetDeleteDirect"}).Bytes(), -1, "")
+		})
+	})
+
+	Describe("Call to CC method proposalApprove", func() {
+		It("Should execute proposal with identity of requester when quorum is reached", func() {
+			proposalUUID := propose("assetDeleteDirect", "mockincident", incidentUUID)
+
+			tctx.Error("requester cannot approve own proposal", "proposalApprove", proposalUUID)
+
+			// approver does not need permission for proposed function
+			tctx.SetActor("ordinaryUser")
+			tctx.Error("permission denied", "assetGet", "mockincident", incidentUUID, false, "")
+			proposal := tctx.Rmap("proposalApprove", proposalUUID)
+			Expect(proposal.Mapa).To(HaveKeyWithValue(konst.ProposalStatusKey, konst.ProposalExecuted))
+			Expect(proposal.Mapa).To(HaveKeyWithValue(konst.ProposalExecutedByKey, tctx.GetActorFingerprint("ordinaryUser")))
+			Expect(proposal.Mapa).To(HaveKey(konst.ProposalResultKey))
+
+			tctx.SetActor("superUser")
+			tctx.Error("state entry not found", "assetGet", "mockincident", incidentUUID, false, "")
+			tctx.Error("proposal is not pending, status: executed", "proposalApprove", proposalUUID)
+		})
+
+		It("Should wait for quorum of distinct approvers", func() {
+			proposalUUID := propose("registryUpsert", "mockproposed", string(rmap.MustNewFromYAMLFile("../internal/testdata/assets/mockstate.yaml").Bytes()))
+
+			tctx.SetActor("ordinaryUser")
+			proposal := tctx.Rmap("proposalApprove", proposalUUID)
+			Expect(proposal.Mapa).To(HaveKeyWithValue(konst.ProposalStatusKey, konst.ProposalPending))
+			Expect(proposal.Mapa[konst.ProposalApprovalsKey]).To(HaveLen(1))
+			tctx.Error("proposal is already approved by this identity", "proposalApprove", proposalUUID)
+
+			tctx.SetActor("superUser")
+			tctx.Error("not found", "registryGet", "mockproposed", -1)
+
+			tctx.SetActor("nobodyUser")
+			proposal = tctx.Rmap("proposalApprove", proposalUUID)
+			Expect(proposal.Mapa).To(HaveKeyWithValue(konst.ProposalStatusKey, konst.ProposalExecuted))
+			Expect(proposal.Mapa[konst.ProposalApprovalsKey]).To(HaveLen(2))
+
+			tctx.SetActor("superUser")
+			tctx.Ok("registryGet", "mockproposed", -1)
+		})
+
+		It("Should fail approval when requester is not allowed to execute proposed function", func() {
+			tctx.SetActor("ordinaryUser")
+			proposalUUID := propose("assetDeleteDirect", "mockincident", incidentUUID)
+
+			tctx.SetActor("nobodyUser")
+			tctx.Error("permission denied", "proposalApprove", proposalUUID)
+
+			proposal := tctx.Rmap("assetGet", "proposal", proposalUUID, false, "")
+			Expect(proposal.Mapa).To(HaveKeyWithValue(konst.ProposalStatusKey, konst.ProposalPending))
+			Expect(proposal.Mapa[konst.ProposalApprovalsKey]).To(BeEmpty())
+		})
+
+		It("Should expire proposal after timeout", func() {
+			proposalUUID := propose("assetDeleteDirect", "mockincident", incidentUUID)
+			tctx.TravelInTime(2 * 3600)
+
+			tctx.SetActor("ordinaryUser")
+			proposal := tctx.Rmap("proposalApprove", proposalUUID)
+			Expect(proposal.Mapa).To(HaveKeyWithValue(konst.ProposalStatusKey, konst.ProposalExpired))
+
+			tctx.SetActor("superUser")
+			tctx.Ok("assetGet", "mockincident", incidentUUID, false, "")
+			tctx.Error("proposal is not pending, status: expired", "proposalReject", proposalUUID, "")
+		})
+	})
+
+	Describe("Call to CC method proposalReject", func() {
+		It("Should reject proposal by approver", func() {
+			proposalUUID := propose("assetDeleteDirect", "mockincident", incidentUUID)
+
+			tctx.Ok("assetUpdate", "identity", tctx.GetActorFingerprint("nobodyUser"), rmap.NewFromMap(map[string]interface{}{"roles": []string{}}).Bytes())
+			tctx.SetActor("nobodyUser")
+			tctx.Error("permission denied", "proposalReject", proposalUUID, "")
+
+			tctx.SetActor("ordinaryUser")
+			proposal := tctx.Rmap("proposalReject", proposalUUID, rmap.NewFromMap(map[string]interface{}{"reason": "not now"}).Bytes())
+			Expect(proposal.Mapa).To(HaveKeyWithValue(konst.ProposalStatusKey, konst.ProposalRejected))
+			Expect(proposal.Mapa).To(HaveKeyWithValue(konst.ProposalRejectedByKey, tctx.GetActorFingerprint("ordinaryUser")))
+			Expect(proposal.Mapa).To(HaveKeyWithValue(konst.ProposalReasonKey, "not now"))
+
+			tctx.Error("proposal is not pending, status: rejected", "proposalApprove", proposalUUID)
+		})
+
+		It("Should allow requester to withdraw proposal", func() {
+			tctx.SetActor("ordinaryUser")
+			proposalUUID := propose("assetDeleteDirect", "mockincident", incidentUUID)
+
+			tctx.SetActor("superUser")
+			tctx.Ok("assetUpdate", "identity", tctx.GetActorFingerprint("ordinaryUser"), rmap.NewFromMap(map[string]interface{}{"roles": []string{}}).Bytes())
+
+			tctx.SetActor("ordinaryUser")
+			proposal := tctx.Rmap("proposalReject", proposalUUID, "")
+			Expect(proposal.Mapa).To(HaveKeyWithValue(konst.ProposalStatusKey, konst.ProposalRejected))
+			Expect(proposal.Mapa).NotTo(HaveKey(konst.ProposalReasonKey))
+		})
+	})
+
+	Describe("History of proposals", func() {
+		It("Should be queryable", func() {
+			executedUUID := propose("assetDeleteDirect", "mockincident", incidentUUID)
+			rejectedUUID := propose("registryUpsert", "mockproposed", string(rmap.MustNewFromYAMLFile("../internal/testdata/assets/mockstate.yaml").Bytes()))
+
+			tctx.SetActor("ordinaryUser")
+			tctx.Ok("proposalApprove", executedUUID)
+			tctx.Ok("proposalReject", rejectedUUID, "")
+
+			tctx.SetActor("superUser")
+			query := fmt.Sprintf(`{"selector": {"status": "%s"}}`, konst.ProposalExecuted)
+			executed := tctx.JSONNoResult("assetQuery", "proposal", query, false)["result"].([]interface{})
+			Expect(executed).To(HaveLen(1))
+			Expect(executed[0]).To(HaveKeyWithValue("uuid", executedUUID))
+
+			rejected := tctx.Rmap("assetGet", "proposal", rejectedUUID, false, "")
+			Expect(rejected.Mapa).To(HaveKeyWithValue(konst.ProposalStatusKey, konst.ProposalRejected))
+		})
+	})
+})
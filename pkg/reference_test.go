@@ -0,0 +1,222 @@
+package cc_core
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/KompiTech/fabric-cc-core/v2/pkg/konst"
+	. "github.com/KompiTech/fabric-cc-core/v2/pkg/testing"
+	"github.com/KompiTech/rmap"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("referential integrity tests", func() {
+	var tctx *TestContext
+
+	BeforeEach(func() {
+		tctx = getDefaultTextContext()
+		tctx.InitOk(tctx.GetInit("../internal/testdata/assets", "").Bytes())
+		tctx.RegisterAllActors()
+	})
+
+	// upsertPolicies creates version 2 of mockincident with set-null policy for timelogs and version 2 of mocktimelog with cascade policy for incident
+	upsertPolicies := func() {
+		incidentV2 := rmap.MustNewFromYAMLFile("../internal/testdata/assets/mockincident.yaml")
+		incidentV2.Mapa["on_delete"] = map[string]interface{}{"/timelogs": "set-null"}
+		tctx.Ok("registryUpsert", "mockincident", incidentV2.Bytes())
+
+		timelogV2 := rmap.MustNewFromYAMLFile("../internal/testdata/assets/mocktimelog.yaml")
+		timelogV2.MustSetJPtr("/schema/properties/incident/description", "REF->MOCKINCIDENT ON_DELETE->cascade Related Incident")
+		tctx.Ok("registryUpsert", "mocktimelog", timelogV2.Bytes())
+	}
+
+	// declareRestrict creates new version of asset with restrict policy for reference on pointer
+	declareRestrict := func(name, pointer string) {
+		item := rmap.MustNewFromYAMLFile(fmt.Sprintf("../internal/testdata/assets/%s.yaml", name))
+		item.Mapa["on_delete"] = map[string]interface{}{pointer: "restrict"}
+		tctx.Ok("registryUpsert", name, item.Bytes())
+	}
+
+	It("Should not enforce reference without declared delete policy", func() {
+		incidentID := MustGetID(tctx.Rmap("assetCreate", "mockincident", `{"description":"referenced"}`, -1, ""))
+		timelogID := MustGetID(tctx.Rmap("assetCreate", "mocktimelog", fmt.Sprintf(`{"incident":"%s"}`, incidentID), -1, ""))
+
+		tctx.Ok("assetDelete", "mockincident", incidentID)
+
+		// referencing asset keeps dangling reference
+		timelog := tctx.Rmap("assetGet", "mocktimelog", timelogID, false, "")
+		Expect(timelog.Mapa).To(HaveKeyWithValue("incident", incidentID))
+	})
+
+	It("Should restrict delete of referenced asset, when restrict is declared", func() {
+		declareRestrict("mockincident", "/timelogs")
+		declareRestrict("mocktimelog", "/incident")
+
+		incidentID := MustGetID(tctx.Rmap("assetCreate", "mockincident", `{"description":"referenced"}`, -1, ""))
+		timelogID := MustGetID(tctx.Rmap("assetCreate", "mocktimelog", fmt.Sprintf(`{"incident":"%s"}`, incidentID), -1, ""))
+		tctx.Ok("assetUpdate", "mockincident", incidentID, fmt.Sprintf(`{"timelogs":["%s"]}`, timelogID))
+
+		Expect(tctx.ErrorJSON(fmt.Sprintf("asset is referenced by: MOCKTIMELOG, id: %s, field: /incident", timelogID), "assetDelete", "mockincident", incidentID).Mapa).To(HaveKeyWithValue(konst.ErrorStatusKey, float64(409)))
+		Expect(tctx.ErrorJSON(fmt.Sprintf("asset is referenced by: MOCKINCIDENT, id: %s, field: /timelogs/0", incidentID), "assetDelete", "mocktimelog", timelogID).Mapa).To(HaveKeyWithValue(konst.ErrorStatusKey, float64(409)))
+
+		// referencing asset is still valid
+		tctx.Ok("assetUpdate", "mocktimelog", timelogID, `{"expenses":[]}`)
+	})
+
+	It("Should update index when reference changes", func() {
+		declareRestrict("mockreffieldblacklist", "/blacklisted")
+
+		firstID := MustGetID(tctx.Rmap("assetCreate", "mockincident", `{"description":"first"}`, -1, ""))
+		secondID := MustGetID(tctx.Rmap("assetCreate", "mockincident", `{"description":"second"}`, -1, ""))
+		requestID := MustGetID(tctx.Rmap("assetCreate", "mockreffieldblacklist", fmt.Sprintf(`{"blacklisted":"%s"}`, firstID), -1, ""))
+
+		tctx.Error("asset is referenced by: MOCKREFFIELDBLACKLIST", "assetDelete", "mockincident", firstID)
+
+		tctx.Ok("assetUpdate", "mockreffieldblacklist", requestID, fmt.Sprintf(`{"blacklisted":"%s"}`, secondID))
+		tctx.Ok("assetDelete", "mockincident", firstID)
+		tctx.Error("asset is referenced by: MOCKREFFIELDBLACKLIST", "assetDelete", "mockincident", secondID)
+	})
+
+	It("Should cascade delete to referencing asset", func() {
+		upsertPolicies()
+
+		incidentID := MustGetID(tctx.Rmap("assetCreate", "mockincident", `{"description":"cascade"}`, -1, ""))
+		timelogID := MustGetID(tctx.Rmap("assetCreate", "mocktimelog", fmt.Sprintf(`{"incident":"%s"}`, incidentID), -1, ""))
+		tctx.Ok("assetUpdate", "mockincident", incidentID, fmt.Sprintf(`{"timelogs":["%s"]}`, timelogID))
+
+		tctx.Ok("assetDelete", "mockincident", incidentID)
+		tctx.Error("state entry not found", "assetGet", "mockincident", incidentID, false, "")
+		tctx.Error("state entry not found", "assetGet", "mocktimelog", timelogID, false, "")
+	})
+
+	It("Should remove reference from referencing asset", func() {
+		upsertPolicies()
+
+		incidentID := MustGetID(tctx.Rmap("assetCreate", "mockincident", `{"description":"set-null"}`, -1, ""))
+		firstID := MustGetID(tctx.Rmap("assetCreate", "mocktimelog", fmt.Sprintf(`{"incident":"%s"}`, incidentID), -1, ""))
+		secondID := MustGetID(tctx.Rmap("assetCreate", "mocktimelog", fmt.Sprintf(`{"incident":"%s"}`, incidentID), -1, ""))
+		tctx.Ok("assetUpdate", "mockincident", incidentID, fmt.Sprintf(`{"timelogs":["%s","%s"]}`, firstID, secondID))
+
+		tctx.Ok("assetDelete", "mocktimelog", firstID)
+
+		incident := tctx.Rmap("assetGet", "mockincident", incidentID, false, "")
+		Expect(incident.Mapa).To(HaveKeyWithValue("timelogs", []interface{}{secondID}))
+	})
+
+	Context("chaincode upgraded from version without reference index", func() {
+		var incidentID, timelogID string
+
+		BeforeEach(func() {
+			declareRestrict("mocktimelog", "/incident")
+
+			incidentID = MustGetID(tctx.Rmap("assetCreate", "mockincident", `{"description":"legacy"}`, -1, ""))
+			timelogID = MustGetID(tctx.Rmap("assetCreate", "mocktimelog", fmt.Sprintf(`{"incident":"%s"}`, incidentID), -1, ""))
+
+			// remove index and its marker, as if assets were stored before the index was introduced
+			cc := tctx.GetCC()
+			cc.MockTransactionStart("legacy")
+			for key := range cc.State {
+				if key == konst.ReferenceIndexStateKey || strings.HasPrefix(key, "\x00"+konst.ReferenceIndexPrefix+"\x00") {
+					Expect(cc.DelState(key)).To(Succeed())
+				}
+			}
+			cc.MockTransactionEnd("legacy")
+		})
+
+		It("Should not apply delete policies until index is backfilled", func() {
+			tctx.Ok("assetDelete", "mockincident", incidentID)
+		})
+
+		It("Should backfill index page by page and apply delete policies", func() {
+			input := rmap.NewFromMap(map[string]interface{}{"limit": 1})
+			indexed := []interface{}{}
+
+			for {
+				output := tctx.JSONNoResult("functionInvoke", konst.IndexReferencesFuncName, input.Bytes())
+				result := output[konst.OutputResultKey].([]interface{})
+				Expect(len(result)).To(BeNumerically("<=", 1))
+				indexed = append(indexed, result...)
+
+				if output[konst.OutputBookmarkKey] == "" {
+					break
+				}
+				input.Mapa["bookmark"] = output[konst.OutputBookmarkKey]
+			}
+
+			Expect(indexed).To(ContainElement(map[string]interface{}{konst.AssetDocTypeKey: "MOCKTIMELOG", konst.AssetIdKey: timelogID}))
+
+			tctx.Error(fmt.Sprintf("asset is referenced by: MOCKTIMELOG, id: %s, field: /incident", timelogID), "assetDelete", "mockincident", incidentID)
+		})
+	})
+
+	Context("assetReferrers method", func() {
+		var incidentID string
+
+		BeforeEach(func() {
+			incidentID = MustGetID(tctx.Rmap("assetCreate", "mockincident", `{"description":"referenced"}`, -1, ""))
+		})
+
+		It("Should return all referencing assets", func() {
+			timelogID := MustGetID(tctx.Rmap("assetCreate", "mocktimelog", fmt.Sprintf(`{"incident":"%s"}`, incidentID), -1, ""))
+			requestID := MustGetID(tctx.Rmap("assetCreate", "mockreffieldblacklist", fmt.Sprintf(`{"blacklisted":"%s"}`, incidentID), -1, ""))
+
+			output := tctx.JSONNoResult("assetReferrers", "mockincident", incidentID, "")
+			Expect(output[konst.OutputResultKey]).To(ConsistOf(
+				map[string]interface{}{konst.AssetDocTypeKey: "MOCKTIMELOG", konst.AssetIdKey: timelogID, konst.ReferrerPointerKey: "/incident"},
+				map[string]interface{}{konst.AssetDocTypeKey: "MOCKREFFIELDBLACKLIST", konst.AssetIdKey: requestID, konst.ReferrerPointerKey: "/blacklisted"},
+			))
+			Expect(output[konst.OutputBookmarkKey]).To(BeEmpty())
+
+			output = tctx.JSONNoResult("assetReferrers", "mocktimelog", timelogID, "")
+			Expect(output[konst.OutputResultKey]).To(ConsistOf(
+				map[string]interface{}{konst.AssetDocTypeKey: "MOCKINCIDENT", konst.AssetIdKey: incidentID, konst.ReferrerPointerKey: "/timelogs/0"},
+			))
+		})
+
+		It("Should paginate referencing assets", func() {
+			for i := 0; i < konst.PageSize+1; i++ {
+				tctx.Ok("assetCreate", "mocktimelog", fmt.Sprintf(`{"incident":"%s"}`, incidentID), -1, "")
+			}
+
+			output := tctx.JSONNoResult("assetReferrers", "mockincident", incidentID, "")
+			Expect(output[konst.OutputResultKey]).To(HaveLen(konst.PageSize))
+			bookmark := output[konst.OutputBookmarkKey].(string)
+			Expect(bookmark).NotTo(BeEmpty())
+
+			output = tctx.JSONNoResult("assetReferrers", "mockincident", incidentID, bookmark)
+			Expect(output[konst.OutputResultKey]).To(HaveLen(1))
+			Expect(output[konst.OutputBookmarkKey]).To(BeEmpty())
+		})
+
+		It("Should filter referencing assets that cannot be read", func() {
+			timelogID := MustGetID(tctx.Rmap("assetCreate", "mocktimelog", fmt.Sprintf(`{"incident":"%s"}`, incidentID), -1, ""))
+			requestID := MustGetID(tctx.Rmap("assetCreate", "mockreffieldblacklist", fmt.Sprintf(`{"blacklisted":"%s"}`, incidentID), -1, ""))
+
+			tctx.SetActor("ordinaryUser")
+			tctx.Error("permission denied", "assetReferrers", "mockincident", incidentID, "")
+
+			tctx.SetActor("superUser")
+			role := tctx.JSON("roleCreate", rmap.NewFromMap(map[string]interface{}{
+				"name": "referrersRole",
+				"grants": []map[string]interface{}{{
+					"object": "/mockincident/*",
+					"action": "read",
+				}, {
+					"object": "/mocktimelog/*",
+					"action": "read",
+				}},
+			}).Bytes(), "")
+			tctx.Ok("identityUpdate", tctx.GetActorFingerprint("ordinaryUser"), rmap.NewFromMap(map[string]interface{}{
+				"roles": []string{role[konst.AssetIdKey].(string)},
+			}).Bytes())
+
+			tctx.SetActor("ordinaryUser")
+			output := tctx.JSONNoResult("assetReferrers", "mockincident", incidentID, "")
+			Expect(output[konst.OutputResultKey]).To(ConsistOf(
+				map[string]interface{}{konst.AssetDocTypeKey: "MOCKTIMELOG", konst.AssetIdKey: timelogID, konst.ReferrerPointerKey: "/incident"},
+				And(HaveKeyWithValue(konst.AssetIdKey, requestID), HaveKeyWithValue(konst.FilteredKey, ContainSubstring("permission denied"))),
+			))
+		})
+	})
+})
@@ -1,6 +1,7 @@
 package cc_core
 
 import (
+	"github.com/KompiTech/fabric-cc-core/v2/pkg/konst"
 	. "github.com/KompiTech/fabric-cc-core/v2/pkg/testing"
 	"github.com/KompiTech/rmap"
 	. "github.com/onsi/ginkgo"
@@ -272,6 +273,55 @@ var _ = Describe("registry* method family tests", func() {
 				Expect(change).To(HaveKeyWithValue("version", float64(3)))
 			})
 
+			It("Should return compatibility report of new version", func() {
+				myRegItem := v2.Copy()
+				myRegItem.MustSetJPtr("/schema/properties/foo", map[string]interface{}{"type": "string"})
+				result := tctx.Rmap("registryUpsert", assetName, myRegItem.Bytes())
+				Expect(result.MustGetJPtr("/compatibility")).To(Equal(map[string]interface{}{
+					"compatibility": "backward-compatible",
+					"changes": []interface{}{map[string]interface{}{
+						"pointer":       "/foo",
+						"description":   "property added",
+						"compatibility": "backward-compatible",
+					}},
+				}))
+			})
+
+			It("Should reject change of schema, that is not backward compatible, unless forced", func() {
+				myRegItem := v2.Copy()
+				myRegItem.MustSetJPtr("/schema/properties/foobar/type", "integer")
+				myRegItem.MustSetJPtr("/schema/required", []interface{}{"description", "foo"})
+				myRegItem.MustSetJPtr("/schema/properties/foo", map[string]interface{}{"type": "string"})
+
+				Expect(tctx.ErrorJSON("change(s) of schema not backward compatible: /foo: required property added without default, /foobar: type changed from: string to: integer, use force to upsert anyway", "registryUpsert", assetName, myRegItem.Bytes()).Mapa).To(HaveKeyWithValue(konst.ErrorStatusKey, float64(409)))
+				tctx.Error("change(s) of schema not backward compatible", "registryUpsert", assetName, myRegItem.Bytes(), false)
+
+				result := tctx.Rmap("registryUpsert", assetName, myRegItem.Bytes(), true)
+				Expect(result.Mapa).To(HaveKeyWithValue("version", float64(3)))
+				Expect(result.MustGetJPtrString("/compatibility/compatibility")).To(Equal("breaking"))
+			})
+
+			It("Should allow required field added with migration default", func() {
+				myRegItem := v2.Copy()
+				myRegItem.MustSetJPtr("/schema/required", []interface{}{"description", "foobar"})
+				myRegItem.Mapa["migration"] = map[string]interface{}{
+					"defaults": map[string]interface{}{"/foobar": "none"},
+				}
+
+				result := tctx.Rmap("registryUpsert", assetName, myRegItem.Bytes())
+				Expect(result.MustGetJPtrString("/compatibility/compatibility")).To(Equal("fully-compatible"))
+			})
+
+			It("Should reject forward compatible change of schema unless forced", func() {
+				myRegItem := v2.Copy()
+				myRegItem.MustSetJPtr("/schema/properties/foobar/maxLength", 10)
+
+				tctx.Error("change(s) of schema not backward compatible: /foobar: maxLength added: 10, use force to upsert anyway", "registryUpsert", assetName, myRegItem.Bytes())
+
+				result := tctx.Rmap("registryUpsert", assetName, myRegItem.Bytes(), true)
+				Expect(result.MustGetJPtrString("/compatibility/compatibility")).To(Equal("forward-compatible"))
+			})
+
 			It("Should not create registryItem with next version and not produce changelog item if schema is identical to previous version", func() {
 				result := tctx.Rmap("registryUpsert", assetName, v2.Bytes()).Mapa
 				Expect(result).To(HaveKeyWithValue("name", assetName))
@@ -367,6 +417,8 @@ var _ = Describe("registry* method family tests", func() {
 				"mockworknote":          struct{}{},
 				"mockworknoteparent":    struct{}{},
 				"mocklegacyschema": 	 struct{}{},
+				"mockevent":             struct{}{},
+				"mocksoftdelete":        struct{}{},
 			}
 			Expect(seen.Mapa).To(Equal(refMap))
 		})
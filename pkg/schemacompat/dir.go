@@ -0,0 +1,67 @@
+package schemacompat
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/KompiTech/rmap"
+	"github.com/pkg/errors"
+)
+
+// AnalyzeDirs compares registry items in YAML files of newDir with files of the same name in oldDir
+// returns report for each asset name present in both directories, new asset names are skipped
+func AnalyzeDirs(oldDir, newDir string) (map[string]Report, error) {
+	oldItems, err := readDir(oldDir)
+	if err != nil {
+		return nil, err
+	}
+
+	newItems, err := readDir(newDir)
+	if err != nil {
+		return nil, err
+	}
+
+	reports := map[string]Report{}
+
+	for name, newItem := range newItems {
+		oldItem, exists := oldItems[name]
+		if !exists {
+			continue
+		}
+
+		report, err := Analyze(oldItem, newItem)
+		if err != nil {
+			return nil, errors.Wrapf(err, "Analyze() failed for: %s", name)
+		}
+
+		reports[name] = report
+	}
+
+	return reports, nil
+}
+
+// readDir reads all registry items from YAML files in directory, file name without extension is asset name
+func readDir(dir string) (map[string]rmap.Rmap, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, errors.Wrap(err, "ioutil.ReadDir() failed")
+	}
+
+	items := map[string]rmap.Rmap{}
+
+	for _, info := range files {
+		if info.IsDir() || !strings.HasSuffix(info.Name(), ".yaml") {
+			continue
+		}
+
+		item, err := rmap.NewFromYAMLFile(filepath.Join(dir, info.Name()))
+		if err != nil {
+			return nil, errors.Wrapf(err, "rmap.NewFromYAMLFile() failed for: %s", info.Name())
+		}
+
+		items[strings.ToLower(strings.TrimSuffix(info.Name(), ".yaml"))] = item
+	}
+
+	return items, nil
+}
@@ -0,0 +1,543 @@
+package schemacompat
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/KompiTech/fabric-cc-core/v2/pkg/konst"
+	"github.com/KompiTech/rmap"
+)
+
+// Compatibility is classification of schema change. Instances of previous version are taken after migration of new version
+type Compatibility string
+
+const (
+	FullyCompatible    Compatibility = "fully-compatible"    // instance is valid for new version exactly when it is valid for previous version
+	BackwardCompatible Compatibility = "backward-compatible" // every instance valid for previous version is valid for new version, but not the other way around
+	ForwardCompatible  Compatibility = "forward-compatible"  // every instance valid for new version is valid for previous version, but existing instances might not be valid for new version
+	Breaking           Compatibility = "breaking"            // neither backward nor forward compatible
+)
+
+// classify returns compatibility of change from its two properties: isBackward if every instance valid for previous version is valid for new version,
+// isForward if every instance valid for new version is valid for previous version
+func classify(isBackward, isForward bool) Compatibility {
+	switch {
+	case isBackward && isForward:
+		return FullyCompatible
+	case isBackward:
+		return BackwardCompatible
+	case isForward:
+		return ForwardCompatible
+	default:
+		return Breaking
+	}
+}
+
+// IsBackward returns true, if existing instances stay valid for new version
+func (c Compatibility) IsBackward() bool {
+	return c == FullyCompatible || c == BackwardCompatible
+}
+
+// IsForward returns true, if instances of new version are valid for previous version
+func (c Compatibility) IsForward() bool {
+	return c == FullyCompatible || c == ForwardCompatible
+}
+
+const (
+	typeKey                 = "type"
+	enumKey                 = "enum"
+	descriptionKey          = "description"
+	propertiesKey           = "properties"
+	requiredKey             = "required"
+	itemsKey                = "items"
+	additionalPropertiesKey = "additionalProperties"
+	uniqueItemsKey          = "uniqueItems"
+)
+
+// upperBounds are numeric keywords, where lower value makes schema stricter
+var upperBounds = []string{"maxLength", "maxItems", "maxProperties", "maximum", "exclusiveMaximum"}
+
+// lowerBounds are numeric keywords, where higher value makes schema stricter
+var lowerBounds = []string{"minLength", "minItems", "minProperties", "minimum", "exclusiveMinimum"}
+
+// restrictions are keywords, where any added or changed value can make existing instances invalid
+var restrictions = []string{"pattern", "format", "const", "$ref"}
+
+// Change is single difference between two versions of schema
+type Change struct {
+	Pointer       string        `json:"pointer"`
+	Description   string        `json:"description"`
+	Compatibility Compatibility `json:"compatibility"`
+}
+
+// Report is result of compatibility analysis, Compatibility is classification of all changes together
+type Report struct {
+	Compatibility Compatibility `json:"compatibility"`
+	Changes       []Change      `json:"changes"`
+}
+
+// IsBackward returns true, if all existing instances stay valid for new version
+func (r Report) IsBackward() bool {
+	return r.Compatibility.IsBackward()
+}
+
+// IncompatibleChanges returns changes, that might make existing instances invalid for new version
+func (r Report) IncompatibleChanges() []Change {
+	incompatible := []Change{}
+	for _, change := range r.Changes {
+		if !change.Compatibility.IsBackward() {
+			incompatible = append(incompatible, change)
+		}
+	}
+	return incompatible
+}
+
+// Rmap returns report as Rmap
+func (r Report) Rmap() rmap.Rmap {
+	changes := make([]interface{}, 0, len(r.Changes))
+	for _, change := range r.Changes {
+		changes = append(changes, map[string]interface{}{
+			"pointer":       change.Pointer,
+			"description":   change.Description,
+			"compatibility": string(change.Compatibility),
+		})
+	}
+
+	return rmap.NewFromMap(map[string]interface{}{
+		"compatibility": string(r.Compatibility),
+		"changes":       changes,
+	})
+}
+
+// String returns human readable list of changes
+func (c Change) String() string {
+	return fmt.Sprintf("%s: %s", c.Pointer, c.Description)
+}
+
+// analyzer holds state of one analysis
+type analyzer struct {
+	added   map[string]bool // pointers of fields, that are set for existing instances by migration of new version
+	removed map[string]bool // pointers of fields, that are removed from existing instances by migration of new version
+	changes []Change
+}
+
+// Analyze compares schema of registry item newItem with previous version oldItem and classifies all changes
+// migration definition of newItem is taken into account, fields that it sets or removes do not break existing instances
+func Analyze(oldItem, newItem rmap.Rmap) (Report, error) {
+	oldSchema, err := oldItem.GetRmap(konst.RegistryItemSchemaKey)
+	if err != nil {
+		return Report{}, err
+	}
+
+	newSchema, err := newItem.GetRmap(konst.RegistryItemSchemaKey)
+	if err != nil {
+		return Report{}, err
+	}
+
+	a := &analyzer{
+		added:   map[string]bool{},
+		removed: map[string]bool{},
+		changes: []Change{},
+	}
+
+	if newItem.Exists(konst.RegistryItemMigrationKey) {
+		migration, err := newItem.GetRmap(konst.RegistryItemMigrationKey)
+		if err != nil {
+			return Report{}, err
+		}
+
+		if err := a.loadMigration(migration); err != nil {
+			return Report{}, err
+		}
+	}
+
+	a.compareNode("", oldSchema.Mapa, newSchema.Mapa)
+
+	// whole schema is backward (forward) compatible only if all changes are
+	isBackward, isForward := true, true
+	for _, change := range a.changes {
+		isBackward = isBackward && change.Compatibility.IsBackward()
+		isForward = isForward && change.Compatibility.IsForward()
+	}
+
+	report := Report{
+		Compatibility: classify(isBackward, isForward),
+		Changes:       a.changes,
+	}
+
+	return report, nil
+}
+
+// loadMigration collects pointers of fields, that migration sets or removes
+func (a *analyzer) loadMigration(migration rmap.Rmap) error {
+	if migration.Exists(konst.MigrationRenameKey) {
+		renames, err := migration.GetRmap(konst.MigrationRenameKey)
+		if err != nil {
+			return err
+		}
+
+		for from, toI := range renames.Mapa {
+			a.removed[from] = true
+			if to, ok := toI.(string); ok {
+				a.added[to] = true
+			}
+		}
+	}
+
+	if migration.Exists(konst.MigrationDefaultsKey) {
+		defaults, err := migration.GetRmap(konst.MigrationDefaultsKey)
+		if err != nil {
+			return err
+		}
+
+		for ptr := range defaults.Mapa {
+			a.added[ptr] = true
+		}
+	}
+
+	if migration.Exists(konst.MigrationPatchKey) {
+		ops, err := migration.GetIterable(konst.MigrationPatchKey)
+		if err != nil {
+			return err
+		}
+
+		for _, opI := range ops {
+			op, ok := opI.(map[string]interface{})
+			if !ok {
+				continue
+			}
+
+			path, _ := op["path"].(string)
+			from, _ := op["from"].(string)
+
+			switch op["op"] {
+			case "add", "replace", "copy":
+				a.added[path] = true
+			case "move":
+				a.added[path] = true
+				a.removed[from] = true
+			case "remove":
+				a.removed[path] = true
+			}
+		}
+	}
+
+	return nil
+}
+
+func (a *analyzer) add(pointer string, compatibility Compatibility, format string, args ...interface{}) {
+	if pointer == "" {
+		pointer = "/"
+	}
+
+	a.changes = append(a.changes, Change{
+		Pointer:       pointer,
+		Description:   fmt.Sprintf(format, args...),
+		Compatibility: compatibility,
+	})
+}
+
+// compareNode compares schemas of one field
+func (a *analyzer) compareNode(pointer string, oldNode, newNode map[string]interface{}) {
+	a.compareTypes(pointer, oldNode, newNode)
+	a.compareEnums(pointer, oldNode, newNode)
+	a.compareRefs(pointer, oldNode, newNode)
+	a.compareBounds(pointer, oldNode, newNode)
+	a.compareRestrictions(pointer, oldNode, newNode)
+
+	if pointer != "" {
+		// additionalProperties on top level is always false
+		oldAP, newAP := isClosed(oldNode), isClosed(newNode)
+		if !oldAP && newAP {
+			a.add(pointer, ForwardCompatible, "additional properties are no longer allowed")
+		} else if oldAP && !newAP {
+			a.add(pointer, BackwardCompatible, "additional properties are allowed")
+		}
+	}
+
+	if oldNode[propertiesKey] != nil || newNode[propertiesKey] != nil {
+		a.compareProperties(pointer, oldNode, newNode)
+	}
+
+	oldItems, oldIsMap := oldNode[itemsKey].(map[string]interface{})
+	newItems, newIsMap := newNode[itemsKey].(map[string]interface{})
+	if oldIsMap && newIsMap {
+		a.compareNode(pointer+"/"+itemsKey, oldItems, newItems)
+	} else if !reflect.DeepEqual(oldNode[itemsKey], newNode[itemsKey]) {
+		a.compareRestriction(pointer, itemsKey, oldNode[itemsKey], newNode[itemsKey])
+	}
+}
+
+// compareProperties compares properties of object and their required flag
+func (a *analyzer) compareProperties(pointer string, oldNode, newNode map[string]interface{}) {
+	oldProps, _ := oldNode[propertiesKey].(map[string]interface{})
+	newProps, _ := newNode[propertiesKey].(map[string]interface{})
+	oldRequired, newRequired := stringSet(oldNode[requiredKey]), stringSet(newNode[requiredKey])
+
+	names := map[string]bool{}
+	for name := range oldProps {
+		names[name] = true
+	}
+	for name := range newProps {
+		names[name] = true
+	}
+
+	for _, name := range sortedKeys(names) {
+		ptr := pointer + "/" + name
+		oldProp, inOld := oldProps[name].(map[string]interface{})
+		newProp, inNew := newProps[name].(map[string]interface{})
+
+		switch {
+		case inOld && !inNew:
+			// instances of new version cannot contain removed property only if new object is closed, then they are valid for previous version unless it was required
+			isForward := isClosed(newNode) && !oldRequired[name]
+
+			description := "property removed"
+			if oldRequired[name] {
+				description = "required property removed"
+			}
+
+			if a.removed[ptr] || !isClosed(newNode) {
+				a.add(ptr, classify(true, isForward), description)
+			} else {
+				a.add(ptr, classify(false, isForward), description+", existing instances containing it are not valid")
+			}
+		case !inOld && inNew:
+			// instances of new version containing added property are valid for previous version only if its object is open
+			isForward := !isClosed(oldNode)
+
+			if newRequired[name] {
+				if a.added[ptr] {
+					a.add(ptr, classify(true, isForward), "required property added, it is set by migration")
+				} else {
+					a.add(ptr, classify(false, isForward), "required property added without default")
+				}
+			} else {
+				// existing instances of open object might contain property with value not valid for its new schema
+				a.add(ptr, classify(isClosed(oldNode), isForward), "property added")
+			}
+		case inOld && inNew:
+			a.compareNode(ptr, oldProp, newProp)
+
+			if !oldRequired[name] && newRequired[name] {
+				if a.added[ptr] {
+					a.add(ptr, FullyCompatible, "property became required, it is set by migration")
+				} else {
+					a.add(ptr, ForwardCompatible, "property became required without default")
+				}
+			} else if oldRequired[name] && !newRequired[name] {
+				a.add(ptr, BackwardCompatible, "property is no longer required")
+			}
+		}
+	}
+}
+
+func (a *analyzer) compareTypes(pointer string, oldNode, newNode map[string]interface{}) {
+	oldTypes, newTypes := stringSet(oldNode[typeKey]), stringSet(newNode[typeKey])
+
+	switch {
+	case reflect.DeepEqual(oldTypes, newTypes):
+		return
+	case len(newTypes) == 0:
+		a.add(pointer, BackwardCompatible, "type constraint removed")
+	case len(oldTypes) == 0:
+		a.add(pointer, ForwardCompatible, "type constraint added: %s", strings.Join(sortedKeys(newTypes), ","))
+	case typesCover(newTypes, oldTypes):
+		a.add(pointer, BackwardCompatible, "type widened from: %s to: %s", strings.Join(sortedKeys(oldTypes), ","), strings.Join(sortedKeys(newTypes), ","))
+	case typesCover(oldTypes, newTypes):
+		a.add(pointer, ForwardCompatible, "type narrowed from: %s to: %s", strings.Join(sortedKeys(oldTypes), ","), strings.Join(sortedKeys(newTypes), ","))
+	default:
+		a.add(pointer, Breaking, "type changed from: %s to: %s", strings.Join(sortedKeys(oldTypes), ","), strings.Join(sortedKeys(newTypes), ","))
+	}
+}
+
+func (a *analyzer) compareEnums(pointer string, oldNode, newNode map[string]interface{}) {
+	oldEnum, oldExists := oldNode[enumKey].([]interface{})
+	newEnum, newExists := newNode[enumKey].([]interface{})
+
+	switch {
+	case !oldExists && !newExists:
+		return
+	case !newExists:
+		a.add(pointer, BackwardCompatible, "enum removed")
+		return
+	case !oldExists:
+		a.add(pointer, ForwardCompatible, "enum added")
+		return
+	}
+
+	removed, added := []string{}, []string{}
+	for _, value := range oldEnum {
+		if !containsValue(newEnum, value) {
+			removed = append(removed, fmt.Sprint(value))
+		}
+	}
+	for _, value := range newEnum {
+		if !containsValue(oldEnum, value) {
+			added = append(added, fmt.Sprint(value))
+		}
+	}
+
+	if len(removed) > 0 {
+		a.add(pointer, ForwardCompatible, "enum value(s) removed: %s", strings.Join(removed, ","))
+	}
+
+	if len(added) > 0 {
+		a.add(pointer, BackwardCompatible, "enum value(s) added: %s", strings.Join(added, ","))
+	}
+}
+
+func (a *analyzer) compareRefs(pointer string, oldNode, newNode map[string]interface{}) {
+	oldRef, newRef := getRef(oldNode), getRef(newNode)
+
+	switch {
+	case oldRef == newRef:
+		return
+	case newRef == "":
+		a.add(pointer, BackwardCompatible, "reference to: %s removed", oldRef)
+	case oldRef == "":
+		a.add(pointer, ForwardCompatible, "reference to: %s added", newRef)
+	default:
+		a.add(pointer, Breaking, "reference target changed from: %s to: %s", oldRef, newRef)
+	}
+}
+
+func (a *analyzer) compareBounds(pointer string, oldNode, newNode map[string]interface{}) {
+	for _, key := range upperBounds {
+		a.compareBound(pointer, key, oldNode[key], newNode[key], true)
+	}
+
+	for _, key := range lowerBounds {
+		a.compareBound(pointer, key, oldNode[key], newNode[key], false)
+	}
+
+	oldUnique, _ := oldNode[uniqueItemsKey].(bool)
+	newUnique, _ := newNode[uniqueItemsKey].(bool)
+	if !oldUnique && newUnique {
+		a.add(pointer, ForwardCompatible, "%s added", uniqueItemsKey)
+	} else if oldUnique && !newUnique {
+		a.add(pointer, BackwardCompatible, "%s removed", uniqueItemsKey)
+	}
+}
+
+func (a *analyzer) compareBound(pointer, key string, oldValue, newValue interface{}, isUpper bool) {
+	oldBound, oldExists := toFloat(oldValue)
+	newBound, newExists := toFloat(newValue)
+
+	switch {
+	case !oldExists && !newExists, oldExists && newExists && oldBound == newBound:
+		return
+	case !newExists:
+		a.add(pointer, BackwardCompatible, "%s removed", key)
+	case !oldExists:
+		a.add(pointer, ForwardCompatible, "%s added: %v", key, newBound)
+	case (newBound < oldBound) == isUpper:
+		a.add(pointer, ForwardCompatible, "%s tightened from: %v to: %v", key, oldBound, newBound)
+	default:
+		a.add(pointer, BackwardCompatible, "%s relaxed from: %v to: %v", key, oldBound, newBound)
+	}
+}
+
+func (a *analyzer) compareRestrictions(pointer string, oldNode, newNode map[string]interface{}) {
+	for _, key := range restrictions {
+		a.compareRestriction(pointer, key, oldNode[key], newNode[key])
+	}
+}
+
+func (a *analyzer) compareRestriction(pointer, key string, oldValue, newValue interface{}) {
+	switch {
+	case reflect.DeepEqual(oldValue, newValue):
+		return
+	case newValue == nil:
+		a.add(pointer, BackwardCompatible, "%s removed", key)
+	case oldValue == nil:
+		a.add(pointer, ForwardCompatible, "%s added", key)
+	default:
+		a.add(pointer, Breaking, "%s changed", key)
+	}
+}
+
+// getRef returns upper case name of asset referenced by field or empty string, if field is not a reference
+func getRef(node map[string]interface{}) string {
+	description, _ := node[descriptionKey].(string)
+	if !strings.HasPrefix(description, konst.RefDescriptionPrefix) {
+		return ""
+	}
+
+	name := strings.TrimPrefix(description, konst.RefDescriptionPrefix)
+	if end := strings.Index(name, " "); end != -1 {
+		name = name[:end]
+	}
+
+	return strings.ToUpper(name)
+}
+
+// isClosed returns true, if schema node does not allow additional properties
+func isClosed(node map[string]interface{}) bool {
+	ap, isBool := node[additionalPropertiesKey].(bool)
+	return isBool && !ap
+}
+
+// typesCover returns true, if every type in subset is allowed by superset. Integer is covered by number
+func typesCover(superset, subset map[string]bool) bool {
+	for typ := range subset {
+		if !superset[typ] && !(typ == "integer" && superset["number"]) {
+			return false
+		}
+	}
+	return true
+}
+
+// stringSet converts string or list of strings to set
+func stringSet(value interface{}) map[string]bool {
+	set := map[string]bool{}
+
+	switch v := value.(type) {
+	case string:
+		set[v] = true
+	case []interface{}:
+		for _, elem := range v {
+			if s, ok := elem.(string); ok {
+				set[s] = true
+			}
+		}
+	case []string:
+		for _, s := range v {
+			set[s] = true
+		}
+	}
+
+	return set
+}
+
+func containsValue(values []interface{}, value interface{}) bool {
+	for _, v := range values {
+		if reflect.DeepEqual(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
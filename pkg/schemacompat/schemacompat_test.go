@@ -0,0 +1,213 @@
+package schemacompat
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/KompiTech/rmap"
+	"github.com/stretchr/testify/assert"
+)
+
+const baseItem = `
+destination: state
+schema:
+  type: object
+  properties:
+    description:
+      type: string
+      maxLength: 100
+    state:
+      type: string
+      enum: [new, closed]
+    count:
+      type: integer
+    assigned_to:
+      description: REF->MOCKUSER User assigned to this
+      type: string
+    tags:
+      type: array
+      items:
+        type: string
+  required:
+    - description
+  additionalProperties: false
+`
+
+func analyze(t *testing.T, modify func(item rmap.Rmap)) Report {
+	oldItem := rmap.MustNewFromYAMLBytes([]byte(baseItem))
+	newItem := oldItem.Copy()
+	modify(newItem)
+
+	report, err := Analyze(oldItem, newItem)
+	assert.Nil(t, err)
+	return report
+}
+
+func TestIdentical(t *testing.T) {
+	report := analyze(t, func(item rmap.Rmap) {
+		item.MustSetJPtr("/schema/properties/description/description", "only documentation changed")
+	})
+
+	assert.Equal(t, FullyCompatible, report.Compatibility)
+	assert.Empty(t, report.Changes)
+}
+
+func TestBackwardCompatible(t *testing.T) {
+	report := analyze(t, func(item rmap.Rmap) {
+		item.MustSetJPtr("/schema/properties/priority", map[string]interface{}{"type": "string"})
+		item.MustSetJPtr("/schema/properties/state/enum", []interface{}{"new", "closed", "resolved"})
+		item.MustSetJPtr("/schema/properties/count/type", "number")
+		item.MustSetJPtr("/schema/properties/description/maxLength", 200)
+		item.MustSetJPtr("/schema/required", []interface{}{})
+	})
+
+	assert.Equal(t, BackwardCompatible, report.Compatibility)
+	assert.Equal(t, []Change{
+		{Pointer: "/count", Description: "type widened from: integer to: number", Compatibility: BackwardCompatible},
+		{Pointer: "/description", Description: "maxLength relaxed from: 100 to: 200", Compatibility: BackwardCompatible},
+		{Pointer: "/description", Description: "property is no longer required", Compatibility: BackwardCompatible},
+		{Pointer: "/priority", Description: "property added", Compatibility: BackwardCompatible},
+		{Pointer: "/state", Description: "enum value(s) added: resolved", Compatibility: BackwardCompatible},
+	}, report.Changes)
+}
+
+func TestForwardCompatible(t *testing.T) {
+	report := analyze(t, func(item rmap.Rmap) {
+		item.MustSetJPtr("/schema/properties/state/enum", []interface{}{"new"})
+		item.MustSetJPtr("/schema/properties/count/minimum", 0)
+		item.MustSetJPtr("/schema/properties/description/maxLength", 50)
+		item.MustSetJPtr("/schema/properties/tags/items/pattern", "^[a-z]+$")
+		item.MustSetJPtr("/schema/required", []interface{}{"description", "state"})
+	})
+
+	assert.Equal(t, ForwardCompatible, report.Compatibility)
+	assert.False(t, report.IsBackward())
+	assert.Equal(t, []Change{
+		{Pointer: "/count", Description: "minimum added: 0", Compatibility: ForwardCompatible},
+		{Pointer: "/description", Description: "maxLength tightened from: 100 to: 50", Compatibility: ForwardCompatible},
+		{Pointer: "/state", Description: "enum value(s) removed: closed", Compatibility: ForwardCompatible},
+		{Pointer: "/state", Description: "property became required without default", Compatibility: ForwardCompatible},
+		{Pointer: "/tags/items", Description: "pattern added", Compatibility: ForwardCompatible},
+	}, report.Changes)
+}
+
+func TestMixedIsBreaking(t *testing.T) {
+	report := analyze(t, func(item rmap.Rmap) {
+		item.MustSetJPtr("/schema/properties/state/enum", []interface{}{"new", "resolved"})
+	})
+
+	// every change alone is compatible in one direction, together they are compatible in none
+	assert.Equal(t, Breaking, report.Compatibility)
+	assert.Equal(t, []Change{
+		{Pointer: "/state", Description: "enum value(s) removed: closed", Compatibility: ForwardCompatible},
+		{Pointer: "/state", Description: "enum value(s) added: resolved", Compatibility: BackwardCompatible},
+	}, report.Changes)
+	assert.Len(t, report.IncompatibleChanges(), 1)
+}
+
+func TestOpenObject(t *testing.T) {
+	// nested object without additionalProperties allows any other property
+	base := rmap.MustNewFromYAMLBytes([]byte(baseItem))
+	base.MustSetJPtr("/schema/properties/details", map[string]interface{}{
+		"type":       "object",
+		"properties": map[string]interface{}{"note": map[string]interface{}{"type": "string"}},
+	})
+
+	newItem := base.Copy()
+	newItem.MustSetJPtr("/schema/properties/details/properties/owner", map[string]interface{}{"type": "string"})
+	newItem.MustDeleteJPtr("/schema/properties/details/properties/note")
+
+	report, err := Analyze(base, newItem)
+	assert.Nil(t, err)
+	assert.Equal(t, Breaking, report.Compatibility)
+	assert.Equal(t, []Change{
+		{Pointer: "/details/note", Description: "property removed", Compatibility: BackwardCompatible},
+		{Pointer: "/details/owner", Description: "property added", Compatibility: ForwardCompatible},
+	}, report.Changes)
+}
+
+func TestBreaking(t *testing.T) {
+	report := analyze(t, func(item rmap.Rmap) {
+		item.MustSetJPtr("/schema/properties/priority", map[string]interface{}{"type": "string"})
+		item.MustSetJPtr("/schema/required", []interface{}{"description", "priority"})
+		item.MustSetJPtr("/schema/properties/state/enum", []interface{}{"new"})
+		item.MustSetJPtr("/schema/properties/count/type", "string")
+		item.MustSetJPtr("/schema/properties/assigned_to/description", "REF->MOCKGROUP Group assigned to this")
+		item.MustSetJPtr("/schema/properties/tags/items/pattern", "^[a-z]+$")
+		item.MustDeleteJPtr("/schema/properties/description")
+	})
+
+	assert.Equal(t, Breaking, report.Compatibility)
+	assert.Equal(t, []Change{
+		{Pointer: "/assigned_to", Description: "reference target changed from: MOCKUSER to: MOCKGROUP", Compatibility: Breaking},
+		{Pointer: "/count", Description: "type changed from: integer to: string", Compatibility: Breaking},
+		{Pointer: "/description", Description: "required property removed, existing instances containing it are not valid", Compatibility: Breaking},
+		{Pointer: "/priority", Description: "required property added without default", Compatibility: Breaking},
+		{Pointer: "/state", Description: "enum value(s) removed: closed", Compatibility: ForwardCompatible},
+		{Pointer: "/tags/items", Description: "pattern added", Compatibility: ForwardCompatible},
+	}, report.Changes)
+	assert.Len(t, report.IncompatibleChanges(), 6)
+}
+
+func TestNarrowedType(t *testing.T) {
+	report := analyze(t, func(item rmap.Rmap) {
+		item.MustSetJPtr("/schema/properties/count/type", []interface{}{"integer", "null"})
+	})
+	assert.Equal(t, "type widened from: integer to: integer,null", report.Changes[0].Description)
+
+	oldItem := rmap.MustNewFromYAMLBytes([]byte(baseItem))
+	oldItem.MustSetJPtr("/schema/properties/count/type", "number")
+	newItem := rmap.MustNewFromYAMLBytes([]byte(baseItem))
+
+	report, err := Analyze(oldItem, newItem)
+	assert.Nil(t, err)
+	assert.Equal(t, []Change{
+		{Pointer: "/count", Description: "type narrowed from: number to: integer", Compatibility: ForwardCompatible},
+	}, report.Changes)
+}
+
+func TestMigration(t *testing.T) {
+	report := analyze(t, func(item rmap.Rmap) {
+		item.MustDeleteJPtr("/schema/properties/description")
+		item.MustSetJPtr("/schema/properties/short_description", map[string]interface{}{"type": "string"})
+		item.MustSetJPtr("/schema/properties/priority", map[string]interface{}{"type": "string"})
+		item.MustSetJPtr("/schema/required", []interface{}{"short_description", "priority", "count"})
+		item.Mapa["migration"] = map[string]interface{}{
+			"rename":   map[string]interface{}{"/description": "/short_description"},
+			"defaults": map[string]interface{}{"/priority": "low"},
+			"patch":    []interface{}{map[string]interface{}{"op": "add", "path": "/count", "value": 0}},
+		}
+	})
+
+	assert.Equal(t, BackwardCompatible, report.Compatibility)
+	assert.Equal(t, []Change{
+		{Pointer: "/count", Description: "property became required, it is set by migration", Compatibility: FullyCompatible},
+		{Pointer: "/description", Description: "required property removed", Compatibility: BackwardCompatible},
+		{Pointer: "/priority", Description: "required property added, it is set by migration", Compatibility: BackwardCompatible},
+		{Pointer: "/short_description", Description: "required property added, it is set by migration", Compatibility: BackwardCompatible},
+	}, report.Changes)
+}
+
+func TestAnalyzeDirs(t *testing.T) {
+	oldDir, err := ioutil.TempDir("", "schemacompat-old")
+	assert.Nil(t, err)
+	defer os.RemoveAll(oldDir)
+
+	newDir, err := ioutil.TempDir("", "schemacompat-new")
+	assert.Nil(t, err)
+	defer os.RemoveAll(newDir)
+
+	newItem := rmap.MustNewFromYAMLBytes([]byte(baseItem))
+	newItem.MustSetJPtr("/schema/properties/count/type", "string")
+
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(oldDir, "incident.yaml"), []byte(baseItem), 0644))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(newDir, "incident.yaml"), newItem.Bytes(), 0644))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(newDir, "request.yaml"), []byte(baseItem), 0644))
+
+	reports, err := AnalyzeDirs(oldDir, newDir)
+	assert.Nil(t, err)
+	assert.Len(t, reports, 1)
+	assert.Equal(t, Breaking, reports["incident"].Compatibility)
+}
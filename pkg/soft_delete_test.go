@@ -0,0 +1,86 @@
+package cc_core
+
+import (
+	"fmt"
+
+	"github.com/KompiTech/fabric-cc-core/v2/pkg/konst"
+	. "github.com/KompiTech/fabric-cc-core/v2/pkg/testing"
+	"github.com/KompiTech/rmap"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("soft delete tests", func() {
+	var tctx *TestContext
+	name := "mocksoftdelete"
+
+	BeforeEach(func() {
+		tctx = getDefaultTextContext()
+		tctx.InitOk(tctx.GetInit("../internal/testdata/assets", "").Bytes())
+		tctx.RegisterAllActors()
+	})
+
+	It("Should keep deleted asset with tombstone and hide it", func() {
+		id := MustGetID(tctx.Rmap("assetCreate", name, `{"text":"hello"}`, -1, ""))
+		tctx.Ok("assetDelete", name, id)
+
+		Expect(tctx.ErrorJSON("asset is deleted: MOCKSOFTDELETE"+id, "assetGet", name, id, false, "").Mapa).To(HaveKeyWithValue(konst.ErrorStatusKey, float64(404)))
+		tctx.Error("asset is deleted", "assetUpdate", name, id, `{"text":"updated"}`)
+		tctx.Error("asset is deleted", "assetDelete", name, id)
+
+		Expect(tctx.JSONNoResult("assetQuery", name, rmap.NewEmpty().Bytes(), false)[konst.OutputResultKey]).To(BeEmpty())
+
+		results := tctx.JSONNoResult("assetQuery", name, `{"include_deleted":true}`, false)[konst.OutputResultKey].([]interface{})
+		Expect(results).To(HaveLen(1))
+		Expect(results[0]).To(HaveKeyWithValue(konst.AssetDeletedKey, true))
+		Expect(results[0]).To(HaveKeyWithValue(konst.AssetDeletedByKey, tctx.GetCurrentActorFingerprint()))
+		Expect(results[0]).To(HaveKey(konst.AssetDeletedAtKey))
+		Expect(results[0]).To(HaveKeyWithValue("text", "hello"))
+	})
+
+	It("Should keep references to deleted asset valid, but not resolve them", func() {
+		parentID := MustGetID(tctx.Rmap("assetCreate", name, `{"text":"parent"}`, -1, ""))
+		childID := MustGetID(tctx.Rmap("assetCreate", name, fmt.Sprintf(`{"text":"child","parent":"%s"}`, parentID), -1, ""))
+		tctx.Ok("assetDelete", name, parentID)
+
+		child := tctx.Rmap("assetGet", name, childID, true, "")
+		Expect(child.Mapa).To(HaveKeyWithValue("parent", parentID))
+
+		tctx.Ok("assetUpdate", name, childID, `{"text":"still valid"}`)
+	})
+
+	It("Should restore deleted asset", func() {
+		id := MustGetID(tctx.Rmap("assetCreate", name, `{"text":"hello"}`, -1, ""))
+		tctx.Error("asset is not deleted", "assetRestore", name, id)
+
+		tctx.Ok("assetDelete", name, id)
+
+		tctx.SetActor("ordinaryUser")
+		tctx.Error("permission denied", "assetRestore", name, id)
+
+		tctx.SetActor("superUser")
+		restored := tctx.Rmap("assetRestore", name, id)
+		Expect(restored.Mapa).NotTo(HaveKey(konst.AssetDeletedKey))
+		Expect(restored.Mapa).NotTo(HaveKey(konst.AssetDeletedByKey))
+		Expect(restored.Mapa).NotTo(HaveKey(konst.AssetDeletedAtKey))
+		Expect(tctx.GetLastEventPayloads("mockevent_text")).To(ConsistOf(
+			rmap.NewFromMap(map[string]interface{}{"text": "hello"}),
+		))
+
+		asset := tctx.Rmap("assetGet", name, id, false, "")
+		Expect(asset.Mapa).To(HaveKeyWithValue("text", "hello"))
+	})
+
+	It("Should purge deleted asset with assetDeleteDirect", func() {
+		id := MustGetID(tctx.Rmap("assetCreate", name, `{"text":"hello"}`, -1, ""))
+		tctx.Ok("assetDelete", name, id)
+		tctx.Ok("assetDeleteDirect", name, id)
+
+		tctx.Error("state entry not found: MOCKSOFTDELETE"+id, "assetRestore", name, id)
+		tctx.Error("state entry not found: MOCKSOFTDELETE"+id, "assetGet", name, id, false, "")
+	})
+
+	It("Should return error if attempting to set deleted mark", func() {
+		tctx.Error("patch contains service key(s)", "assetCreate", name, `{"xxx_deleted":true}`, -1, "")
+	})
+})
@@ -160,6 +160,55 @@ func (tctx *TestContext) GetLastEventPayload() map[string]interface{} {
 	return tctx.cc.GetLastEventPayload()
 }
 
+// getLastEventEnvelopeList returns list stored under key of event envelope emitted in last transaction
+func (tctx *TestContext) getLastEventEnvelopeList(key string) []Rmap {
+	event := tctx.cc.ChaincodeEvent
+	if event == nil || event.EventName != EventEnvelopeName {
+		return []Rmap{}
+	}
+
+	items, err := NewFromMap(tctx.GetLastEventPayload()).GetIterable(key)
+	Expect(err).To(BeNil())
+
+	output := make([]Rmap, 0, len(items))
+	for _, item := range items {
+		itemR, err := NewFromInterface(item)
+		Expect(err).To(BeNil())
+		output = append(output, itemR)
+	}
+
+	return output
+}
+
+// GetLastAssetChanges returns asset changes from event envelope emitted in last transaction
+func (tctx *TestContext) GetLastAssetChanges() []Rmap {
+	return tctx.getLastEventEnvelopeList(EventChangesKey)
+}
+
+// GetLastEvents returns custom events queued by ctx.EmitEvent() in last transaction. Every event has "name" and "payload" keys
+func (tctx *TestContext) GetLastEvents() []Rmap {
+	return tctx.getLastEventEnvelopeList(EventEventsKey)
+}
+
+// GetLastEventPayloads returns payloads of custom events with some name queued by ctx.EmitEvent() in last transaction
+func (tctx *TestContext) GetLastEventPayloads(name string) []Rmap {
+	output := []Rmap{}
+	for _, event := range tctx.GetLastEvents() {
+		eventName, err := event.GetString(EventNameKey)
+		Expect(err).To(BeNil())
+
+		if eventName != name {
+			continue
+		}
+
+		payload, err := event.GetRmap(EventPayloadKey)
+		Expect(err).To(BeNil())
+		output = append(output, payload)
+	}
+
+	return output
+}
+
 // GetCC returns MockStub ref
 func (tctx *TestContext) GetCC() *testing.MockStub {
 	return tctx.cc
@@ -220,6 +269,14 @@ func (tctx *TestContext) Error(errorSubstr string, funcName string, iargs ...int
 	ResponseErrorSubstr(tctx.cc.From(tctx.GetCurrentActor()).Invoke(funcName, iargs...), errorSubstr)
 }
 
+// ErrorJSON invokes mock CC method with Args, expects error with errorSubstr to be present and returns JSON error as Rmap
+func (tctx *TestContext) ErrorJSON(errorSubstr string, funcName string, iargs ...interface{}) Rmap {
+	response := ResponseErrorSubstr(tctx.cc.From(tctx.GetCurrentActor()).Invoke(funcName, iargs...), errorSubstr)
+	output, err := NewFromString(response.Message)
+	Expect(err).To(BeNil())
+	return output
+}
+
 // Rmap invokes mock CC method with Args and returns "result" key of response as Rmap
 func (tctx *TestContext) Rmap(funcName string, iargs ...interface{}) Rmap {
 	rm := tctx.invoke(funcName, iargs...)